@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,12 +13,15 @@ import (
 	"time"
 
 	"github.com/weibh/openClusterClaw/config"
+	"github.com/weibh/openClusterClaw/internal/adapter"
 	"github.com/weibh/openClusterClaw/internal/api"
 	"github.com/weibh/openClusterClaw/internal/model"
 	"github.com/weibh/openClusterClaw/internal/pkg/jwt"
+	"github.com/weibh/openClusterClaw/internal/pkg/secretbox"
 	"github.com/weibh/openClusterClaw/internal/repository"
 	"github.com/weibh/openClusterClaw/internal/runtime/k8s"
 	"github.com/weibh/openClusterClaw/internal/service"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -57,8 +61,22 @@ func main() {
 	instanceRepo := repository.NewInstanceRepository(db)
 	userRepo := repository.NewUserRepository(db)
 	configTemplateRepo := repository.NewConfigTemplateRepository(db)
+	templateRevisionRepo := repository.NewConfigTemplateRevisionRepository(db)
+	instanceConfigRevisionRepo := repository.NewInstanceConfigRevisionRepository(db)
+	rolloutRepo := repository.NewConfigRolloutRepository(db)
 	tenantRepo := repository.NewTenantRepository(db)
 	projectRepo := repository.NewProjectRepository(db)
+	variableRepo := repository.NewSharedVariableRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+	roleBindingRepo := repository.NewRoleBindingRepository(db)
+	deletionRepo := repository.NewDeletionRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+
+	// Secret variables are encrypted at rest
+	secrets, err := newSecretBox(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize secret encryption: %v", err)
+	}
 
 	// Initialize K8S client
 	var podManager *k8s.PodManager
@@ -86,24 +104,57 @@ func main() {
 	}
 
 	// Initialize services
-	instanceService := service.NewInstanceService(instanceRepo, podManager, configMapManager)
-	configTemplateService := service.NewConfigTemplateService(configTemplateRepo)
-	tenantService := service.NewTenantService(tenantRepo, instanceRepo)
-	projectService := service.NewProjectService(projectRepo)
+	instanceService := service.NewInstanceService(instanceRepo, tenantRepo, projectRepo, configTemplateRepo, templateRevisionRepo, instanceConfigRevisionRepo, variableRepo, secrets, podManager, configMapManager)
+	configTemplateService := service.NewConfigTemplateService(configTemplateRepo, templateRevisionRepo, instanceRepo)
+	deletionService := service.NewDeletionService(deletionRepo, tenantRepo, projectRepo, instanceRepo, userRepo, variableRepo, roleBindingRepo, configTemplateRepo, instanceService, time.Duration(cfg.Deletion.GracePeriod)*time.Second)
+	tenantService := service.NewTenantService(tenantRepo, projectRepo, instanceRepo, instanceService, deletionService)
+	projectService := service.NewProjectService(projectRepo, tenantRepo, instanceRepo, deletionService)
+	adapterService := service.NewAdapterService(adapter.DefaultFactory)
+	rolloutService := service.NewRolloutService(rolloutRepo, instanceRepo, configTemplateRepo, instanceService)
+	variableService := service.NewVariableService(variableRepo, tenantRepo, projectRepo, instanceRepo, instanceService, secrets)
+	manifestService := service.NewManifestService(tenantRepo, projectRepo, configTemplateRepo, instanceRepo, tenantService, projectService, configTemplateService, instanceService)
+	rbacService := service.NewRBACService(roleRepo, roleBindingRepo, tenantRepo, projectRepo, userRepo)
+
+	// Continue rollouts interrupted by a previous shutdown
+	if err := rolloutService.ResumeRollouts(context.Background()); err != nil {
+		log.Printf("Warning: Failed to resume config rollouts: %v", err)
+	}
+
+	// Continue deletions interrupted by a previous shutdown and purge those past their grace period
+	if err := deletionService.ResumeDeletions(context.Background()); err != nil {
+		log.Printf("Warning: Failed to resume deletions: %v", err)
+	}
+	if cfg.Deletion.PurgeInterval > 0 {
+		go service.RunPurges(context.Background(), deletionService, time.Duration(cfg.Deletion.PurgeInterval)*time.Second)
+	}
+
+	// Periodically compare applied configs with the cluster
+	if configMapManager != nil && cfg.Drift.Interval > 0 {
+		go service.RunDriftChecks(context.Background(), instanceService, time.Duration(cfg.Drift.Interval)*time.Second, cfg.Drift.AutoCorrect)
+	}
 
 	// Initialize JWT service
 	jwtService := jwt.NewJWTService(cfg)
 
 	// Initialize auth service
-	authService := service.NewAuthService(userRepo, jwtService)
+	passwordPolicy := service.PasswordPolicy{
+		MinLength:        cfg.Password.MinLength,
+		RequireUppercase: cfg.Password.RequireUppercase,
+		RequireLowercase: cfg.Password.RequireLowercase,
+		RequireDigit:     cfg.Password.RequireDigit,
+		RequireSymbol:    cfg.Password.RequireSymbol,
+		History:          cfg.Password.History,
+	}
+	authService := service.NewAuthService(userRepo, tenantRepo, roleBindingRepo, sessionRepo, jwtService, passwordPolicy)
 
 	// Initialize default tenant and admin user if they don't exist
-	if err := initializeDefaultData(db, authService, userRepo); err != nil {
-		log.Printf("Warning: Failed to initialize default data: %v", err)
+	// Without the bootstrap admin nobody could log in, so a failure here stops startup
+	if err := initializeDefaultData(db, authService, userRepo, passwordPolicy); err != nil {
+		log.Fatalf("Failed to initialize default data: %v", err)
 	}
 
 	// Initialize router
-	router := api.NewRouter(instanceService, configTemplateService, tenantService, projectService, authService, jwtService, userRepo, cfg)
+	router := api.NewRouter(instanceService, configTemplateService, tenantService, projectService, adapterService, rolloutService, variableService, manifestService, rbacService, deletionService, authService, jwtService, userRepo, cfg)
 	router.SetupRoutes()
 	engine := router.Engine()
 
@@ -141,6 +192,20 @@ func main() {
 	log.Println("Server exited")
 }
 
+// newSecretBox creates the cipher for secret values from the configured hex key
+func newSecretBox(cfg *config.Config) (*secretbox.Box, error) {
+	key := cfg.Secrets.EncryptionKey
+	if key == "" {
+		log.Println("Warning: secrets.encryption_key is not set, falling back to otp.encryption_key")
+		key = cfg.OTP.EncryptionKey
+	}
+	keyBytes, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encryption key: %w", err)
+	}
+	return secretbox.New(keyBytes)
+}
+
 // initDB initializes the database connection and creates tables
 func initDB(cfg *config.Config) (*gorm.DB, error) {
 	// Ensure data directory exists
@@ -187,22 +252,63 @@ func runMigrations(db *gorm.DB) error {
 		&model.Tenant{},
 		&model.Project{},
 		&model.ConfigTemplate{},
+		&model.ConfigTemplateRevision{},
+		&model.InstanceConfigRevision{},
+		&model.ConfigRollout{},
+		&model.ConfigRolloutTarget{},
+		&model.SharedVariable{},
 		&model.ClawInstance{},
 		&model.User{},
+		&model.PasswordHistory{},
+		&model.Session{},
+		&model.Role{},
+		&model.RoleBinding{},
+		&model.DeletionOperation{},
 	)
 }
 
-// initializeDefaultData creates default tenant and admin user if they don't exist
-func initializeDefaultData(db *gorm.DB, authService *service.AuthService, userRepo *repository.UserRepository) error {
+// Environment variables that set the credentials of the bootstrap admin user
+const (
+	envAdminUsername = "OPENCLUSTERCLAW_ADMIN_USERNAME"
+	envAdminPassword = "OPENCLUSTERCLAW_ADMIN_PASSWORD"
+)
+
+// legacyAdminPassword is the fixed password earlier versions seeded the admin user with
+const legacyAdminPassword = "admin123"
+
+// initializeDefaultData creates default tenant and admin user if they don't exist. The admin
+// password comes from the environment or is generated and printed once; either way it has to
+// be changed at first login.
+func initializeDefaultData(db *gorm.DB, authService *service.AuthService, userRepo *repository.UserRepository, policy service.PasswordPolicy) error {
 	ctx := context.Background()
 
+	username := os.Getenv(envAdminUsername)
+	if username == "" {
+		username = "admin"
+	}
+
 	// Check if default admin user exists
-	_, err := userRepo.GetByUsername(ctx, "admin")
-	if err == nil {
-		// Admin user already exists
+	if admin, err := userRepo.GetByUsername(ctx, username); err == nil {
+		// An admin still on the old fixed password must choose its own
+		if !admin.MustChangePassword && bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(legacyAdminPassword)) == nil {
+			admin.MustChangePassword = true
+			if err := userRepo.Update(ctx, admin); err != nil {
+				return fmt.Errorf("failed to flag default admin password: %w", err)
+			}
+			log.Printf("Warning: admin user %q still uses the default password and must change it at next login", username)
+		}
 		return nil
 	}
 
+	// Reject a misconfigured password before anything is created
+	password := os.Getenv(envAdminPassword)
+	generated := password == ""
+	if !generated {
+		if err := policy.Validate(password); err != nil {
+			return fmt.Errorf("%s does not meet the password policy: %w", envAdminPassword, err)
+		}
+	}
+
 	// Create default tenant
 	tenantID := "default-tenant"
 	tenant := &model.Tenant{
@@ -213,25 +319,36 @@ func initializeDefaultData(db *gorm.DB, authService *service.AuthService, userRe
 		MaxMemory:    "200Gi",
 		MaxStorage:   "1Ti",
 	}
-	if err := db.Create(tenant).Error; err != nil {
+	if err := db.Where("id = ?", tenantID).FirstOrCreate(tenant).Error; err != nil {
 		return fmt.Errorf("failed to create default tenant: %w", err)
 	}
 
+	if generated {
+		var err error
+		if password, err = policy.Generate(); err != nil {
+			return err
+		}
+	}
+
 	// Create default admin user
 	defaultAdmin := &service.CreateUserRequest{
-		Username: "admin",
-		Password: "admin123", // Default password, should be changed in production
-		TenantID: tenantID,
-		Role:     "admin",
+		Username:           username,
+		Password:           password,
+		TenantID:           tenantID,
+		Role:               model.RoleAdmin,
+		MustChangePassword: true,
 	}
 
-	_, err = authService.CreateUser(ctx, defaultAdmin)
-	if err != nil {
+	if _, err := authService.CreateUser(ctx, defaultAdmin); err != nil {
 		return fmt.Errorf("failed to create default admin user: %w", err)
 	}
 
-	log.Println("Default admin user created: username=admin, password=admin123")
-	log.Println("Please change the default password after first login!")
+	if generated {
+		log.Printf("Default admin user created: username=%s, password=%s", username, password)
+		log.Println("This password is shown only once; it must be changed at first login")
+	} else {
+		log.Printf("Default admin user created: username=%s, password from %s; it must be changed at first login", username, envAdminPassword)
+	}
 
 	return nil
 }
\ No newline at end of file
@@ -12,6 +12,10 @@ type Config struct {
 	Log      LogConfig      `mapstructure:"log"`
 	JWT      JWTConfig      `mapstructure:"jwt"`
 	OTP      OTPConfig      `mapstructure:"otp"`
+	Drift    DriftConfig    `mapstructure:"drift"`
+	Secrets  SecretsConfig  `mapstructure:"secrets"`
+	Deletion DeletionConfig `mapstructure:"deletion"`
+	Password PasswordConfig `mapstructure:"password"`
 }
 
 type ServerConfig struct {
@@ -40,6 +44,8 @@ type LogConfig struct {
 type JWTConfig struct {
 	Secret     string `mapstructure:"secret"`
 	ExpireTime int    `mapstructure:"expire_time"`
+	// RefreshExpireTime is the lifetime of a login session in seconds; each refresh extends it
+	RefreshExpireTime int `mapstructure:"refresh_expire_time"`
 }
 
 type OTPConfig struct {
@@ -47,6 +53,38 @@ type OTPConfig struct {
 	Issuer        string `mapstructure:"issuer"`
 }
 
+// DriftConfig controls the periodic config drift check
+type DriftConfig struct {
+	// Interval between checks in seconds; 0 disables the periodic check
+	Interval    int  `mapstructure:"interval"`
+	AutoCorrect bool `mapstructure:"auto_correct"`
+}
+
+// SecretsConfig holds the key used to encrypt secret values stored in the database
+type SecretsConfig struct {
+	// EncryptionKey is a 32-byte key in hex
+	EncryptionKey string `mapstructure:"encryption_key"`
+}
+
+// DeletionConfig controls how long cascading deletions of tenants and projects stay restorable
+type DeletionConfig struct {
+	// GracePeriod in seconds before deleted records are purged; 0 purges them right away
+	GracePeriod int `mapstructure:"grace_period"`
+	// PurgeInterval between checks for expired deletions in seconds; 0 disables purging
+	PurgeInterval int `mapstructure:"purge_interval"`
+}
+
+// PasswordConfig is the policy that new and changed user passwords must satisfy
+type PasswordConfig struct {
+	MinLength        int  `mapstructure:"min_length"`
+	RequireUppercase bool `mapstructure:"require_uppercase"`
+	RequireLowercase bool `mapstructure:"require_lowercase"`
+	RequireDigit     bool `mapstructure:"require_digit"`
+	RequireSymbol    bool `mapstructure:"require_symbol"`
+	// History is how many recent passwords, the current one included, cannot be reused; 0 allows reuse
+	History int `mapstructure:"history"`
+}
+
 var cfg *Config
 
 // Load loads configuration from file
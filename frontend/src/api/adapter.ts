@@ -0,0 +1,57 @@
+import client from './client';
+import type { ApiResponse } from '@/types';
+
+export interface AdapterInfo {
+  type: string;
+  image: string;
+  supported_versions: string[];
+  default_version: string;
+  default_config: Record<string, any>;
+  reload: ReloadPolicy;
+}
+
+export interface ReloadPolicy {
+  method: 'none' | 'signal' | 'http' | 'file_watch';
+  signal?: string;
+  path?: string;
+  restart_fields?: string[];
+}
+
+export interface JSONSchema {
+  $schema?: string;
+  title?: string;
+  description?: string;
+  type?: string;
+  format?: string;
+  properties?: Record<string, JSONSchema>;
+  items?: JSONSchema;
+  additionalProperties?: JSONSchema;
+  required?: string[];
+  enum?: any[];
+  minimum?: number;
+  maximum?: number;
+  default?: any;
+  writeOnly?: boolean;
+}
+
+export interface AdapterListResponse {
+  adapters: AdapterInfo[];
+  total: number;
+}
+
+export const adapterApi = {
+  async listAdapters(): Promise<AdapterInfo[]> {
+    const { data } = await client.get<ApiResponse<AdapterListResponse>>('/adapters');
+    return data.data?.adapters || [];
+  },
+
+  async getAdapter(type: string): Promise<AdapterInfo> {
+    const { data } = await client.get<ApiResponse<AdapterInfo>>(`/adapters/${type}`);
+    return data.data!;
+  },
+
+  async getSchema(type: string): Promise<JSONSchema> {
+    const { data } = await client.get<ApiResponse<JSONSchema>>(`/adapters/${type}/schema`);
+    return data.data!;
+  },
+};
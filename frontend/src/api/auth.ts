@@ -32,6 +32,32 @@ apiClient.interceptors.request.use(
   }
 );
 
+// A refresh token can be used only once, and using it twice revokes the session; concurrent
+// 401 responses therefore share a single refresh
+let pendingRefresh: Promise<string> | null = null;
+
+const refreshAccessToken = (): Promise<string> => {
+  if (!pendingRefresh) {
+    pendingRefresh = (async () => {
+      const refreshToken = localStorage.getItem('refresh_token');
+      if (!refreshToken) {
+        throw new Error('no refresh token');
+      }
+      const response = await axios.post(`${API_BASE_URL}/auth/refresh`, {
+        refresh_token: refreshToken,
+      });
+
+      const { access_token, refresh_token } = response.data.data;
+      localStorage.setItem('access_token', access_token);
+      localStorage.setItem('refresh_token', refresh_token);
+      return access_token as string;
+    })().finally(() => {
+      pendingRefresh = null;
+    });
+  }
+  return pendingRefresh;
+};
+
 // Response interceptor to handle token refresh
 apiClient.interceptors.response.use(
   (response) => response,
@@ -43,20 +69,11 @@ apiClient.interceptors.response.use(
       originalRequest._retry = true;
 
       try {
-        const refreshToken = localStorage.getItem('refresh_token');
-        if (refreshToken) {
-          const response = await axios.post(`${API_BASE_URL}/auth/refresh`, {
-            refresh_token: refreshToken,
-          });
-
-          const { access_token, refresh_token } = response.data.data;
-          localStorage.setItem('access_token', access_token);
-          localStorage.setItem('refresh_token', refresh_token);
-
-          // Retry original request with new token
-          originalRequest.headers.Authorization = `Bearer ${access_token}`;
-          return apiClient(originalRequest);
-        }
+        const accessToken = await refreshAccessToken();
+
+        // Retry original request with new token
+        originalRequest.headers.Authorization = `Bearer ${accessToken}`;
+        return apiClient(originalRequest);
       } catch (refreshError) {
         // Refresh failed, clear tokens and redirect to login
         localStorage.removeItem('access_token');
@@ -87,6 +104,8 @@ export interface LoginResponse {
     tenant_id: string;
     role: string;
     is_active: boolean;
+    // The tokens are restricted to changing the password until it is changed
+    must_change_password?: boolean;
     created_at: string;
   };
 }
@@ -102,6 +121,8 @@ export interface LoginOTPResponse {
     tenant_id: string;
     role: string;
     is_active: boolean;
+    // The tokens are restricted to changing the password until it is changed
+    must_change_password?: boolean;
     created_at: string;
   };
   requires_otp: boolean;
@@ -113,7 +134,51 @@ export interface User {
   tenant_id: string;
   role: string;
   is_active: boolean;
+  password_changed_at?: string;
+  must_change_password?: boolean;
+  created_at: string;
+}
+
+export interface CreateUserRequest {
+  username: string;
+  password: string;
+  tenant_id?: string;
+  role?: 'admin' | 'user';
+  must_change_password?: boolean;
+}
+
+export interface UpdateUserRequest {
+  role?: 'admin' | 'user';
+  tenant_id?: string;
+}
+
+// An active login session; current marks the session of the caller
+export interface Session {
+  id: string;
+  user_id: string;
+  user_agent: string;
+  client_ip: string;
   created_at: string;
+  last_used_at: string;
+  expires_at: string;
+  current: boolean;
+}
+
+export interface UserListParams {
+  tenant_id?: string;
+  role?: string;
+  is_active?: boolean;
+  // Matches part of the username
+  q?: string;
+  page?: number;
+  page_size?: number;
+}
+
+export interface UserList {
+  users: User[];
+  total: number;
+  page: number;
+  page_size: number;
 }
 
 // Auth API
@@ -138,6 +203,75 @@ export const authApi = {
     });
     return response.data.data;
   },
+
+  listSessions: async (): Promise<Session[]> => {
+    const response = await apiClient.get<ApiResponse<Session[]>>('/auth/sessions');
+    return response.data.data;
+  },
+
+  revokeSession: async (id: string): Promise<void> => {
+    await apiClient.delete(`/auth/sessions/${id}`);
+  },
+
+  // Revokes all sessions and returns the tokens of a new, unrestricted one
+
+  changePassword: async (currentPassword: string, newPassword: string): Promise<LoginResponse> => {
+    const response = await apiClient.post<ApiResponse<LoginResponse>>('/auth/password', {
+      current_password: currentPassword,
+      new_password: newPassword,
+    });
+    return response.data.data;
+  },
+};
+
+// User management API
+export const userApi = {
+  list: async (params?: UserListParams): Promise<UserList> => {
+    const response = await apiClient.get<ApiResponse<UserList>>('/auth/users', { params });
+    return response.data.data;
+  },
+
+  get: async (id: string): Promise<User> => {
+    const response = await apiClient.get<ApiResponse<User>>(`/auth/users/${id}`);
+    return response.data.data;
+  },
+
+  create: async (req: CreateUserRequest): Promise<User> => {
+    const response = await apiClient.post<ApiResponse<User>>('/auth/users', req);
+    return response.data.data;
+  },
+
+  update: async (id: string, req: UpdateUserRequest): Promise<User> => {
+    const response = await apiClient.put<ApiResponse<User>>(`/auth/users/${id}`, req);
+    return response.data.data;
+  },
+
+  setActive: async (id: string, active: boolean): Promise<User> => {
+    const response = await apiClient.post<ApiResponse<User>>(`/auth/users/${id}/${active ? 'activate' : 'deactivate'}`);
+    return response.data.data;
+  },
+
+  delete: async (id: string): Promise<void> => {
+    await apiClient.delete(`/auth/users/${id}`);
+  },
+
+  resetPassword: async (id: string, newPassword: string): Promise<void> => {
+    await apiClient.post(`/auth/users/${id}/password`, { new_password: newPassword });
+  },
+
+  listSessions: async (id: string): Promise<Session[]> => {
+    const response = await apiClient.get<ApiResponse<Session[]>>(`/auth/users/${id}/sessions`);
+    return response.data.data;
+  },
+
+  // Signs the user out everywhere
+  revokeSessions: async (id: string): Promise<void> => {
+    await apiClient.delete(`/auth/users/${id}/sessions`);
+  },
+
+  revokeSession: async (id: string, sessionId: string): Promise<void> => {
+    await apiClient.delete(`/auth/users/${id}/sessions/${sessionId}`);
+  },
 };
 
 // Token management utilities
@@ -10,6 +10,8 @@ export interface TemplateVariable {
   secret: boolean;
 }
 
+export type TemplateKind = 'template' | 'fragment';
+
 export interface ConfigTemplate {
   id: string;
   name: string;
@@ -17,16 +19,60 @@ export interface ConfigTemplate {
   variables: TemplateVariable[];
   adapter_type: string;
   version: string;
+  kind: TemplateKind;
+  parent_id?: string;
+  // Fragment IDs, merged in order after the parent
+  fragments?: string[];
+  // Variables after merging the parent and fragments
+  resolved_variables?: TemplateVariable[];
+  revision: number;
+  content_hash: string;
   created_at: string;
   updated_at: string;
 }
 
+export interface TemplateRevision {
+  template_id: string;
+  revision: number;
+  content_hash: string;
+  name: string;
+  description: string;
+  variables: TemplateVariable[];
+  adapter_type: string;
+  version: string;
+  kind: TemplateKind;
+  parent_id?: string;
+  fragments?: string[];
+  resolved_variables?: TemplateVariable[];
+  author: string;
+  changelog: string;
+  instance_count?: number;
+  created_at: string;
+}
+
+export interface ConfigChange {
+  path: string;
+  type: 'added' | 'removed' | 'modified';
+  old?: any;
+  new?: any;
+}
+
+export interface TemplateRevisionDiff {
+  template_id: string;
+  from: number;
+  to: number;
+  from_hash: string;
+  to_hash: string;
+  changes: ConfigChange[];
+}
+
 export interface CreateTemplateRequest {
   name: string;
   description?: string;
   variables: TemplateVariable[];
   adapter_type: string;
   version?: string;
+  changelog?: string;
 }
 
 export interface UpdateTemplateRequest {
@@ -34,6 +80,7 @@ export interface UpdateTemplateRequest {
   description?: string;
   variables?: TemplateVariable[];
   version?: string;
+  changelog?: string;
 }
 
 export interface ConfigTemplateListResponse {
@@ -71,4 +118,24 @@ export const configApi = {
   async deleteTemplate(id: string): Promise<void> {
     await client.delete(`/configs/${id}`);
   },
+
+  async listRevisions(id: string, page = 1, pageSize = 10): Promise<{ items: TemplateRevision[]; total: number }> {
+    const { data } = await client.get<ApiResponse<{ items: TemplateRevision[]; total: number }>>(
+      `/configs/${id}/revisions`,
+      { params: { page, page_size: pageSize } },
+    );
+    return data.data || { items: [], total: 0 };
+  },
+
+  async getRevision(id: string, revision: number): Promise<TemplateRevision> {
+    const { data } = await client.get<ApiResponse<TemplateRevision>>(`/configs/${id}/revisions/${revision}`);
+    return data.data!;
+  },
+
+  async diffRevisions(id: string, from: number, to?: number): Promise<TemplateRevisionDiff> {
+    const { data } = await client.get<ApiResponse<TemplateRevisionDiff>>(`/configs/${id}/diff`, {
+      params: { from, to },
+    });
+    return data.data!;
+  },
 };
\ No newline at end of file
@@ -4,6 +4,10 @@ import type {
   CreateInstanceRequest,
   UpdateInstanceRequest,
   InstanceListResponse,
+  InstanceConfigRevision,
+  InstanceConfigRevisionListResponse,
+  InstanceConfigDiff,
+  ConfigDrift,
   ApiResponse,
 } from '@/types';
 
@@ -39,6 +43,32 @@ export const instanceApi = {
       params: tailLines ? { tail_lines: tailLines } : undefined,
     }),
 
+  // List applied config revisions, newest first
+  listConfigRevisions: (id: string, params?: { page?: number; page_size?: number }) =>
+    apiClient.get<ApiResponse<InstanceConfigRevisionListResponse>>(`/instances/${id}/config/revisions`, { params }),
+
+  // Get a config revision with its rendered files
+  getConfigRevision: (id: string, revision: number) =>
+    apiClient.get<ApiResponse<InstanceConfigRevision>>(`/instances/${id}/config/revisions/${revision}`),
+
+  // Diff two config revisions; `to` defaults to the current revision
+  diffConfigRevisions: (id: string, from: number, to?: number) =>
+    apiClient.get<ApiResponse<InstanceConfigDiff>>(`/instances/${id}/config/diff`, { params: { from, to } }),
+
+  // Re-apply a previous config revision
+  rollbackConfig: (id: string, revision: number, changelog?: string) =>
+    apiClient.post<ApiResponse<ClawInstance>>(`/instances/${id}/config/rollback`, { revision, changelog }),
+
+  // Compare the applied config with the live ConfigMap and pod
+  getConfigDrift: (id: string) => apiClient.get<ApiResponse<ConfigDrift>>(`/instances/${id}/config/drift`),
+
+  // Rewrite a drifted ConfigMap from the current config revision
+  correctConfigDrift: (id: string) => apiClient.post<ApiResponse<ConfigDrift>>(`/instances/${id}/config/drift/correct`),
+
+  // Check all instances of a tenant or project for drift
+  listConfigDrift: (params?: { tenant_id?: string; project_id?: string; drifted?: boolean }) =>
+    apiClient.get<ApiResponse<{ items: ConfigDrift[]; total: number }>>('/instances/drift', { params }),
+
   // Helper method to get logs string directly
   getInstanceLogs: async (id: string, tailLines = 100): Promise<string> => {
     const response = await instanceApi.getLogs(id, tailLines);
@@ -0,0 +1,46 @@
+import client from './client';
+import type { ApiResponse } from '@/types';
+import type { ConfigChange } from './config';
+
+export type ManifestKind = 'Tenant' | 'Project' | 'ConfigTemplate' | 'Instance';
+
+export interface ManifestChange {
+  kind: ManifestKind;
+  name: string;
+  tenant?: string;
+  project?: string;
+  action: 'create' | 'update' | 'delete' | 'unchanged';
+  diff?: ConfigChange[];
+  error?: string;
+}
+
+export interface ManifestApplyResult {
+  dry_run: boolean;
+  changes: ManifestChange[];
+  // Nothing is applied when a change fails to plan
+  failed: number;
+}
+
+export interface ManifestApplyOptions {
+  dryRun?: boolean;
+  prune?: boolean;
+  tenant?: string;
+}
+
+export const manifestApi = {
+  async exportManifests(kinds?: ManifestKind[], tenant?: string): Promise<string> {
+    const { data } = await client.get<string>('/manifests', {
+      params: { kind: kinds?.join(','), tenant },
+      responseType: 'text',
+    });
+    return data;
+  },
+
+  async applyManifests(yaml: string, options: ManifestApplyOptions = {}): Promise<ManifestApplyResult> {
+    const { data } = await client.post<ApiResponse<ManifestApplyResult>>('/manifests/apply', yaml, {
+      params: { dry_run: options.dryRun, prune: options.prune, tenant: options.tenant },
+      headers: { 'Content-Type': 'application/yaml' },
+    });
+    return data.data!;
+  },
+};
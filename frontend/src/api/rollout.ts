@@ -0,0 +1,95 @@
+import client from './client';
+import type { ApiResponse } from '@/types';
+
+export type RolloutStatus = 'Pending' | 'Running' | 'Succeeded' | 'Failed' | 'Aborted';
+export type RolloutTargetStatus = 'Pending' | 'Applied' | 'Healthy' | 'Failed' | 'RolledBack';
+
+export interface RolloutSelector {
+  template_name?: string;
+  tenant_id?: string;
+  project_id?: string;
+  labels?: Record<string, string>;
+  instance_ids?: string[];
+}
+
+export interface ConfigPatch {
+  template_name?: string;
+  template_revision?: number;
+  overrides?: Record<string, string>;
+}
+
+export interface RolloutStrategy {
+  canary_percent?: number;
+  batch_size?: number;
+  pause_seconds?: number;
+  health_check_seconds?: number;
+  max_failures?: number;
+}
+
+export interface RolloutTarget {
+  id: string;
+  rollout_id: string;
+  instance_id: string;
+  wave: number;
+  status: RolloutTargetStatus;
+  previous_revision: number;
+  applied_revision: number;
+  was_running: boolean;
+  restarts_before: number;
+  message: string;
+  updated_at: string;
+}
+
+export interface Rollout {
+  id: string;
+  name: string;
+  selector: RolloutSelector;
+  patch: ConfigPatch;
+  canary_percent: number;
+  batch_size: number;
+  pause_seconds: number;
+  health_check_seconds: number;
+  max_failures: number;
+  status: RolloutStatus;
+  current_wave: number;
+  total_waves: number;
+  failures: number;
+  message: string;
+  author: string;
+  started_at?: string;
+  finished_at?: string;
+  created_at: string;
+  updated_at: string;
+  targets?: RolloutTarget[];
+}
+
+export interface CreateRolloutRequest {
+  name: string;
+  selector: RolloutSelector;
+  patch: ConfigPatch;
+  strategy?: RolloutStrategy;
+}
+
+export const rolloutApi = {
+  async createRollout(requestData: CreateRolloutRequest): Promise<Rollout> {
+    const { data } = await client.post<ApiResponse<Rollout>>('/rollouts', requestData);
+    return data.data!;
+  },
+
+  async listRollouts(page = 1, pageSize = 10): Promise<{ items: Rollout[]; total: number }> {
+    const { data } = await client.get<ApiResponse<{ items: Rollout[]; total: number }>>('/rollouts', {
+      params: { page, page_size: pageSize },
+    });
+    return data.data || { items: [], total: 0 };
+  },
+
+  async getRollout(id: string): Promise<Rollout> {
+    const { data } = await client.get<ApiResponse<Rollout>>(`/rollouts/${id}`);
+    return data.data!;
+  },
+
+  async abortRollout(id: string): Promise<Rollout> {
+    const { data } = await client.post<ApiResponse<Rollout>>(`/rollouts/${id}/abort`);
+    return data.data!;
+  },
+};
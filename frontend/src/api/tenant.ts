@@ -1,6 +1,9 @@
 import client from './client';
 import type { ApiResponse } from '@/types';
 
+// Suspended and archived tenants have their instances stopped and their users locked out
+export type TenantStatus = 'Active' | 'Suspended' | 'Archived';
+
 export interface Tenant {
   id: string;
   name: string;
@@ -8,11 +11,59 @@ export interface Tenant {
   max_cpu: string;
   max_memory: string;
   max_storage: string;
+  status: TenantStatus;
+  status_reason?: string;
   created_at: string;
   updated_at: string;
 }
 
-export interface Project {
+export interface QuotaUsage {
+  used: string;
+  // Absent when the dimension is unlimited
+  limit?: string;
+}
+
+export interface ResourceUsage {
+  instances: QuotaUsage;
+  cpu: QuotaUsage;
+  memory: QuotaUsage;
+  storage: QuotaUsage;
+}
+
+export interface ProjectUsage extends ResourceUsage {
+  project_id: string;
+  name: string;
+}
+
+export interface TenantUsage extends ResourceUsage {
+  tenant_id: string;
+  projects?: ProjectUsage[];
+}
+
+// Returned in the `quota` field of a 403 response when an instance request exceeds a quota
+export interface QuotaExceeded {
+  scope: 'tenant' | 'project';
+  tenant_id: string;
+  project_id?: string;
+  resource: 'instances' | 'cpu' | 'memory' | 'storage';
+  requested: string;
+  used: string;
+  limit: string;
+}
+
+// Project limits are allocated out of the tenant quota; empty or zero limits are unlimited
+export interface ProjectQuota {
+  max_instances?: number;
+  max_cpu?: string;
+  max_memory?: string;
+  max_storage?: string;
+  // Resources given to instances created without their own
+  default_cpu?: string;
+  default_memory?: string;
+  default_storage?: string;
+}
+
+export interface Project extends ProjectQuota {
   id: string;
   tenant_id: string;
   name: string;
@@ -36,12 +87,12 @@ export interface UpdateTenantRequest {
   max_storage?: string;
 }
 
-export interface CreateProjectRequest {
+export interface CreateProjectRequest extends ProjectQuota {
   tenant_id: string;
   name: string;
 }
 
-export interface UpdateProjectRequest {
+export interface UpdateProjectRequest extends ProjectQuota {
   name?: string;
 }
 
@@ -59,6 +110,20 @@ export interface ProjectListResponse {
   page_size: number;
 }
 
+export interface ProjectMember {
+  user_id: string;
+  username: string;
+  role: string;
+  binding_id: string;
+  added_by: string;
+  created_at: string;
+}
+
+export interface SetProjectMemberRequest {
+  user_id: string;
+  role: string;
+}
+
 export const tenantApi = {
   async listTenants(): Promise<Tenant[]> {
     const { data } = await client.get<ApiResponse<TenantListResponse>>('/tenants');
@@ -80,8 +145,30 @@ export const tenantApi = {
     return data.data!;
   },
 
-  async deleteTenant(id: string): Promise<void> {
-    await client.delete(`/tenants/${id}`);
+  // With cascade the tenant and everything it owns are deleted in the background
+  async deleteTenant(id: string, cascade = false): Promise<void> {
+    await client.delete(`/tenants/${id}`, { params: cascade ? { cascade: true } : undefined });
+  },
+
+  async getTenantUsage(id: string): Promise<TenantUsage> {
+    const { data } = await client.get<ApiResponse<TenantUsage>>(`/tenants/${id}/usage`);
+    return data.data!;
+  },
+
+  async suspendTenant(id: string, reason?: string): Promise<Tenant> {
+    const { data } = await client.post<ApiResponse<Tenant>>(`/tenants/${id}/suspend`, { reason });
+    return data.data!;
+  },
+
+  // Archived tenants resume with all of their instances stopped
+  async archiveTenant(id: string, reason?: string): Promise<Tenant> {
+    const { data } = await client.post<ApiResponse<Tenant>>(`/tenants/${id}/archive`, { reason });
+    return data.data!;
+  },
+
+  async resumeTenant(id: string): Promise<Tenant> {
+    const { data } = await client.post<ApiResponse<Tenant>>(`/tenants/${id}/resume`);
+    return data.data!;
   },
 };
 
@@ -107,7 +194,26 @@ export const projectApi = {
     return data.data!;
   },
 
-  async deleteProject(id: string): Promise<void> {
-    await client.delete(`/projects/${id}`);
+  async deleteProject(id: string, cascade = false): Promise<void> {
+    await client.delete(`/projects/${id}`, { params: cascade ? { cascade: true } : undefined });
+  },
+
+  async getProjectUsage(id: string): Promise<ProjectUsage> {
+    const { data } = await client.get<ApiResponse<ProjectUsage>>(`/projects/${id}/usage`);
+    return data.data!;
+  },
+
+  async listProjectMembers(id: string): Promise<ProjectMember[]> {
+    const { data } = await client.get<ApiResponse<{ items: ProjectMember[] }>>(`/projects/${id}/members`);
+    return data.data?.items || [];
+  },
+
+  async setProjectMember(id: string, reqData: SetProjectMemberRequest): Promise<ProjectMember> {
+    const { data } = await client.post<ApiResponse<ProjectMember>>(`/projects/${id}/members`, reqData);
+    return data.data!;
+  },
+
+  async removeProjectMember(id: string, userId: string): Promise<void> {
+    await client.delete(`/projects/${id}/members/${userId}`);
   },
 };
\ No newline at end of file
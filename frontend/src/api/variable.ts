@@ -0,0 +1,69 @@
+import client from './client';
+import type { ApiResponse, ConfigApply } from '@/types';
+
+export type VariableScope = 'global' | 'tenant' | 'project' | 'instance';
+
+export interface VariableRerender {
+  instance_id: string;
+  revision?: number;
+  config_apply?: ConfigApply;
+  error?: string;
+}
+
+export interface SharedVariable {
+  id: string;
+  scope: VariableScope;
+  scope_id?: string;
+  name: string;
+  // Secret values are returned as "******"
+  value: string;
+  secret: boolean;
+  description: string;
+  updated_by: string;
+  created_at: string;
+  updated_at: string;
+  rerendered?: VariableRerender[];
+}
+
+export interface CreateVariableRequest {
+  scope: VariableScope;
+  scope_id?: string;
+  name: string;
+  value: string;
+  secret?: boolean;
+  description?: string;
+}
+
+export interface UpdateVariableRequest {
+  value?: string;
+  secret?: boolean;
+  description?: string;
+}
+
+export const variableApi = {
+  async createVariable(requestData: CreateVariableRequest): Promise<SharedVariable> {
+    const { data } = await client.post<ApiResponse<SharedVariable>>('/variables', requestData);
+    return data.data!;
+  },
+
+  async listVariables(scope?: VariableScope, scopeId?: string): Promise<{ items: SharedVariable[]; total: number }> {
+    const { data } = await client.get<ApiResponse<{ items: SharedVariable[]; total: number }>>('/variables', {
+      params: { scope, scope_id: scopeId },
+    });
+    return data.data || { items: [], total: 0 };
+  },
+
+  async getVariable(id: string): Promise<SharedVariable> {
+    const { data } = await client.get<ApiResponse<SharedVariable>>(`/variables/${id}`);
+    return data.data!;
+  },
+
+  async updateVariable(id: string, requestData: UpdateVariableRequest): Promise<SharedVariable> {
+    const { data } = await client.put<ApiResponse<SharedVariable>>(`/variables/${id}`, requestData);
+    return data.data!;
+  },
+
+  async deleteVariable(id: string): Promise<void> {
+    await client.delete(`/variables/${id}`);
+  },
+};
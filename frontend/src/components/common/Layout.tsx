@@ -2,7 +2,7 @@ import React, { useState, useEffect } from 'react';
 import { Layout, Menu, theme, Dropdown, Avatar } from 'antd';
 import { AppstoreOutlined, SettingOutlined, ClusterOutlined, FileOutlined, UserOutlined, LogoutOutlined, SafetyOutlined, TeamOutlined } from '@ant-design/icons';
 import { useNavigate, useLocation } from 'react-router-dom';
-import { tokenManager, User } from '../../api/auth';
+import { authApi, tokenManager, User } from '../../api/auth';
 
 const { Header, Content } = Layout;
 
@@ -27,8 +27,13 @@ const AppLayout: React.FC<AppLayoutProps> = ({ children }) => {
     setUser(currentUser);
   }, []);
 
-  // Handle logout
-  const handleLogout = () => {
+  // Handle logout; the session is revoked on the server, local tokens are dropped regardless
+  const handleLogout = async () => {
+    try {
+      await authApi.logout();
+    } catch {
+      // The session may already be revoked or expired
+    }
     tokenManager.clearTokens();
     navigate('/login');
   };
@@ -1,6 +1,8 @@
 import React from 'react';
 import { Modal, Form, Input, Select, InputNumber, message } from 'antd';
 import { instanceApi } from '@/api/instance';
+import { adapterApi, type AdapterInfo } from '@/api/adapter';
+import { configApi, type ConfigTemplate } from '@/api/config';
 import type { CreateInstanceRequest } from '@/types';
 
 interface CreateInstanceModalProps {
@@ -12,6 +14,33 @@ interface CreateInstanceModalProps {
 const CreateInstanceModal: React.FC<CreateInstanceModalProps> = ({ open, onClose, onSuccess }) => {
   const [form] = Form.useForm();
   const [loading, setLoading] = React.useState(false);
+  const [adapters, setAdapters] = React.useState<AdapterInfo[]>([]);
+  const selectedType = Form.useWatch('type', form);
+  const selectedAdapter = adapters.find((a) => a.type === selectedType);
+  const [templates, setTemplates] = React.useState<ConfigTemplate[]>([]);
+  const selectedTemplateName = Form.useWatch('template_name', form);
+  const selectedTemplate = templates.find((t) => t.name === selectedTemplateName);
+
+  React.useEffect(() => {
+    if (!open) {
+      return;
+    }
+    adapterApi
+      .listAdapters()
+      .then(setAdapters)
+      .catch(() => message.error('获取实例类型失败'));
+  }, [open]);
+
+  React.useEffect(() => {
+    if (!selectedType) {
+      setTemplates([]);
+      return;
+    }
+    configApi
+      .listTemplates(selectedType)
+      .then(setTemplates)
+      .catch(() => message.error('获取配置模板失败'));
+  }, [selectedType]);
 
   const handleOk = async () => {
     try {
@@ -28,6 +57,16 @@ const CreateInstanceModal: React.FC<CreateInstanceModalProps> = ({ open, onClose
         memory: values.memory ? `${values.memory}Mi` : undefined,
       };
 
+      if (values.template_name) {
+        const overrides: Record<string, string> = {};
+        Object.entries(values.variables || {}).forEach(([name, value]) => {
+          if (value !== undefined && value !== null && value !== '') {
+            overrides[name] = String(value);
+          }
+        });
+        data.config = { template_name: values.template_name, overrides };
+      }
+
       const response = await instanceApi.create(data);
 
       if (response.data.code === 0) {
@@ -38,8 +77,13 @@ const CreateInstanceModal: React.FC<CreateInstanceModalProps> = ({ open, onClose
       } else {
         message.error(response.data.message || '创建失败');
       }
-    } catch (error) {
-      message.error('创建实例失败');
+    } catch (error: any) {
+      const fieldErrors: { field: string; message: string }[] | undefined = error?.response?.data?.errors;
+      if (fieldErrors?.length) {
+        message.error(fieldErrors.map((e) => `${e.field}: ${e.message}`).join('; '));
+      } else {
+        message.error(error?.response?.data?.message || '创建实例失败');
+      }
     } finally {
       setLoading(false);
     }
@@ -89,20 +133,67 @@ const CreateInstanceModal: React.FC<CreateInstanceModalProps> = ({ open, onClose
           label="实例类型"
           rules={[{ required: true, message: '请选择实例类型' }]}
         >
-          <Select placeholder="请选择实例类型">
-            <Select.Option value="OpenClaw">OpenClaw</Select.Option>
-            <Select.Option value="NanoClaw">NanoClaw</Select.Option>
+          <Select
+            placeholder="请选择实例类型"
+            onChange={(value) => {
+              const adapter = adapters.find((a) => a.type === value);
+              form.setFieldValue('version', adapter?.default_version);
+              form.setFieldValue('template_name', undefined);
+            }}
+          >
+            {adapters.map((a) => (
+              <Select.Option key={a.type} value={a.type}>
+                {a.type}
+              </Select.Option>
+            ))}
           </Select>
         </Form.Item>
 
         <Form.Item
           name="version"
           label="版本"
-          rules={[{ required: true, message: '请输入版本' }]}
+          rules={[{ required: true, message: '请选择版本' }]}
         >
-          <Input placeholder="请输入版本号，如 1.0.0" />
+          <Select placeholder="请选择版本" disabled={!selectedAdapter}>
+            {selectedAdapter?.supported_versions.map((v) => (
+              <Select.Option key={v} value={v}>
+                {v}
+              </Select.Option>
+            ))}
+          </Select>
+        </Form.Item>
+
+        <Form.Item name="template_name" label="配置模板">
+          <Select placeholder="可选，选择配置模板" allowClear disabled={!selectedType}>
+            {templates.map((t) => (
+              <Select.Option key={t.id} value={t.name}>
+                {t.name} ({t.version})
+              </Select.Option>
+            ))}
+          </Select>
         </Form.Item>
 
+        {selectedTemplate?.variables?.map((v) => (
+          <Form.Item
+            key={v.name}
+            name={['variables', v.name]}
+            label={v.name}
+            tooltip={v.description || undefined}
+            rules={[{ required: v.required && (v.default === undefined || v.default === null || v.default === ''), message: `请输入 ${v.name}` }]}
+          >
+            {v.type === 'boolean' ? (
+              <Select placeholder={v.default !== undefined && v.default !== '' ? `默认: ${v.default}` : undefined} allowClear>
+                <Select.Option value="true">true</Select.Option>
+                <Select.Option value="false">false</Select.Option>
+              </Select>
+            ) : v.secret ? (
+              <Input.Password placeholder={v.default ? '已有默认值' : undefined} />
+            ) : (
+              <Input placeholder={v.default !== undefined && v.default !== '' ? `默认: ${typeof v.default === 'object' ? JSON.stringify(v.default) : v.default}` : undefined} />
+            )}
+          </Form.Item>
+        ))}
+
         <Form.Item name="cpu" label="CPU (m)">
           <InputNumber min={100} max={8000} step={100} style={{ width: '100%' }} placeholder="100-8000" />
         </Form.Item>
@@ -216,7 +216,10 @@ const ConfigTemplateList: React.FC = () => {
                         <Select placeholder="Type" style={{ width: 100 }}>
                           <Select.Option value="string">String</Select.Option>
                           <Select.Option value="number">Number</Select.Option>
+                          <Select.Option value="integer">Integer</Select.Option>
                           <Select.Option value="boolean">Boolean</Select.Option>
+                          <Select.Option value="array">Array</Select.Option>
+                          <Select.Option value="object">Object</Select.Option>
                         </Select>
                       </Form.Item>
                       <Form.Item {...restField} name={[name, 'default']}>
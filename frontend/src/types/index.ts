@@ -26,7 +26,9 @@ export interface StorageSpec {
 
 export interface InstanceConfig {
   template_name: string;
+  template_version?: string;
   overrides: Record<string, string>;
+  values?: Record<string, string>;
 }
 
 export interface ClawInstance {
@@ -38,12 +40,22 @@ export interface ClawInstance {
   version: string;
   status: InstanceStatus;
   config?: InstanceConfig;
+  labels?: Record<string, string>;
+  config_revision?: number;
+  config_apply?: ConfigApply;
   resources?: ResourceSpec;
   storage?: StorageSpec;
   created_at: string;
   updated_at: string;
 }
 
+export interface ConfigApply {
+  action: 'none' | 'reload' | 'restart';
+  method?: string;
+  reason?: string;
+  restart_fields?: string[];
+}
+
 export interface CreateInstanceRequest {
   name: string;
   tenant_id: string;
@@ -51,15 +63,82 @@ export interface CreateInstanceRequest {
   type: string;
   version: string;
   config?: InstanceConfig;
+  labels?: Record<string, string>;
   cpu?: string;
   memory?: string;
+  storage?: StorageSpec;
 }
 
 export interface UpdateInstanceRequest {
   name?: string;
   config?: InstanceConfig;
-  cpu?: string;
-  memory?: string;
+  resources?: ResourceSpec;
+  labels?: Record<string, string>;
+  changelog?: string;
+}
+
+export interface InstanceConfigFile {
+  name: string;
+  format: string;
+  content: string;
+}
+
+export interface InstanceConfigRevision {
+  instance_id: string;
+  revision: number;
+  current: boolean;
+  content_hash: string;
+  author: string;
+  changelog: string;
+  rolled_back_from?: number;
+  config: InstanceConfig;
+  files?: InstanceConfigFile[];
+  env_vars?: Record<string, string>;
+  created_at: string;
+}
+
+export interface InstanceConfigRevisionListResponse {
+  items: InstanceConfigRevision[];
+  total: number;
+  page: number;
+  page_size: number;
+}
+
+export interface InstanceConfigChange {
+  path: string;
+  type: 'added' | 'removed' | 'modified';
+  old?: any;
+  new?: any;
+}
+
+export interface DriftIssue {
+  resource: 'configmap' | 'pod';
+  type: 'missing' | 'modified' | 'stale' | 'env_outdated';
+  message: string;
+  keys?: string[];
+}
+
+export interface ConfigDrift {
+  instance_id: string;
+  config_revision: number;
+  checked: boolean;
+  drifted: boolean;
+  desired_hash?: string;
+  live_hash?: string;
+  pod_revision?: number;
+  issues?: DriftIssue[];
+  corrected: boolean;
+  message?: string;
+  checked_at: string;
+}
+
+export interface InstanceConfigDiff {
+  instance_id: string;
+  from: number;
+  to: number;
+  from_hash: string;
+  to_hash: string;
+  changes: InstanceConfigChange[];
 }
 
 export interface InstanceListResponse {
@@ -2,7 +2,9 @@
 package adapter
 
 import (
+	"context"
 	"fmt"
+	"strings"
 )
 
 // UnifiedConfig represents the unified configuration format used by the control plane
@@ -58,28 +60,77 @@ type VolumeMount struct {
 	ReadOnly  bool   `json:"read_only" yaml:"read_only"`
 }
 
-// ClawAdapter defines the interface for adapting unified config to specific Claw types
-type ClawAdapter interface {
-	// ParseConfig parses the unified config into adapter-specific format
-	ParseConfig(unifiedConfig UnifiedConfig) error
+// DefaultConfigMountPath is where the instance ConfigMap is mounted inside the Claw container
+const DefaultConfigMountPath = "/etc/claw/config"
+
+// RuntimeInfo describes where and how a Claw instance runs.
+// Fields the control plane does not know yet are left empty.
+type RuntimeInfo struct {
+	InstanceID      string         `json:"instance_id"`
+	InstanceName    string         `json:"instance_name"`
+	TenantID        string         `json:"tenant_id"`
+	ProjectID       string         `json:"project_id"`
+	Version         string         `json:"version"`
+	Namespace       string         `json:"namespace"`
+	ServiceURL      string         `json:"service_url,omitempty"`
+	ConfigMountPath string         `json:"config_mount_path"`
+	ConfigDir       string         `json:"config_dir,omitempty"`
+	DataDir         string         `json:"data_dir,omitempty"`
+	Ports           map[string]int `json:"ports,omitempty"`
+}
 
-	// GenerateConfig generates the target configuration string (YAML/JSON) for the Claw instance
-	GenerateConfig() (string, error)
+// ConfigFile is a single rendered configuration file.
+// Name is used as the file name inside the config mount and must be a valid ConfigMap key.
+type ConfigFile struct {
+	Name    string       `json:"name"`
+	Format  ConfigFormat `json:"format"`
+	Content string       `json:"content"`
+}
 
-	// Validate validates the configuration
-	Validate() error
+// Artifacts is everything an adapter produces for one instance
+type Artifacts struct {
+	// Config is the effective config after runtime information was applied
+	Config       UnifiedConfig     `json:"config"`
+	Files        []ConfigFile      `json:"files"`
+	EnvVars      map[string]string `json:"env_vars"`
+	VolumeMounts []VolumeMount     `json:"volume_mounts"`
+}
 
-	// GetImage returns the Docker image name for this Claw type
-	GetImage(version string) string
+// File returns the rendered file with the given name
+func (a Artifacts) File(name string) (ConfigFile, bool) {
+	for _, f := range a.Files {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return ConfigFile{}, false
+}
+
+// ClawAdapter defines the interface for adapting unified config to specific Claw types.
+// Implementations must not keep per-render state so one adapter can be shared across goroutines.
+type ClawAdapter interface {
+	// Render validates a fully resolved config and produces the files, env vars and mounts for
+	// the instance described by runtime. Defaults are not merged in again, so build config
+	// with ResolveConfig from GetDefaultConfig; an explicit zero value is kept as is.
+	Render(ctx context.Context, config UnifiedConfig, runtime RuntimeInfo) (Artifacts, error)
 
-	// GetEnvVars returns environment variables needed by this Claw type
-	GetEnvVars() map[string]string
+	// Validate validates a fully merged configuration
+	Validate(config UnifiedConfig) error
 
-	// GetVolumeMounts returns additional volume mounts needed
-	GetVolumeMounts() []VolumeMount
+	// GetImage returns the Docker image name for this Claw type
+	GetImage(version string) string
 
 	// GetDefaultConfig returns default configuration values
 	GetDefaultConfig() UnifiedConfig
+
+	// GetSupportedVersions returns the Claw versions this adapter can run, newest first
+	GetSupportedVersions() []string
+
+	// GetConfigSchema returns a JSON Schema describing the configurable fields
+	GetConfigSchema() *JSONSchema
+
+	// GetReloadPolicy returns how a running Claw applies config changes without a restart
+	GetReloadPolicy() ReloadPolicy
 }
 
 // AdapterType represents the type of Claw adapter
@@ -97,3 +148,26 @@ var ErrAdapterNotFound = fmt.Errorf("adapter not found")
 
 // ErrInvalidConfig is returned when configuration is invalid
 var ErrInvalidConfig = fmt.Errorf("invalid configuration")
+
+// FieldError describes a validation failure on a single config field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects the field errors found while validating a config
+type ValidationErrors []FieldError
+
+// Error implements the error interface
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+	}
+	return fmt.Sprintf("%s: %s", ErrInvalidConfig, strings.Join(msgs, "; "))
+}
+
+// Unwrap allows errors.Is(err, ErrInvalidConfig)
+func (e ValidationErrors) Unwrap() error {
+	return ErrInvalidConfig
+}
@@ -2,11 +2,15 @@ package adapter
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 )
 
-// Factory creates ClawAdapter instances based on type
+// Factory creates ClawAdapter instances based on type.
+// It is safe for concurrent use.
 type Factory struct {
+	mu       sync.RWMutex
 	adapters map[AdapterType]func() ClawAdapter
 }
 
@@ -31,12 +35,16 @@ func NewFactory() *Factory {
 
 // Register registers a new adapter type
 func (f *Factory) Register(adapterType AdapterType, constructor func() ClawAdapter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.adapters[adapterType] = constructor
 }
 
 // Create creates a ClawAdapter for the given type
 func (f *Factory) Create(adapterType AdapterType) (ClawAdapter, error) {
+	f.mu.RLock()
 	constructor, ok := f.adapters[adapterType]
+	f.mu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("%w: %s", ErrAdapterNotFound, adapterType)
 	}
@@ -52,16 +60,21 @@ func (f *Factory) CreateByString(typeName string) (ClawAdapter, error) {
 
 // IsSupported checks if an adapter type is supported
 func (f *Factory) IsSupported(adapterType AdapterType) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	_, ok := f.adapters[adapterType]
 	return ok
 }
 
-// GetSupportedTypes returns a list of supported adapter types
+// GetSupportedTypes returns a list of supported adapter types, sorted by name
 func (f *Factory) GetSupportedTypes() []AdapterType {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	types := make([]AdapterType, 0, len(f.adapters))
 	for t := range f.adapters {
 		types = append(types, t)
 	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
 	return types
 }
 
@@ -78,6 +91,11 @@ func CreateByString(typeName string) (ClawAdapter, error) {
 	return DefaultFactory.CreateByString(typeName)
 }
 
+// GetSupportedTypes returns the adapter types registered in the default factory
+func GetSupportedTypes() []AdapterType {
+	return DefaultFactory.GetSupportedTypes()
+}
+
 // IsSupported checks if an adapter type is supported by the default factory
 func IsSupported(adapterType AdapterType) bool {
 	return DefaultFactory.IsSupported(adapterType)
@@ -0,0 +1,98 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFormat is the serialization format of a rendered config file
+type ConfigFormat string
+
+const (
+	// FormatYAML is a YAML document
+	FormatYAML ConfigFormat = "yaml"
+	// FormatJSON is a JSON document
+	FormatJSON ConfigFormat = "json"
+	// FormatTOML is a TOML document
+	FormatTOML ConfigFormat = "toml"
+	// FormatEnv is a dotenv file of KEY=value lines
+	FormatEnv ConfigFormat = "env"
+	// FormatText is free-form text such as a prompt file
+	FormatText ConfigFormat = "text"
+)
+
+// ErrUnsupportedFormat is returned when a value cannot be encoded in the requested format
+var ErrUnsupportedFormat = fmt.Errorf("unsupported config format")
+
+// EncodeConfig serializes v in the given format.
+// FormatEnv expects a map[string]string and FormatText a string.
+func EncodeConfig(format ConfigFormat, v interface{}) (string, error) {
+	switch format {
+	case FormatYAML:
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal YAML: %w", err)
+		}
+		return string(out), nil
+	case FormatJSON:
+		out, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		return string(out) + "\n", nil
+	case FormatTOML:
+		out, err := toml.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal TOML: %w", err)
+		}
+		return string(out), nil
+	case FormatEnv:
+		vars, ok := v.(map[string]string)
+		if !ok {
+			return "", fmt.Errorf("%w: env format expects map[string]string, got %T", ErrUnsupportedFormat, v)
+		}
+		return encodeEnv(vars), nil
+	case FormatText:
+		text, ok := v.(string)
+		if !ok {
+			return "", fmt.Errorf("%w: text format expects string, got %T", ErrUnsupportedFormat, v)
+		}
+		return text, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
+
+// NewConfigFile encodes v in the given format into a named config file
+func NewConfigFile(name string, format ConfigFormat, v interface{}) (ConfigFile, error) {
+	content, err := EncodeConfig(format, v)
+	if err != nil {
+		return ConfigFile{}, fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return ConfigFile{Name: name, Format: format, Content: content}, nil
+}
+
+// encodeEnv renders sorted KEY=value lines, quoting values that need it
+func encodeEnv(vars map[string]string) string {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		value := vars[key]
+		if value == "" || strings.ContainsAny(value, " \t\n\"'#$\\=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", key, value)
+	}
+	return b.String()
+}
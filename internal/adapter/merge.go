@@ -0,0 +1,60 @@
+package adapter
+
+import (
+	"reflect"
+)
+
+// ResolveConfig applies path-based overrides on top of a copy of defaults. Fields named by an
+// override path take its value even when that value is zero, false or empty; every other
+// field keeps its default. Maps are merged key by key because each path sets a single key.
+// The config is returned together with any ValidationErrors from paths that could not be
+// applied, so callers can report all problems at once. defaults is not modified.
+func ResolveConfig(defaults UnifiedConfig, overrides map[string]string) (UnifiedConfig, error) {
+	resolved := reflect.New(reflect.TypeOf(defaults)).Elem()
+	resolved.Set(deepCopy(reflect.ValueOf(defaults)))
+	config := resolved.Interface().(UnifiedConfig)
+	err := ApplyOverrides(&config, overrides)
+	return config, err
+}
+
+// deepCopy returns a copy of v that shares no maps or slices with it
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Struct:
+		copied := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if copied.Field(i).CanSet() {
+				copied.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return copied
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return copied
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			copied.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return copied
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		copied := reflect.New(v.Type()).Elem()
+		copied.Set(deepCopy(v.Elem()))
+		return copied
+	default:
+		return v
+	}
+}
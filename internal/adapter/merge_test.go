@@ -0,0 +1,72 @@
+package adapter
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestResolveConfigKeepsExplicitZeroValues(t *testing.T) {
+	a := NewOpenClawAdapter()
+	defaults := a.GetDefaultConfig()
+	defaults.Plugins.Config["audit"] = map[string]interface{}{"enabled": true, "level": "full"}
+
+	config, err := ResolveConfig(defaults, map[string]string{
+		"model.temperature":            "0",
+		"logging.output":               "",
+		"server.cors_origins":          "",
+		"plugins.config.audit.enabled": "false",
+	})
+	if err != nil {
+		t.Fatalf("ResolveConfig: %v", err)
+	}
+
+	if config.Model.Temperature != 0 {
+		t.Errorf("model.temperature = %v, want 0", config.Model.Temperature)
+	}
+	if config.Logging.Output != "" {
+		t.Errorf("logging.output = %q, want empty", config.Logging.Output)
+	}
+	if len(config.Server.CORSOrigins) != 0 {
+		t.Errorf("server.cors_origins = %v, want empty", config.Server.CORSOrigins)
+	}
+	audit, _ := config.Plugins.Config["audit"].(map[string]interface{})
+	if audit["enabled"] != false {
+		t.Errorf("plugins.config.audit.enabled = %v, want false", audit["enabled"])
+	}
+	if audit["level"] != "full" {
+		t.Errorf("plugins.config.audit.level = %v, want the default to be kept", audit["level"])
+	}
+
+	// Fields without an override keep their defaults
+	if config.Model.MaxTokens != 4096 || config.Logging.Level != "info" {
+		t.Errorf("defaults lost: max_tokens=%d level=%q", config.Model.MaxTokens, config.Logging.Level)
+	}
+
+	// The defaults themselves are not modified
+	if defaults.Model.Temperature != 0.7 || defaults.Logging.Output != "stdout" {
+		t.Errorf("defaults modified: %+v", defaults)
+	}
+	if enabled := defaults.Plugins.Config["audit"].(map[string]interface{})["enabled"]; enabled != true {
+		t.Errorf("default plugin config modified: enabled = %v", enabled)
+	}
+}
+
+func TestRenderKeepsExplicitZeroValues(t *testing.T) {
+	a := NewOpenClawAdapter()
+	config, err := ResolveConfig(a.GetDefaultConfig(), map[string]string{"model.temperature": "0"})
+	if err != nil {
+		t.Fatalf("ResolveConfig: %v", err)
+	}
+
+	artifacts, err := a.Render(context.Background(), config, RuntimeInfo{InstanceID: "instance-1"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if artifacts.Config.Model.Temperature != 0 {
+		t.Errorf("rendered model.temperature = %v, want 0", artifacts.Config.Model.Temperature)
+	}
+	if len(artifacts.Files) == 0 || !strings.Contains(artifacts.Files[0].Content, "temperature: 0\n") {
+		t.Errorf("rendered config file does not set temperature to 0:\n%v", artifacts.Files)
+	}
+}
@@ -1,15 +1,18 @@
 package adapter
 
 import (
+	"context"
 	"fmt"
-
-	"gopkg.in/yaml.v3"
+	"math"
+	"path"
 )
 
-// OpenClawAdapter adapts unified config to OpenClaw format
-type OpenClawAdapter struct {
-	config UnifiedConfig
-}
+// OpenClawAdapter adapts unified config to OpenClaw format.
+// It holds no state and is safe for concurrent use.
+type OpenClawAdapter struct{}
+
+// openClawConfigFile is the name of the rendered OpenClaw config file
+const openClawConfigFile = "config.yaml"
 
 // OpenClawConfig represents the OpenClaw-specific configuration format
 type OpenClawConfig struct {
@@ -29,7 +32,7 @@ type OpenClawModelConfig struct {
 	APIKey      string  `yaml:"api_key,omitempty"`
 	BaseURL     string  `yaml:"base_url,omitempty"`
 	MaxTokens   int     `yaml:"max_tokens,omitempty"`
-	Temperature float64 `yaml:"temperature,omitempty"`
+	Temperature float64 `yaml:"temperature"`
 }
 
 // OpenClawMemoryConfig represents OpenClaw memory configuration
@@ -64,96 +67,124 @@ type OpenClawSkillConfig struct {
 
 // NewOpenClawAdapter creates a new OpenClaw adapter
 func NewOpenClawAdapter() *OpenClawAdapter {
-	return &OpenClawAdapter{
-		config: GetDefaultOpenClawConfig(),
-	}
+	return &OpenClawAdapter{}
 }
 
-// ParseConfig parses the unified config into OpenClaw format
-func (a *OpenClawAdapter) ParseConfig(unifiedConfig UnifiedConfig) error {
-	// Merge with defaults
-	if unifiedConfig.Model.Name != "" {
-		a.config.Model = unifiedConfig.Model
+// Render produces the OpenClaw config file, env vars and mounts for an instance
+func (a *OpenClawAdapter) Render(ctx context.Context, config UnifiedConfig, runtime RuntimeInfo) (Artifacts, error) {
+	if err := ctx.Err(); err != nil {
+		return Artifacts{}, err
 	}
-	if unifiedConfig.Memory.Limit > 0 {
-		a.config.Memory = unifiedConfig.Memory
+	if runtime.InstanceID == "" {
+		return Artifacts{}, fmt.Errorf("%w: instance ID is required", ErrInvalidConfig)
 	}
-	if unifiedConfig.Server.Port > 0 {
-		a.config.Server = unifiedConfig.Server
+	if runtime.ConfigMountPath == "" {
+		runtime.ConfigMountPath = DefaultConfigMountPath
 	}
-	if unifiedConfig.Logging.Level != "" {
-		a.config.Logging = unifiedConfig.Logging
+	if runtime.DataDir == "" {
+		runtime.DataDir = "/var/lib/claw"
 	}
-	a.config.Plugins = unifiedConfig.Plugins
 
-	return nil
+	// config is a copy, so runtime values can be applied without touching the caller's config
+	merged := config
+
+	// An assigned port takes precedence over the configured one
+	if port, ok := runtime.Ports["http"]; ok && port > 0 {
+		merged.Server.Port = port
+	}
+
+	if err := a.Validate(merged); err != nil {
+		return Artifacts{}, err
+	}
+
+	configFile, err := a.generateConfig(merged)
+	if err != nil {
+		return Artifacts{}, err
+	}
+
+	return Artifacts{
+		Config:       merged,
+		Files:        []ConfigFile{configFile},
+		EnvVars:      a.envVars(runtime),
+		VolumeMounts: a.volumeMounts(runtime),
+	}, nil
 }
 
-// GenerateConfig generates the OpenClaw YAML configuration
-func (a *OpenClawAdapter) GenerateConfig() (string, error) {
+// generateConfig generates the OpenClaw YAML configuration
+func (a *OpenClawAdapter) generateConfig(config UnifiedConfig) (ConfigFile, error) {
 	openclawConfig := OpenClawConfig{
 		Version: "1.0",
 		Model: OpenClawModelConfig{
 			Provider:    "anthropic",
-			Name:        a.config.Model.Name,
-			APIKey:      a.config.Model.APIKey,
-			BaseURL:     a.config.Model.BaseURL,
-			MaxTokens:   a.config.Model.MaxTokens,
-			Temperature: a.config.Model.Temperature,
+			Name:        config.Model.Name,
+			APIKey:      config.Model.APIKey,
+			BaseURL:     config.Model.BaseURL,
+			MaxTokens:   config.Model.MaxTokens,
+			Temperature: config.Model.Temperature,
 		},
 		Memory: OpenClawMemoryConfig{
-			Type:        a.config.Memory.StorageType,
-			Limit:       a.config.Memory.Limit,
-			PersistPath: a.config.Memory.PersistPath,
+			Type:        config.Memory.StorageType,
+			Limit:       config.Memory.Limit,
+			PersistPath: config.Memory.PersistPath,
 		},
 		Server: OpenClawServerConfig{
-			Port:        a.config.Server.Port,
-			Host:        a.config.Server.Host,
-			CORSOrigins: a.config.Server.CORSOrigins,
-			Headers:     a.config.Server.Headers,
+			Port:        config.Server.Port,
+			Host:        config.Server.Host,
+			CORSOrigins: config.Server.CORSOrigins,
+			Headers:     config.Server.Headers,
 		},
 		Logging: OpenClawLoggingConfig{
-			Level:  a.config.Logging.Level,
-			Format: a.config.Logging.Format,
-			Output: a.config.Logging.Output,
+			Level:  config.Logging.Level,
+			Format: config.Logging.Format,
+			Output: config.Logging.Output,
 		},
 	}
 
 	// Add plugins if configured
-	if a.config.Plugins.Config != nil {
-		openclawConfig.Plugins = a.config.Plugins.Config
+	if len(config.Plugins.Config) > 0 {
+		openclawConfig.Plugins = config.Plugins.Config
 	}
 
 	// Add enabled skills
-	for _, skillName := range a.config.Plugins.Enabled {
+	for _, skillName := range config.Plugins.Enabled {
 		openclawConfig.Skills = append(openclawConfig.Skills, OpenClawSkillConfig{
 			Name:    skillName,
 			Enabled: true,
 		})
 	}
 
-	yamlBytes, err := yaml.Marshal(openclawConfig)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal OpenClaw config: %w", err)
-	}
-
-	return string(yamlBytes), nil
+	return NewConfigFile(openClawConfigFile, FormatYAML, openclawConfig)
 }
 
 // Validate validates the OpenClaw configuration
-func (a *OpenClawAdapter) Validate() error {
-	if a.config.Model.Name == "" {
-		return fmt.Errorf("%w: model name is required", ErrInvalidConfig)
+func (a *OpenClawAdapter) Validate(config UnifiedConfig) error {
+	var errs ValidationErrors
+
+	if config.Model.Name == "" {
+		errs = append(errs, FieldError{Field: "model.name", Message: "model name is required"})
+	}
+
+	if config.Model.Temperature < 0 || config.Model.Temperature > 2 {
+		errs = append(errs, FieldError{Field: "model.temperature", Message: "temperature must be between 0 and 2"})
+	}
+
+	if config.Memory.Limit <= 0 {
+		errs = append(errs, FieldError{Field: "memory.limit", Message: "memory limit must be positive"})
 	}
 
-	if a.config.Memory.Limit <= 0 {
-		return fmt.Errorf("%w: memory limit must be positive", ErrInvalidConfig)
+	if config.Server.Port < 1 || config.Server.Port > 65535 {
+		errs = append(errs, FieldError{Field: "server.port", Message: "invalid server port"})
 	}
 
-	if a.config.Server.Port < 1 || a.config.Server.Port > 65535 {
-		return fmt.Errorf("%w: invalid server port", ErrInvalidConfig)
+	switch config.Logging.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, FieldError{Field: "logging.level", Message: "logging level must be one of debug, info, warn, error"})
 	}
 
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
 
@@ -165,25 +196,41 @@ func (a *OpenClawAdapter) GetImage(version string) string {
 	return fmt.Sprintf("openclaw/openclaw:%s", version)
 }
 
-// GetEnvVars returns environment variables needed by OpenClaw
-func (a *OpenClawAdapter) GetEnvVars() map[string]string {
-	return map[string]string{
-		"CLAW_TYPE":       "openclaw",
-		"CLAW_CONFIG_PATH": "/etc/claw/config/config.yaml",
+// envVars returns environment variables needed by OpenClaw
+func (a *OpenClawAdapter) envVars(runtime RuntimeInfo) map[string]string {
+	envVars := map[string]string{
+		"CLAW_TYPE":        "openclaw",
+		"CLAW_CONFIG_PATH": path.Join(runtime.ConfigMountPath, openClawConfigFile),
 	}
+
+	runtimeEnv := map[string]string{
+		"CLAW_INSTANCE_ID":   runtime.InstanceID,
+		"CLAW_INSTANCE_NAME": runtime.InstanceName,
+		"CLAW_TENANT_ID":     runtime.TenantID,
+		"CLAW_PROJECT_ID":    runtime.ProjectID,
+		"CLAW_SERVICE_URL":   runtime.ServiceURL,
+		"CLAW_DATA_DIR":      runtime.DataDir,
+	}
+	for key, value := range runtimeEnv {
+		if value != "" {
+			envVars[key] = value
+		}
+	}
+
+	return envVars
 }
 
-// GetVolumeMounts returns additional volume mounts needed by OpenClaw
-func (a *OpenClawAdapter) GetVolumeMounts() []VolumeMount {
+// volumeMounts returns the volume mounts needed by OpenClaw
+func (a *OpenClawAdapter) volumeMounts(runtime RuntimeInfo) []VolumeMount {
 	return []VolumeMount{
 		{
 			Name:      "config",
-			MountPath: "/etc/claw/config",
+			MountPath: runtime.ConfigMountPath,
 			ReadOnly:  true,
 		},
 		{
 			Name:      "data",
-			MountPath: "/var/lib/claw",
+			MountPath: runtime.DataDir,
 			ReadOnly:  false,
 		},
 	}
@@ -194,6 +241,43 @@ func (a *OpenClawAdapter) GetDefaultConfig() UnifiedConfig {
 	return GetDefaultOpenClawConfig()
 }
 
+// GetSupportedVersions returns the OpenClaw versions supported by this adapter
+func (a *OpenClawAdapter) GetSupportedVersions() []string {
+	return []string{"latest", "1.0"}
+}
+
+// GetReloadPolicy returns how OpenClaw reloads its config file.
+// OpenClaw re-reads config.yaml on SIGHUP but binds its listener and storage backend only at startup.
+func (a *OpenClawAdapter) GetReloadPolicy() ReloadPolicy {
+	return ReloadPolicy{
+		Method:        ReloadSignal,
+		Signal:        "HUP",
+		RestartFields: []string{"server.port", "server.host", "memory.storage_type", "memory.persist_path", "plugins.enabled"},
+	}
+}
+
+// GetConfigSchema returns the JSON Schema of the OpenClaw configurable fields
+func (a *OpenClawAdapter) GetConfigSchema() *JSONSchema {
+	schema := BuildSchema("OpenClaw configuration", GetDefaultOpenClawConfig())
+
+	// Keep in sync with Validate
+	schema.Require("model", "memory", "server", "model.name", "memory.limit", "server.port")
+	schema.SetRange("server.port", 1, 65535)
+	schema.SetRange("memory.limit", 1, math.MaxInt32)
+	schema.SetRange("model.temperature", 0, 2)
+	schema.SetEnum("logging.level", "debug", "info", "warn", "error")
+	schema.SetEnum("logging.format", "json", "text")
+	schema.MarkSecret("model.api_key")
+
+	schema.SetDescription("model.name", "Model identifier passed to the provider")
+	schema.SetDescription("model.api_key", "Provider API key")
+	schema.SetDescription("model.base_url", "Custom provider endpoint")
+	schema.SetDescription("memory.limit", "Maximum number of memory entries")
+	schema.SetDescription("plugins.enabled", "Skills enabled for the instance")
+
+	return schema
+}
+
 // GetDefaultOpenClawConfig returns the default OpenClaw configuration
 func GetDefaultOpenClawConfig() UnifiedConfig {
 	return UnifiedConfig{
@@ -0,0 +1,296 @@
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrUnknownConfigPath is returned when an override path does not exist in UnifiedConfig
+	ErrUnknownConfigPath = errors.New("unknown config path")
+	// ErrInvalidOverrideValue is returned when an override value cannot be coerced to the field type
+	ErrInvalidOverrideValue = errors.New("invalid override value")
+)
+
+// pathToken is a single step of an override path: a field/map key or an array index
+type pathToken struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// ApplyOverrides applies path-based overrides to the config.
+// Paths use json field names separated by dots with optional array indexes,
+// e.g. "model.temperature", "server.headers.X-Api-Key", "plugins.enabled[0]".
+// Every failing path is reported as a FieldError in the returned ValidationErrors.
+func ApplyOverrides(config *UnifiedConfig, overrides map[string]string) error {
+	paths := make([]string, 0, len(overrides))
+	for path := range overrides {
+		paths = append(paths, path)
+	}
+	sortPaths(paths)
+
+	var errs ValidationErrors
+	for _, path := range paths {
+		if err := ApplyOverride(config, path, overrides[path]); err != nil {
+			errs = append(errs, FieldError{Field: path, Message: err.Error()})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// sortPaths orders override paths so that parents come before their children and array
+// indexes are compared numerically, so appends at index 10 and beyond find the array extended.
+// Malformed paths keep their string order and sort last.
+func sortPaths(paths []string) {
+	parsed := make(map[string][]pathToken, len(paths))
+	for _, path := range paths {
+		if tokens, err := parsePath(path); err == nil {
+			parsed[path] = tokens
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		a, aok := parsed[paths[i]]
+		b, bok := parsed[paths[j]]
+		if !aok || !bok {
+			if aok != bok {
+				return aok
+			}
+			return paths[i] < paths[j]
+		}
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if c := compareTokens(a[k], b[k]); c != 0 {
+				return c < 0
+			}
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return paths[i] < paths[j]
+	})
+}
+
+// compareTokens orders keys by name and indexes by number; keys come before indexes
+func compareTokens(a, b pathToken) int {
+	switch {
+	case a.isIndex && b.isIndex:
+		return a.index - b.index
+	case a.isIndex != b.isIndex:
+		if a.isIndex {
+			return 1
+		}
+		return -1
+	default:
+		return strings.Compare(a.key, b.key)
+	}
+}
+
+// ApplyOverride sets the value at the given path, coercing the string value to the field type.
+// Scalars are parsed from their string form; slices, maps and structs accept JSON,
+// and string slices also accept a comma-separated list.
+func ApplyOverride(config *UnifiedConfig, path, value string) error {
+	tokens, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	return setPath(reflect.ValueOf(config).Elem(), tokens, value)
+}
+
+// parsePath splits an override path into tokens
+func parsePath(path string) ([]pathToken, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("%w: empty path", ErrUnknownConfigPath)
+	}
+
+	var tokens []pathToken
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var indexes []int
+		if open := strings.Index(part, "["); open >= 0 {
+			key = part[:open]
+			rest := part[open:]
+			for rest != "" {
+				end := strings.Index(rest, "]")
+				if rest[0] != '[' || end < 0 {
+					return nil, fmt.Errorf("%w: malformed index in %q", ErrUnknownConfigPath, part)
+				}
+				idx, err := strconv.Atoi(rest[1:end])
+				if err != nil || idx < 0 {
+					return nil, fmt.Errorf("%w: invalid index in %q", ErrUnknownConfigPath, part)
+				}
+				indexes = append(indexes, idx)
+				rest = rest[end+1:]
+			}
+		}
+
+		if key != "" {
+			tokens = append(tokens, pathToken{key: key})
+		} else if len(indexes) == 0 || len(tokens) == 0 {
+			return nil, fmt.Errorf("%w: empty segment in %q", ErrUnknownConfigPath, path)
+		}
+		for _, idx := range indexes {
+			tokens = append(tokens, pathToken{index: idx, isIndex: true})
+		}
+	}
+	return tokens, nil
+}
+
+// setPath walks v along tokens and assigns the coerced value at the end
+func setPath(v reflect.Value, tokens []pathToken, value string) error {
+	if len(tokens) == 0 {
+		return coerceInto(v, value)
+	}
+	token := tokens[0]
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if token.isIndex {
+			return fmt.Errorf("%w: cannot index object", ErrUnknownConfigPath)
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			if name, ok := jsonFieldName(t.Field(i)); ok && name == token.key {
+				return setPath(v.Field(i), tokens[1:], value)
+			}
+		}
+		return fmt.Errorf("%w: %s", ErrUnknownConfigPath, token.key)
+
+	case reflect.Slice:
+		if !token.isIndex {
+			return fmt.Errorf("%w: expected array index before %q", ErrUnknownConfigPath, token.key)
+		}
+		if token.index > v.Len() {
+			return fmt.Errorf("%w: index %d out of range (length %d)", ErrUnknownConfigPath, token.index, v.Len())
+		}
+		if token.index == v.Len() {
+			// Appending one past the end extends the array
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := setPath(elem, tokens[1:], value); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, elem))
+			return nil
+		}
+		return setPath(v.Index(token.index), tokens[1:], value)
+
+	case reflect.Map:
+		if token.isIndex {
+			return fmt.Errorf("%w: cannot index object", ErrUnknownConfigPath)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(token.key)
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			elem.Set(existing)
+		}
+		if err := setPath(elem, tokens[1:], value); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+
+	case reflect.Interface:
+		// Free-form values (e.g. plugin config) grow nested objects and arrays on demand
+		current := v.Elem()
+		if !current.IsValid() || (token.isIndex && current.Kind() != reflect.Slice) || (!token.isIndex && current.Kind() != reflect.Map) {
+			if token.isIndex {
+				current = reflect.ValueOf([]interface{}{})
+			} else {
+				current = reflect.ValueOf(map[string]interface{}{})
+			}
+		}
+		copied := reflect.New(current.Type()).Elem()
+		copied.Set(current)
+		if err := setPath(copied, tokens, value); err != nil {
+			return err
+		}
+		v.Set(copied)
+		return nil
+
+	default:
+		return fmt.Errorf("%w: %s is not an object or array", ErrUnknownConfigPath, v.Kind())
+	}
+}
+
+// coerceInto parses value according to the kind of v and assigns it
+func coerceInto(v reflect.Value, value string) error {
+	trimmed := strings.TrimSpace(value)
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(trimmed, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%w: expected integer, got %q", ErrInvalidOverrideValue, value)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(trimmed, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%w: expected non-negative integer, got %q", ErrInvalidOverrideValue, value)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(trimmed, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%w: expected number, got %q", ErrInvalidOverrideValue, value)
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(trimmed)
+		if err != nil {
+			return fmt.Errorf("%w: expected boolean, got %q", ErrInvalidOverrideValue, value)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(trimmed, "[") {
+			parts := []string{}
+			if trimmed != "" {
+				for _, p := range strings.Split(trimmed, ",") {
+					parts = append(parts, strings.TrimSpace(p))
+				}
+			}
+			v.Set(reflect.ValueOf(parts))
+			return nil
+		}
+		return unmarshalInto(v, trimmed, "array")
+	case reflect.Map, reflect.Struct:
+		return unmarshalInto(v, trimmed, "object")
+	case reflect.Interface:
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(trimmed), &decoded); err != nil {
+			// Not JSON, keep the raw string
+			decoded = value
+		}
+		if decoded == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(decoded))
+		}
+	default:
+		return fmt.Errorf("%w: unsupported field type %s", ErrInvalidOverrideValue, v.Kind())
+	}
+	return nil
+}
+
+// unmarshalInto decodes a JSON value into v
+func unmarshalInto(v reflect.Value, value, expected string) error {
+	target := reflect.New(v.Type())
+	if err := json.Unmarshal([]byte(value), target.Interface()); err != nil {
+		return fmt.Errorf("%w: expected JSON %s, got %q", ErrInvalidOverrideValue, expected, value)
+	}
+	v.Set(target.Elem())
+	return nil
+}
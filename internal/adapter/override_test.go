@@ -0,0 +1,40 @@
+package adapter
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestApplyOverridesAppendsPastTenElements(t *testing.T) {
+	config := NewOpenClawAdapter().GetDefaultConfig()
+	config.Plugins.Enabled = nil
+
+	overrides := make(map[string]string)
+	for i := 0; i < 12; i++ {
+		overrides[fmt.Sprintf("plugins.enabled[%d]", i)] = fmt.Sprintf("plugin-%d", i)
+	}
+	if err := ApplyOverrides(&config, overrides); err != nil {
+		t.Fatalf("ApplyOverrides: %v", err)
+	}
+
+	if len(config.Plugins.Enabled) != 12 {
+		t.Fatalf("plugins.enabled has %d elements, want 12", len(config.Plugins.Enabled))
+	}
+	for i, name := range config.Plugins.Enabled {
+		if want := fmt.Sprintf("plugin-%d", i); name != want {
+			t.Errorf("plugins.enabled[%d] = %q, want %q", i, name, want)
+		}
+	}
+}
+
+func TestSortPaths(t *testing.T) {
+	paths := []string{"a[10]", "a[2].b", "a[2]", "a", "a.[", "b", "a[1]"}
+	sortPaths(paths)
+
+	want := []string{"a", "a[1]", "a[2]", "a[2].b", "a[10]", "b", "a.["}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Fatalf("sortPaths = %v, want %v", paths, want)
+		}
+	}
+}
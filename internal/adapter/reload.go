@@ -0,0 +1,47 @@
+package adapter
+
+import "strings"
+
+// ReloadMethod is how a running Claw picks up changed config files without being recreated
+type ReloadMethod string
+
+const (
+	// ReloadNone means the Claw only reads its config at startup
+	ReloadNone ReloadMethod = "none"
+	// ReloadSignal means the Claw reloads when its main process receives ReloadPolicy.Signal
+	ReloadSignal ReloadMethod = "signal"
+	// ReloadHTTP means the Claw reloads when ReloadPolicy.Path is POSTed on its server port
+	ReloadHTTP ReloadMethod = "http"
+	// ReloadFileWatch means the Claw watches the mounted config files itself
+	ReloadFileWatch ReloadMethod = "file_watch"
+)
+
+// ReloadPolicy declares how a Claw type applies config changes in place.
+// Env var changes always require a restart because they are fixed when the pod is created.
+type ReloadPolicy struct {
+	Method ReloadMethod `json:"method"`
+	// Signal is the signal name without the SIG prefix, e.g. "HUP"
+	Signal string `json:"signal,omitempty"`
+	// Path is the HTTP path of the reload endpoint
+	Path string `json:"path,omitempty"`
+	// RestartFields are the UnifiedConfig paths that cannot be reloaded.
+	// A path also covers everything below it, so "server" matches "server.port".
+	RestartFields []string `json:"restart_fields,omitempty"`
+}
+
+// RequiresRestart reports whether a change at the given config path needs the Claw to be restarted
+func (p ReloadPolicy) RequiresRestart(path string) bool {
+	if p.Method == ReloadNone || p.Method == "" {
+		return true
+	}
+	for _, field := range p.RestartFields {
+		if path == field || strings.HasPrefix(path, field+".") || strings.HasPrefix(path, field+"[") {
+			return true
+		}
+		// A change to a whole section covers the restart fields below it
+		if strings.HasPrefix(field, path+".") {
+			return true
+		}
+	}
+	return false
+}
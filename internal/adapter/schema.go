@@ -0,0 +1,175 @@
+package adapter
+
+import (
+	"reflect"
+	"strings"
+)
+
+// JSONSchemaDraft is the JSON Schema dialect produced by BuildSchema
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is a minimal JSON Schema document describing configurable fields
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	WriteOnly            bool                   `json:"writeOnly,omitempty"`
+}
+
+// BuildSchema generates a JSON Schema for the given config value using its json tags.
+// Values present in the config are reported as field defaults.
+func BuildSchema(title string, config UnifiedConfig) *JSONSchema {
+	schema := schemaForValue(reflect.ValueOf(config))
+	schema.Schema = JSONSchemaDraft
+	schema.Title = title
+	return schema
+}
+
+// Property returns the schema of a nested property addressed by a dot path, or nil if absent
+func (s *JSONSchema) Property(path string) *JSONSchema {
+	current := s
+	for _, key := range strings.Split(path, ".") {
+		if current == nil || current.Properties == nil {
+			return nil
+		}
+		current = current.Properties[key]
+	}
+	return current
+}
+
+// Require marks the nested properties addressed by dot paths as required on their parents
+func (s *JSONSchema) Require(paths ...string) {
+	for _, path := range paths {
+		parent := s
+		key := path
+		if idx := strings.LastIndex(path, "."); idx >= 0 {
+			parent = s.Property(path[:idx])
+			key = path[idx+1:]
+		}
+		if parent == nil || parent.Properties[key] == nil {
+			continue
+		}
+		parent.Required = append(parent.Required, key)
+	}
+}
+
+// SetRange sets inclusive numeric bounds on a nested property
+func (s *JSONSchema) SetRange(path string, minimum, maximum float64) {
+	if prop := s.Property(path); prop != nil {
+		prop.Minimum = &minimum
+		prop.Maximum = &maximum
+	}
+}
+
+// SetEnum restricts a nested property to the given values
+func (s *JSONSchema) SetEnum(path string, values ...interface{}) {
+	if prop := s.Property(path); prop != nil {
+		prop.Enum = values
+	}
+}
+
+// SetDescription sets the description of a nested property
+func (s *JSONSchema) SetDescription(path, description string) {
+	if prop := s.Property(path); prop != nil {
+		prop.Description = description
+	}
+}
+
+// MarkSecret flags a nested property as sensitive so clients never echo it back
+func (s *JSONSchema) MarkSecret(path string) {
+	if prop := s.Property(path); prop != nil {
+		prop.WriteOnly = true
+		prop.Format = "password"
+	}
+}
+
+// schemaForValue builds the schema of a reflected value
+func schemaForValue(v reflect.Value) *JSONSchema {
+	schema := schemaForType(v.Type())
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := jsonFieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+			schema.Properties[name] = schemaForValue(v.Field(i))
+		}
+	case reflect.Slice, reflect.Map:
+		if !v.IsNil() && v.Len() > 0 {
+			schema.Default = v.Interface()
+		}
+	default:
+		if !v.IsZero() {
+			schema.Default = v.Interface()
+		}
+	}
+
+	return schema
+}
+
+// schemaForType builds the schema of a reflected type without defaults
+func schemaForType(t reflect.Type) *JSONSchema {
+	switch t.Kind() {
+	case reflect.Struct:
+		schema := &JSONSchema{
+			Type:       "object",
+			Properties: make(map[string]*JSONSchema),
+		}
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := jsonFieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+			schema.Properties[name] = schemaForType(t.Field(i).Type)
+		}
+		return schema
+	case reflect.Slice, reflect.Array:
+		return &JSONSchema{Type: "array", Items: schemaForType(t.Elem())}
+	case reflect.Map:
+		schema := &JSONSchema{Type: "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			schema.AdditionalProperties = schemaForType(t.Elem())
+		}
+		return schema
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	default:
+		return &JSONSchema{}
+	}
+}
+
+// jsonFieldName returns the JSON name of a struct field, or false if it is not serialized
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/internal/service"
+)
+
+// AdapterHandler handles adapter discovery requests
+type AdapterHandler struct {
+	service service.AdapterService
+}
+
+// NewAdapterHandler creates a new adapter handler
+func NewAdapterHandler(service service.AdapterService) *AdapterHandler {
+	return &AdapterHandler{
+		service: service,
+	}
+}
+
+// List retrieves all registered adapters
+// @Summary List adapters
+// @Tags adapters
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} service.AdapterInfo
+// @Router /adapters [get]
+func (h *AdapterHandler) List(c *gin.Context) {
+	adapters, err := h.service.ListAdapters(c.Request.Context())
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "failed to list adapters", err)
+		return
+	}
+
+	success(c, gin.H{
+		"adapters": adapters,
+		"total":    len(adapters),
+	})
+}
+
+// Get retrieves a single adapter by type
+// @Summary Get adapter
+// @Tags adapters
+// @Security BearerAuth
+// @Produce json
+// @Param type path string true "Adapter type"
+// @Success 200 {object} service.AdapterInfo
+// @Router /adapters/{type} [get]
+func (h *AdapterHandler) Get(c *gin.Context) {
+	info, err := h.service.GetAdapter(c.Request.Context(), c.Param("type"))
+	if err != nil {
+		errorResponse(c, http.StatusNotFound, "adapter not found", err)
+		return
+	}
+
+	success(c, info)
+}
+
+// Schema retrieves the JSON Schema of an adapter's configurable fields
+// @Summary Get adapter config schema
+// @Tags adapters
+// @Security BearerAuth
+// @Produce json
+// @Param type path string true "Adapter type"
+// @Success 200 {object} adapter.JSONSchema
+// @Router /adapters/{type}/schema [get]
+func (h *AdapterHandler) Schema(c *gin.Context) {
+	schema, err := h.service.GetAdapterSchema(c.Request.Context(), c.Param("type"))
+	if err != nil {
+		errorResponse(c, http.StatusNotFound, "adapter not found", err)
+		return
+	}
+
+	success(c, schema)
+}
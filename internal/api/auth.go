@@ -1,10 +1,14 @@
 package api
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/internal/middleware"
 	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
 	"github.com/weibh/openClusterClaw/internal/service"
 )
 
@@ -44,6 +48,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	loginReq := &service.LoginRequest{
 		Username: req.Username,
 		Password: req.Password,
+		Client:   clientInfo(c),
 	}
 
 	resp, err := h.authService.Login(c.Request.Context(), loginReq)
@@ -55,7 +60,16 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	success(c, resp)
 }
 
-// RefreshToken handles token refresh
+// clientInfo describes the client of a request for the login session it starts or uses
+func clientInfo(c *gin.Context) service.ClientInfo {
+	return service.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+// RefreshToken rotates the refresh token of a session. A refresh token can be used only once;
+// using it again revokes the session.
 // @Summary Refresh access token
 // @Tags auth
 // @Accept json
@@ -74,7 +88,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken)
+	resp, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken, clientInfo(c))
 	if err != nil {
 		errorResponse(c, http.StatusUnauthorized, "invalid refresh token", err)
 		return
@@ -83,21 +97,56 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	success(c, resp)
 }
 
-// Logout handles user logout
+// Logout revokes the caller's session, so neither its access nor its refresh token can be used
+// any more
 // @Summary User logout
 // @Tags auth
 // @Security BearerAuth
 // @Success 200 {object} Response
 // @Router /auth/logout [post]
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// In current implementation, logout is handled on client side
-	// The client simply discards the tokens
-	// For a more secure implementation, we could:
-	// 1. Add tokens to a blacklist
-	// 2. Use short-lived access tokens with refresh tokens
+	if err := h.authService.Logout(c.Request.Context(), middleware.GetSessionID(c)); err != nil {
+		errorResponse(c, http.StatusInternalServerError, "failed to log out", err)
+		return
+	}
+
 	success(c, gin.H{"message": "logged out successfully"})
 }
 
+// ListSessions lists the caller's active sessions
+// @Summary List own sessions
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} service.SessionResponse
+// @Router /auth/sessions [get]
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+	sessions, err := h.authService.ListSessions(c.Request.Context(), middleware.GetUserID(c), middleware.GetSessionID(c))
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "failed to list sessions", err)
+		return
+	}
+
+	success(c, sessions)
+}
+
+// RevokeSession revokes one of the caller's sessions, signing that client out
+// @Summary Revoke own session
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Session ID"
+// @Success 200 {object} Response
+// @Router /auth/sessions/{id} [delete]
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+	if err := h.authService.RevokeSession(c.Request.Context(), middleware.GetUserID(c), c.Param("id")); err != nil {
+		userError(c, err, "failed to revoke session")
+		return
+	}
+
+	success(c, gin.H{"message": "session revoked"})
+}
+
 // GetCurrentUser returns the current authenticated user
 // @Summary Get current user
 // @Tags auth
@@ -123,12 +172,14 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 // CreateUserRequest represents a create user request
 type CreateUserRequest struct {
 	Username string            `json:"username" binding:"required"`
-	Password string            `json:"password" binding:"required,min=6"`
+	Password string            `json:"password" binding:"required"`
 	TenantID string            `json:"tenant_id"`
 	Role     model.UserRole    `json:"role"`
+	MustChangePassword bool    `json:"must_change_password"`
 }
 
-// CreateUser creates a new user (admin only)
+// CreateUser creates a new user. Non-admin users create users in their own tenant by default
+// and cannot create admins.
 // @Summary Create user
 // @Tags auth
 // @Security BearerAuth
@@ -144,18 +195,354 @@ func (h *AuthHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
+	if !middleware.IsAdmin(c) {
+		if req.Role == model.RoleAdmin {
+			errorResponse(c, http.StatusForbidden, "admin role required to create admins", nil)
+			return
+		}
+		if req.TenantID == "" {
+			req.TenantID = middleware.GetTenantID(c)
+		}
+	}
+
 	createReq := &service.CreateUserRequest{
 		Username: req.Username,
 		Password: req.Password,
 		TenantID: req.TenantID,
 		Role:     req.Role,
+		MustChangePassword: req.MustChangePassword,
 	}
 
 	user, err := h.authService.CreateUser(c.Request.Context(), createReq)
 	if err != nil {
-		errorResponse(c, http.StatusInternalServerError, "failed to create user", err)
+		userError(c, err, "failed to create user")
+		return
+	}
+
+	success(c, user)
+}
+
+// ListUsers lists users with pagination. Non-admin users list their own tenant.
+// @Summary List users
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param tenant_id query string false "Tenant ID"
+// @Param role query string false "Role"
+// @Param is_active query bool false "Only active or only deactivated users"
+// @Param q query string false "Part of the username"
+// @Param page query int false "Page"
+// @Param page_size query int false "Page size"
+// @Success 200 {object} Response
+// @Router /auth/users [get]
+func (h *AuthHandler) ListUsers(c *gin.Context) {
+	filter := repository.UserFilter{
+		TenantID: c.Query("tenant_id"),
+		Role:     model.UserRole(c.Query("role")),
+		Query:    c.Query("q"),
+	}
+	if activeStr := c.Query("is_active"); activeStr != "" {
+		active, err := strconv.ParseBool(activeStr)
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, "invalid is_active", err)
+			return
+		}
+		filter.IsActive = &active
+	}
+	page, pageSize := parsePagination(c)
+
+	users, total, err := h.authService.ListUsers(c.Request.Context(), filter, page, pageSize)
+	if err != nil {
+		userError(c, err, "failed to list users")
+		return
+	}
+
+	success(c, gin.H{
+		"users":     users,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetUser retrieves a user by ID
+// @Summary Get user
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} model.UserResponse
+// @Router /auth/users/{id} [get]
+func (h *AuthHandler) GetUser(c *gin.Context) {
+	user, err := h.authService.GetUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		userError(c, err, "failed to get user")
+		return
+	}
+
+	success(c, user.ToResponse())
+}
+
+// UpdateUser changes the role or tenant of a user. Only admins can grant or change the admin role.
+// @Summary Update user
+// @Tags users
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param request body service.UpdateUserRequest true "Role and tenant"
+// @Success 200 {object} model.UserResponse
+// @Router /auth/users/{id} [put]
+func (h *AuthHandler) UpdateUser(c *gin.Context) {
+	var req service.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	if !h.canManage(c, false) {
+		return
+	}
+	if req.Role != nil && *req.Role == model.RoleAdmin && !middleware.IsAdmin(c) {
+		errorResponse(c, http.StatusForbidden, "admin role required to create admins", nil)
+		return
+	}
+
+	user, err := h.authService.UpdateUser(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		userError(c, err, "failed to update user")
+		return
+	}
+
+	success(c, user)
+}
+
+// DeactivateUser disables a user's login
+// @Summary Deactivate user
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} model.UserResponse
+// @Router /auth/users/{id}/deactivate [post]
+func (h *AuthHandler) DeactivateUser(c *gin.Context) {
+	h.setActive(c, false)
+}
+
+// ActivateUser re-enables a deactivated user
+// @Summary Reactivate user
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} model.UserResponse
+// @Router /auth/users/{id}/activate [post]
+func (h *AuthHandler) ActivateUser(c *gin.Context) {
+	h.setActive(c, true)
+}
+
+func (h *AuthHandler) setActive(c *gin.Context, active bool) {
+	if !h.canManage(c, !active) {
+		return
+	}
+
+	user, err := h.authService.SetUserActive(c.Request.Context(), c.Param("id"), active)
+	if err != nil {
+		userError(c, err, "failed to update user")
 		return
 	}
 
 	success(c, user)
 }
+
+// DeleteUser deletes a user and its role bindings
+// @Summary Delete user
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} Response
+// @Router /auth/users/{id} [delete]
+func (h *AuthHandler) DeleteUser(c *gin.Context) {
+	if !h.canManage(c, true) {
+		return
+	}
+
+	if err := h.authService.DeleteUser(c.Request.Context(), c.Param("id")); err != nil {
+		userError(c, err, "failed to delete user")
+		return
+	}
+
+	success(c, gin.H{"message": "user deleted"})
+}
+
+// ListUserSessions lists the active sessions of a user
+// @Summary List user sessions
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {array} service.SessionResponse
+// @Router /auth/users/{id}/sessions [get]
+func (h *AuthHandler) ListUserSessions(c *gin.Context) {
+	sessions, err := h.authService.ListUserSessions(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		userError(c, err, "failed to list sessions")
+		return
+	}
+
+	success(c, sessions)
+}
+
+// RevokeUserSessions revokes all sessions of a user, signing them out everywhere
+// @Summary Revoke all user sessions
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} Response
+// @Router /auth/users/{id}/sessions [delete]
+func (h *AuthHandler) RevokeUserSessions(c *gin.Context) {
+	if !h.canManage(c, false) {
+		return
+	}
+
+	if err := h.authService.RevokeUserSessions(c.Request.Context(), c.Param("id")); err != nil {
+		userError(c, err, "failed to revoke sessions")
+		return
+	}
+
+	success(c, gin.H{"message": "sessions revoked"})
+}
+
+// RevokeUserSession revokes one session of a user
+// @Summary Revoke user session
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Param session_id path string true "Session ID"
+// @Success 200 {object} Response
+// @Router /auth/users/{id}/sessions/{session_id} [delete]
+func (h *AuthHandler) RevokeUserSession(c *gin.Context) {
+	if !h.canManage(c, false) {
+		return
+	}
+
+	if err := h.authService.RevokeUserSession(c.Request.Context(), c.Param("id"), c.Param("session_id")); err != nil {
+		userError(c, err, "failed to revoke session")
+		return
+	}
+
+	success(c, gin.H{"message": "session revoked"})
+}
+
+// ResetPasswordRequest sets a user's password on their behalf
+type ResetPasswordRequest struct {
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ResetPassword sets a new password for a user without their current password
+// @Summary Reset user password
+// @Tags users
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param request body ResetPasswordRequest true "New password"
+// @Success 200 {object} Response
+// @Router /auth/users/{id}/password [post]
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	if !h.canManage(c, false) {
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), c.Param("id"), req.NewPassword); err != nil {
+		userError(c, err, "failed to reset password")
+		return
+	}
+
+	success(c, gin.H{"message": "password reset"})
+}
+
+// ChangePasswordRequest replaces the caller's own password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword changes the caller's own password after verifying the current one, revokes
+// all of the caller's sessions and returns the tokens of a new one. It is the only route a restricted token issued to a user who must change their
+// password can use, besides reading the current user and logging out.
+// @Summary Change own password
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} service.LoginResponse
+// @Router /auth/password [post]
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	resp, err := h.authService.ChangePassword(c.Request.Context(), middleware.GetUserID(c), req.CurrentPassword, req.NewPassword, clientInfo(c))
+	if err != nil {
+		userError(c, err, "failed to change password")
+		return
+	}
+
+	success(c, resp)
+}
+
+// canManage rejects changes to the user named by the id parameter that the caller may not
+// make: only admins manage admins, and nobody deactivates or deletes themselves. It reports
+// whether the change may go ahead.
+func (h *AuthHandler) canManage(c *gin.Context, removesAccess bool) bool {
+	target, err := h.authService.GetUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		userError(c, err, "failed to get user")
+		return false
+	}
+	if target.Role == model.RoleAdmin && !middleware.IsAdmin(c) {
+		errorResponse(c, http.StatusForbidden, "admin role required to manage admins", nil)
+		return false
+	}
+	if removesAccess && target.ID == middleware.GetUserID(c) {
+		errorResponse(c, http.StatusConflict, "users cannot deactivate or delete themselves", nil)
+		return false
+	}
+	return true
+}
+
+// userError writes the response for a failed user management request
+func userError(c *gin.Context, err error, fallback string) {
+	var policy *service.PasswordPolicyError
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		errorResponse(c, http.StatusNotFound, "user not found", err)
+	case errors.Is(err, service.ErrSessionNotFound):
+		errorResponse(c, http.StatusNotFound, "session not found", err)
+	case errors.Is(err, service.ErrTenantNotFound):
+		errorResponse(c, http.StatusBadRequest, "tenant not found", err)
+	case errors.Is(err, service.ErrTenantAccessDenied):
+		errorResponse(c, http.StatusForbidden, "access to another tenant is denied", err)
+	case errors.Is(err, service.ErrUsernameExists):
+		errorResponse(c, http.StatusConflict, "username already exists", err)
+	case errors.Is(err, service.ErrInvalidUserRole), errors.Is(err, service.ErrPasswordReused):
+		errorResponse(c, http.StatusBadRequest, err.Error(), err)
+	case errors.As(err, &policy):
+		errorResponse(c, http.StatusBadRequest, policy.Error(), err)
+	case errors.Is(err, service.ErrInvalidCurrentPassword):
+		errorResponse(c, http.StatusForbidden, err.Error(), err)
+	default:
+		errorResponse(c, http.StatusInternalServerError, fallback, err)
+	}
+}
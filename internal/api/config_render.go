@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/internal/domain"
+	"github.com/weibh/openClusterClaw/internal/service"
+)
+
+// ConfigRenderHandler handles config preview requests
+type ConfigRenderHandler struct {
+	service service.InstanceService
+}
+
+// NewConfigRenderHandler creates a new config render handler
+func NewConfigRenderHandler(service service.InstanceService) *ConfigRenderHandler {
+	return &ConfigRenderHandler{
+		service: service,
+	}
+}
+
+// RenderConfigRequest represents the request to preview a rendered config
+type RenderConfigRequest struct {
+	AdapterType      string               `json:"adapter_type" binding:"required"`
+	Version          string               `json:"version"`
+	TemplateName     string               `json:"template_name"`
+	TemplateRevision int                  `json:"template_revision"`
+	Overrides        map[string]string    `json:"overrides"`
+	Resources        *domain.ResourceSpec `json:"resources"`
+	// TenantID and ProjectID select the shared variables used by the preview
+	TenantID  string `json:"tenant_id"`
+	ProjectID string `json:"project_id"`
+}
+
+// Render renders a config without creating an instance
+// @Summary Preview rendered config
+// @Tags config-templates
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body RenderConfigRequest true "Adapter type, template and overrides"
+// @Success 200 {object} service.RenderConfigResult
+// @Router /configs/render [post]
+func (h *ConfigRenderHandler) Render(c *gin.Context) {
+	var req RenderConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	result, err := h.service.RenderConfig(c.Request.Context(), &service.RenderConfigRequest{
+		AdapterType:      req.AdapterType,
+		Version:          req.Version,
+		TemplateName:     req.TemplateName,
+		TemplateRevision: req.TemplateRevision,
+		Overrides:        req.Overrides,
+		Resources:        req.Resources,
+		TenantID:         req.TenantID,
+		ProjectID:        req.ProjectID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrTenantAccessDenied):
+			errorResponse(c, http.StatusForbidden, "access to another tenant is denied", err)
+		case errors.Is(err, service.ErrProjectAccessDenied):
+			errorResponse(c, http.StatusForbidden, "access to the project is denied", err)
+		case errors.Is(err, service.ErrAdapterNotFound):
+			errorResponse(c, http.StatusBadRequest, "adapter not found", err)
+		case errors.Is(err, service.ErrConfigTemplateNotFound):
+			errorResponse(c, http.StatusNotFound, "config template not found", err)
+		case errors.Is(err, service.ErrTemplateAdapterMismatch):
+			errorResponse(c, http.StatusBadRequest, "config template does not match adapter type", err)
+		case errors.Is(err, service.ErrTemplateIsFragment):
+			errorResponse(c, http.StatusBadRequest, "config template is a fragment and cannot be used directly", err)
+		case errors.Is(err, service.ErrTemplateRevisionNotFound):
+			errorResponse(c, http.StatusNotFound, "config template revision not found", err)
+		default:
+			errorResponse(c, http.StatusInternalServerError, "failed to render config", err)
+		}
+		return
+	}
+
+	success(c, result)
+}
@@ -2,10 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/internal/middleware"
 	"github.com/weibh/openClusterClaw/internal/model"
 	"github.com/weibh/openClusterClaw/internal/service"
 )
@@ -29,6 +32,10 @@ type CreateTemplateRequest struct {
 	Variables   []service.TemplateVariable   `json:"variables"`
 	AdapterType string                       `json:"adapter_type" binding:"required"`
 	Version     string                       `json:"version"`
+	Kind        string                       `json:"kind"`
+	ParentID    string                       `json:"parent_id"`
+	Fragments   []string                     `json:"fragments"`
+	Changelog   string                       `json:"changelog"`
 }
 
 // UpdateTemplateRequest represents the request to update a config template
@@ -37,6 +44,9 @@ type UpdateTemplateRequest struct {
 	Description *string                     `json:"description"`
 	Variables   *[]service.TemplateVariable `json:"variables"`
 	Version     *string                     `json:"version"`
+	ParentID    *string                     `json:"parent_id"`
+	Fragments   *[]string                   `json:"fragments"`
+	Changelog   string                      `json:"changelog"`
 }
 
 // ConfigTemplateResponse represents the config template response
@@ -47,10 +57,37 @@ type ConfigTemplateResponse struct {
 	Variables   []service.TemplateVariable  `json:"variables"`
 	AdapterType string                      `json:"adapter_type"`
 	Version     string                      `json:"version"`
+	Kind        string                      `json:"kind"`
+	ParentID    string                      `json:"parent_id,omitempty"`
+	Fragments   []string                    `json:"fragments,omitempty"`
+	// ResolvedVariables are the variables after merging the parent and fragments
+	ResolvedVariables []service.TemplateVariable `json:"resolved_variables,omitempty"`
+	Revision    int                         `json:"revision"`
+	ContentHash string                      `json:"content_hash"`
 	CreatedAt   string                      `json:"created_at"`
 	UpdatedAt   string                      `json:"updated_at"`
 }
 
+// TemplateRevisionResponse represents an immutable config template revision
+type TemplateRevisionResponse struct {
+	TemplateID    string                     `json:"template_id"`
+	Revision      int                        `json:"revision"`
+	ContentHash   string                     `json:"content_hash"`
+	Name          string                     `json:"name"`
+	Description   string                     `json:"description"`
+	Variables     []service.TemplateVariable `json:"variables"`
+	AdapterType   string                     `json:"adapter_type"`
+	Version       string                     `json:"version"`
+	Kind          string                     `json:"kind"`
+	ParentID      string                     `json:"parent_id,omitempty"`
+	Fragments     []string                   `json:"fragments,omitempty"`
+	ResolvedVariables []service.TemplateVariable `json:"resolved_variables,omitempty"`
+	Author        string                     `json:"author"`
+	Changelog     string                     `json:"changelog"`
+	InstanceCount *int                       `json:"instance_count,omitempty"`
+	CreatedAt     string                     `json:"created_at"`
+}
+
 // Create creates a new config template
 // @Summary Create config template
 // @Tags config-templates
@@ -73,6 +110,11 @@ func (h *ConfigTemplateHandler) Create(c *gin.Context) {
 		Variables:   req.Variables,
 		AdapterType: req.AdapterType,
 		Version:     req.Version,
+		Kind:        req.Kind,
+		ParentID:    req.ParentID,
+		Fragments:   req.Fragments,
+		Changelog:   req.Changelog,
+		Author:      middleware.GetUsername(c),
 	}
 
 	template, err := h.service.CreateTemplate(c.Request.Context(), createReq)
@@ -81,6 +123,10 @@ func (h *ConfigTemplateHandler) Create(c *gin.Context) {
 			errorResponse(c, http.StatusConflict, "config template name already exists", nil)
 			return
 		}
+		if isTemplateContentError(err) {
+			errorResponse(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
 		errorResponse(c, http.StatusInternalServerError, "failed to create config template", err)
 		return
 	}
@@ -176,6 +222,10 @@ func (h *ConfigTemplateHandler) Update(c *gin.Context) {
 		Description: req.Description,
 		Variables:   req.Variables,
 		Version:     req.Version,
+		ParentID:    req.ParentID,
+		Fragments:   req.Fragments,
+		Changelog:   req.Changelog,
+		Author:      middleware.GetUsername(c),
 	}
 
 	template, err := h.service.UpdateTemplate(c.Request.Context(), id, updateReq)
@@ -188,6 +238,10 @@ func (h *ConfigTemplateHandler) Update(c *gin.Context) {
 			errorResponse(c, http.StatusConflict, "config template name already exists", nil)
 			return
 		}
+		if isTemplateContentError(err) {
+			errorResponse(c, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
 		errorResponse(c, http.StatusInternalServerError, "failed to update config template", err)
 		return
 	}
@@ -206,13 +260,177 @@ func (h *ConfigTemplateHandler) Update(c *gin.Context) {
 func (h *ConfigTemplateHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.service.DeleteTemplate(c.Request.Context(), id); err != nil {
-		errorResponse(c, http.StatusNotFound, "config template not found", err)
+		if errors.Is(err, service.ErrTemplateInUse) {
+			errorResponse(c, http.StatusConflict, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, service.ErrConfigTemplateNotFound) {
+			errorResponse(c, http.StatusNotFound, "config template not found", err)
+			return
+		}
+		errorResponse(c, http.StatusInternalServerError, "failed to delete config template", err)
 		return
 	}
 
 	success(c, gin.H{"message": "config template deleted"})
 }
 
+// Revisions lists the revisions of a config template, newest first
+// @Summary List config template revisions
+// @Tags config-templates
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Config template ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(10)
+// @Success 200 {object} object
+// @Router /configs/{id}/revisions [get]
+func (h *ConfigTemplateHandler) Revisions(c *gin.Context) {
+	id := c.Param("id")
+	page, pageSize := parsePagination(c)
+
+	revisions, total, err := h.service.ListRevisions(c.Request.Context(), id, page, pageSize)
+	if err != nil {
+		if errors.Is(err, service.ErrConfigTemplateNotFound) {
+			errorResponse(c, http.StatusNotFound, "config template not found", err)
+			return
+		}
+		errorResponse(c, http.StatusInternalServerError, "failed to list config template revisions", err)
+		return
+	}
+
+	items := make([]*TemplateRevisionResponse, len(revisions))
+	for i, rev := range revisions {
+		items[i] = h.toRevisionResponse(rev.ConfigTemplateRevision)
+		count := rev.InstanceCount
+		items[i].InstanceCount = &count
+	}
+
+	success(c, gin.H{
+		"items":     items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// Revision retrieves a single config template revision
+// @Summary Get config template revision
+// @Tags config-templates
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Config template ID"
+// @Param revision path int true "Revision number"
+// @Success 200 {object} TemplateRevisionResponse
+// @Router /configs/{id}/revisions/{revision} [get]
+func (h *ConfigTemplateHandler) Revision(c *gin.Context) {
+	id := c.Param("id")
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || revision < 1 {
+		errorResponse(c, http.StatusBadRequest, "invalid revision", err)
+		return
+	}
+
+	rev, err := h.service.GetRevision(c.Request.Context(), id, revision)
+	if err != nil {
+		h.revisionError(c, err)
+		return
+	}
+
+	success(c, h.toRevisionResponse(rev))
+}
+
+// Diff compares two revisions of a config template
+// @Summary Diff config template revisions
+// @Tags config-templates
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Config template ID"
+// @Param from query int true "Base revision"
+// @Param to query int false "Target revision, defaults to the latest"
+// @Success 200 {object} service.TemplateRevisionDiff
+// @Router /configs/{id}/diff [get]
+func (h *ConfigTemplateHandler) Diff(c *gin.Context) {
+	id := c.Param("id")
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil || from < 1 {
+		errorResponse(c, http.StatusBadRequest, "invalid from revision", err)
+		return
+	}
+
+	var to int
+	if toStr := c.Query("to"); toStr != "" {
+		to, err = strconv.Atoi(toStr)
+		if err != nil || to < 1 {
+			errorResponse(c, http.StatusBadRequest, "invalid to revision", err)
+			return
+		}
+	} else {
+		template, err := h.service.GetTemplate(c.Request.Context(), id)
+		if err != nil {
+			errorResponse(c, http.StatusNotFound, "config template not found", err)
+			return
+		}
+		to = template.Revision
+	}
+
+	diff, err := h.service.DiffRevisions(c.Request.Context(), id, from, to)
+	if err != nil {
+		h.revisionError(c, err)
+		return
+	}
+
+	success(c, diff)
+}
+
+// isTemplateContentError reports whether err rejects the submitted template content
+func isTemplateContentError(err error) bool {
+	return errors.Is(err, service.ErrInvalidTemplateVariable) ||
+		errors.Is(err, service.ErrInvalidTemplateReference) ||
+		errors.Is(err, service.ErrTemplateCycle) ||
+		errors.Is(err, service.ErrTemplateConflict)
+}
+
+// revisionError maps revision lookup errors to responses
+func (h *ConfigTemplateHandler) revisionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrConfigTemplateNotFound):
+		errorResponse(c, http.StatusNotFound, "config template not found", err)
+	case errors.Is(err, service.ErrTemplateRevisionNotFound):
+		errorResponse(c, http.StatusNotFound, "config template revision not found", err)
+	default:
+		errorResponse(c, http.StatusInternalServerError, "failed to get config template revision", err)
+	}
+}
+
+// toRevisionResponse converts a config template revision model to response DTO
+func (h *ConfigTemplateHandler) toRevisionResponse(rev *model.ConfigTemplateRevision) *TemplateRevisionResponse {
+	resp := &TemplateRevisionResponse{
+		TemplateID:  rev.TemplateID,
+		Revision:    rev.Revision,
+		ContentHash: rev.ContentHash,
+		Name:        rev.Name,
+		Description: rev.Description,
+		AdapterType: rev.AdapterType,
+		Version:     rev.Version,
+		Kind:        rev.Kind,
+		ParentID:    rev.ParentID,
+		Author:      rev.Author,
+		Changelog:   rev.Changelog,
+		CreatedAt:   rev.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+	}
+
+	if len(rev.Variables) > 0 {
+		var variables []service.TemplateVariable
+		if err := json.Unmarshal(rev.Variables, &variables); err == nil {
+			resp.Variables = variables
+		}
+	}
+	decodeComposition(rev.Fragments, rev.ResolvedVariables, &resp.Fragments, &resp.ResolvedVariables)
+
+	return resp
+}
+
 // toResponse converts a config template model to response DTO
 func (h *ConfigTemplateHandler) toResponse(template *model.ConfigTemplate) *ConfigTemplateResponse {
 	resp := &ConfigTemplateResponse{
@@ -221,6 +439,10 @@ func (h *ConfigTemplateHandler) toResponse(template *model.ConfigTemplate) *Conf
 		Description: template.Description,
 		AdapterType: template.AdapterType,
 		Version:     template.Version,
+		Kind:        template.Kind,
+		ParentID:    template.ParentID,
+		Revision:    template.Revision,
+		ContentHash: template.ContentHash,
 		CreatedAt:   template.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt:   template.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
@@ -232,6 +454,17 @@ func (h *ConfigTemplateHandler) toResponse(template *model.ConfigTemplate) *Conf
 			resp.Variables = variables
 		}
 	}
+	decodeComposition(template.Fragments, template.ResolvedVariables, &resp.Fragments, &resp.ResolvedVariables)
 
 	return resp
+}
+
+// decodeComposition parses the stored fragment IDs and merged variables of a template
+func decodeComposition(fragmentsJSON, resolvedJSON []byte, fragments *[]string, resolved *[]service.TemplateVariable) {
+	if len(fragmentsJSON) > 0 {
+		_ = json.Unmarshal(fragmentsJSON, fragments)
+	}
+	if len(resolvedJSON) > 0 {
+		_ = json.Unmarshal(resolvedJSON, resolved)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/internal/middleware"
+	"github.com/weibh/openClusterClaw/internal/service"
+)
+
+// DeletionHandler handles requests on cascading tenant and project deletions
+type DeletionHandler struct {
+	service service.DeletionService
+}
+
+// NewDeletionHandler creates a new deletion handler
+func NewDeletionHandler(service service.DeletionService) *DeletionHandler {
+	return &DeletionHandler{service: service}
+}
+
+// List retrieves cascading deletions, newest first. Non-admin users only see the deletions of
+// their own tenant.
+// @Summary List deletions
+// @Tags deletions
+// @Security BearerAuth
+// @Produce json
+// @Param tenant_id query string false "Tenant ID"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Router /deletions [get]
+func (h *DeletionHandler) List(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+	tenantID := c.Query("tenant_id")
+	if !middleware.IsAdmin(c) {
+		tenantID = middleware.GetTenantID(c)
+	}
+
+	deletions, total, err := h.service.ListDeletions(c.Request.Context(), tenantID, page, pageSize)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "failed to list deletions", err)
+		return
+	}
+
+	success(c, gin.H{
+		"items":     deletions,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// Get retrieves a deletion with the report of what it covers
+// @Summary Get deletion
+// @Tags deletions
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Deletion ID"
+// @Success 200 {object} service.DeletionInfo
+// @Router /deletions/{id} [get]
+func (h *DeletionHandler) Get(c *gin.Context) {
+	deletion, err := h.service.GetDeletion(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		deletionError(c, err, "failed to get deletion")
+		return
+	}
+
+	success(c, deletion)
+}
+
+// Restore brings back the tenant or project of a deletion within its grace period
+// @Summary Restore deletion
+// @Tags deletions
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Deletion ID"
+// @Success 200 {object} service.DeletionInfo
+// @Router /deletions/{id}/restore [post]
+func (h *DeletionHandler) Restore(c *gin.Context) {
+	deletion, err := h.service.RestoreDeletion(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		deletionError(c, err, "failed to restore deletion")
+		return
+	}
+
+	success(c, deletion)
+}
+
+func deletionError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrDeletionNotFound):
+		errorResponse(c, http.StatusNotFound, "deletion not found", err)
+	case errors.Is(err, service.ErrDeletionNotRestorable):
+		errorResponse(c, http.StatusConflict, err.Error(), err)
+	default:
+		errorResponse(c, http.StatusInternalServerError, fallback, err)
+	}
+}
+
+// dependencyError writes the response for deletions refused because the tenant or project still
+// owns records, listing them. It reports whether err was handled.
+func dependencyError(c *gin.Context, err error) bool {
+	var dependent *service.DependencyError
+	if !errors.As(err, &dependent) {
+		return false
+	}
+	c.JSON(http.StatusConflict, ErrorResponse{
+		Code:         http.StatusConflict,
+		Message:      dependent.Error(),
+		Dependencies: dependent.Report,
+	})
+	return true
+}
@@ -1,8 +1,12 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/internal/adapter"
+	"github.com/weibh/openClusterClaw/internal/domain"
+	"github.com/weibh/openClusterClaw/internal/middleware"
 	"github.com/weibh/openClusterClaw/internal/service"
 	"net/http"
 )
@@ -28,9 +32,14 @@ type Response struct {
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Error   string `json:"error,omitempty"`
+	Code    int                  `json:"code"`
+	Message string               `json:"message"`
+	Error   string               `json:"error,omitempty"`
+	Errors  []adapter.FieldError `json:"errors,omitempty"`
+	// Quota names the quota dimension a rejected request would exceed
+	Quota *service.QuotaExceededError `json:"quota,omitempty"`
+	// Dependencies lists what keeps a tenant or project from being deleted
+	Dependencies *service.DependencyReport `json:"dependencies,omitempty"`
 }
 
 // success returns a success response
@@ -42,6 +51,15 @@ func success(c *gin.Context, data interface{}) {
 	})
 }
 
+// accepted returns the response for an operation that continues in the background
+func accepted(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusAccepted, Response{
+		Code:    0,
+		Message: "accepted",
+		Data:    data,
+	})
+}
+
 // errorResponse returns an error response
 func errorResponse(c *gin.Context, code int, message string, err error) {
 	resp := ErrorResponse{
@@ -54,6 +72,38 @@ func errorResponse(c *gin.Context, code int, message string, err error) {
 	c.JSON(code, resp)
 }
 
+// parsePagination reads the page and page_size query parameters, defaulting to page 1 of 10
+func parsePagination(c *gin.Context) (int, int) {
+	page := 1
+	pageSize := 10
+	if pageStr := c.Query("page"); pageStr != "" {
+		if _, err := fmt.Sscanf(pageStr, "%d", &page); err != nil || page < 1 {
+			page = 1
+		}
+	}
+	if pageSizeStr := c.Query("page_size"); pageSizeStr != "" {
+		if _, err := fmt.Sscanf(pageSizeStr, "%d", &pageSize); err != nil || pageSize < 1 {
+			pageSize = 10
+		}
+	}
+	return page, pageSize
+}
+
+// validationErrorResponse returns a 400 response listing the invalid config fields
+func validationErrorResponse(c *gin.Context, message string, err error) {
+	resp := ErrorResponse{
+		Code:    http.StatusBadRequest,
+		Message: message,
+	}
+	var fieldErrs adapter.ValidationErrors
+	if errors.As(err, &fieldErrs) {
+		resp.Errors = fieldErrs
+	} else if err != nil {
+		resp.Error = err.Error()
+	}
+	c.JSON(http.StatusBadRequest, resp)
+}
+
 // InstanceHandler handles instance-related requests
 type InstanceHandler struct {
 	service service.InstanceService
@@ -67,13 +117,16 @@ func NewInstanceHandler(service service.InstanceService) *InstanceHandler {
 // CreateInstanceRequest represents the request to create an instance
 type CreateInstanceRequest struct {
 	Name      string                 `json:"name" binding:"required"`
-	TenantID  string                 `json:"tenant_id" binding:"required"`
+	// TenantID defaults to the caller's tenant
+	TenantID  string                 `json:"tenant_id"`
 	ProjectID string                 `json:"project_id" binding:"required"`
 	Type      string                 `json:"type" binding:"required"`
 	Version   string                 `json:"version" binding:"required"`
-	Config    map[string]interface{} `json:"config"`
+	Config    *domain.InstanceConfig `json:"config"`
+	Labels    map[string]string      `json:"labels"`
 	CPU       string                 `json:"cpu"`
 	Memory    string                 `json:"memory"`
+	Storage   *domain.StorageSpec    `json:"storage"`
 }
 
 // Create creates a new instance
@@ -84,21 +137,118 @@ func (h *InstanceHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if req.TenantID == "" {
+		req.TenantID = middleware.GetTenantID(c)
+	}
+
 	instance, err := h.service.CreateInstance(c.Request.Context(), &service.CreateInstanceRequest{
 		Name:      req.Name,
 		TenantID:  req.TenantID,
 		ProjectID: req.ProjectID,
 		Type:      req.Type,
 		Version:   req.Version,
+		Config:    req.Config,
+		Labels:    req.Labels,
+		Resources: &domain.ResourceSpec{CPU: req.CPU, Memory: req.Memory},
+		Storage:   req.Storage,
+		Author:    middleware.GetUsername(c),
 	})
 	if err != nil {
-		errorResponse(c, http.StatusInternalServerError, "failed to create instance", err)
+		if errors.Is(err, service.ErrTenantAccessDenied) {
+			errorResponse(c, http.StatusForbidden, "access to another tenant is denied", err)
+		} else if errors.Is(err, service.ErrProjectAccessDenied) {
+			errorResponse(c, http.StatusForbidden, "access to the project is denied", err)
+		} else if errors.Is(err, service.ErrTenantNotFound) {
+			errorResponse(c, http.StatusBadRequest, "tenant not found", err)
+		} else if errors.Is(err, service.ErrProjectNotFound) {
+			errorResponse(c, http.StatusBadRequest, "project not found", err)
+		} else if !configError(c, err) && !quotaError(c, err) {
+			errorResponse(c, http.StatusInternalServerError, "failed to create instance", err)
+		}
 		return
 	}
 
 	success(c, instance)
 }
 
+// UpdateInstanceRequest represents the request to update an instance
+type UpdateInstanceRequest struct {
+	Name      *string                `json:"name"`
+	Config    *domain.InstanceConfig `json:"config"`
+	Resources *domain.ResourceSpec   `json:"resources"`
+	Labels    map[string]string      `json:"labels"`
+	Changelog string                 `json:"changelog"`
+}
+
+// Update updates an instance; a config change is applied as a new config revision
+func (h *InstanceHandler) Update(c *gin.Context) {
+	var req UpdateInstanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	instance, err := h.service.UpdateInstance(c.Request.Context(), c.Param("id"), &service.UpdateInstanceRequest{
+		Name:      req.Name,
+		Config:    req.Config,
+		Resources: req.Resources,
+		Labels:    req.Labels,
+		Changelog: req.Changelog,
+		Author:    middleware.GetUsername(c),
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInstanceNotFound) {
+			errorResponse(c, http.StatusNotFound, "instance not found", err)
+		} else if !configError(c, err) && !quotaError(c, err) {
+			errorResponse(c, http.StatusInternalServerError, "failed to update instance", err)
+		}
+		return
+	}
+
+	success(c, instance)
+}
+
+// configError writes the response for errors caused by an invalid instance config.
+// It reports whether err was handled.
+func configError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, adapter.ErrInvalidConfig):
+		validationErrorResponse(c, "invalid instance config", err)
+	case errors.Is(err, service.ErrConfigTemplateNotFound):
+		errorResponse(c, http.StatusBadRequest, "config template not found", err)
+	case errors.Is(err, service.ErrTemplateAdapterMismatch):
+		errorResponse(c, http.StatusBadRequest, "config template does not match instance type", err)
+	case errors.Is(err, service.ErrTemplateIsFragment):
+		errorResponse(c, http.StatusBadRequest, "config template is a fragment and cannot be used directly", err)
+	case errors.Is(err, service.ErrTemplateRevisionNotFound):
+		errorResponse(c, http.StatusBadRequest, "config template revision not found", err)
+	default:
+		return false
+	}
+	return true
+}
+
+// quotaError writes the response for requests rejected by tenant quota admission.
+// It reports whether err was handled.
+func quotaError(c *gin.Context, err error) bool {
+	var exceeded *service.QuotaExceededError
+	switch {
+	case errors.As(err, &exceeded):
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Code:    http.StatusForbidden,
+			Message: exceeded.Error(),
+			Quota:   exceeded,
+		})
+	case errors.Is(err, service.ErrInvalidQuantity):
+		errorResponse(c, http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, service.ErrTenantInactive):
+		errorResponse(c, http.StatusForbidden, err.Error(), err)
+	default:
+		return false
+	}
+	return true
+}
+
 // Get retrieves an instance by ID
 func (h *InstanceHandler) Get(c *gin.Context) {
 	id := c.Param("id")
@@ -123,6 +273,14 @@ func (h *InstanceHandler) List(c *gin.Context) {
 
 	instances, total, err := h.service.ListInstances(c.Request.Context(), tenantID, projectID, 1, 10)
 	if err != nil {
+		if errors.Is(err, service.ErrTenantAccessDenied) {
+			errorResponse(c, http.StatusForbidden, "access to another tenant is denied", err)
+			return
+		}
+		if errors.Is(err, service.ErrProjectAccessDenied) {
+			errorResponse(c, http.StatusForbidden, "access to the project is denied", err)
+			return
+		}
 		errorResponse(c, http.StatusInternalServerError, "failed to list instances", err)
 		return
 	}
@@ -139,7 +297,7 @@ func (h *InstanceHandler) List(c *gin.Context) {
 func (h *InstanceHandler) Start(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.service.StartInstance(c.Request.Context(), id); err != nil {
-		errorResponse(c, http.StatusBadRequest, "failed to start instance", err)
+		instanceActionError(c, err, "failed to start instance")
 		return
 	}
 
@@ -150,7 +308,7 @@ func (h *InstanceHandler) Start(c *gin.Context) {
 func (h *InstanceHandler) Stop(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.service.StopInstance(c.Request.Context(), id); err != nil {
-		errorResponse(c, http.StatusBadRequest, "failed to stop instance", err)
+		instanceActionError(c, err, "failed to stop instance")
 		return
 	}
 
@@ -161,7 +319,7 @@ func (h *InstanceHandler) Stop(c *gin.Context) {
 func (h *InstanceHandler) Restart(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.service.RestartInstance(c.Request.Context(), id); err != nil {
-		errorResponse(c, http.StatusBadRequest, "failed to restart instance", err)
+		instanceActionError(c, err, "failed to restart instance")
 		return
 	}
 
@@ -172,13 +330,33 @@ func (h *InstanceHandler) Restart(c *gin.Context) {
 func (h *InstanceHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
 	if err := h.service.DeleteInstance(c.Request.Context(), id); err != nil {
-		errorResponse(c, http.StatusBadRequest, "failed to delete instance", err)
+		instanceActionError(c, err, "failed to delete instance")
 		return
 	}
 
 	success(c, gin.H{"message": "instance deleted"})
 }
 
+// instanceActionError writes the response for a failed lifecycle action
+func instanceActionError(c *gin.Context, err error, message string) {
+	if errors.Is(err, service.ErrInstanceNotFound) {
+		errorResponse(c, http.StatusNotFound, "instance not found", err)
+	} else if !quotaError(c, err) {
+		errorResponse(c, http.StatusBadRequest, message, err)
+	}
+}
+
+// Health reports whether an instance is serving
+func (h *InstanceHandler) Health(c *gin.Context) {
+	health, err := h.service.GetInstanceHealth(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		errorResponse(c, http.StatusNotFound, "instance not found", err)
+		return
+	}
+
+	success(c, health)
+}
+
 // Logs retrieves logs for an instance
 func (h *InstanceHandler) Logs(c *gin.Context) {
 	id := c.Param("id")
@@ -190,6 +368,10 @@ func (h *InstanceHandler) Logs(c *gin.Context) {
 
 	logs, err := h.service.GetInstanceLogs(c.Request.Context(), id, tailLines)
 	if err != nil {
+		if errors.Is(err, service.ErrInstanceNotFound) {
+			errorResponse(c, http.StatusNotFound, "instance not found", err)
+			return
+		}
 		errorResponse(c, http.StatusInternalServerError, "failed to get logs", err)
 		return
 	}
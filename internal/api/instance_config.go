@@ -0,0 +1,226 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/internal/middleware"
+	"github.com/weibh/openClusterClaw/internal/service"
+)
+
+// RollbackConfigRequest represents the request to re-apply a previous config revision
+type RollbackConfigRequest struct {
+	Revision  int    `json:"revision" binding:"required,min=1"`
+	Changelog string `json:"changelog"`
+}
+
+// ConfigRevisions lists the config revisions applied to an instance, newest first
+// @Summary List instance config revisions
+// @Tags instances
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Instance ID"
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Router /instances/{id}/config/revisions [get]
+func (h *InstanceHandler) ConfigRevisions(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+
+	revisions, total, err := h.service.ListConfigRevisions(c.Request.Context(), c.Param("id"), page, pageSize)
+	if err != nil {
+		configRevisionError(c, err)
+		return
+	}
+
+	success(c, gin.H{
+		"items":     revisions,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// ConfigRevision retrieves a single config revision including the rendered files
+// @Summary Get instance config revision
+// @Tags instances
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Instance ID"
+// @Param revision path int true "Revision number"
+// @Success 200 {object} service.InstanceConfigRevisionInfo
+// @Router /instances/{id}/config/revisions/{revision} [get]
+func (h *InstanceHandler) ConfigRevision(c *gin.Context) {
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil || revision < 1 {
+		errorResponse(c, http.StatusBadRequest, "invalid revision", err)
+		return
+	}
+
+	info, err := h.service.GetConfigRevision(c.Request.Context(), c.Param("id"), revision)
+	if err != nil {
+		configRevisionError(c, err)
+		return
+	}
+
+	success(c, info)
+}
+
+// ConfigDiff compares two config revisions of an instance
+// @Summary Diff instance config revisions
+// @Tags instances
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Instance ID"
+// @Param from query int true "Base revision"
+// @Param to query int false "Target revision, defaults to the current one"
+// @Success 200 {object} service.InstanceConfigDiff
+// @Router /instances/{id}/config/diff [get]
+func (h *InstanceHandler) ConfigDiff(c *gin.Context) {
+	id := c.Param("id")
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil || from < 1 {
+		errorResponse(c, http.StatusBadRequest, "invalid from revision", err)
+		return
+	}
+
+	var to int
+	if toStr := c.Query("to"); toStr != "" {
+		to, err = strconv.Atoi(toStr)
+		if err != nil || to < 1 {
+			errorResponse(c, http.StatusBadRequest, "invalid to revision", err)
+			return
+		}
+	} else {
+		instance, err := h.service.GetInstance(c.Request.Context(), id)
+		if err != nil {
+			errorResponse(c, http.StatusNotFound, "instance not found", err)
+			return
+		}
+		to = instance.ConfigRevision
+	}
+
+	diff, err := h.service.DiffConfigRevisions(c.Request.Context(), id, from, to)
+	if err != nil {
+		configRevisionError(c, err)
+		return
+	}
+
+	success(c, diff)
+}
+
+// RollbackConfig re-applies the rendered output of a previous config revision as a new revision
+// @Summary Roll back instance config
+// @Tags instances
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Instance ID"
+// @Param request body RollbackConfigRequest true "Rollback request"
+// @Router /instances/{id}/config/rollback [post]
+func (h *InstanceHandler) RollbackConfig(c *gin.Context) {
+	var req RollbackConfigRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	instance, err := h.service.RollbackConfig(c.Request.Context(), c.Param("id"), &service.ConfigRollbackRequest{
+		Revision:  req.Revision,
+		Changelog: req.Changelog,
+		Author:    middleware.GetUsername(c),
+	})
+	if err != nil {
+		configRevisionError(c, err)
+		return
+	}
+
+	success(c, instance)
+}
+
+// configRevisionError maps config revision errors to responses
+func configRevisionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrInstanceNotFound):
+		errorResponse(c, http.StatusNotFound, "instance not found", err)
+	case errors.Is(err, service.ErrConfigRevisionNotFound):
+		errorResponse(c, http.StatusNotFound, "instance config revision not found", err)
+	default:
+		errorResponse(c, http.StatusInternalServerError, "failed to process instance config revision", err)
+	}
+}
+
+// ConfigDrift compares the applied config of an instance with its live ConfigMap and pod
+// @Summary Check instance config drift
+// @Tags instances
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Instance ID"
+// @Success 200 {object} service.ConfigDrift
+// @Router /instances/{id}/config/drift [get]
+func (h *InstanceHandler) ConfigDrift(c *gin.Context) {
+	h.configDrift(c, false)
+}
+
+// CorrectConfigDrift rewrites a drifted ConfigMap from the current config revision
+// @Summary Correct instance config drift
+// @Tags instances
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Instance ID"
+// @Success 200 {object} service.ConfigDrift
+// @Router /instances/{id}/config/drift/correct [post]
+func (h *InstanceHandler) CorrectConfigDrift(c *gin.Context) {
+	h.configDrift(c, true)
+}
+
+func (h *InstanceHandler) configDrift(c *gin.Context, correct bool) {
+	report, err := h.service.CheckConfigDrift(c.Request.Context(), c.Param("id"), correct)
+	if err != nil {
+		configRevisionError(c, err)
+		return
+	}
+
+	success(c, report)
+}
+
+// ListConfigDrift checks the instances of a tenant or project for config drift
+// @Summary List instance config drift
+// @Tags instances
+// @Security BearerAuth
+// @Produce json
+// @Param tenant_id query string false "Tenant ID"
+// @Param project_id query string false "Project ID"
+// @Param drifted query bool false "Only return drifted instances"
+// @Router /instances/drift [get]
+func (h *InstanceHandler) ListConfigDrift(c *gin.Context) {
+	reports, err := h.service.ListConfigDrift(c.Request.Context(), c.Query("tenant_id"), c.Query("project_id"), false)
+	if err != nil {
+		if errors.Is(err, service.ErrTenantAccessDenied) {
+			errorResponse(c, http.StatusForbidden, "access to another tenant is denied", err)
+			return
+		}
+		if errors.Is(err, service.ErrProjectAccessDenied) {
+			errorResponse(c, http.StatusForbidden, "access to the project is denied", err)
+			return
+		}
+		errorResponse(c, http.StatusInternalServerError, "failed to check config drift", err)
+		return
+	}
+
+	if c.Query("drifted") == "true" {
+		drifted := reports[:0]
+		for _, report := range reports {
+			if report.Drifted {
+				drifted = append(drifted, report)
+			}
+		}
+		reports = drifted
+	}
+
+	success(c, gin.H{
+		"items": reports,
+		"total": len(reports),
+	})
+}
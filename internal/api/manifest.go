@@ -0,0 +1,98 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/internal/middleware"
+	"github.com/weibh/openClusterClaw/internal/service"
+)
+
+// maxManifestSize limits the size of an apply request body
+const maxManifestSize = 10 << 20
+
+// ManifestHandler handles manifest export and apply requests
+type ManifestHandler struct {
+	service service.ManifestService
+}
+
+// NewManifestHandler creates a new manifest handler
+func NewManifestHandler(service service.ManifestService) *ManifestHandler {
+	return &ManifestHandler{service: service}
+}
+
+// Export returns tenants, projects, templates and instances as YAML manifests
+// @Summary Export manifests
+// @Tags manifests
+// @Security BearerAuth
+// @Produce application/yaml
+// @Param kind query []string false "Tenant, Project, ConfigTemplate or Instance; repeat or comma-separate for several"
+// @Param tenant query string false "Tenant name; templates are not exported in a tenant scope"
+// @Success 200 {string} string "YAML documents"
+// @Router /manifests [get]
+func (h *ManifestHandler) Export(c *gin.Context) {
+	filter := &service.ManifestFilter{Tenant: c.Query("tenant")}
+	for _, kinds := range c.QueryArray("kind") {
+		for _, kind := range strings.Split(kinds, ",") {
+			if kind = strings.TrimSpace(kind); kind != "" {
+				filter.Kinds = append(filter.Kinds, kind)
+			}
+		}
+	}
+
+	data, err := h.service.Export(c.Request.Context(), filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidManifest):
+			errorResponse(c, http.StatusBadRequest, err.Error(), err)
+		case errors.Is(err, service.ErrTenantNotFound):
+			errorResponse(c, http.StatusNotFound, "tenant not found", err)
+		default:
+			errorResponse(c, http.StatusInternalServerError, "failed to export manifests", err)
+		}
+		return
+	}
+
+	c.Data(http.StatusOK, "application/yaml; charset=utf-8", data)
+}
+
+// Apply creates, updates and optionally prunes resources to match YAML manifests
+// @Summary Apply manifests
+// @Tags manifests
+// @Security BearerAuth
+// @Accept application/yaml
+// @Produce json
+// @Param dry_run query bool false "Only report the planned changes"
+// @Param prune query bool false "Delete resources of the applied kinds that the manifests do not define"
+// @Param tenant query string false "Restrict the manifests and pruning to one tenant"
+// @Param request body string true "YAML documents"
+// @Success 200 {object} service.ManifestApplyResult
+// @Router /manifests/apply [post]
+func (h *ManifestHandler) Apply(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxManifestSize)
+	data, err := c.GetRawData()
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	opts := &service.ManifestApplyOptions{
+		DryRun: c.Query("dry_run") == "true",
+		Prune:  c.Query("prune") == "true",
+		Tenant: c.Query("tenant"),
+		Author: middleware.GetUsername(c),
+	}
+	result, err := h.service.Apply(c.Request.Context(), data, opts)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidManifest) {
+			errorResponse(c, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		errorResponse(c, http.StatusInternalServerError, "failed to apply manifests", err)
+		return
+	}
+
+	success(c, result)
+}
@@ -150,6 +150,7 @@ func (h *OTPHandler) VerifyOTP(c *gin.Context) {
 	verifyReq := &service.VerifyOTPRequest{
 		TempToken: req.TempToken,
 		Code:      req.Code,
+		Client:    clientInfo(c),
 	}
 
 	resp, err := h.otpService.VerifyOTP(c.Request.Context(), verifyReq)
@@ -230,6 +231,7 @@ func (h *OTPHandler) LoginWithOTP(c *gin.Context) {
 	loginReq := &service.LoginRequest{
 		Username: req.Username,
 		Password: req.Password,
+		Client:   clientInfo(c),
 	}
 
 	resp, err := h.authService.LoginWithOTP(c.Request.Context(), loginReq)
@@ -0,0 +1,479 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/internal/middleware"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
+	"github.com/weibh/openClusterClaw/internal/service"
+)
+
+// RBACHandler handles role and role binding requests
+type RBACHandler struct {
+	service service.RBACService
+}
+
+// NewRBACHandler creates a new role and role binding handler
+func NewRBACHandler(service service.RBACService) *RBACHandler {
+	return &RBACHandler{service: service}
+}
+
+// ListRoles retrieves the built-in and custom roles
+// @Summary List roles
+// @Tags rbac
+// @Security BearerAuth
+// @Produce json
+// @Router /roles [get]
+func (h *RBACHandler) ListRoles(c *gin.Context) {
+	roles, err := h.service.ListRoles(c.Request.Context())
+	if err != nil {
+		rbacError(c, err, "failed to list roles")
+		return
+	}
+
+	success(c, gin.H{
+		"items": roles,
+		"total": len(roles),
+	})
+}
+
+// GetRole retrieves a role by name
+// @Summary Get role
+// @Tags rbac
+// @Security BearerAuth
+// @Produce json
+// @Param name path string true "Role name"
+// @Success 200 {object} service.RoleInfo
+// @Router /roles/{name} [get]
+func (h *RBACHandler) GetRole(c *gin.Context) {
+	role, err := h.service.GetRole(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		rbacError(c, err, "failed to get role")
+		return
+	}
+
+	success(c, role)
+}
+
+// CreateRole defines a custom role
+// @Summary Create role
+// @Tags rbac
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body service.CreateRoleRequest true "Role"
+// @Success 200 {object} service.RoleInfo
+// @Router /roles [post]
+func (h *RBACHandler) CreateRole(c *gin.Context) {
+	var req service.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	role, err := h.service.CreateRole(c.Request.Context(), &req)
+	if err != nil {
+		rbacError(c, err, "failed to create role")
+		return
+	}
+
+	success(c, role)
+}
+
+// UpdateRole changes the description or permissions of a custom role
+// @Summary Update role
+// @Tags rbac
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param name path string true "Role name"
+// @Param request body service.UpdateRoleRequest true "Role changes"
+// @Success 200 {object} service.RoleInfo
+// @Router /roles/{name} [put]
+func (h *RBACHandler) UpdateRole(c *gin.Context) {
+	var req service.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	role, err := h.service.UpdateRole(c.Request.Context(), c.Param("name"), &req)
+	if err != nil {
+		rbacError(c, err, "failed to update role")
+		return
+	}
+
+	success(c, role)
+}
+
+// DeleteRole deletes a custom role that is not bound to any user
+// @Summary Delete role
+// @Tags rbac
+// @Security BearerAuth
+// @Param name path string true "Role name"
+// @Success 200 {object} Response
+// @Router /roles/{name} [delete]
+func (h *RBACHandler) DeleteRole(c *gin.Context) {
+	if err := h.service.DeleteRole(c.Request.Context(), c.Param("name")); err != nil {
+		rbacError(c, err, "failed to delete role")
+		return
+	}
+
+	success(c, gin.H{"message": "role deleted successfully"})
+}
+
+// ListBindings retrieves role bindings. Non-admin users only see the bindings of their own
+// tenant.
+// @Summary List role bindings
+// @Tags rbac
+// @Security BearerAuth
+// @Produce json
+// @Param user_id query string false "User ID"
+// @Param role query string false "Role name"
+// @Param tenant_id query string false "Tenant ID"
+// @Router /role-bindings [get]
+func (h *RBACHandler) ListBindings(c *gin.Context) {
+	filter := repository.RoleBindingFilter{
+		UserID:   c.Query("user_id"),
+		Role:     c.Query("role"),
+		TenantID: c.Query("tenant_id"),
+	}
+	if !middleware.IsAdmin(c) {
+		filter.TenantID = middleware.GetTenantID(c)
+	}
+
+	bindings, err := h.service.ListBindings(c.Request.Context(), filter)
+	if err != nil {
+		rbacError(c, err, "failed to list role bindings")
+		return
+	}
+
+	success(c, gin.H{
+		"items": bindings,
+		"total": len(bindings),
+	})
+}
+
+// CreateBinding grants a user a role at global, tenant or project scope. Callers can only
+// grant permissions they hold themselves at that scope.
+// @Summary Create role binding
+// @Tags rbac
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body service.CreateRoleBindingRequest true "Role binding"
+// @Success 200 {object} model.RoleBinding
+// @Router /role-bindings [post]
+func (h *RBACHandler) CreateBinding(c *gin.Context) {
+	var req service.CreateRoleBindingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	req.Author = middleware.GetUsername(c)
+
+	binding, err := h.service.CreateBinding(c.Request.Context(), middleware.GetSubject(c), &req)
+	if err != nil {
+		rbacError(c, err, "failed to create role binding")
+		return
+	}
+
+	success(c, binding)
+}
+
+// DeleteBinding revokes a role binding
+// @Summary Delete role binding
+// @Tags rbac
+// @Security BearerAuth
+// @Param id path string true "Role binding ID"
+// @Success 200 {object} Response
+// @Router /role-bindings/{id} [delete]
+func (h *RBACHandler) DeleteBinding(c *gin.Context) {
+	if err := h.service.DeleteBinding(c.Request.Context(), middleware.GetSubject(c), c.Param("id")); err != nil {
+		rbacError(c, err, "failed to delete role binding")
+		return
+	}
+
+	success(c, gin.H{"message": "role binding deleted successfully"})
+}
+
+// ListProjectMembers retrieves the members of a project and their roles
+// @Summary List project members
+// @Tags rbac
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Project ID"
+// @Router /projects/{id}/members [get]
+func (h *RBACHandler) ListProjectMembers(c *gin.Context) {
+	members, err := h.service.ListProjectMembers(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		rbacError(c, err, "failed to list project members")
+		return
+	}
+
+	success(c, gin.H{
+		"items": members,
+		"total": len(members),
+	})
+}
+
+// SetProjectMember adds a user of the project's tenant to the project with a role, or changes
+// the role of an existing member
+// @Summary Add or update project member
+// @Tags rbac
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Project ID"
+// @Param request body service.SetProjectMemberRequest true "Member"
+// @Success 200 {object} service.ProjectMember
+// @Router /projects/{id}/members [post]
+func (h *RBACHandler) SetProjectMember(c *gin.Context) {
+	var req service.SetProjectMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	req.Author = middleware.GetUsername(c)
+
+	member, err := h.service.SetProjectMember(c.Request.Context(), middleware.GetSubject(c), c.Param("id"), &req)
+	if err != nil {
+		rbacError(c, err, "failed to set project member")
+		return
+	}
+
+	success(c, member)
+}
+
+// RemoveProjectMember removes a user from a project
+// @Summary Remove project member
+// @Tags rbac
+// @Security BearerAuth
+// @Param id path string true "Project ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} Response
+// @Router /projects/{id}/members/{user_id} [delete]
+func (h *RBACHandler) RemoveProjectMember(c *gin.Context) {
+	if err := h.service.RemoveProjectMember(c.Request.Context(), middleware.GetSubject(c), c.Param("id"), c.Param("user_id")); err != nil {
+		rbacError(c, err, "failed to remove project member")
+		return
+	}
+
+	success(c, gin.H{"message": "project member removed successfully"})
+}
+
+func rbacError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrRoleNotFound), errors.Is(err, service.ErrBindingNotFound),
+		errors.Is(err, service.ErrProjectNotFound), errors.Is(err, service.ErrMemberNotFound):
+		errorResponse(c, http.StatusNotFound, err.Error(), err)
+	case errors.Is(err, service.ErrInvalidRole), errors.Is(err, service.ErrInvalidBinding):
+		errorResponse(c, http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, service.ErrPermissionDenied):
+		errorResponse(c, http.StatusForbidden, err.Error(), err)
+	case errors.Is(err, service.ErrRoleExists), errors.Is(err, service.ErrRoleBuiltIn),
+		errors.Is(err, service.ErrRoleInUse), errors.Is(err, service.ErrBindingExists):
+		errorResponse(c, http.StatusConflict, err.Error(), err)
+	default:
+		errorResponse(c, http.StatusInternalServerError, fallback, err)
+	}
+}
+
+// scopeResolvers find the tenant and project that requests act on for route authorization
+type scopeResolvers struct {
+	instances service.InstanceService
+	projects  service.ProjectService
+	deletions service.DeletionService
+	users     *service.AuthService
+	variables service.VariableService
+}
+
+// instance resolves routes on the instance named by the id parameter. Instances outside the
+// caller's tenant scope are not found.
+func (r *scopeResolvers) instance(c *gin.Context) (service.AccessScope, bool) {
+	instance, err := r.instances.GetInstance(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		errorResponse(c, http.StatusNotFound, "instance not found", err)
+		return service.AccessScope{}, false
+	}
+	return service.AccessScope{TenantID: instance.TenantID, ProjectID: instance.ProjectID}, true
+}
+
+// project resolves routes on the project named by the id parameter
+func (r *scopeResolvers) project(c *gin.Context) (service.AccessScope, bool) {
+	project, err := r.projects.GetProject(c.Request.Context(), c.Param("id"))
+	if err != nil || !repository.InTenantScope(c.Request.Context(), project.TenantID) {
+		errorResponse(c, http.StatusNotFound, "project not found", err)
+		return service.AccessScope{}, false
+	}
+	return service.AccessScope{TenantID: project.TenantID, ProjectID: project.ID}, true
+}
+
+// deletion resolves routes on the deletion named by the id parameter. Project deletions are
+// scoped to the project; tenant deletions are global, since the tenant's own bindings are gone
+// with it.
+func (r *scopeResolvers) deletion(c *gin.Context) (service.AccessScope, bool) {
+	deletion, err := r.deletions.GetDeletion(c.Request.Context(), c.Param("id"))
+	if err != nil || !repository.InTenantScope(c.Request.Context(), deletion.TenantID) {
+		errorResponse(c, http.StatusNotFound, "deletion not found", err)
+		return service.AccessScope{}, false
+	}
+	if deletion.Kind == model.DeletionKindTenant {
+		return service.AccessScope{}, true
+	}
+	return service.AccessScope{TenantID: deletion.TenantID, ProjectID: deletion.TargetID}, true
+}
+
+// user resolves routes on the user named by the id parameter. Users outside the caller's
+// tenant scope are not found.
+func (r *scopeResolvers) user(c *gin.Context) (service.AccessScope, bool) {
+	user, err := r.users.GetUser(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		errorResponse(c, http.StatusNotFound, "user not found", err)
+		return service.AccessScope{}, false
+	}
+	return service.AccessScope{TenantID: user.TenantID}, true
+}
+
+// tenant resolves routes on the tenant named by the id parameter
+func (r *scopeResolvers) tenant(c *gin.Context) (service.AccessScope, bool) {
+	return service.AccessScope{TenantID: c.Param("id")}, true
+}
+
+// query resolves list routes from the tenant_id and project_id query parameters. Non-admin
+// users list their own tenant unless they name another.
+func (r *scopeResolvers) query(c *gin.Context) (service.AccessScope, bool) {
+	return r.within(c, c.Query("tenant_id"), c.Query("project_id")), true
+}
+
+// listing resolves instance list routes like query. Without a project, the listing is narrowed
+// to the projects the caller can see, so project members may list too.
+func (r *scopeResolvers) listing(c *gin.Context) (service.AccessScope, bool) {
+	scope := r.within(c, c.Query("tenant_id"), c.Query("project_id"))
+	scope.AnyProject = c.Query("project_id") == ""
+	return scope, true
+}
+
+// body resolves create routes from the tenant_id and project_id fields of the JSON body,
+// leaving the body in place for the handler
+func (r *scopeResolvers) body(c *gin.Context) (service.AccessScope, bool) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return service.AccessScope{}, false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+
+	var target struct {
+		TenantID  string `json:"tenant_id"`
+		ProjectID string `json:"project_id"`
+	}
+	// Malformed bodies are rejected by the handler's own binding
+	_ = json.Unmarshal(data, &target)
+	return r.within(c, target.TenantID, target.ProjectID), true
+}
+
+// variable resolves routes on the shared variable named by the id parameter. Variables whose
+// tenant is outside the caller's tenant scope are not found.
+func (r *scopeResolvers) variable(c *gin.Context) (service.AccessScope, bool) {
+	variable, err := r.variables.GetVariable(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		errorResponse(c, http.StatusNotFound, "variable not found", err)
+		return service.AccessScope{}, false
+	}
+	scope, err := r.variableScope(c, variable.Scope, variable.ScopeID)
+	if err != nil {
+		errorResponse(c, http.StatusNotFound, "variable not found", err)
+		return service.AccessScope{}, false
+	}
+	return scope, true
+}
+
+// variableBody resolves variable create routes from the scope and scope_id fields of the JSON
+// body, leaving the body in place for the handler
+func (r *scopeResolvers) variableBody(c *gin.Context) (service.AccessScope, bool) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return service.AccessScope{}, false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+
+	var target struct {
+		Scope   model.VariableScope `json:"scope"`
+		ScopeID string              `json:"scope_id"`
+	}
+	// Malformed bodies are rejected by the handler's own binding
+	_ = json.Unmarshal(data, &target)
+	scope, err := r.variableScope(c, target.Scope, target.ScopeID)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error(), err)
+		return service.AccessScope{}, false
+	}
+	return scope, true
+}
+
+// variableQuery resolves variable list routes from the scope and scope_id query parameters.
+// Listings that do not name one tenant, project or instance span tenants and are global.
+func (r *scopeResolvers) variableQuery(c *gin.Context) (service.AccessScope, bool) {
+	scopeID := c.Query("scope_id")
+	if scopeID == "" {
+		return service.AccessScope{}, true
+	}
+	scope, err := r.variableScope(c, model.VariableScope(c.Query("scope")), scopeID)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, err.Error(), err)
+		return service.AccessScope{}, false
+	}
+	return scope, true
+}
+
+// variableScope maps the scope a variable is defined at to the tenant and project it belongs
+// to. Global variables affect every tenant, so only global bindings cover them; instance
+// variables belong to the instance's project.
+func (r *scopeResolvers) variableScope(c *gin.Context, scope model.VariableScope, scopeID string) (service.AccessScope, error) {
+	ctx := c.Request.Context()
+	var access service.AccessScope
+	switch scope {
+	case model.VariableScopeGlobal:
+		return access, nil
+	case model.VariableScopeTenant:
+		access.TenantID = scopeID
+	case model.VariableScopeProject:
+		if project, err := r.projects.GetProject(ctx, scopeID); err == nil {
+			access = service.AccessScope{TenantID: project.TenantID, ProjectID: project.ID}
+		}
+	case model.VariableScopeInstance:
+		if instance, err := r.instances.GetInstance(ctx, scopeID); err == nil {
+			access = service.AccessScope{TenantID: instance.TenantID, ProjectID: instance.ProjectID}
+		}
+	default:
+		return access, fmt.Errorf("%w: unknown scope %q", service.ErrInvalidVariable, scope)
+	}
+	if scopeID == "" || access.TenantID == "" || !repository.InTenantScope(ctx, access.TenantID) {
+		return service.AccessScope{}, fmt.Errorf("%w: %s %q not found", service.ErrInvalidVariable, scope, scopeID)
+	}
+	return access, nil
+}
+
+// within builds the scope of a tenant and optional project. A project only narrows the scope
+// when it belongs to the tenant, so a project binding cannot reach into another tenant.
+func (r *scopeResolvers) within(c *gin.Context, tenantID, projectID string) service.AccessScope {
+	if tenantID == "" && !middleware.IsAdmin(c) {
+		tenantID = middleware.GetTenantID(c)
+	}
+	scope := service.AccessScope{TenantID: tenantID}
+	if projectID != "" {
+		if project, err := r.projects.GetProject(c.Request.Context(), projectID); err == nil && project.TenantID == tenantID {
+			scope.ProjectID = project.ID
+		}
+	}
+	return scope
+}
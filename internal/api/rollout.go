@@ -0,0 +1,140 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/internal/middleware"
+	"github.com/weibh/openClusterClaw/internal/service"
+)
+
+// RolloutHandler handles config rollout requests
+type RolloutHandler struct {
+	service service.RolloutService
+}
+
+// NewRolloutHandler creates a new rollout handler
+func NewRolloutHandler(service service.RolloutService) *RolloutHandler {
+	return &RolloutHandler{service: service}
+}
+
+// CreateRolloutRequest represents the request to start a config rollout
+type CreateRolloutRequest struct {
+	Name     string                  `json:"name" binding:"required"`
+	Selector service.RolloutSelector `json:"selector"`
+	Patch    service.ConfigPatch     `json:"patch"`
+	Strategy service.RolloutStrategy `json:"strategy"`
+}
+
+// Create starts a config rollout; it runs in the background and is polled with Get
+// @Summary Create config rollout
+// @Tags rollouts
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body CreateRolloutRequest true "Rollout"
+// @Success 200 {object} service.RolloutInfo
+// @Router /rollouts [post]
+func (h *RolloutHandler) Create(c *gin.Context) {
+	var req CreateRolloutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+
+	rollout, err := h.service.CreateRollout(c.Request.Context(), &service.CreateRolloutRequest{
+		Name:     req.Name,
+		Selector: req.Selector,
+		Patch:    req.Patch,
+		Strategy: req.Strategy,
+		Author:   middleware.GetUsername(c),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRollout):
+			errorResponse(c, http.StatusBadRequest, err.Error(), err)
+		case errors.Is(err, service.ErrConfigTemplateNotFound):
+			errorResponse(c, http.StatusBadRequest, "config template not found", err)
+		case errors.Is(err, service.ErrRolloutConflict):
+			errorResponse(c, http.StatusConflict, err.Error(), err)
+		default:
+			errorResponse(c, http.StatusInternalServerError, "failed to create config rollout", err)
+		}
+		return
+	}
+
+	success(c, rollout)
+}
+
+// List retrieves config rollouts, newest first
+// @Summary List config rollouts
+// @Tags rollouts
+// @Security BearerAuth
+// @Produce json
+// @Param page query int false "Page number"
+// @Param page_size query int false "Page size"
+// @Router /rollouts [get]
+func (h *RolloutHandler) List(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+
+	rollouts, total, err := h.service.ListRollouts(c.Request.Context(), page, pageSize)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "failed to list config rollouts", err)
+		return
+	}
+
+	success(c, gin.H{
+		"items":     rollouts,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// Get retrieves a config rollout with the status of every target
+// @Summary Get config rollout
+// @Tags rollouts
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Rollout ID"
+// @Success 200 {object} service.RolloutInfo
+// @Router /rollouts/{id} [get]
+func (h *RolloutHandler) Get(c *gin.Context) {
+	rollout, err := h.service.GetRollout(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrRolloutNotFound) {
+			errorResponse(c, http.StatusNotFound, "config rollout not found", err)
+			return
+		}
+		errorResponse(c, http.StatusInternalServerError, "failed to get config rollout", err)
+		return
+	}
+
+	success(c, rollout)
+}
+
+// Abort stops a running config rollout and rolls back the instances it changed
+// @Summary Abort config rollout
+// @Tags rollouts
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Rollout ID"
+// @Success 200 {object} service.RolloutInfo
+// @Router /rollouts/{id}/abort [post]
+func (h *RolloutHandler) Abort(c *gin.Context) {
+	rollout, err := h.service.AbortRollout(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRolloutNotFound):
+			errorResponse(c, http.StatusNotFound, "config rollout not found", err)
+		case errors.Is(err, service.ErrRolloutFinished):
+			errorResponse(c, http.StatusConflict, "config rollout already finished", err)
+		default:
+			errorResponse(c, http.StatusInternalServerError, "failed to abort config rollout", err)
+		}
+		return
+	}
+
+	success(c, rollout)
+}
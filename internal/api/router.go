@@ -21,6 +21,17 @@ type Router struct {
 	configHandler   *ConfigTemplateHandler
 	tenantHandler   *TenantHandler
 	projectHandler  *ProjectHandler
+	adapterHandler  *AdapterHandler
+	renderHandler   *ConfigRenderHandler
+	rolloutHandler  *RolloutHandler
+	variableHandler *VariableHandler
+	manifestHandler *ManifestHandler
+	rbacHandler     *RBACHandler
+	deletionHandler *DeletionHandler
+	rbacService     service.RBACService
+	tenantService   service.TenantService
+	authService     *service.AuthService
+	resolvers       *scopeResolvers
 	engine          *gin.Engine
 	jwtService      *jwt.JWTService
 }
@@ -31,6 +42,12 @@ func NewRouter(
 	configTemplateService service.ConfigTemplateService,
 	tenantService service.TenantService,
 	projectService service.ProjectService,
+	adapterService service.AdapterService,
+	rolloutService service.RolloutService,
+	variableService service.VariableService,
+	manifestService service.ManifestService,
+	rbacService service.RBACService,
+	deletionService service.DeletionService,
 	authService *service.AuthService,
 	jwtService *jwt.JWTService,
 	userRepo *repository.UserRepository,
@@ -39,8 +56,15 @@ func NewRouter(
 	handler := NewHandler(instanceService)
 	authHandler := NewAuthHandler(authService)
 	configHandler := NewConfigTemplateHandler(configTemplateService)
-	tenantHandler := NewTenantHandler(tenantService)
-	projectHandler := NewProjectHandler(projectService)
+	tenantHandler := NewTenantHandler(tenantService, deletionService)
+	projectHandler := NewProjectHandler(projectService, deletionService)
+	adapterHandler := NewAdapterHandler(adapterService)
+	renderHandler := NewConfigRenderHandler(instanceService)
+	rolloutHandler := NewRolloutHandler(rolloutService)
+	variableHandler := NewVariableHandler(variableService)
+	manifestHandler := NewManifestHandler(manifestService)
+	rbacHandler := NewRBACHandler(rbacService)
+	deletionHandler := NewDeletionHandler(deletionService)
 	engine := gin.Default()
 
 	// Create OTP service from config
@@ -52,7 +76,7 @@ func NewRouter(
 		log.Fatalf("Encryption key must be 32 bytes, got %d", len(encryptionKeyBytes))
 	}
 	otpService := otp.NewService(string(encryptionKeyBytes), cfg.OTP.Issuer)
-	otpSvc := service.NewOTPService(userRepo, otpService, jwtService)
+	otpSvc := service.NewOTPService(userRepo, otpService, authService)
 	otpHandler := NewOTPHandler(otpSvc, authService, userRepo)
 
 	return &Router{
@@ -62,6 +86,17 @@ func NewRouter(
 		configHandler:   configHandler,
 		tenantHandler:   tenantHandler,
 		projectHandler:  projectHandler,
+		adapterHandler:  adapterHandler,
+		renderHandler:   renderHandler,
+		rolloutHandler:  rolloutHandler,
+		variableHandler: variableHandler,
+		manifestHandler: manifestHandler,
+		rbacHandler:     rbacHandler,
+		deletionHandler: deletionHandler,
+		rbacService:     rbacService,
+		tenantService:   tenantService,
+		authService:     authService,
+		resolvers:       &scopeResolvers{instances: instanceService, projects: projectService, deletions: deletionService, users: authService, variables: variableService},
 		engine:          engine,
 		jwtService:      jwtService,
 	}
@@ -79,17 +114,20 @@ func (r *Router) SetupRoutes() {
 			auth.POST("/otp/verify", r.otpHandler.VerifyOTP)
 		}
 
+		// User routes; also reachable with the restricted token of a user who must change
+		// their password
+		account := api.Group("/auth")
+		account.Use(middleware.AuthMiddleware(r.jwtService, r.authService), middleware.ActiveTenant(r.tenantService))
+		{
+			account.POST("/logout", r.authHandler.Logout)
+			account.GET("/me", r.authHandler.GetCurrentUser)
+			account.POST("/password", r.authHandler.ChangePassword)
+		}
+
 		// Authenticated routes
 		authenticated := api.Group("")
-		authenticated.Use(middleware.AuthMiddleware(r.jwtService))
+		authenticated.Use(middleware.AuthMiddleware(r.jwtService, r.authService), middleware.ActiveTenant(r.tenantService), middleware.RequirePasswordChanged())
 		{
-			// User routes
-			auth := authenticated.Group("/auth")
-			{
-				auth.POST("/logout", r.authHandler.Logout)
-				auth.GET("/me", r.authHandler.GetCurrentUser)
-			}
-
 			// OTP routes
 			otp := authenticated.Group("/auth/otp")
 			{
@@ -100,58 +138,171 @@ func (r *Router) SetupRoutes() {
 				otp.GET("/status", r.otpHandler.GetOTPStatus)
 			}
 
-			// User management (admin only)
+			// Login sessions of the caller
+			sessions := authenticated.Group("/auth/sessions")
+			{
+				sessions.GET("", r.authHandler.ListSessions)
+				sessions.DELETE("/:id", r.authHandler.RevokeSession)
+			}
+
+			// Routes below are authorized per route against the caller's role bindings; see
+			// service.RBACService. Admins bypass role bindings.
+			authorize := func(resource, verb string, resolve middleware.ScopeResolver) gin.HandlerFunc {
+				return middleware.Authorize(r.rbacService, resource, verb, resolve)
+			}
+			global := middleware.ScopeResolver(middleware.GlobalScope)
+			res := r.resolvers
+
+			// User management; non-admin users only see their own tenant
 			users := authenticated.Group("/auth/users")
-			users.Use(middleware.RequireAdmin())
+			users.Use(middleware.TenantScope())
+			{
+				users.POST("", authorize(service.ResourceUsers, service.VerbCreate, res.body), r.authHandler.CreateUser)
+				users.GET("", authorize(service.ResourceUsers, service.VerbList, res.query), r.authHandler.ListUsers)
+				users.GET("/:id", authorize(service.ResourceUsers, service.VerbGet, res.user), r.authHandler.GetUser)
+				users.PUT("/:id", authorize(service.ResourceUsers, service.VerbUpdate, res.user), r.authHandler.UpdateUser)
+				users.DELETE("/:id", authorize(service.ResourceUsers, service.VerbDelete, res.user), r.authHandler.DeleteUser)
+				users.POST("/:id/deactivate", authorize(service.ResourceUsers, service.VerbUpdate, res.user), r.authHandler.DeactivateUser)
+				users.POST("/:id/activate", authorize(service.ResourceUsers, service.VerbUpdate, res.user), r.authHandler.ActivateUser)
+				users.POST("/:id/password", authorize(service.ResourceUsers, service.VerbUpdate, res.user), r.authHandler.ResetPassword)
+				users.GET("/:id/sessions", authorize(service.ResourceUsers, service.VerbGet, res.user), r.authHandler.ListUserSessions)
+				users.DELETE("/:id/sessions", authorize(service.ResourceUsers, service.VerbUpdate, res.user), r.authHandler.RevokeUserSessions)
+				users.DELETE("/:id/sessions/:session_id", authorize(service.ResourceUsers, service.VerbUpdate, res.user), r.authHandler.RevokeUserSession)
+			}
+
+			// Role routes; anyone may read roles, only admins define them
+			roles := authenticated.Group("/roles")
+			{
+				roles.GET("", r.rbacHandler.ListRoles)
+				roles.GET("/:name", r.rbacHandler.GetRole)
+				roles.POST("", middleware.RequireAdmin(), r.rbacHandler.CreateRole)
+				roles.PUT("/:name", middleware.RequireAdmin(), r.rbacHandler.UpdateRole)
+				roles.DELETE("/:name", middleware.RequireAdmin(), r.rbacHandler.DeleteRole)
+			}
+
+			// Role binding routes; creating and deleting bindings is authorized by the service
+			// at the binding's scope
+			bindings := authenticated.Group("/role-bindings")
 			{
-				users.POST("", r.authHandler.CreateUser)
+				bindings.GET("", authorize(service.ResourceUsers, service.VerbList, res.query), r.rbacHandler.ListBindings)
+				bindings.POST("", r.rbacHandler.CreateBinding)
+				bindings.DELETE("/:id", r.rbacHandler.DeleteBinding)
 			}
 
-			// Config template routes (admin only)
+			// Config template routes
 			configs := authenticated.Group("/configs")
-			configs.Use(middleware.RequireAdmin())
 			{
-				configs.POST("", r.configHandler.Create)
-				configs.GET("", r.configHandler.List)
-				configs.GET("/:id", r.configHandler.Get)
-				configs.PUT("/:id", r.configHandler.Update)
-				configs.DELETE("/:id", r.configHandler.Delete)
+				configs.POST("", authorize(service.ResourceTemplates, service.VerbCreate, global), r.configHandler.Create)
+				configs.GET("", authorize(service.ResourceTemplates, service.VerbList, global), r.configHandler.List)
+				configs.GET("/:id", authorize(service.ResourceTemplates, service.VerbGet, global), r.configHandler.Get)
+				configs.PUT("/:id", authorize(service.ResourceTemplates, service.VerbUpdate, global), r.configHandler.Update)
+				configs.DELETE("/:id", authorize(service.ResourceTemplates, service.VerbDelete, global), r.configHandler.Delete)
+				configs.GET("/:id/revisions", authorize(service.ResourceTemplates, service.VerbGet, global), r.configHandler.Revisions)
+				configs.GET("/:id/revisions/:revision", authorize(service.ResourceTemplates, service.VerbGet, global), r.configHandler.Revision)
+				configs.GET("/:id/diff", authorize(service.ResourceTemplates, service.VerbGet, global), r.configHandler.Diff)
+				// The preview resolves tenant and project variables, which must be in the caller's scope
+				configs.POST("/render", middleware.TenantScope(), middleware.ProjectScope(r.rbacService), authorize(service.ResourceTemplates, service.VerbGet, global), r.renderHandler.Render)
+			}
+
+			// Config rollout routes; rollouts span tenants, so they need a global binding
+			rollouts := authenticated.Group("/rollouts")
+			{
+				rollouts.POST("", authorize(service.ResourceInstances, service.VerbUpdate, global), r.rolloutHandler.Create)
+				rollouts.GET("", authorize(service.ResourceInstances, service.VerbList, global), r.rolloutHandler.List)
+				rollouts.GET("/:id", authorize(service.ResourceInstances, service.VerbGet, global), r.rolloutHandler.Get)
+				rollouts.POST("/:id/abort", authorize(service.ResourceInstances, service.VerbUpdate, global), r.rolloutHandler.Abort)
+			}
+
+			// Shared variable routes; variables are authorized at the tenant or project they are
+			// defined in, and global variables need a global binding
+			variables := authenticated.Group("/variables")
+			variables.Use(middleware.TenantScope())
+			{
+				variables.POST("", authorize(service.ResourceVariables, service.VerbCreate, res.variableBody), r.variableHandler.Create)
+				variables.GET("", authorize(service.ResourceVariables, service.VerbList, res.variableQuery), r.variableHandler.List)
+				variables.GET("/:id", authorize(service.ResourceVariables, service.VerbGet, res.variable), r.variableHandler.Get)
+				variables.PUT("/:id", authorize(service.ResourceVariables, service.VerbUpdate, res.variable), r.variableHandler.Update)
+				variables.DELETE("/:id", authorize(service.ResourceVariables, service.VerbDelete, res.variable), r.variableHandler.Delete)
+			}
+
+			// Manifest export and apply routes (admin only)
+			manifests := authenticated.Group("/manifests")
+			manifests.Use(middleware.RequireAdmin())
+			{
+				manifests.GET("", r.manifestHandler.Export)
+				manifests.POST("/apply", r.manifestHandler.Apply)
 			}
 
-			// Tenant routes (admin only)
+			// Tenant routes
 			tenants := authenticated.Group("/tenants")
-			tenants.Use(middleware.RequireAdmin())
 			{
-				tenants.POST("", r.tenantHandler.Create)
-				tenants.GET("", r.tenantHandler.List)
-				tenants.GET("/:id", r.tenantHandler.Get)
-				tenants.PUT("/:id", r.tenantHandler.Update)
-				tenants.DELETE("/:id", r.tenantHandler.Delete)
+				tenants.POST("", authorize(service.ResourceTenants, service.VerbCreate, global), r.tenantHandler.Create)
+				tenants.GET("", authorize(service.ResourceTenants, service.VerbList, global), r.tenantHandler.List)
+				tenants.GET("/:id", authorize(service.ResourceTenants, service.VerbGet, res.tenant), r.tenantHandler.Get)
+				tenants.PUT("/:id", authorize(service.ResourceTenants, service.VerbUpdate, res.tenant), r.tenantHandler.Update)
+				tenants.DELETE("/:id", authorize(service.ResourceTenants, service.VerbDelete, global), r.tenantHandler.Delete)
+				tenants.GET("/:id/usage", authorize(service.ResourceTenants, service.VerbGet, res.tenant), r.tenantHandler.Usage)
+				// Suspension locks the tenant's own admins out, so only global bindings may change it
+				tenants.POST("/:id/suspend", authorize(service.ResourceTenants, service.VerbUpdate, global), r.tenantHandler.Suspend)
+				tenants.POST("/:id/archive", authorize(service.ResourceTenants, service.VerbUpdate, global), r.tenantHandler.Archive)
+				tenants.POST("/:id/resume", authorize(service.ResourceTenants, service.VerbUpdate, global), r.tenantHandler.Resume)
 			}
 
-			// Project routes (admin only)
+			// Project routes; non-admin users only see their own tenant
 			projects := authenticated.Group("/projects")
-			projects.Use(middleware.RequireAdmin())
+			projects.Use(middleware.TenantScope())
+			{
+				projects.POST("", authorize(service.ResourceProjects, service.VerbCreate, res.body), r.projectHandler.Create)
+				projects.GET("", authorize(service.ResourceProjects, service.VerbList, res.query), r.projectHandler.List)
+				projects.GET("/:id", authorize(service.ResourceProjects, service.VerbGet, res.project), r.projectHandler.Get)
+				projects.PUT("/:id", authorize(service.ResourceProjects, service.VerbUpdate, res.project), r.projectHandler.Update)
+				projects.DELETE("/:id", authorize(service.ResourceProjects, service.VerbDelete, res.project), r.projectHandler.Delete)
+				projects.GET("/:id/usage", authorize(service.ResourceProjects, service.VerbGet, res.project), r.projectHandler.Usage)
+				projects.GET("/:id/members", authorize(service.ResourceProjects, service.VerbGet, res.project), r.rbacHandler.ListProjectMembers)
+				projects.POST("/:id/members", authorize(service.ResourceUsers, service.VerbUpdate, res.project), r.rbacHandler.SetProjectMember)
+				projects.DELETE("/:id/members/:user_id", authorize(service.ResourceUsers, service.VerbUpdate, res.project), r.rbacHandler.RemoveProjectMember)
+			}
+
+			// Cascading deletion routes; deletions are started with DELETE ?cascade=true on
+			// tenants and projects
+			deletions := authenticated.Group("/deletions")
+			deletions.Use(middleware.TenantScope())
+			{
+				deletions.GET("", authorize(service.ResourceProjects, service.VerbList, res.query), r.deletionHandler.List)
+				deletions.GET("/:id", authorize(service.ResourceProjects, service.VerbGet, res.deletion), r.deletionHandler.Get)
+				deletions.POST("/:id/restore", authorize(service.ResourceProjects, service.VerbDelete, res.deletion), r.deletionHandler.Restore)
+			}
+
+			// Adapter discovery routes
+			adapters := authenticated.Group("/adapters")
 			{
-				projects.POST("", r.projectHandler.Create)
-				projects.GET("", r.projectHandler.List)
-				projects.GET("/:id", r.projectHandler.Get)
-				projects.PUT("/:id", r.projectHandler.Update)
-				projects.DELETE("/:id", r.projectHandler.Delete)
+				adapters.GET("", r.adapterHandler.List)
+				adapters.GET("/:type", r.adapterHandler.Get)
+				adapters.GET("/:type/schema", r.adapterHandler.Schema)
 			}
 
-			// Instance routes (need authentication)
+			// Instance routes; non-admin users only see their own tenant and the projects they can access
 			instanceHandler := NewInstanceHandler(r.handler.instanceService)
 			instances := authenticated.Group("/instances")
+			instances.Use(middleware.TenantScope(), middleware.ProjectScope(r.rbacService))
 			{
-				instances.POST("", instanceHandler.Create)
-				instances.GET("", instanceHandler.List)
-				instances.GET("/:id", instanceHandler.Get)
-				instances.DELETE("/:id", instanceHandler.Delete)
-				instances.POST("/:id/start", instanceHandler.Start)
-				instances.POST("/:id/stop", instanceHandler.Stop)
-				instances.POST("/:id/restart", instanceHandler.Restart)
-				instances.GET("/:id/logs", instanceHandler.Logs)
+				instances.POST("", authorize(service.ResourceInstances, service.VerbCreate, res.body), instanceHandler.Create)
+				instances.GET("", authorize(service.ResourceInstances, service.VerbList, res.listing), instanceHandler.List)
+				instances.GET("/drift", authorize(service.ResourceInstances, service.VerbList, res.listing), instanceHandler.ListConfigDrift)
+				instances.GET("/:id", authorize(service.ResourceInstances, service.VerbGet, res.instance), instanceHandler.Get)
+				instances.PUT("/:id", authorize(service.ResourceInstances, service.VerbUpdate, res.instance), instanceHandler.Update)
+				instances.DELETE("/:id", authorize(service.ResourceInstances, service.VerbDelete, res.instance), instanceHandler.Delete)
+				instances.POST("/:id/start", authorize(service.ResourceInstances, service.VerbOperate, res.instance), instanceHandler.Start)
+				instances.POST("/:id/stop", authorize(service.ResourceInstances, service.VerbOperate, res.instance), instanceHandler.Stop)
+				instances.POST("/:id/restart", authorize(service.ResourceInstances, service.VerbOperate, res.instance), instanceHandler.Restart)
+				instances.GET("/:id/logs", authorize(service.ResourceInstances, service.VerbGet, res.instance), instanceHandler.Logs)
+				instances.GET("/:id/health", authorize(service.ResourceInstances, service.VerbGet, res.instance), instanceHandler.Health)
+				instances.GET("/:id/config/revisions", authorize(service.ResourceInstances, service.VerbGet, res.instance), instanceHandler.ConfigRevisions)
+				instances.GET("/:id/config/revisions/:revision", authorize(service.ResourceInstances, service.VerbGet, res.instance), instanceHandler.ConfigRevision)
+				instances.GET("/:id/config/diff", authorize(service.ResourceInstances, service.VerbGet, res.instance), instanceHandler.ConfigDiff)
+				instances.POST("/:id/config/rollback", authorize(service.ResourceInstances, service.VerbUpdate, res.instance), instanceHandler.RollbackConfig)
+				instances.GET("/:id/config/drift", authorize(service.ResourceInstances, service.VerbGet, res.instance), instanceHandler.ConfigDrift)
+				instances.POST("/:id/config/drift/correct", authorize(service.ResourceInstances, service.VerbUpdate, res.instance), instanceHandler.CorrectConfigDrift)
 			}
 		}
 	}
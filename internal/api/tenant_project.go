@@ -1,23 +1,27 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/internal/middleware"
 	"github.com/weibh/openClusterClaw/internal/model"
 	"github.com/weibh/openClusterClaw/internal/service"
 )
 
 // TenantHandler handles tenant-related requests
 type TenantHandler struct {
-	service service.TenantService
+	service   service.TenantService
+	deletions service.DeletionService
 }
 
 // NewTenantHandler creates a new tenant handler
-func NewTenantHandler(service service.TenantService) *TenantHandler {
+func NewTenantHandler(service service.TenantService, deletions service.DeletionService) *TenantHandler {
 	return &TenantHandler{
-		service: service,
+		service:   service,
+		deletions: deletions,
 	}
 }
 
@@ -47,10 +51,17 @@ type TenantResponse struct {
 	MaxCPU      string `json:"max_cpu"`
 	MaxMemory   string `json:"max_memory"`
 	MaxStorage  string `json:"max_storage"`
+	Status       model.TenantStatus `json:"status"`
+	StatusReason string             `json:"status_reason,omitempty"`
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
 }
 
+// TenantStatusRequest carries the reason recorded when suspending or archiving a tenant
+type TenantStatusRequest struct {
+	Reason string `json:"reason"`
+}
+
 // Create creates a new tenant
 func (h *TenantHandler) Create(c *gin.Context) {
 	var req CreateTenantRequest
@@ -73,6 +84,10 @@ func (h *TenantHandler) Create(c *gin.Context) {
 			errorResponse(c, http.StatusConflict, "tenant name already exists", nil)
 			return
 		}
+		if errors.Is(err, service.ErrInvalidQuantity) {
+			errorResponse(c, http.StatusBadRequest, err.Error(), err)
+			return
+		}
 		errorResponse(c, http.StatusInternalServerError, "failed to create tenant", err)
 		return
 	}
@@ -150,6 +165,14 @@ func (h *TenantHandler) Update(c *gin.Context) {
 			errorResponse(c, http.StatusConflict, "tenant name already exists", nil)
 			return
 		}
+		if errors.Is(err, service.ErrInvalidQuantity) {
+			errorResponse(c, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		if errors.Is(err, service.ErrQuotaAllocation) {
+			errorResponse(c, http.StatusConflict, err.Error(), err)
+			return
+		}
 		errorResponse(c, http.StatusInternalServerError, "failed to update tenant", err)
 		return
 	}
@@ -157,25 +180,164 @@ func (h *TenantHandler) Update(c *gin.Context) {
 	success(c, h.toResponse(tenant))
 }
 
-// Delete deletes a tenant
+// Delete deletes a tenant that owns nothing. With cascade=true it starts a background
+// deletion of the tenant and everything it owns instead.
+// @Summary Delete tenant
+// @Tags tenants
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param cascade query bool false "Delete instances, projects, users, variables and role bindings too"
+// @Success 200 {object} Response
+// @Success 202 {object} service.DeletionInfo
+// @Failure 409 {object} ErrorResponse
+// @Router /tenants/{id} [delete]
 func (h *TenantHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
+	if c.Query("cascade") == "true" {
+		deletion, err := h.deletions.DeleteTenant(c.Request.Context(), id, middleware.GetUsername(c))
+		if err != nil {
+			if errors.Is(err, service.ErrTenantNotFound) {
+				errorResponse(c, http.StatusNotFound, "tenant not found", err)
+				return
+			}
+			if errors.Is(err, service.ErrDeletionUnsafe) {
+				errorResponse(c, http.StatusConflict, err.Error(), err)
+				return
+			}
+			errorResponse(c, http.StatusInternalServerError, "failed to delete tenant", err)
+			return
+		}
+		accepted(c, deletion)
+		return
+	}
+
 	if err := h.service.DeleteTenant(c.Request.Context(), id); err != nil {
 		if err == service.ErrTenantNotFound {
 			errorResponse(c, http.StatusNotFound, "tenant not found", nil)
 			return
 		}
-		if err == service.ErrTenantHasInstances {
-			errorResponse(c, http.StatusConflict, "tenant has instances and cannot be deleted", nil)
-			return
+		if !dependencyError(c, err) {
+			errorResponse(c, http.StatusInternalServerError, "failed to delete tenant", err)
 		}
-		errorResponse(c, http.StatusInternalServerError, "failed to delete tenant", err)
 		return
 	}
 
 	success(c, gin.H{"message": "tenant deleted"})
 }
 
+// Usage reports the resources used by a tenant's instances against its quota
+// @Summary Get tenant quota usage
+// @Tags tenants
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Success 200 {object} service.TenantUsage
+// @Router /tenants/{id}/usage [get]
+func (h *TenantHandler) Usage(c *gin.Context) {
+	usage, err := h.service.GetTenantUsage(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrTenantNotFound) {
+			errorResponse(c, http.StatusNotFound, "tenant not found", err)
+			return
+		}
+		errorResponse(c, http.StatusInternalServerError, "failed to get tenant usage", err)
+		return
+	}
+
+	success(c, usage)
+}
+
+// Suspend stops a tenant's running instances and locks its users out until it is resumed
+// @Summary Suspend tenant
+// @Tags tenants
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param request body TenantStatusRequest false "Suspension reason"
+// @Success 200 {object} TenantResponse
+// @Router /tenants/{id}/suspend [post]
+func (h *TenantHandler) Suspend(c *gin.Context) {
+	req, ok := bindStatusRequest(c)
+	if !ok {
+		return
+	}
+	tenant, err := h.service.SuspendTenant(c.Request.Context(), c.Param("id"), req.Reason)
+	if err != nil {
+		tenantStatusError(c, err, "failed to suspend tenant")
+		return
+	}
+
+	success(c, h.toResponse(tenant))
+}
+
+// Archive suspends a tenant for good; resuming it later leaves its instances stopped
+// @Summary Archive tenant
+// @Tags tenants
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Param request body TenantStatusRequest false "Archive reason"
+// @Success 200 {object} TenantResponse
+// @Router /tenants/{id}/archive [post]
+func (h *TenantHandler) Archive(c *gin.Context) {
+	req, ok := bindStatusRequest(c)
+	if !ok {
+		return
+	}
+	tenant, err := h.service.ArchiveTenant(c.Request.Context(), c.Param("id"), req.Reason)
+	if err != nil {
+		tenantStatusError(c, err, "failed to archive tenant")
+		return
+	}
+
+	success(c, h.toResponse(tenant))
+}
+
+// Resume reactivates a suspended or archived tenant and restarts the instances its suspension stopped
+// @Summary Resume tenant
+// @Tags tenants
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Tenant ID"
+// @Success 200 {object} TenantResponse
+// @Router /tenants/{id}/resume [post]
+func (h *TenantHandler) Resume(c *gin.Context) {
+	tenant, err := h.service.ResumeTenant(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		tenantStatusError(c, err, "failed to resume tenant")
+		return
+	}
+
+	success(c, h.toResponse(tenant))
+}
+
+// bindStatusRequest reads the optional body of a suspend or archive request
+func bindStatusRequest(c *gin.Context) (*TenantStatusRequest, bool) {
+	var req TenantStatusRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			errorResponse(c, http.StatusBadRequest, "invalid request", err)
+			return nil, false
+		}
+	}
+	return &req, true
+}
+
+// tenantStatusError writes the response for a failed tenant status change
+func tenantStatusError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, service.ErrTenantNotFound):
+		errorResponse(c, http.StatusNotFound, "tenant not found", err)
+	case errors.Is(err, service.ErrInvalidTenantTransition):
+		errorResponse(c, http.StatusConflict, err.Error(), err)
+	default:
+		errorResponse(c, http.StatusInternalServerError, message, err)
+	}
+}
+
 // toResponse converts a tenant model to response DTO
 func (h *TenantHandler) toResponse(tenant *model.Tenant) *TenantResponse {
 	return &TenantResponse{
@@ -185,6 +347,8 @@ func (h *TenantHandler) toResponse(tenant *model.Tenant) *TenantResponse {
 		MaxCPU:      tenant.MaxCPU,
 		MaxMemory:   tenant.MaxMemory,
 		MaxStorage:  tenant.MaxStorage,
+		Status:       tenant.Status,
+		StatusReason: tenant.StatusReason,
 		CreatedAt:   tenant.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 		UpdatedAt:   tenant.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
@@ -192,34 +356,57 @@ func (h *TenantHandler) toResponse(tenant *model.Tenant) *TenantResponse {
 
 // ProjectHandler handles project-related requests
 type ProjectHandler struct {
-	service service.ProjectService
+	service   service.ProjectService
+	deletions service.DeletionService
 }
 
 // NewProjectHandler creates a new project handler
-func NewProjectHandler(service service.ProjectService) *ProjectHandler {
+func NewProjectHandler(service service.ProjectService, deletions service.DeletionService) *ProjectHandler {
 	return &ProjectHandler{
-		service: service,
+		service:   service,
+		deletions: deletions,
 	}
 }
 
 // CreateProjectRequest represents the request to create a project
 type CreateProjectRequest struct {
-	TenantID string `json:"tenant_id" binding:"required"`
-	Name     string `json:"name" binding:"required"`
+	TenantID       string `json:"tenant_id" binding:"required"`
+	Name           string `json:"name" binding:"required"`
+	MaxInstances   int    `json:"max_instances"`
+	MaxCPU         string `json:"max_cpu"`
+	MaxMemory      string `json:"max_memory"`
+	MaxStorage     string `json:"max_storage"`
+	DefaultCPU     string `json:"default_cpu"`
+	DefaultMemory  string `json:"default_memory"`
+	DefaultStorage string `json:"default_storage"`
 }
 
 // UpdateProjectRequest represents the request to update a project
 type UpdateProjectRequest struct {
-	Name *string `json:"name"`
+	Name           *string `json:"name"`
+	MaxInstances   *int    `json:"max_instances"`
+	MaxCPU         *string `json:"max_cpu"`
+	MaxMemory      *string `json:"max_memory"`
+	MaxStorage     *string `json:"max_storage"`
+	DefaultCPU     *string `json:"default_cpu"`
+	DefaultMemory  *string `json:"default_memory"`
+	DefaultStorage *string `json:"default_storage"`
 }
 
 // ProjectResponse represents the project response
 type ProjectResponse struct {
-	ID        string `json:"id"`
-	TenantID  string `json:"tenant_id"`
-	Name      string `json:"name"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
+	ID             string `json:"id"`
+	TenantID       string `json:"tenant_id"`
+	Name           string `json:"name"`
+	MaxInstances   int    `json:"max_instances"`
+	MaxCPU         string `json:"max_cpu"`
+	MaxMemory      string `json:"max_memory"`
+	MaxStorage     string `json:"max_storage"`
+	DefaultCPU     string `json:"default_cpu"`
+	DefaultMemory  string `json:"default_memory"`
+	DefaultStorage string `json:"default_storage"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
 }
 
 // Create creates a new project
@@ -231,13 +418,22 @@ func (h *ProjectHandler) Create(c *gin.Context) {
 	}
 
 	createReq := &service.CreateProjectRequest{
-		TenantID: req.TenantID,
-		Name:     req.Name,
+		TenantID:       req.TenantID,
+		Name:           req.Name,
+		MaxInstances:   req.MaxInstances,
+		MaxCPU:         req.MaxCPU,
+		MaxMemory:      req.MaxMemory,
+		MaxStorage:     req.MaxStorage,
+		DefaultCPU:     req.DefaultCPU,
+		DefaultMemory:  req.DefaultMemory,
+		DefaultStorage: req.DefaultStorage,
 	}
 
 	project, err := h.service.CreateProject(c.Request.Context(), createReq)
 	if err != nil {
-		errorResponse(c, http.StatusInternalServerError, "failed to create project", err)
+		if !projectQuotaError(c, err) {
+			errorResponse(c, http.StatusInternalServerError, "failed to create project", err)
+		}
 		return
 	}
 
@@ -271,6 +467,10 @@ func (h *ProjectHandler) List(c *gin.Context) {
 
 	projects, total, err := h.service.ListProjects(c.Request.Context(), tenantID, page, pageSize)
 	if err != nil {
+		if errors.Is(err, service.ErrTenantAccessDenied) {
+			errorResponse(c, http.StatusForbidden, "access to another tenant is denied", err)
+			return
+		}
 		errorResponse(c, http.StatusInternalServerError, "failed to list projects", err)
 		return
 	}
@@ -298,7 +498,14 @@ func (h *ProjectHandler) Update(c *gin.Context) {
 	}
 
 	updateReq := &service.UpdateProjectRequest{
-		Name: req.Name,
+		Name:           req.Name,
+		MaxInstances:   req.MaxInstances,
+		MaxCPU:         req.MaxCPU,
+		MaxMemory:      req.MaxMemory,
+		MaxStorage:     req.MaxStorage,
+		DefaultCPU:     req.DefaultCPU,
+		DefaultMemory:  req.DefaultMemory,
+		DefaultStorage: req.DefaultStorage,
 	}
 
 	project, err := h.service.UpdateProject(c.Request.Context(), id, updateReq)
@@ -307,31 +514,111 @@ func (h *ProjectHandler) Update(c *gin.Context) {
 			errorResponse(c, http.StatusNotFound, "project not found", nil)
 			return
 		}
-		errorResponse(c, http.StatusInternalServerError, "failed to update project", err)
+		if !projectQuotaError(c, err) {
+			errorResponse(c, http.StatusInternalServerError, "failed to update project", err)
+		}
 		return
 	}
 
 	success(c, h.toResponse(project))
 }
 
-// Delete deletes a project
+// Delete deletes a project without instances, variables or members. With cascade=true it
+// starts a background deletion of the project and everything it owns instead.
+// @Summary Delete project
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Project ID"
+// @Param cascade query bool false "Delete instances, variables and memberships too"
+// @Success 200 {object} Response
+// @Success 202 {object} service.DeletionInfo
+// @Failure 409 {object} ErrorResponse
+// @Router /projects/{id} [delete]
 func (h *ProjectHandler) Delete(c *gin.Context) {
 	id := c.Param("id")
+	if c.Query("cascade") == "true" {
+		deletion, err := h.deletions.DeleteProject(c.Request.Context(), id, middleware.GetUsername(c))
+		if err != nil {
+			if errors.Is(err, service.ErrProjectNotFound) {
+				errorResponse(c, http.StatusNotFound, "project not found", err)
+				return
+			}
+			errorResponse(c, http.StatusInternalServerError, "failed to delete project", err)
+			return
+		}
+		accepted(c, deletion)
+		return
+	}
+
 	if err := h.service.DeleteProject(c.Request.Context(), id); err != nil {
-		errorResponse(c, http.StatusNotFound, "project not found", err)
+		if errors.Is(err, service.ErrProjectNotFound) {
+			errorResponse(c, http.StatusNotFound, "project not found", err)
+			return
+		}
+		if !dependencyError(c, err) {
+			errorResponse(c, http.StatusInternalServerError, "failed to delete project", err)
+		}
 		return
 	}
 
 	success(c, gin.H{"message": "project deleted"})
 }
 
+// Usage reports the resources used by a project's instances against its allocation
+// @Summary Get project quota usage
+// @Tags projects
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Project ID"
+// @Success 200 {object} service.ProjectUsage
+// @Router /projects/{id}/usage [get]
+func (h *ProjectHandler) Usage(c *gin.Context) {
+	usage, err := h.service.GetProjectUsage(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrProjectNotFound) {
+			errorResponse(c, http.StatusNotFound, "project not found", err)
+			return
+		}
+		errorResponse(c, http.StatusInternalServerError, "failed to get project usage", err)
+		return
+	}
+
+	success(c, usage)
+}
+
+// projectQuotaError writes the response for invalid project quotas and defaults.
+// It reports whether err was handled.
+func projectQuotaError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, service.ErrTenantAccessDenied):
+		errorResponse(c, http.StatusForbidden, "access to another tenant is denied", err)
+	case errors.Is(err, service.ErrTenantNotFound):
+		errorResponse(c, http.StatusBadRequest, "tenant not found", err)
+	case errors.Is(err, service.ErrInvalidQuantity):
+		errorResponse(c, http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, service.ErrQuotaAllocation):
+		errorResponse(c, http.StatusConflict, err.Error(), err)
+	default:
+		return false
+	}
+	return true
+}
+
 // toResponse converts a project model to response DTO
 func (h *ProjectHandler) toResponse(project *model.Project) *ProjectResponse {
 	return &ProjectResponse{
-		ID:        project.ID,
-		TenantID:  project.TenantID,
-		Name:      project.Name,
-		CreatedAt: project.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		UpdatedAt: project.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		ID:             project.ID,
+		TenantID:       project.TenantID,
+		Name:           project.Name,
+		MaxInstances:   project.MaxInstances,
+		MaxCPU:         project.MaxCPU,
+		MaxMemory:      project.MaxMemory,
+		MaxStorage:     project.MaxStorage,
+		DefaultCPU:     project.DefaultCPU,
+		DefaultMemory:  project.DefaultMemory,
+		DefaultStorage: project.DefaultStorage,
+		CreatedAt:      project.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		UpdatedAt:      project.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"),
 	}
 }
\ No newline at end of file
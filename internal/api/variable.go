@@ -0,0 +1,143 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/internal/middleware"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/service"
+)
+
+// VariableHandler handles shared variable requests
+type VariableHandler struct {
+	service service.VariableService
+}
+
+// NewVariableHandler creates a new shared variable handler
+func NewVariableHandler(service service.VariableService) *VariableHandler {
+	return &VariableHandler{service: service}
+}
+
+// Create defines a shared variable and re-renders the instances that reference it
+// @Summary Create shared variable
+// @Tags variables
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body service.CreateVariableRequest true "Variable"
+// @Success 200 {object} service.VariableInfo
+// @Router /variables [post]
+func (h *VariableHandler) Create(c *gin.Context) {
+	var req service.CreateVariableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	req.Author = middleware.GetUsername(c)
+
+	variable, err := h.service.CreateVariable(c.Request.Context(), &req)
+	if err != nil {
+		variableError(c, err, "failed to create variable")
+		return
+	}
+
+	success(c, variable)
+}
+
+// List retrieves the shared variables of a scope
+// @Summary List shared variables
+// @Tags variables
+// @Security BearerAuth
+// @Produce json
+// @Param scope query string false "global, tenant, project or instance"
+// @Param scope_id query string false "Tenant, project or instance ID"
+// @Router /variables [get]
+func (h *VariableHandler) List(c *gin.Context) {
+	variables, err := h.service.ListVariables(c.Request.Context(), model.VariableScope(c.Query("scope")), c.Query("scope_id"))
+	if err != nil {
+		variableError(c, err, "failed to list variables")
+		return
+	}
+
+	success(c, gin.H{
+		"items": variables,
+		"total": len(variables),
+	})
+}
+
+// Get retrieves a shared variable; secret values are redacted
+// @Summary Get shared variable
+// @Tags variables
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Variable ID"
+// @Success 200 {object} service.VariableInfo
+// @Router /variables/{id} [get]
+func (h *VariableHandler) Get(c *gin.Context) {
+	variable, err := h.service.GetVariable(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		variableError(c, err, "failed to get variable")
+		return
+	}
+
+	success(c, variable)
+}
+
+// Update changes a shared variable and re-renders the instances that reference it
+// @Summary Update shared variable
+// @Tags variables
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Variable ID"
+// @Param request body service.UpdateVariableRequest true "Changes"
+// @Success 200 {object} service.VariableInfo
+// @Router /variables/{id} [put]
+func (h *VariableHandler) Update(c *gin.Context) {
+	var req service.UpdateVariableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "invalid request", err)
+		return
+	}
+	req.Author = middleware.GetUsername(c)
+
+	variable, err := h.service.UpdateVariable(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		variableError(c, err, "failed to update variable")
+		return
+	}
+
+	success(c, variable)
+}
+
+// Delete removes a shared variable that no instance references
+// @Summary Delete shared variable
+// @Tags variables
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Variable ID"
+// @Router /variables/{id} [delete]
+func (h *VariableHandler) Delete(c *gin.Context) {
+	if err := h.service.DeleteVariable(c.Request.Context(), c.Param("id")); err != nil {
+		variableError(c, err, "failed to delete variable")
+		return
+	}
+
+	success(c, gin.H{"message": "variable deleted"})
+}
+
+// variableError maps shared variable errors to HTTP responses
+func variableError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, service.ErrVariableNotFound):
+		errorResponse(c, http.StatusNotFound, "variable not found", err)
+	case errors.Is(err, service.ErrInvalidVariable):
+		errorResponse(c, http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, service.ErrVariableExists), errors.Is(err, service.ErrVariableInUse):
+		errorResponse(c, http.StatusConflict, err.Error(), err)
+	default:
+		errorResponse(c, http.StatusInternalServerError, fallback, err)
+	}
+}
@@ -25,29 +25,50 @@ type ClawInstance struct {
 	Version     string          `json:"version"`
 	Status      InstanceStatus  `json:"status"`
 	Config      *InstanceConfig `json:"config"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	// ConfigRevision is the number of the config revision currently applied
+	ConfigRevision int          `json:"config_revision"`
+	// ConfigApply reports how the last config change reached the instance; only set on change responses
+	ConfigApply *ConfigApply    `json:"config_apply,omitempty"`
 	Resources   *ResourceSpec   `json:"resources"`
 	Storage     *StorageSpec    `json:"storage"`
 	CreatedAt   time.Time       `json:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at"`
 }
 
+// ConfigApply describes how a config change is delivered to a running instance
+type ConfigApply struct {
+	// Action is "none", "reload" or "restart"
+	Action string `json:"action"`
+	// Method is the reload method declared by the adapter
+	Method string `json:"method,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// RestartFields lists the changed config paths that cannot be reloaded in place
+	RestartFields []string `json:"restart_fields,omitempty"`
+}
+
 // InstanceConfig represents instance configuration
 type InstanceConfig struct {
-	TemplateName string            `json:"template_name"`
-	Overrides    map[string]string `json:"overrides"`
+	TemplateName string `json:"template_name"`
+	// TemplateRevision pins a template revision; 0 uses the latest one
+	TemplateRevision int               `json:"template_revision,omitempty"`
+	TemplateVersion  string            `json:"template_version,omitempty"`
+	Overrides        map[string]string `json:"overrides"`
+	// Values holds the resolved template variables and overrides keyed by config path; secrets are redacted
+	Values map[string]string `json:"values,omitempty"`
 }
 
 // ResourceSpec represents resource requirements
 type ResourceSpec struct {
-	CPU    string `json:"cpu"`
-	Memory string `json:"memory"`
+	CPU    string `json:"cpu" yaml:"cpu,omitempty"`
+	Memory string `json:"memory" yaml:"memory,omitempty"`
 }
 
 // StorageSpec represents storage configuration
 type StorageSpec struct {
-	ConfigDir string `json:"config_dir"`
-	DataDir   string `json:"data_dir"`
-	Size      string `json:"size"`
+	ConfigDir string `json:"config_dir" yaml:"config_dir,omitempty"`
+	DataDir   string `json:"data_dir" yaml:"data_dir,omitempty"`
+	Size      string `json:"size" yaml:"size,omitempty"`
 }
 
 // ConfigTemplate represents a configuration template
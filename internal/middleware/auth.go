@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
@@ -8,6 +9,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/weibh/openClusterClaw/internal/pkg/jwt"
 	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
+	"github.com/weibh/openClusterClaw/internal/service"
 )
 
 const (
@@ -19,10 +22,20 @@ const (
 	ContextTenantIDKey = "tenant_id"
 	// ContextUserRoleKey is the key for user role in gin context
 	ContextUserRoleKey = "role"
+	// ContextMustChangePasswordKey marks requests made with a restricted token
+	ContextMustChangePasswordKey = "must_change_password"
+	// ContextSessionIDKey is the key for the login session ID in gin context
+	ContextSessionIDKey = "session_id"
 )
 
-// AuthMiddleware validates JWT tokens and injects user info into context
-func AuthMiddleware(jwtService *jwt.JWTService) gin.HandlerFunc {
+// SessionValidator checks that the login session of an access token is still active
+type SessionValidator interface {
+	ValidateSession(ctx context.Context, userID, sessionID string) error
+}
+
+// AuthMiddleware validates access tokens and the session they belong to, and injects user info
+// into context. Refresh tokens and tokens of revoked sessions are rejected.
+func AuthMiddleware(jwtService *jwt.JWTService, sessions SessionValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -48,7 +61,10 @@ func AuthMiddleware(jwtService *jwt.JWTService) gin.HandlerFunc {
 
 		// Validate token
 		token := parts[1]
-		claims, err := jwtService.ValidateToken(token)
+		claims, err := jwtService.ValidateAccessToken(token)
+		if err == nil {
+			err = sessions.ValidateSession(c.Request.Context(), claims.UserID, claims.SessionID)
+		}
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code":    401,
@@ -63,6 +79,25 @@ func AuthMiddleware(jwtService *jwt.JWTService) gin.HandlerFunc {
 		c.Set(ContextUsernameKey, claims.Username)
 		c.Set(ContextTenantIDKey, claims.TenantID)
 		c.Set(ContextUserRoleKey, claims.Role)
+		c.Set(ContextMustChangePasswordKey, claims.MustChangePassword)
+		c.Set(ContextSessionIDKey, claims.SessionID)
+
+		c.Next()
+	}
+}
+
+// RequirePasswordChanged rejects restricted tokens, which are issued to users who must change
+// their password before they can use the API
+func RequirePasswordChanged() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetBool(ContextMustChangePasswordKey) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code":    403,
+				"message": "password change required",
+			})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -94,6 +129,52 @@ func RequireAdmin() gin.HandlerFunc {
 	}
 }
 
+// IsAdmin reports whether the authenticated user has the admin role
+func IsAdmin(c *gin.Context) bool {
+	return GetUserRole(c) == string(model.RoleAdmin)
+}
+
+// TenantScope restricts the data a non-admin user reads and writes to the tenant in their token.
+// Admins are not scoped and select tenants explicitly.
+func TenantScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsAdmin(c) {
+			ctx := repository.WithTenantScope(c.Request.Context(), GetTenantID(c))
+			c.Request = c.Request.WithContext(ctx)
+		}
+		c.Next()
+	}
+}
+
+// ActiveTenant rejects requests from users whose tenant is suspended, archived or cannot be
+// loaded, e.g. because it is being deleted, so that tokens issued before stop working right
+// away. Admins and users without a tenant are not tenant-bound.
+func ActiveTenant(tenants service.TenantService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenantID := GetTenantID(c)
+		if !IsAdmin(c) && tenantID != "" {
+			message := ""
+			tenant, err := tenants.GetTenant(c.Request.Context(), tenantID)
+			switch {
+			case err != nil:
+				message = "tenant is not available"
+			case !tenant.IsActive():
+				message = "tenant is suspended"
+			}
+			if message != "" {
+				c.JSON(http.StatusForbidden, gin.H{
+					"code":    403,
+					"message": message,
+				})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
 // GetUserID retrieves user ID from context
 func GetUserID(c *gin.Context) string {
 	userID, _ := c.Get(ContextUserIDKey)
@@ -112,6 +193,15 @@ func GetTenantID(c *gin.Context) string {
 	return tenantID.(string)
 }
 
+// GetSessionID retrieves the login session ID from context
+func GetSessionID(c *gin.Context) string {
+	sessionID, _ := c.Get(ContextSessionIDKey)
+	if sessionID == nil {
+		return ""
+	}
+	return sessionID.(string)
+}
+
 // GetUsername retrieves username from context
 func GetUsername(c *gin.Context) string {
 	username, _ := c.Get(ContextUsernameKey)
@@ -124,10 +214,13 @@ func GetUsername(c *gin.Context) string {
 // GetUserRole retrieves user role from context
 func GetUserRole(c *gin.Context) string {
 	role, _ := c.Get(ContextUserRoleKey)
-	if role == nil {
-		return ""
+	switch r := role.(type) {
+	case model.UserRole:
+		return string(r)
+	case string:
+		return r
 	}
-	return role.(string)
+	return ""
 }
 
 // OptionalAuthMiddleware authenticates if token is provided, but doesn't require it
@@ -147,7 +240,7 @@ func OptionalAuthMiddleware(jwtService *jwt.JWTService) gin.HandlerFunc {
 		}
 
 		token := parts[1]
-		claims, err := jwtService.ValidateToken(token)
+		claims, err := jwtService.ValidateAccessToken(token)
 		if err != nil {
 			// Invalid token, continue without auth
 			c.Next()
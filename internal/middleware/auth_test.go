@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/weibh/openClusterClaw/config"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/pkg/jwt"
+	"github.com/weibh/openClusterClaw/internal/service"
+)
+
+// revokedSessions rejects the sessions it lists
+type revokedSessions map[string]bool
+
+func (r revokedSessions) ValidateSession(ctx context.Context, userID, sessionID string) error {
+	if r[sessionID] {
+		return service.ErrSessionRevoked
+	}
+	return nil
+}
+
+// tenantLookup serves GetTenant from a map; other methods are not used
+type tenantLookup struct {
+	service.TenantService
+	tenants map[string]*model.Tenant
+}
+
+func (l tenantLookup) GetTenant(ctx context.Context, id string) (*model.Tenant, error) {
+	if tenant, ok := l.tenants[id]; ok {
+		return tenant, nil
+	}
+	return nil, errors.New("tenant not found")
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtService := jwt.NewJWTService(&config.Config{JWT: config.JWTConfig{Secret: "test-secret", ExpireTime: 900}})
+	tenants := tenantLookup{tenants: map[string]*model.Tenant{
+		"active":    {ID: "active", Status: model.TenantActive},
+		"suspended": {ID: "suspended", Status: model.TenantSuspended},
+	}}
+
+	engine := gin.New()
+	engine.GET("/", AuthMiddleware(jwtService, revokedSessions{"revoked": true}), ActiveTenant(tenants), RequirePasswordChanged(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	token := func(user *model.User, sessionID string, refresh bool) string {
+		var s string
+		var err error
+		if refresh {
+			s, err = jwtService.GenerateRefreshToken(user, sessionID, "jti")
+		} else {
+			s, err = jwtService.GenerateAccessToken(user, sessionID)
+		}
+		if err != nil {
+			t.Fatalf("generate token: %v", err)
+		}
+		return s
+	}
+	user := &model.User{ID: "u1", TenantID: "active", Role: model.RoleUser}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"access token", token(user, "s1", false), http.StatusOK},
+		{"no token", "", http.StatusUnauthorized},
+		{"refresh token", token(user, "s1", true), http.StatusUnauthorized},
+		{"revoked session", token(user, "revoked", false), http.StatusUnauthorized},
+		{"restricted token", token(&model.User{ID: "u1", TenantID: "active", MustChangePassword: true}, "s1", false), http.StatusForbidden},
+		{"suspended tenant", token(&model.User{ID: "u1", TenantID: "suspended"}, "s1", false), http.StatusForbidden},
+		{"missing tenant", token(&model.User{ID: "u1", TenantID: "deleted"}, "s1", false), http.StatusForbidden},
+		{"no tenant", token(&model.User{ID: "u1"}, "s1", false), http.StatusOK},
+		{"admin of a missing tenant", token(&model.User{ID: "u1", TenantID: "deleted", Role: model.RoleAdmin}, "s1", false), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // InstanceStatus is a custom type for instance status
@@ -17,35 +19,183 @@ const (
 
 // ClawInstance is the database model for claw instances
 type ClawInstance struct {
-	ID          string         `gorm:"primaryKey" json:"id"`
-	Name        string         `gorm:"not null" json:"name"`
-	TenantID    string         `gorm:"index;not null" json:"tenant_id"`
-	ProjectID   string         `gorm:"index" json:"project_id"`
-	Type        string         `gorm:"not null" json:"type"`
-	Version     string         `gorm:"not null" json:"version"`
-	Status      InstanceStatus `gorm:"not null;default:'Creating'" json:"status"`
-	Config      []byte         `json:"config"`
-	CPU         string         `json:"cpu"`
-	Memory      string         `json:"memory"`
-	ConfigDir   string         `json:"config_dir"`
-	DataDir     string         `json:"data_dir"`
-	StorageSize string         `json:"storage_size"`
-	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	ID        string         `gorm:"primaryKey" json:"id"`
+	Name      string         `gorm:"not null" json:"name"`
+	TenantID  string         `gorm:"index;not null" json:"tenant_id"`
+	ProjectID string         `gorm:"index" json:"project_id"`
+	Type      string         `gorm:"not null" json:"type"`
+	Version   string         `gorm:"not null" json:"version"`
+	Status    InstanceStatus `gorm:"not null;default:'Creating'" json:"status"`
+	Config    []byte         `json:"config"`
+	// Labels is a JSON object of key/value pairs used to select instances, e.g. for rollouts
+	Labels []byte `json:"labels"`
+	// TemplateID and TemplateRevision record the template revision the config was rendered from
+	TemplateID       string `gorm:"index" json:"template_id"`
+	TemplateRevision int    `json:"template_revision"`
+	// ConfigRevision is the number of the InstanceConfigRevision currently applied
+	ConfigRevision int       `gorm:"default:0" json:"config_revision"`
+	CPU            string    `json:"cpu"`
+	Memory         string    `json:"memory"`
+	ConfigDir      string    `json:"config_dir"`
+	DataDir        string    `json:"data_dir"`
+	StorageSize    string    `json:"storage_size"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	// DeletedAt is set while a cascading deletion can still restore the instance
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (ClawInstance) TableName() string {
 	return "claw_instances"
 }
 
+// InstanceConfigRevision is an immutable record of a config applied to an instance
+type InstanceConfigRevision struct {
+	ID          string `gorm:"primaryKey" json:"id"`
+	InstanceID  string `gorm:"uniqueIndex:idx_instance_config_revision;not null" json:"instance_id"`
+	Revision    int    `gorm:"uniqueIndex:idx_instance_config_revision;not null" json:"revision"`
+	ContentHash string `gorm:"not null" json:"content_hash"`
+	// Config is the resolved instance config, Files and EnvVars the rendered adapter output (all JSON)
+	Config    []byte `json:"config"`
+	Files     []byte `json:"files"`
+	EnvVars   []byte `json:"env_vars"`
+	Author    string `json:"author"`
+	Changelog string `json:"changelog"`
+	// RolledBackFrom is the revision this one restores, 0 for regular changes
+	RolledBackFrom int       `json:"rolled_back_from"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (InstanceConfigRevision) TableName() string {
+	return "instance_config_revisions"
+}
+
+// RolloutStatus is the lifecycle status of a config rollout
+type RolloutStatus string
+
+const (
+	RolloutPending   RolloutStatus = "Pending"
+	RolloutRunning   RolloutStatus = "Running"
+	RolloutSucceeded RolloutStatus = "Succeeded"
+	// RolloutFailed means the failure threshold was exceeded and applied instances were rolled back
+	RolloutFailed RolloutStatus = "Failed"
+	// RolloutAborted means the rollout was stopped by a user and applied instances were rolled back
+	RolloutAborted RolloutStatus = "Aborted"
+)
+
+// RolloutTargetStatus is the status of a single instance within a rollout
+type RolloutTargetStatus string
+
+const (
+	TargetPending    RolloutTargetStatus = "Pending"
+	TargetApplied    RolloutTargetStatus = "Applied"
+	TargetHealthy    RolloutTargetStatus = "Healthy"
+	TargetFailed     RolloutTargetStatus = "Failed"
+	TargetRolledBack RolloutTargetStatus = "RolledBack"
+)
+
+// ConfigRollout applies a config change to a set of instances in waves
+type ConfigRollout struct {
+	ID   string `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"not null" json:"name"`
+	// Selector and Patch are JSON; the patch is merged into each target's current config
+	Selector []byte `json:"selector"`
+	Patch    []byte `json:"patch"`
+	// Strategy
+	CanaryPercent      int `json:"canary_percent"`
+	BatchSize          int `json:"batch_size"`
+	PauseSeconds       int `json:"pause_seconds"`
+	HealthCheckSeconds int `json:"health_check_seconds"`
+	MaxFailures        int `json:"max_failures"`
+	// Progress
+	Status      RolloutStatus `gorm:"index;not null" json:"status"`
+	CurrentWave int           `json:"current_wave"`
+	TotalWaves  int           `json:"total_waves"`
+	Failures    int           `json:"failures"`
+	Message     string        `json:"message"`
+	Author      string        `json:"author"`
+	StartedAt   *time.Time    `json:"started_at"`
+	FinishedAt  *time.Time    `json:"finished_at"`
+	CreatedAt   time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (ConfigRollout) TableName() string {
+	return "config_rollouts"
+}
+
+// ConfigRolloutTarget tracks one instance of a rollout
+type ConfigRolloutTarget struct {
+	ID         string              `gorm:"primaryKey" json:"id"`
+	RolloutID  string              `gorm:"index;not null" json:"rollout_id"`
+	InstanceID string              `gorm:"index;not null" json:"instance_id"`
+	Wave       int                 `json:"wave"`
+	Status     RolloutTargetStatus `gorm:"not null" json:"status"`
+	// PreviousRevision is the config revision restored on rollback, AppliedRevision the one the rollout created
+	PreviousRevision int `json:"previous_revision"`
+	AppliedRevision  int `json:"applied_revision"`
+	// WasRunning and RestartsBefore capture the instance state before the change for health checks
+	WasRunning     bool      `json:"was_running"`
+	RestartsBefore int32     `json:"restarts_before"`
+	Message        string    `json:"message"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (ConfigRolloutTarget) TableName() string {
+	return "config_rollout_targets"
+}
+
+// VariableScope is the level a shared variable is defined at
+type VariableScope string
+
+const (
+	VariableScopeGlobal   VariableScope = "global"
+	VariableScopeTenant   VariableScope = "tenant"
+	VariableScopeProject  VariableScope = "project"
+	VariableScopeInstance VariableScope = "instance"
+)
+
+// SharedVariable is a named value referenced from templates and overrides as ${var.NAME}.
+// A variable at a more specific scope takes precedence over one with the same name at a broader scope.
+type SharedVariable struct {
+	ID    string        `gorm:"primaryKey" json:"id"`
+	Scope VariableScope `gorm:"uniqueIndex:idx_shared_variable;not null" json:"scope"`
+	// ScopeID is the tenant, project or instance ID; empty for global variables
+	ScopeID string `gorm:"uniqueIndex:idx_shared_variable" json:"scope_id"`
+	Name    string `gorm:"uniqueIndex:idx_shared_variable;not null" json:"name"`
+	// Value is encrypted when Secret is set
+	Value       string         `json:"-"`
+	Secret      bool           `json:"secret"`
+	Description string         `json:"description"`
+	UpdatedBy   string         `json:"updated_by"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (SharedVariable) TableName() string {
+	return "shared_variables"
+}
+
 // ConfigTemplate is the database model for config templates
 type ConfigTemplate struct {
-	ID          string    `gorm:"primaryKey;uniqueIndex" json:"id"`
-	Name        string    `gorm:"uniqueIndex;not null" json:"name"`
-	Description string    `json:"description"`
-	Variables   []byte    `json:"variables"`
-	AdapterType string    `gorm:"not null" json:"adapter_type"`
-	Version     string    `gorm:"default:'1.0.0'" json:"version"`
+	ID          string `gorm:"primaryKey;uniqueIndex" json:"id"`
+	Name        string `gorm:"uniqueIndex;not null" json:"name"`
+	Description string `json:"description"`
+	Variables   []byte `json:"variables"`
+	AdapterType string `gorm:"not null" json:"adapter_type"`
+	Version     string `gorm:"default:'1.0.0'" json:"version"`
+	// Kind is "template" for templates instances are created from, or "fragment" for partial templates that are only composed into others
+	Kind string `gorm:"default:'template'" json:"kind"`
+	// ParentID is the template this one inherits from
+	ParentID string `gorm:"index" json:"parent_id"`
+	// Fragments is a JSON array of fragment IDs merged in order after the parent
+	Fragments []byte `json:"fragments"`
+	// ResolvedVariables are the variables after the parent and fragments were merged, empty for templates that compose nothing
+	ResolvedVariables []byte `json:"resolved_variables"`
+	// Revision is the number of the latest ConfigTemplateRevision, 0 for templates created before revisions existed
+	Revision    int       `gorm:"default:0" json:"revision"`
+	ContentHash string    `json:"content_hash"`
 	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
@@ -54,31 +204,178 @@ func (ConfigTemplate) TableName() string {
 	return "config_templates"
 }
 
+// ConfigTemplateRevision is an immutable snapshot of a config template
+type ConfigTemplateRevision struct {
+	ID          string `gorm:"primaryKey" json:"id"`
+	TemplateID  string `gorm:"uniqueIndex:idx_template_revision;not null" json:"template_id"`
+	Revision    int    `gorm:"uniqueIndex:idx_template_revision;not null" json:"revision"`
+	ContentHash string `gorm:"not null" json:"content_hash"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Variables   []byte `json:"variables"`
+	AdapterType string `json:"adapter_type"`
+	Version     string `json:"version"`
+	Kind        string `json:"kind"`
+	ParentID    string `json:"parent_id"`
+	Fragments   []byte `json:"fragments"`
+	// ResolvedVariables pins the merged variables so the revision renders the same after its parent or fragments change
+	ResolvedVariables []byte    `json:"resolved_variables"`
+	Author            string    `json:"author"`
+	Changelog         string    `json:"changelog"`
+	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (ConfigTemplateRevision) TableName() string {
+	return "config_template_revisions"
+}
+
+// TenantStatus is the lifecycle status of a tenant
+type TenantStatus string
+
+const (
+	TenantActive TenantStatus = "Active"
+	// TenantSuspended tenants keep their data but their instances are stopped and their users locked out
+	TenantSuspended TenantStatus = "Suspended"
+	// TenantArchived tenants are suspended with no instances to bring back on resume
+	TenantArchived TenantStatus = "Archived"
+)
+
 // Tenant is the database model for tenants
 type Tenant struct {
-	ID           string    `gorm:"primaryKey;uniqueIndex" json:"id"`
-	Name         string    `gorm:"uniqueIndex;not null" json:"name"`
-	MaxInstances int       `gorm:"default:10" json:"max_instances"`
-	MaxCPU       string    `gorm:"default:'10'" json:"max_cpu"`
-	MaxMemory    string    `gorm:"default:'20Gi'" json:"max_memory"`
-	MaxStorage   string    `gorm:"default:'100Gi'" json:"max_storage"`
-	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID              string       `gorm:"primaryKey;uniqueIndex" json:"id"`
+	Name            string       `gorm:"uniqueIndex;not null" json:"name"`
+	MaxInstances    int          `gorm:"default:10" json:"max_instances"`
+	MaxCPU          string       `gorm:"default:'10'" json:"max_cpu"`
+	MaxMemory       string       `gorm:"default:'20Gi'" json:"max_memory"`
+	MaxStorage      string       `gorm:"default:'100Gi'" json:"max_storage"`
+	Status          TenantStatus `gorm:"not null;default:'Active'" json:"status"`
+	StatusReason    string       `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time   `json:"status_changed_at,omitempty"`
+	// SuspendedInstances is a JSON list of the instances that were running when the tenant
+	// was suspended; resuming the tenant starts them again
+	SuspendedInstances []byte    `json:"-"`
+	CreatedAt          time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	// DeletedAt is set while a cascading deletion of the tenant can still be restored
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (Tenant) TableName() string {
 	return "tenants"
 }
 
+// IsActive reports whether the tenant is neither suspended nor archived
+func (t *Tenant) IsActive() bool {
+	return t.Status == "" || t.Status == TenantActive
+}
+
 // Project is the database model for projects
 type Project struct {
-	ID        string    `gorm:"primaryKey" json:"id"`
-	TenantID  string    `gorm:"index;not null" json:"tenant_id"`
-	Name      string    `gorm:"not null" json:"name"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID       string `gorm:"primaryKey" json:"id"`
+	TenantID string `gorm:"index;not null" json:"tenant_id"`
+	Name     string `gorm:"not null" json:"name"`
+	// Quota allocated to the project out of the tenant quota; empty or zero limits leave
+	// the project bounded only by the tenant
+	MaxInstances int    `json:"max_instances"`
+	MaxCPU       string `json:"max_cpu"`
+	MaxMemory    string `json:"max_memory"`
+	MaxStorage   string `json:"max_storage"`
+	// Resources given to instances created in the project without their own
+	DefaultCPU     string         `json:"default_cpu"`
+	DefaultMemory  string         `json:"default_memory"`
+	DefaultStorage string         `json:"default_storage"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (Project) TableName() string {
 	return "projects"
-}
\ No newline at end of file
+}
+
+// Role is a custom role granting a set of permissions; built-in roles are defined in code
+type Role struct {
+	ID          string `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"uniqueIndex;not null" json:"name"`
+	Description string `json:"description"`
+	// Permissions is a JSON array of "resource:verb" strings; either part may be "*"
+	Permissions []byte    `json:"-"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+// BindingScope is the level a role binding applies at
+type BindingScope string
+
+const (
+	BindingScopeGlobal  BindingScope = "global"
+	BindingScopeTenant  BindingScope = "tenant"
+	BindingScopeProject BindingScope = "project"
+)
+
+// RoleBinding grants a user a role within a scope
+type RoleBinding struct {
+	ID     string       `gorm:"primaryKey" json:"id"`
+	UserID string       `gorm:"uniqueIndex:idx_role_binding;not null" json:"user_id"`
+	Role   string       `gorm:"uniqueIndex:idx_role_binding;index;not null" json:"role"`
+	Scope  BindingScope `gorm:"uniqueIndex:idx_role_binding;not null" json:"scope"`
+	// ScopeID is the tenant or project ID; empty for global bindings
+	ScopeID string `gorm:"uniqueIndex:idx_role_binding" json:"scope_id"`
+	// TenantID is the tenant of tenant and project bindings
+	TenantID  string         `gorm:"index" json:"tenant_id"`
+	CreatedBy string         `json:"created_by"`
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (RoleBinding) TableName() string {
+	return "role_bindings"
+}
+
+// DeletionKind is the kind of object a cascading deletion removes
+type DeletionKind string
+
+const (
+	DeletionKindTenant  DeletionKind = "tenant"
+	DeletionKindProject DeletionKind = "project"
+)
+
+// DeletionStatus is the lifecycle status of a cascading deletion
+type DeletionStatus string
+
+const (
+	DeletionPending DeletionStatus = "Pending"
+	DeletionRunning DeletionStatus = "Running"
+	// DeletionSoftDeleted deletions have hidden their records, which can be restored until PurgeAfter
+	DeletionSoftDeleted DeletionStatus = "SoftDeleted"
+	DeletionRestored    DeletionStatus = "Restored"
+	DeletionPurged      DeletionStatus = "Purged"
+	DeletionFailed      DeletionStatus = "Failed"
+)
+
+// DeletionOperation removes a tenant or project with everything it owns in the background
+type DeletionOperation struct {
+	ID         string       `gorm:"primaryKey" json:"id"`
+	Kind       DeletionKind `gorm:"not null" json:"kind"`
+	TargetID   string       `gorm:"index;not null" json:"target_id"`
+	TargetName string       `json:"target_name"`
+	// TenantID is the deleted tenant, or the tenant of the deleted project
+	TenantID string         `gorm:"index" json:"tenant_id"`
+	Status   DeletionStatus `gorm:"index;not null" json:"status"`
+	// Report is a JSON list of the records the deletion covers, taken when it ran
+	Report      []byte     `json:"-"`
+	Message     string     `json:"message"`
+	RequestedBy string     `json:"requested_by"`
+	PurgeAfter  *time.Time `json:"purge_after"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (DeletionOperation) TableName() string {
+	return "deletion_operations"
+}
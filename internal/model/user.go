@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 // UserRole represents the role of a user
@@ -25,14 +27,57 @@ type User struct {
 	OTPBackupCodes    *string    `json:"-"`                      // JSON array of backup codes
 	TempOTPToken      *string    `gorm:"index" json:"-"`         // Temporary token for login OTP verification
 	TempOTPTokenExpiresAt *time.Time `json:"-"`                  // Expiration time for temp token
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"`
+	// MustChangePassword restricts the user to changing their password until they do so
+	MustChangePassword bool      `gorm:"default:false" json:"must_change_password"`
 	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt         time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	// DeletedAt hides users of a tenant under cascading deletion until it is purged or restored
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 func (User) TableName() string {
 	return "users"
 }
 
+// PasswordHistory keeps a previous password hash of a user so that the password policy can
+// refuse its reuse
+type PasswordHistory struct {
+	ID           uint      `gorm:"primaryKey" json:"-"`
+	UserID       string    `gorm:"index;not null" json:"-"`
+	PasswordHash string    `gorm:"not null" json:"-"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"-"`
+}
+
+func (PasswordHistory) TableName() string {
+	return "password_histories"
+}
+
+// Session is a login of a user on one client. Its refresh token is rotated on every refresh;
+// only the refresh token with RefreshJTI is valid, so presenting an older one reveals a stolen
+// token and revokes the session.
+type Session struct {
+	ID            string     `gorm:"primaryKey" json:"id"`
+	UserID        string     `gorm:"index;not null" json:"user_id"`
+	RefreshJTI    string     `gorm:"uniqueIndex;not null" json:"-"`
+	UserAgent     string     `json:"user_agent"`
+	ClientIP      string     `json:"client_ip"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	LastUsedAt    time.Time  `json:"last_used_at"`
+	ExpiresAt     time.Time  `gorm:"index;not null" json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason,omitempty"`
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// IsActive reports whether the session is neither revoked nor expired
+func (s *Session) IsActive() bool {
+	return s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
 // UserResponse represents user information for API responses (without sensitive data)
 type UserResponse struct {
 	ID        string    `json:"id"`
@@ -40,6 +85,8 @@ type UserResponse struct {
 	TenantID  string    `json:"tenant_id"`
 	Role      UserRole  `json:"role"`
 	IsActive  bool      `json:"is_active"`
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty"`
+	MustChangePassword bool      `json:"must_change_password"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -51,6 +98,8 @@ func (u *User) ToResponse() UserResponse {
 		TenantID:  u.TenantID,
 		Role:      u.Role,
 		IsActive:  u.IsActive,
+		PasswordChangedAt: u.PasswordChangedAt,
+		MustChangePassword: u.MustChangePassword,
 		CreatedAt: u.CreatedAt,
 	}
 }
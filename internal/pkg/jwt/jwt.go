@@ -15,6 +15,16 @@ var (
 	ErrInvalidToken = errors.New("invalid token")
 	// ErrExpiredToken is returned when the token has expired
 	ErrExpiredToken = errors.New("token has expired")
+	// ErrWrongTokenType is returned when a refresh token is used as access token or vice versa
+	ErrWrongTokenType = errors.New("wrong token type")
+)
+
+// TokenType tells access tokens and refresh tokens apart
+type TokenType string
+
+const (
+	TokenTypeAccess  TokenType = "access"
+	TokenTypeRefresh TokenType = "refresh"
 )
 
 // Claims represents JWT claims
@@ -23,6 +33,12 @@ type Claims struct {
 	Username string      `json:"username"`
 	TenantID string      `json:"tenant_id"`
 	Role     model.UserRole `json:"role"`
+	// MustChangePassword marks a restricted token that can only be used to change the password
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+	TokenType          TokenType `json:"token_type"`
+	// SessionID is the login session the token belongs to; the token is only valid while the
+	// session is. The ID of a refresh token (jti) is rotated on every refresh.
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
@@ -36,7 +52,10 @@ type JWTService struct {
 // NewJWTService creates a new JWT service
 func NewJWTService(cfg *config.Config) *JWTService {
 	accessExp := time.Duration(cfg.JWT.ExpireTime) * time.Second
-	refreshExp := 7 * 24 * time.Hour // 7 days for refresh token
+	refreshExp := time.Duration(cfg.JWT.RefreshExpireTime) * time.Second
+	if refreshExp <= 0 {
+		refreshExp = 7 * 24 * time.Hour // 7 days for refresh token
+	}
 
 	return &JWTService{
 		secret:      []byte(cfg.JWT.Secret),
@@ -45,14 +64,17 @@ func NewJWTService(cfg *config.Config) *JWTService {
 	}
 }
 
-// GenerateAccessToken generates a new access token
-func (s *JWTService) GenerateAccessToken(user *model.User) (string, error) {
+// GenerateAccessToken generates a new access token for a session
+func (s *JWTService) GenerateAccessToken(user *model.User, sessionID string) (string, error) {
 	now := time.Now()
 	claims := Claims{
 		UserID:   user.ID,
 		Username: user.Username,
 		TenantID: user.TenantID,
 		Role:     user.Role,
+		MustChangePassword: user.MustChangePassword,
+		TokenType:          TokenTypeAccess,
+		SessionID:          sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessExp)),
 			IssuedAt:  jwt.NewNumericDate(now),
@@ -64,15 +86,19 @@ func (s *JWTService) GenerateAccessToken(user *model.User) (string, error) {
 	return token.SignedString(s.secret)
 }
 
-// GenerateRefreshToken generates a new refresh token
-func (s *JWTService) GenerateRefreshToken(user *model.User) (string, error) {
+// GenerateRefreshToken generates a new refresh token for a session with the given token ID
+func (s *JWTService) GenerateRefreshToken(user *model.User, sessionID, jti string) (string, error) {
 	now := time.Now()
 	claims := Claims{
 		UserID:   user.ID,
 		Username: user.Username,
 		TenantID: user.TenantID,
 		Role:     user.Role,
+		MustChangePassword: user.MustChangePassword,
+		TokenType:          TokenTypeRefresh,
+		SessionID:          sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(now.Add(s.refreshExp)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
@@ -108,6 +134,27 @@ func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 	return claims, nil
 }
 
+// ValidateAccessToken validates a token and checks that it is an access token
+func (s *JWTService) ValidateAccessToken(tokenString string) (*Claims, error) {
+	return s.validateType(tokenString, TokenTypeAccess)
+}
+
+// ValidateRefreshToken validates a token and checks that it is a refresh token
+func (s *JWTService) ValidateRefreshToken(tokenString string) (*Claims, error) {
+	return s.validateType(tokenString, TokenTypeRefresh)
+}
+
+func (s *JWTService) validateType(tokenString string, tokenType TokenType) (*Claims, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != tokenType || claims.SessionID == "" {
+		return nil, ErrWrongTokenType
+	}
+	return claims, nil
+}
+
 // GetAccessExpiration returns the access token expiration duration
 func (s *JWTService) GetAccessExpiration() int64 {
 	return int64(s.accessExp.Seconds())
@@ -0,0 +1,90 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/weibh/openClusterClaw/config"
+	"github.com/weibh/openClusterClaw/internal/model"
+)
+
+func newTestService(secret string) *JWTService {
+	return NewJWTService(&config.Config{JWT: config.JWTConfig{Secret: secret, ExpireTime: 900}})
+}
+
+func TestTokenTypes(t *testing.T) {
+	s := newTestService("test-secret")
+	user := &model.User{ID: "u1", Username: "alice", TenantID: "t1", Role: model.RoleUser}
+
+	access, err := s.GenerateAccessToken(user, "s1")
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	refresh, err := s.GenerateRefreshToken(user, "s1", "jti-1")
+	if err != nil {
+		t.Fatalf("GenerateRefreshToken: %v", err)
+	}
+
+	claims, err := s.ValidateAccessToken(access)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken: %v", err)
+	}
+	if claims.UserID != "u1" || claims.TenantID != "t1" || claims.Role != model.RoleUser || claims.SessionID != "s1" {
+		t.Errorf("access claims = %+v", claims)
+	}
+	claims, err = s.ValidateRefreshToken(refresh)
+	if err != nil {
+		t.Fatalf("ValidateRefreshToken: %v", err)
+	}
+	if claims.ID != "jti-1" || claims.SessionID != "s1" {
+		t.Errorf("refresh claims = %+v", claims)
+	}
+
+	if _, err := s.ValidateAccessToken(refresh); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("refresh token as access token = %v, want ErrWrongTokenType", err)
+	}
+	if _, err := s.ValidateRefreshToken(access); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("access token as refresh token = %v, want ErrWrongTokenType", err)
+	}
+	if _, err := newTestService("other-secret").ValidateAccessToken(access); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token signed with another secret = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestTokensWithoutSessionAreRejected(t *testing.T) {
+	s := newTestService("test-secret")
+	access, err := s.GenerateAccessToken(&model.User{ID: "u1"}, "")
+	if err != nil {
+		t.Fatalf("GenerateAccessToken: %v", err)
+	}
+	if _, err := s.ValidateAccessToken(access); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("token without a session = %v, want ErrWrongTokenType", err)
+	}
+}
+
+func TestRestrictedTokens(t *testing.T) {
+	s := newTestService("test-secret")
+	for _, mustChange := range []bool{true, false} {
+		user := &model.User{ID: "u1", MustChangePassword: mustChange}
+		access, err := s.GenerateAccessToken(user, "s1")
+		if err != nil {
+			t.Fatalf("GenerateAccessToken: %v", err)
+		}
+		refresh, err := s.GenerateRefreshToken(user, "s1", "jti-1")
+		if err != nil {
+			t.Fatalf("GenerateRefreshToken: %v", err)
+		}
+
+		accessClaims, err := s.ValidateAccessToken(access)
+		if err != nil {
+			t.Fatalf("ValidateAccessToken: %v", err)
+		}
+		refreshClaims, err := s.ValidateRefreshToken(refresh)
+		if err != nil {
+			t.Fatalf("ValidateRefreshToken: %v", err)
+		}
+		if accessClaims.MustChangePassword != mustChange || refreshClaims.MustChangePassword != mustChange {
+			t.Errorf("must_change_password = %v/%v, want %v", accessClaims.MustChangePassword, refreshClaims.MustChangePassword, mustChange)
+		}
+	}
+}
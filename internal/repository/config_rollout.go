@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"gorm.io/gorm"
+)
+
+// configRolloutRepository implements ConfigRolloutRepository
+type configRolloutRepository struct {
+	db *gorm.DB
+}
+
+// NewConfigRolloutRepository creates a new config rollout repository
+func NewConfigRolloutRepository(db *gorm.DB) ConfigRolloutRepository {
+	return &configRolloutRepository{db: db}
+}
+
+// Create stores a rollout together with its targets
+func (r *configRolloutRepository) Create(ctx context.Context, rollout *model.ConfigRollout, targets []*model.ConfigRolloutTarget) error {
+	if rollout.ID == "" {
+		rollout.ID = uuid.New().String()
+	}
+	for _, target := range targets {
+		if target.ID == "" {
+			target.ID = uuid.New().String()
+		}
+		target.RolloutID = rollout.ID
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(rollout).Error; err != nil {
+			return err
+		}
+		if len(targets) > 0 {
+			return tx.Create(&targets).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create config rollout: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a rollout by ID
+func (r *configRolloutRepository) GetByID(ctx context.Context, id string) (*model.ConfigRollout, error) {
+	var rollout model.ConfigRollout
+	result := r.db.WithContext(ctx).Where("id = ?", id).First(&rollout)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("config rollout not found")
+		}
+		return nil, fmt.Errorf("failed to get config rollout: %w", result.Error)
+	}
+	return &rollout, nil
+}
+
+// List retrieves rollouts, newest first
+func (r *configRolloutRepository) List(ctx context.Context, limit, offset int) ([]*model.ConfigRollout, int, error) {
+	var rollouts []*model.ConfigRollout
+	query := r.db.WithContext(ctx).Model(&model.ConfigRollout{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count config rollouts: %w", err)
+	}
+
+	result := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&rollouts)
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("failed to list config rollouts: %w", result.Error)
+	}
+	return rollouts, int(total), nil
+}
+
+// ListByStatus retrieves the rollouts in any of the given statuses
+func (r *configRolloutRepository) ListByStatus(ctx context.Context, statuses ...model.RolloutStatus) ([]*model.ConfigRollout, error) {
+	var rollouts []*model.ConfigRollout
+	result := r.db.WithContext(ctx).Where("status IN ?", statuses).Order("created_at ASC").Find(&rollouts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list config rollouts: %w", result.Error)
+	}
+	return rollouts, nil
+}
+
+// Update saves the progress of a rollout
+func (r *configRolloutRepository) Update(ctx context.Context, rollout *model.ConfigRollout) error {
+	result := r.db.WithContext(ctx).Model(rollout).Updates(map[string]any{
+		"status":       rollout.Status,
+		"current_wave": rollout.CurrentWave,
+		"failures":     rollout.Failures,
+		"message":      rollout.Message,
+		"started_at":   rollout.StartedAt,
+		"finished_at":  rollout.FinishedAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update config rollout: %w", result.Error)
+	}
+	return nil
+}
+
+// ListTargets retrieves the targets of a rollout ordered by wave
+func (r *configRolloutRepository) ListTargets(ctx context.Context, rolloutID string) ([]*model.ConfigRolloutTarget, error) {
+	var targets []*model.ConfigRolloutTarget
+	result := r.db.WithContext(ctx).Where("rollout_id = ?", rolloutID).Order("wave ASC, id ASC").Find(&targets)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list config rollout targets: %w", result.Error)
+	}
+	return targets, nil
+}
+
+// UpdateTarget saves the progress of a single target
+func (r *configRolloutRepository) UpdateTarget(ctx context.Context, target *model.ConfigRolloutTarget) error {
+	result := r.db.WithContext(ctx).Model(target).Updates(map[string]any{
+		"status":            target.Status,
+		"previous_revision": target.PreviousRevision,
+		"applied_revision":  target.AppliedRevision,
+		"was_running":       target.WasRunning,
+		"restarts_before":   target.RestartsBefore,
+		"message":           target.Message,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update config rollout target: %w", result.Error)
+	}
+	return nil
+}
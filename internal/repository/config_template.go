@@ -97,15 +97,34 @@ func (r *configTemplateRepository) ListWithFilter(ctx context.Context, adapterTy
 	return templates, int(total), nil
 }
 
+// ListDependents retrieves the templates that inherit from or include the given template
+func (r *configTemplateRepository) ListDependents(ctx context.Context, id string) ([]*model.ConfigTemplate, error) {
+	var templates []*model.ConfigTemplate
+	result := r.db.WithContext(ctx).
+		Where("parent_id = ?", id).
+		Or("CAST(fragments AS TEXT) LIKE ?", "%\""+id+"\"%").
+		Order("name ASC").
+		Find(&templates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list dependent config templates: %w", result.Error)
+	}
+	return templates, nil
+}
+
 // Update updates a config template
 func (r *configTemplateRepository) Update(ctx context.Context, template *model.ConfigTemplate) error {
 	template.UpdatedAt = time.Now()
 	result := r.db.WithContext(ctx).Model(template).Updates(map[string]any{
-		"name":         template.Name,
-		"description":  template.Description,
-		"variables":    template.Variables,
-		"adapter_type": template.AdapterType,
-		"version":      template.Version,
+		"name":               template.Name,
+		"description":        template.Description,
+		"variables":          template.Variables,
+		"adapter_type":       template.AdapterType,
+		"version":            template.Version,
+		"revision":           template.Revision,
+		"content_hash":       template.ContentHash,
+		"parent_id":          template.ParentID,
+		"fragments":          template.Fragments,
+		"resolved_variables": template.ResolvedVariables,
 	})
 	if result.Error != nil {
 		return fmt.Errorf("failed to update config template: %w", result.Error)
@@ -123,4 +142,4 @@ func (r *configTemplateRepository) Delete(ctx context.Context, id string) error
 		return fmt.Errorf("config template not found")
 	}
 	return nil
-}
\ No newline at end of file
+}
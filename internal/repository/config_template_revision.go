@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"gorm.io/gorm"
+)
+
+// configTemplateRevisionRepository implements ConfigTemplateRevisionRepository
+type configTemplateRevisionRepository struct {
+	db *gorm.DB
+}
+
+// NewConfigTemplateRevisionRepository creates a new config template revision repository
+func NewConfigTemplateRevisionRepository(db *gorm.DB) ConfigTemplateRevisionRepository {
+	return &configTemplateRevisionRepository{db: db}
+}
+
+// Create stores a new revision
+func (r *configTemplateRevisionRepository) Create(ctx context.Context, revision *model.ConfigTemplateRevision) error {
+	if revision.ID == "" {
+		revision.ID = uuid.New().String()
+	}
+
+	result := r.db.WithContext(ctx).Create(revision)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create config template revision: %w", result.Error)
+	}
+	return nil
+}
+
+// Get retrieves a revision of a template by number
+func (r *configTemplateRevisionRepository) Get(ctx context.Context, templateID string, revision int) (*model.ConfigTemplateRevision, error) {
+	var rev model.ConfigTemplateRevision
+	result := r.db.WithContext(ctx).Where("template_id = ? AND revision = ?", templateID, revision).First(&rev)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("config template revision not found")
+		}
+		return nil, fmt.Errorf("failed to get config template revision: %w", result.Error)
+	}
+	return &rev, nil
+}
+
+// ListByTemplate retrieves the revisions of a template, newest first
+func (r *configTemplateRevisionRepository) ListByTemplate(ctx context.Context, templateID string, limit, offset int) ([]*model.ConfigTemplateRevision, int, error) {
+	var revisions []*model.ConfigTemplateRevision
+	query := r.db.WithContext(ctx).Model(&model.ConfigTemplateRevision{}).Where("template_id = ?", templateID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count config template revisions: %w", err)
+	}
+
+	result := query.Order("revision DESC").Limit(limit).Offset(offset).Find(&revisions)
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("failed to list config template revisions: %w", result.Error)
+	}
+	return revisions, int(total), nil
+}
+
+// DeleteByTemplate deletes all revisions of a template
+func (r *configTemplateRevisionRepository) DeleteByTemplate(ctx context.Context, templateID string) error {
+	result := r.db.WithContext(ctx).Where("template_id = ?", templateID).Delete(&model.ConfigTemplateRevision{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete config template revisions: %w", result.Error)
+	}
+	return nil
+}
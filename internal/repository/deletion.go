@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"gorm.io/gorm"
+)
+
+// deletionRepository implements DeletionRepository
+type deletionRepository struct {
+	db *gorm.DB
+}
+
+// NewDeletionRepository creates a new cascading deletion repository
+func NewDeletionRepository(db *gorm.DB) DeletionRepository {
+	return &deletionRepository{db: db}
+}
+
+// Create stores a new deletion operation
+func (r *deletionRepository) Create(ctx context.Context, operation *model.DeletionOperation) error {
+	if operation.ID == "" {
+		operation.ID = uuid.New().String()
+	}
+
+	result := r.db.WithContext(ctx).Create(operation)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create deletion: %w", result.Error)
+	}
+	return nil
+}
+
+// GetByID retrieves a deletion operation by ID
+func (r *deletionRepository) GetByID(ctx context.Context, id string) (*model.DeletionOperation, error) {
+	var operation model.DeletionOperation
+	result := r.db.WithContext(ctx).Where("id = ?", id).First(&operation)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("deletion not found")
+		}
+		return nil, fmt.Errorf("failed to get deletion: %w", result.Error)
+	}
+	return &operation, nil
+}
+
+// List retrieves deletion operations, newest first; an empty tenantID lists all
+func (r *deletionRepository) List(ctx context.Context, tenantID string, limit, offset int) ([]*model.DeletionOperation, int, error) {
+	var operations []*model.DeletionOperation
+	query := r.db.WithContext(ctx).Model(&model.DeletionOperation{})
+	if tenantID != "" {
+		query = query.Where("tenant_id = ?", tenantID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count deletions: %w", err)
+	}
+
+	result := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&operations)
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("failed to list deletions: %w", result.Error)
+	}
+	return operations, int(total), nil
+}
+
+// ListByStatus retrieves the deletion operations in any of the given statuses, oldest first
+func (r *deletionRepository) ListByStatus(ctx context.Context, statuses ...model.DeletionStatus) ([]*model.DeletionOperation, error) {
+	var operations []*model.DeletionOperation
+	result := r.db.WithContext(ctx).Where("status IN ?", statuses).Order("created_at ASC").Find(&operations)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list deletions: %w", result.Error)
+	}
+	return operations, nil
+}
+
+// Update saves the progress of a deletion operation
+func (r *deletionRepository) Update(ctx context.Context, operation *model.DeletionOperation) error {
+	result := r.db.WithContext(ctx).Model(operation).Updates(map[string]any{
+		"status":      operation.Status,
+		"report":      operation.Report,
+		"message":     operation.Message,
+		"purge_after": operation.PurgeAfter,
+		"finished_at": operation.FinishedAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update deletion: %w", result.Error)
+	}
+	return nil
+}
+
+// SessionRevokedUserDeleted is the reason recorded on the sessions of soft-deleted users
+const SessionRevokedUserDeleted = "user_deleted"
+
+// SoftDelete hides the records in one transaction, dependents before their owners. The sessions
+// of the hidden users are revoked, so their tokens stop working during the grace period.
+func (r *deletionRepository) SoftDelete(ctx context.Context, records *DeletionRecords) error {
+	now := time.Now()
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(records.Users) > 0 {
+			if err := tx.Model(&model.Session{}).Where("user_id IN ? AND revoked_at IS NULL", records.Users).
+				Updates(map[string]any{"revoked_at": now, "revoked_reason": SessionRevokedUserDeleted}).Error; err != nil {
+				return err
+			}
+		}
+		for _, set := range records.sets() {
+			if len(set.ids) == 0 {
+				continue
+			}
+			if err := tx.Model(set.model).Where("id IN ?", set.ids).Update("deleted_at", now).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to soft-delete records: %w", err)
+	}
+	return nil
+}
+
+// Restore brings back soft-deleted records in one transaction
+func (r *deletionRepository) Restore(ctx context.Context, records *DeletionRecords) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, set := range records.sets() {
+			if len(set.ids) == 0 {
+				continue
+			}
+			if err := tx.Unscoped().Model(set.model).Where("id IN ?", set.ids).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore records: %w", err)
+	}
+	return nil
+}
+
+// Purge removes the records, the config revisions of their instances and the password history
+// and sessions of their users in one transaction
+func (r *deletionRepository) Purge(ctx context.Context, records *DeletionRecords) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(records.Instances) > 0 {
+			if err := tx.Where("instance_id IN ?", records.Instances).Delete(&model.InstanceConfigRevision{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(records.Users) > 0 {
+			if err := tx.Where("user_id IN ?", records.Users).Delete(&model.PasswordHistory{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id IN ?", records.Users).Delete(&model.Session{}).Error; err != nil {
+				return err
+			}
+		}
+		for _, set := range records.sets() {
+			if len(set.ids) == 0 {
+				continue
+			}
+			if err := tx.Unscoped().Where("id IN ?", set.ids).Delete(set.model).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to purge records: %w", err)
+	}
+	return nil
+}
+
+type recordSet struct {
+	model any
+	ids   []string
+}
+
+// sets returns the records by table, dependents before their owners
+func (d *DeletionRecords) sets() []recordSet {
+	return []recordSet{
+		{&model.ClawInstance{}, d.Instances},
+		{&model.SharedVariable{}, d.Variables},
+		{&model.RoleBinding{}, d.RoleBindings},
+		{&model.Project{}, d.Projects},
+		{&model.User{}, d.Users},
+		{&model.Tenant{}, d.Tenants},
+	}
+}
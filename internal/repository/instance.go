@@ -3,6 +3,8 @@ package repository
 import (
 	"context"
 	"fmt"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/weibh/openClusterClaw/internal/model"
 	"gorm.io/gorm"
@@ -15,19 +17,138 @@ type InstanceRepository interface {
 	List(ctx context.Context, tenantID, projectID string, limit, offset int) ([]*model.ClawInstance, error)
 	Update(ctx context.Context, instance *model.ClawInstance) error
 	UpdateStatus(ctx context.Context, id string, status model.InstanceStatus) error
+	// UpdateStatusFrom changes the status only while it is still from, and reports whether it did
+	UpdateStatusFrom(ctx context.Context, id string, from, to model.InstanceStatus) (bool, error)
+	Delete(ctx context.Context, id string) error
+	CountByTemplateRevision(ctx context.Context, templateID string) (map[int]int, error)
+	ListByFilter(ctx context.Context, filter InstanceFilter) ([]*model.ClawInstance, error)
+}
+
+// InstanceFilter selects instances by column values; empty fields match any value
+type InstanceFilter struct {
+	TenantID   string
+	ProjectID  string
+	TemplateID string
+	IDs        []string
+}
+
+// ConfigRolloutRepository defines the interface for config rollout data access
+type ConfigRolloutRepository interface {
+	Create(ctx context.Context, rollout *model.ConfigRollout, targets []*model.ConfigRolloutTarget) error
+	GetByID(ctx context.Context, id string) (*model.ConfigRollout, error)
+	List(ctx context.Context, limit, offset int) ([]*model.ConfigRollout, int, error)
+	ListByStatus(ctx context.Context, statuses ...model.RolloutStatus) ([]*model.ConfigRollout, error)
+	Update(ctx context.Context, rollout *model.ConfigRollout) error
+	ListTargets(ctx context.Context, rolloutID string) ([]*model.ConfigRolloutTarget, error)
+	UpdateTarget(ctx context.Context, target *model.ConfigRolloutTarget) error
+}
+
+// SharedVariableRepository defines the interface for shared variable data access
+type SharedVariableRepository interface {
+	Create(ctx context.Context, variable *model.SharedVariable) error
+	GetByID(ctx context.Context, id string) (*model.SharedVariable, error)
+	// List retrieves the variables defined at a scope; an empty scope lists all variables
+	List(ctx context.Context, scope model.VariableScope, scopeID string) ([]*model.SharedVariable, error)
+	// ListForInstance retrieves the global variables and those defined for the given tenant, project and instance
+	ListForInstance(ctx context.Context, tenantID, projectID, instanceID string) ([]*model.SharedVariable, error)
+	Update(ctx context.Context, variable *model.SharedVariable) error
+	Delete(ctx context.Context, id string) error
+}
+
+// RoleRepository defines the interface for custom role data access
+type RoleRepository interface {
+	Create(ctx context.Context, role *model.Role) error
+	GetByName(ctx context.Context, name string) (*model.Role, error)
+	List(ctx context.Context) ([]*model.Role, error)
+	Update(ctx context.Context, role *model.Role) error
+	Delete(ctx context.Context, id string) error
+}
+
+// RoleBindingRepository defines the interface for role binding data access
+type RoleBindingRepository interface {
+	Create(ctx context.Context, binding *model.RoleBinding) error
+	GetByID(ctx context.Context, id string) (*model.RoleBinding, error)
+	List(ctx context.Context, filter RoleBindingFilter) ([]*model.RoleBinding, error)
 	Delete(ctx context.Context, id string) error
 }
 
+// RoleBindingFilter selects role bindings by column values; empty fields match any value
+type RoleBindingFilter struct {
+	UserID   string
+	Role     string
+	TenantID string
+	Scope    model.BindingScope
+	ScopeID  string
+}
+
+// DeletionRepository defines the interface for cascading deletion data access
+type DeletionRepository interface {
+	Create(ctx context.Context, operation *model.DeletionOperation) error
+	GetByID(ctx context.Context, id string) (*model.DeletionOperation, error)
+	List(ctx context.Context, tenantID string, limit, offset int) ([]*model.DeletionOperation, int, error)
+	ListByStatus(ctx context.Context, statuses ...model.DeletionStatus) ([]*model.DeletionOperation, error)
+	Update(ctx context.Context, operation *model.DeletionOperation) error
+	// SoftDelete hides the records, Restore brings them back and Purge removes them for good
+	SoftDelete(ctx context.Context, records *DeletionRecords) error
+	Restore(ctx context.Context, records *DeletionRecords) error
+	Purge(ctx context.Context, records *DeletionRecords) error
+}
+
+// DeletionRecords are the IDs of the records a cascading deletion covers
+type DeletionRecords struct {
+	Tenants      []string
+	Projects     []string
+	Instances    []string
+	Users        []string
+	Variables    []string
+	RoleBindings []string
+}
+
+// SessionRepository defines the interface for login session data access
+type SessionRepository interface {
+	Create(ctx context.Context, session *model.Session) error
+	GetByID(ctx context.Context, id string) (*model.Session, error)
+	// ListActive returns the sessions of a user that are neither revoked nor expired
+	ListActive(ctx context.Context, userID string) ([]*model.Session, error)
+	// Rotate replaces the refresh token ID of a session if it is still oldJTI. It reports
+	// false when another refresh rotated the session first.
+	Rotate(ctx context.Context, session *model.Session, oldJTI string) (bool, error)
+	Revoke(ctx context.Context, id, reason string) error
+	RevokeByUser(ctx context.Context, userID, reason string) error
+	DeleteByUser(ctx context.Context, userID string) error
+	// DeleteExpired removes the sessions that expired before the given time
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
 // ConfigTemplateRepository defines the interface for config template data access
 type ConfigTemplateRepository interface {
 	Create(ctx context.Context, template *model.ConfigTemplate) error
 	GetByID(ctx context.Context, id string) (*model.ConfigTemplate, error)
 	GetByName(ctx context.Context, name string) (*model.ConfigTemplate, error)
 	List(ctx context.Context, limit, offset int) ([]*model.ConfigTemplate, error)
+	// ListDependents returns the templates that use id as their parent or as a fragment
+	ListDependents(ctx context.Context, id string) ([]*model.ConfigTemplate, error)
 	Update(ctx context.Context, template *model.ConfigTemplate) error
 	Delete(ctx context.Context, id string) error
 }
 
+// InstanceConfigRevisionRepository defines the interface for instance config revision data access
+type InstanceConfigRevisionRepository interface {
+	Create(ctx context.Context, revision *model.InstanceConfigRevision) error
+	Get(ctx context.Context, instanceID string, revision int) (*model.InstanceConfigRevision, error)
+	ListByInstance(ctx context.Context, instanceID string, limit, offset int) ([]*model.InstanceConfigRevision, int, error)
+	DeleteByInstance(ctx context.Context, instanceID string) error
+}
+
+// ConfigTemplateRevisionRepository defines the interface for config template revision data access.
+// Revisions are immutable, so there is no Update.
+type ConfigTemplateRevisionRepository interface {
+	Create(ctx context.Context, revision *model.ConfigTemplateRevision) error
+	Get(ctx context.Context, templateID string, revision int) (*model.ConfigTemplateRevision, error)
+	ListByTemplate(ctx context.Context, templateID string, limit, offset int) ([]*model.ConfigTemplateRevision, int, error)
+	DeleteByTemplate(ctx context.Context, templateID string) error
+}
+
 // TenantRepository defines the interface for tenant data access
 type TenantRepository interface {
 	Create(ctx context.Context, tenant *model.Tenant) error
@@ -36,6 +157,7 @@ type TenantRepository interface {
 	List(ctx context.Context, limit, offset int) ([]*model.Tenant, error)
 	ListWithCount(ctx context.Context, limit, offset int) ([]*model.Tenant, int, error)
 	Update(ctx context.Context, tenant *model.Tenant) error
+	UpdateStatus(ctx context.Context, tenant *model.Tenant) error
 	Delete(ctx context.Context, id string) error
 }
 
@@ -63,6 +185,12 @@ func (r *instanceRepository) Create(ctx context.Context, instance *model.ClawIns
 	if instance.ID == "" {
 		instance.ID = uuid.New().String()
 	}
+	if !InTenantScope(ctx, instance.TenantID) {
+		return ErrOutOfTenantScope
+	}
+	if !InProjectScope(ctx, instance.ProjectID) {
+		return ErrOutOfProjectScope
+	}
 
 	result := r.db.WithContext(ctx).Create(instance)
 	if result.Error != nil {
@@ -73,7 +201,7 @@ func (r *instanceRepository) Create(ctx context.Context, instance *model.ClawIns
 
 func (r *instanceRepository) GetByID(ctx context.Context, id string) (*model.ClawInstance, error) {
 	var instance model.ClawInstance
-	result := r.db.WithContext(ctx).Where("id = ?", id).First(&instance)
+	result := scopeInstances(ctx, r.db.WithContext(ctx)).Where("id = ?", id).First(&instance)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("instance not found")
@@ -85,7 +213,7 @@ func (r *instanceRepository) GetByID(ctx context.Context, id string) (*model.Cla
 
 func (r *instanceRepository) List(ctx context.Context, tenantID, projectID string, limit, offset int) ([]*model.ClawInstance, error) {
 	var instances []*model.ClawInstance
-	query := r.db.WithContext(ctx).Model(&model.ClawInstance{})
+	query := scopeInstances(ctx, r.db.WithContext(ctx).Model(&model.ClawInstance{}))
 
 	if tenantID != "" {
 		query = query.Where("tenant_id = ?", tenantID)
@@ -102,12 +230,16 @@ func (r *instanceRepository) List(ctx context.Context, tenantID, projectID strin
 }
 
 func (r *instanceRepository) Update(ctx context.Context, instance *model.ClawInstance) error {
-	result := r.db.WithContext(ctx).Model(instance).Updates(map[string]any{
+	result := scopeInstances(ctx, r.db.WithContext(ctx).Model(instance)).Updates(map[string]any{
 		"name":         instance.Name,
 		"type":         instance.Type,
 		"version":      instance.Version,
 		"status":       instance.Status,
 		"config":       instance.Config,
+		"labels":       instance.Labels,
+		"template_id":  instance.TemplateID,
+		"template_revision": instance.TemplateRevision,
+		"config_revision": instance.ConfigRevision,
 		"cpu":          instance.CPU,
 		"memory":       instance.Memory,
 		"config_dir":   instance.ConfigDir,
@@ -117,21 +249,84 @@ func (r *instanceRepository) Update(ctx context.Context, instance *model.ClawIns
 	if result.Error != nil {
 		return fmt.Errorf("failed to update instance: %w", result.Error)
 	}
+	if result.RowsAffected == 0 && (!InTenantScope(ctx, instance.TenantID) || !InProjectScope(ctx, instance.ProjectID)) {
+		return fmt.Errorf("instance not found")
+	}
 	return nil
 }
 
 func (r *instanceRepository) UpdateStatus(ctx context.Context, id string, status model.InstanceStatus) error {
-	result := r.db.WithContext(ctx).Model(&model.ClawInstance{}).Where("id = ?", id).Update("status", status)
+	result := scopeInstances(ctx, r.db.WithContext(ctx).Model(&model.ClawInstance{})).Where("id = ?", id).Update("status", status)
 	if result.Error != nil {
 		return fmt.Errorf("failed to update status: %w", result.Error)
 	}
+	if scoped(ctx) && result.RowsAffected == 0 {
+		return fmt.Errorf("instance not found")
+	}
 	return nil
 }
 
+func (r *instanceRepository) UpdateStatusFrom(ctx context.Context, id string, from, to model.InstanceStatus) (bool, error) {
+	result := scopeInstances(ctx, r.db.WithContext(ctx).Model(&model.ClawInstance{})).Where("id = ? AND status = ?", id, from).Update("status", to)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update status: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
 func (r *instanceRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.ClawInstance{})
+	result := scopeInstances(ctx, r.db.WithContext(ctx).Unscoped()).Where("id = ?", id).Delete(&model.ClawInstance{})
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete instance: %w", result.Error)
 	}
+	if scoped(ctx) && result.RowsAffected == 0 {
+		return fmt.Errorf("instance not found")
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// CountByTemplateRevision returns the number of instances per revision of a config template
+func (r *instanceRepository) CountByTemplateRevision(ctx context.Context, templateID string) (map[int]int, error) {
+	var rows []struct {
+		TemplateRevision int
+		Count            int
+	}
+	result := scopeInstances(ctx, r.db.WithContext(ctx).Model(&model.ClawInstance{})).
+		Select("template_revision, COUNT(*) AS count").
+		Where("template_id = ?", templateID).
+		Group("template_revision").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to count instances by template revision: %w", result.Error)
+	}
+
+	counts := make(map[int]int, len(rows))
+	for _, row := range rows {
+		counts[row.TemplateRevision] = row.Count
+	}
+	return counts, nil
+}
+// ListByFilter returns all instances matching the filter, oldest first
+func (r *instanceRepository) ListByFilter(ctx context.Context, filter InstanceFilter) ([]*model.ClawInstance, error) {
+	var instances []*model.ClawInstance
+	query := scopeInstances(ctx, r.db.WithContext(ctx).Model(&model.ClawInstance{}))
+
+	if filter.TenantID != "" {
+		query = query.Where("tenant_id = ?", filter.TenantID)
+	}
+	if filter.ProjectID != "" {
+		query = query.Where("project_id = ?", filter.ProjectID)
+	}
+	if filter.TemplateID != "" {
+		query = query.Where("template_id = ?", filter.TemplateID)
+	}
+	if len(filter.IDs) > 0 {
+		query = query.Where("id IN ?", filter.IDs)
+	}
+
+	result := query.Order("created_at ASC").Find(&instances)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", result.Error)
+	}
+	return instances, nil
+}
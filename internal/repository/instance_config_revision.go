@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"gorm.io/gorm"
+)
+
+// instanceConfigRevisionRepository implements InstanceConfigRevisionRepository
+type instanceConfigRevisionRepository struct {
+	db *gorm.DB
+}
+
+// NewInstanceConfigRevisionRepository creates a new instance config revision repository
+func NewInstanceConfigRevisionRepository(db *gorm.DB) InstanceConfigRevisionRepository {
+	return &instanceConfigRevisionRepository{db: db}
+}
+
+// Create stores a new revision
+func (r *instanceConfigRevisionRepository) Create(ctx context.Context, revision *model.InstanceConfigRevision) error {
+	if revision.ID == "" {
+		revision.ID = uuid.New().String()
+	}
+
+	result := r.db.WithContext(ctx).Create(revision)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create instance config revision: %w", result.Error)
+	}
+	return nil
+}
+
+// Get retrieves a revision of an instance by number
+func (r *instanceConfigRevisionRepository) Get(ctx context.Context, instanceID string, revision int) (*model.InstanceConfigRevision, error) {
+	var rev model.InstanceConfigRevision
+	result := r.db.WithContext(ctx).Where("instance_id = ? AND revision = ?", instanceID, revision).First(&rev)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("instance config revision not found")
+		}
+		return nil, fmt.Errorf("failed to get instance config revision: %w", result.Error)
+	}
+	return &rev, nil
+}
+
+// ListByInstance retrieves the revisions of an instance, newest first
+func (r *instanceConfigRevisionRepository) ListByInstance(ctx context.Context, instanceID string, limit, offset int) ([]*model.InstanceConfigRevision, int, error) {
+	var revisions []*model.InstanceConfigRevision
+	query := r.db.WithContext(ctx).Model(&model.InstanceConfigRevision{}).Where("instance_id = ?", instanceID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count instance config revisions: %w", err)
+	}
+
+	result := query.Order("revision DESC").Limit(limit).Offset(offset).Find(&revisions)
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("failed to list instance config revisions: %w", result.Error)
+	}
+	return revisions, int(total), nil
+}
+
+// DeleteByInstance deletes all revisions of an instance
+func (r *instanceConfigRevisionRepository) DeleteByInstance(ctx context.Context, instanceID string) error {
+	result := r.db.WithContext(ctx).Where("instance_id = ?", instanceID).Delete(&model.InstanceConfigRevision{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete instance config revisions: %w", result.Error)
+	}
+	return nil
+}
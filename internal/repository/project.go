@@ -87,8 +87,15 @@ func (r *projectRepository) List(ctx context.Context, limit, offset int) ([]*mod
 // Update updates a project
 func (r *projectRepository) Update(ctx context.Context, project *model.Project) error {
 	result := r.db.WithContext(ctx).Model(project).Updates(map[string]any{
-		"tenant_id": project.TenantID,
-		"name":      project.Name,
+		"tenant_id":       project.TenantID,
+		"name":            project.Name,
+		"max_instances":   project.MaxInstances,
+		"max_cpu":         project.MaxCPU,
+		"max_memory":      project.MaxMemory,
+		"max_storage":     project.MaxStorage,
+		"default_cpu":     project.DefaultCPU,
+		"default_memory":  project.DefaultMemory,
+		"default_storage": project.DefaultStorage,
 	})
 	if result.Error != nil {
 		return fmt.Errorf("failed to update project: %w", result.Error)
@@ -98,7 +105,7 @@ func (r *projectRepository) Update(ctx context.Context, project *model.Project)
 
 // Delete deletes a project by ID
 func (r *projectRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.Project{})
+	result := r.db.WithContext(ctx).Unscoped().Where("id = ?", id).Delete(&model.Project{})
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete project: %w", result.Error)
 	}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"gorm.io/gorm"
+)
+
+// roleRepository implements RoleRepository
+type roleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates a new custom role repository
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+// Create stores a new role
+func (r *roleRepository) Create(ctx context.Context, role *model.Role) error {
+	if role.ID == "" {
+		role.ID = uuid.New().String()
+	}
+
+	result := r.db.WithContext(ctx).Create(role)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create role: %w", result.Error)
+	}
+	return nil
+}
+
+// GetByName retrieves a role by name
+func (r *roleRepository) GetByName(ctx context.Context, name string) (*model.Role, error) {
+	var role model.Role
+	result := r.db.WithContext(ctx).Where("name = ?", name).First(&role)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("role not found")
+		}
+		return nil, fmt.Errorf("failed to get role: %w", result.Error)
+	}
+	return &role, nil
+}
+
+// List retrieves all custom roles ordered by name
+func (r *roleRepository) List(ctx context.Context) ([]*model.Role, error) {
+	var roles []*model.Role
+	result := r.db.WithContext(ctx).Order("name ASC").Find(&roles)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", result.Error)
+	}
+	return roles, nil
+}
+
+// Update saves the description and permissions of a role
+func (r *roleRepository) Update(ctx context.Context, role *model.Role) error {
+	result := r.db.WithContext(ctx).Model(role).Updates(map[string]any{
+		"description": role.Description,
+		"permissions": role.Permissions,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update role: %w", result.Error)
+	}
+	return nil
+}
+
+// Delete removes a role by ID
+func (r *roleRepository) Delete(ctx context.Context, id string) error {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.Role{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete role: %w", result.Error)
+	}
+	return nil
+}
+
+// roleBindingRepository implements RoleBindingRepository
+type roleBindingRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleBindingRepository creates a new role binding repository
+func NewRoleBindingRepository(db *gorm.DB) RoleBindingRepository {
+	return &roleBindingRepository{db: db}
+}
+
+// Create stores a new role binding
+func (r *roleBindingRepository) Create(ctx context.Context, binding *model.RoleBinding) error {
+	if binding.ID == "" {
+		binding.ID = uuid.New().String()
+	}
+
+	result := r.db.WithContext(ctx).Create(binding)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create role binding: %w", result.Error)
+	}
+	return nil
+}
+
+// GetByID retrieves a role binding by ID
+func (r *roleBindingRepository) GetByID(ctx context.Context, id string) (*model.RoleBinding, error) {
+	var binding model.RoleBinding
+	result := r.db.WithContext(ctx).Where("id = ?", id).First(&binding)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("role binding not found")
+		}
+		return nil, fmt.Errorf("failed to get role binding: %w", result.Error)
+	}
+	return &binding, nil
+}
+
+// List retrieves the role bindings matching the filter, oldest first
+func (r *roleBindingRepository) List(ctx context.Context, filter RoleBindingFilter) ([]*model.RoleBinding, error) {
+	var bindings []*model.RoleBinding
+	query := r.db.WithContext(ctx).Model(&model.RoleBinding{})
+
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.TenantID != "" {
+		query = query.Where("tenant_id = ?", filter.TenantID)
+	}
+	if filter.Scope != "" {
+		query = query.Where("scope = ?", filter.Scope)
+	}
+	if filter.ScopeID != "" {
+		query = query.Where("scope_id = ?", filter.ScopeID)
+	}
+
+	result := query.Order("created_at ASC").Find(&bindings)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %w", result.Error)
+	}
+	return bindings, nil
+}
+
+// Delete removes a role binding by ID
+func (r *roleBindingRepository) Delete(ctx context.Context, id string) error {
+	result := r.db.WithContext(ctx).Unscoped().Where("id = ?", id).Delete(&model.RoleBinding{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete role binding: %w", result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrOutOfTenantScope is returned when a write targets a tenant other than the one the context is scoped to
+var ErrOutOfTenantScope = errors.New("tenant is outside the request scope")
+
+// ErrOutOfProjectScope is returned when a write targets a project other than those the context is scoped to
+var ErrOutOfProjectScope = errors.New("project is outside the request scope")
+
+type tenantScopeKey struct{}
+
+// WithTenantScope returns a context that restricts tenant-owned records read or written with it
+// to one tenant. Records of other tenants behave as if they did not exist.
+func WithTenantScope(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantScopeKey{}, tenantID)
+}
+
+// TenantScope returns the tenant a context is restricted to; ok is false for unscoped contexts
+func TenantScope(ctx context.Context) (tenantID string, ok bool) {
+	tenantID, ok = ctx.Value(tenantScopeKey{}).(string)
+	return tenantID, ok
+}
+
+// InTenantScope reports whether a context may access records of tenantID
+func InTenantScope(ctx context.Context, tenantID string) bool {
+	scope, ok := TenantScope(ctx)
+	return !ok || scope == tenantID
+}
+
+// scopeTenant adds the tenant restriction of ctx, if any, to a query on a table with a tenant_id column
+func scopeTenant(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tenantID, ok := TenantScope(ctx); ok {
+		return db.Where("tenant_id = ?", tenantID)
+	}
+	return db
+}
+
+type projectScopeKey struct{}
+
+// WithProjectScope returns a context that further restricts instances read or written with it to
+// the given projects. Instances of other projects behave as if they did not exist.
+func WithProjectScope(ctx context.Context, projectIDs []string) context.Context {
+	if projectIDs == nil {
+		projectIDs = []string{}
+	}
+	return context.WithValue(ctx, projectScopeKey{}, projectIDs)
+}
+
+// WithoutProjectScope returns a context that lifts the project restriction of ctx while keeping
+// its tenant restriction, for tenant-wide checks such as quota admission
+func WithoutProjectScope(ctx context.Context) context.Context {
+	return context.WithValue(ctx, projectScopeKey{}, nil)
+}
+
+// ProjectScope returns the projects a context is restricted to; ok is false when it is not
+// restricted to projects
+func ProjectScope(ctx context.Context) (projectIDs []string, ok bool) {
+	projectIDs, ok = ctx.Value(projectScopeKey{}).([]string)
+	return projectIDs, ok
+}
+
+// InProjectScope reports whether a context may access instances of projectID
+func InProjectScope(ctx context.Context, projectID string) bool {
+	projectIDs, ok := ProjectScope(ctx)
+	if !ok {
+		return true
+	}
+	for _, id := range projectIDs {
+		if id == projectID {
+			return true
+		}
+	}
+	return false
+}
+
+// scoped reports whether ctx restricts records to a tenant or to projects
+func scoped(ctx context.Context) bool {
+	_, tenant := TenantScope(ctx)
+	_, projects := ProjectScope(ctx)
+	return tenant || projects
+}
+
+// scopeInstances adds the tenant and project restrictions of ctx, if any, to a query on the instances table
+func scopeInstances(ctx context.Context, db *gorm.DB) *gorm.DB {
+	db = scopeTenant(ctx, db)
+	if projectIDs, ok := ProjectScope(ctx); ok {
+		return db.Where("project_id IN ?", projectIDs)
+	}
+	return db
+}
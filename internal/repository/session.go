@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"gorm.io/gorm"
+)
+
+// sessionRepository implements SessionRepository
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository creates a new login session repository
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// Create stores a new session
+func (r *sessionRepository) Create(ctx context.Context, session *model.Session) error {
+	if session.ID == "" {
+		session.ID = uuid.New().String()
+	}
+
+	result := r.db.WithContext(ctx).Create(session)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create session: %w", result.Error)
+	}
+	return nil
+}
+
+// GetByID retrieves a session by ID
+func (r *sessionRepository) GetByID(ctx context.Context, id string) (*model.Session, error) {
+	var session model.Session
+	result := r.db.WithContext(ctx).Where("id = ?", id).First(&session)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("session not found")
+		}
+		return nil, fmt.Errorf("failed to get session: %w", result.Error)
+	}
+	return &session, nil
+}
+
+// ListActive retrieves the active sessions of a user, most recently used first
+func (r *sessionRepository) ListActive(ctx context.Context, userID string) ([]*model.Session, error) {
+	var sessions []*model.Session
+	result := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", result.Error)
+	}
+	return sessions, nil
+}
+
+// Rotate stores the new refresh token ID and client details of a session, provided no other
+// refresh replaced oldJTI in the meantime
+func (r *sessionRepository) Rotate(ctx context.Context, session *model.Session, oldJTI string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&model.Session{}).
+		Where("id = ? AND refresh_jti = ? AND revoked_at IS NULL", session.ID, oldJTI).
+		Updates(map[string]any{
+			"refresh_jti":  session.RefreshJTI,
+			"user_agent":   session.UserAgent,
+			"client_ip":    session.ClientIP,
+			"last_used_at": session.LastUsedAt,
+			"expires_at":   session.ExpiresAt,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to rotate session: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Revoke marks a session as revoked; revoking it again keeps the first reason
+func (r *sessionRepository) Revoke(ctx context.Context, id, reason string) error {
+	result := r.db.WithContext(ctx).Model(&model.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]any{"revoked_at": time.Now(), "revoked_reason": reason})
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	return nil
+}
+
+// RevokeByUser revokes all sessions of a user that are not revoked yet
+func (r *sessionRepository) RevokeByUser(ctx context.Context, userID, reason string) error {
+	result := r.db.WithContext(ctx).Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]any{"revoked_at": time.Now(), "revoked_reason": reason})
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", result.Error)
+	}
+	return nil
+}
+
+// DeleteByUser removes all sessions of a user
+func (r *sessionRepository) DeleteByUser(ctx context.Context, userID string) error {
+	result := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&model.Session{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete sessions: %w", result.Error)
+	}
+	return nil
+}
+
+// DeleteExpired removes the sessions that expired before the given time
+func (r *sessionRepository) DeleteExpired(ctx context.Context, before time.Time) error {
+	result := r.db.WithContext(ctx).Where("expires_at < ?", before).Delete(&model.Session{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", result.Error)
+	}
+	return nil
+}
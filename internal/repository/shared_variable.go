@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"gorm.io/gorm"
+)
+
+// sharedVariableRepository implements SharedVariableRepository
+type sharedVariableRepository struct {
+	db *gorm.DB
+}
+
+// NewSharedVariableRepository creates a new shared variable repository
+func NewSharedVariableRepository(db *gorm.DB) SharedVariableRepository {
+	return &sharedVariableRepository{db: db}
+}
+
+// Create stores a new variable
+func (r *sharedVariableRepository) Create(ctx context.Context, variable *model.SharedVariable) error {
+	if variable.ID == "" {
+		variable.ID = uuid.New().String()
+	}
+
+	result := r.db.WithContext(ctx).Create(variable)
+	if result.Error != nil {
+		return fmt.Errorf("failed to create shared variable: %w", result.Error)
+	}
+	return nil
+}
+
+// GetByID retrieves a variable by ID
+func (r *sharedVariableRepository) GetByID(ctx context.Context, id string) (*model.SharedVariable, error) {
+	var variable model.SharedVariable
+	result := r.db.WithContext(ctx).Where("id = ?", id).First(&variable)
+	if result.Error != nil {
+		if result.Error == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("shared variable not found")
+		}
+		return nil, fmt.Errorf("failed to get shared variable: %w", result.Error)
+	}
+	return &variable, nil
+}
+
+// List retrieves the variables of a scope ordered by name
+func (r *sharedVariableRepository) List(ctx context.Context, scope model.VariableScope, scopeID string) ([]*model.SharedVariable, error) {
+	var variables []*model.SharedVariable
+	query := r.db.WithContext(ctx)
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	if scopeID != "" {
+		query = query.Where("scope_id = ?", scopeID)
+	}
+
+	result := query.Order("scope ASC, name ASC").Find(&variables)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list shared variables: %w", result.Error)
+	}
+	return variables, nil
+}
+
+// ListForInstance retrieves every variable visible to an instance
+func (r *sharedVariableRepository) ListForInstance(ctx context.Context, tenantID, projectID, instanceID string) ([]*model.SharedVariable, error) {
+	var variables []*model.SharedVariable
+	result := r.db.WithContext(ctx).
+		Where("scope = ?", model.VariableScopeGlobal).
+		Or("scope = ? AND scope_id = ?", model.VariableScopeTenant, tenantID).
+		Or("scope = ? AND scope_id = ?", model.VariableScopeProject, projectID).
+		Or("scope = ? AND scope_id = ?", model.VariableScopeInstance, instanceID).
+		Find(&variables)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list shared variables: %w", result.Error)
+	}
+	return variables, nil
+}
+
+// Update saves a variable
+func (r *sharedVariableRepository) Update(ctx context.Context, variable *model.SharedVariable) error {
+	result := r.db.WithContext(ctx).Save(variable)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update shared variable: %w", result.Error)
+	}
+	return nil
+}
+
+// Delete removes a variable
+func (r *sharedVariableRepository) Delete(ctx context.Context, id string) error {
+	result := r.db.WithContext(ctx).Unscoped().Delete(&model.SharedVariable{}, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete shared variable: %w", result.Error)
+	}
+	return nil
+}
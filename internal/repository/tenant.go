@@ -117,9 +117,24 @@ func (r *tenantRepository) Update(ctx context.Context, tenant *model.Tenant) err
 	return nil
 }
 
+// UpdateStatus writes the lifecycle status of a tenant and the instances to restore on resume
+func (r *tenantRepository) UpdateStatus(ctx context.Context, tenant *model.Tenant) error {
+	tenant.UpdatedAt = time.Now()
+	result := r.db.WithContext(ctx).Model(tenant).Updates(map[string]any{
+		"status":              tenant.Status,
+		"status_reason":       tenant.StatusReason,
+		"status_changed_at":   tenant.StatusChangedAt,
+		"suspended_instances": tenant.SuspendedInstances,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update tenant status: %w", result.Error)
+	}
+	return nil
+}
+
 // Delete deletes a tenant by ID
 func (r *tenantRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.Tenant{})
+	result := r.db.WithContext(ctx).Unscoped().Where("id = ?", id).Delete(&model.Tenant{})
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete tenant: %w", result.Error)
 	}
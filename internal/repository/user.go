@@ -63,6 +63,8 @@ func (r *UserRepository) Update(ctx context.Context, user *model.User) error {
 		"tenant_id":        user.TenantID,
 		"role":             user.Role,
 		"is_active":        user.IsActive,
+		"password_changed_at": user.PasswordChangedAt,
+		"must_change_password": user.MustChangePassword,
 		"otp_secret":       user.OTPSecret,
 		"otp_enabled":      user.OTPEnabled,
 		"otp_backup_codes": user.OTPBackupCodes,
@@ -132,7 +134,7 @@ func (r *UserRepository) GetByTempOTPToken(ctx context.Context, token string) (*
 
 // Delete deletes a user by ID
 func (r *UserRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.User{})
+	result := r.db.WithContext(ctx).Unscoped().Where("id = ?", id).Delete(&model.User{})
 	if result.Error != nil {
 		return fmt.Errorf("failed to delete user: %w", result.Error)
 	}
@@ -142,13 +144,36 @@ func (r *UserRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// UserFilter selects users; empty fields match any value
+type UserFilter struct {
+	TenantID string
+	Role     model.UserRole
+	IsActive *bool
+	// Query matches part of the username
+	Query string
+}
+
 // List retrieves a list of users, optionally filtered by tenant
 func (r *UserRepository) List(ctx context.Context, tenantID string, limit, offset int) ([]*model.User, int, error) {
+	return r.Search(ctx, UserFilter{TenantID: tenantID}, limit, offset)
+}
+
+// Search retrieves a page of the users matching filter and the total number of matches
+func (r *UserRepository) Search(ctx context.Context, filter UserFilter, limit, offset int) ([]*model.User, int, error) {
 	var users []*model.User
 	query := r.db.WithContext(ctx).Model(&model.User{})
 
-	if tenantID != "" {
-		query = query.Where("tenant_id = ?", tenantID)
+	if filter.TenantID != "" {
+		query = query.Where("tenant_id = ?", filter.TenantID)
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.IsActive != nil {
+		query = query.Where("is_active = ?", *filter.IsActive)
+	}
+	if filter.Query != "" {
+		query = query.Where("username LIKE ?", "%"+filter.Query+"%")
 	}
 
 	var total int64
@@ -164,6 +189,40 @@ func (r *UserRepository) List(ctx context.Context, tenantID string, limit, offse
 	return users, int(total), nil
 }
 
+// AddPasswordHistory records a previous password hash of a user, keeping only the keep most
+// recent entries
+func (r *UserRepository) AddPasswordHistory(ctx context.Context, userID, passwordHash string, keep int) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Create(&model.PasswordHistory{UserID: userID, PasswordHash: passwordHash}).Error; err != nil {
+		return fmt.Errorf("failed to record password history: %w", err)
+	}
+	recent := db.Model(&model.PasswordHistory{}).Select("id").Where("user_id = ?", userID).Order("id DESC").Limit(keep)
+	result := db.Where("user_id = ? AND id NOT IN (?)", userID, recent).Delete(&model.PasswordHistory{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to prune password history: %w", result.Error)
+	}
+	return nil
+}
+
+// ListPasswordHistory returns the most recent previous password hashes of a user, newest first
+func (r *UserRepository) ListPasswordHistory(ctx context.Context, userID string, limit int) ([]string, error) {
+	var hashes []string
+	result := r.db.WithContext(ctx).Model(&model.PasswordHistory{}).Where("user_id = ?", userID).
+		Order("id DESC").Limit(limit).Pluck("password_hash", &hashes)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to list password history: %w", result.Error)
+	}
+	return hashes, nil
+}
+
+// DeletePasswordHistory removes the password history of a user
+func (r *UserRepository) DeletePasswordHistory(ctx context.Context, userID string) error {
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&model.PasswordHistory{}).Error; err != nil {
+		return fmt.Errorf("failed to delete password history: %w", err)
+	}
+	return nil
+}
+
 // GenerateID generates a new UUID for a user
 func (r *UserRepository) GenerateID() string {
 	return uuid.New().String()
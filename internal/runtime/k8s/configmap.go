@@ -2,11 +2,16 @@ package k8s
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/weibaohui/kom/kom"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // ConfigMapManager handles ConfigMap operations
@@ -26,40 +31,81 @@ func (cm *ConfigMapManager) GetNamespace() string {
 	return cm.namespace
 }
 
+// Annotations recording which config generation a ConfigMap or Pod carries
+const (
+	// AnnotationConfigRevision is the instance config revision the object was written from
+	AnnotationConfigRevision = "openclusterclaw.io/config-revision"
+	// AnnotationConfigHash is the HashData of the ConfigMap data as written by the control plane
+	AnnotationConfigHash = "openclusterclaw.io/config-hash"
+	// AnnotationEnvHash is the HashData of the env vars a Pod was created with
+	AnnotationEnvHash = "openclusterclaw.io/env-hash"
+)
+
 // ConfigMapData represents the data to store in a ConfigMap
 type ConfigMapData struct {
-	ConfigYAML  string            `json:"config_yaml,omitempty"`
-	ConfigJSON  string            `json:"config_json,omitempty"`
+	// Files maps file names inside the config mount to their content
+	Files       map[string]string `json:"files,omitempty"`
 	Environment map[string]string `json:"environment,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
-// CreateOrUpdateConfigMap creates or updates a ConfigMap for an instance
-func (cm *ConfigMapManager) CreateOrUpdateConfigMap(ctx context.Context, name string, labels map[string]string, data ConfigMapData) (*corev1.ConfigMap, error) {
-	configMap := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: cm.namespace,
-			Labels:    labels,
-		},
-		Data: make(map[string]string),
+// Build returns the ConfigMap data entries for the files and environment
+func (d ConfigMapData) Build() (map[string]string, error) {
+	data := make(map[string]string, len(d.Files)+len(d.Environment))
+
+	// Add config files under their file names
+	for name, content := range d.Files {
+		if errs := validation.IsConfigMapKey(name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid config file name %q: %s", name, strings.Join(errs, "; "))
+		}
+		data[name] = content
 	}
 
-	// Add config data
-	if data.ConfigYAML != "" {
-		configMap.Data["config.yaml"] = data.ConfigYAML
+	// Add environment variables
+	for key, value := range d.Environment {
+		if _, ok := data[key]; ok {
+			return nil, fmt.Errorf("environment key %q conflicts with a config file name", key)
+		}
+		data[key] = value
 	}
-	if data.ConfigJSON != "" {
-		configMap.Data["config.json"] = data.ConfigJSON
+	return data, nil
+}
+
+// HashData returns a SHA-256 hash of string data that does not depend on key order
+func HashData(data map[string]string) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	// Add environment variables
-	for key, value := range data.Environment {
-		configMap.Data[key] = value
+	h := sha256.New()
+	for _, key := range keys {
+		// Length prefixes keep key/value boundaries unambiguous
+		fmt.Fprintf(h, "%d:%s%d:%s", len(key), key, len(data[key]), data[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CreateOrUpdateConfigMap creates or updates a ConfigMap for an instance
+func (cm *ConfigMapManager) CreateOrUpdateConfigMap(ctx context.Context, name string, labels map[string]string, data ConfigMapData) (*corev1.ConfigMap, error) {
+	built, err := data.Build()
+	if err != nil {
+		return nil, err
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   cm.namespace,
+			Labels:      labels,
+			Annotations: data.Annotations,
+		},
+		Data: built,
 	}
 
 	// Check if ConfigMap already exists
 	existing := &corev1.ConfigMap{}
-	err := kom.DefaultCluster().
+	err = kom.DefaultCluster().
 		Resource(existing).
 		Namespace(cm.namespace).
 		Name(name).
@@ -75,8 +121,14 @@ func (cm *ConfigMapManager) CreateOrUpdateConfigMap(ctx context.Context, name st
 			return nil, fmt.Errorf("failed to create configmap: %w", err)
 		}
 	} else {
-		// Update existing ConfigMap
+		// Update existing ConfigMap, keeping labels and annotations added by others
 		existing.Data = configMap.Data
+		if existing.Annotations == nil {
+			existing.Annotations = make(map[string]string, len(data.Annotations))
+		}
+		for key, value := range data.Annotations {
+			existing.Annotations[key] = value
+		}
 		err = kom.DefaultCluster().
 			Resource(existing).
 			Namespace(cm.namespace).
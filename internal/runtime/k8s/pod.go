@@ -3,6 +3,7 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/weibaohui/kom/kom"
@@ -42,6 +43,7 @@ type PodSpec struct {
 	Name            string
 	Namespace       string
 	Labels          map[string]string
+	Annotations     map[string]string
 	Image           string
 	Command         []string
 	Args            []string
@@ -73,6 +75,26 @@ func (pm *PodManager) GetNamespace() string {
 
 // CreatePod creates a new Pod for a Claw instance
 func (pm *PodManager) CreatePod(ctx context.Context, spec PodSpec) (*corev1.Pod, error) {
+	spec.Namespace = pm.namespace
+	pod, err := BuildPod(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use kom to create the pod
+	err = kom.DefaultCluster().
+		Resource(pod).
+		Namespace(pm.namespace).
+		Create(pod).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pod: %w", err)
+	}
+
+	return pod, nil
+}
+
+// BuildPod builds the Pod manifest for a Claw instance without creating it
+func BuildPod(spec PodSpec) (*corev1.Pod, error) {
 	envVars := make([]corev1.EnvVar, 0, len(spec.Env))
 	for key, value := range spec.Env {
 		envVars = append(envVars, corev1.EnvVar{
@@ -80,6 +102,7 @@ func (pm *PodManager) CreatePod(ctx context.Context, spec PodSpec) (*corev1.Pod,
 			Value: value,
 		})
 	}
+	sort.Slice(envVars, func(i, j int) bool { return envVars[i].Name < envVars[j].Name })
 
 	volumes := []corev1.Volume{}
 	volumeMounts := []corev1.VolumeMount{}
@@ -103,10 +126,15 @@ func (pm *PodManager) CreatePod(ctx context.Context, spec PodSpec) (*corev1.Pod,
 	}
 
 	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Pod",
+		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      spec.Name,
-			Namespace: pm.namespace,
-			Labels:    spec.Labels,
+			Name:        spec.Name,
+			Namespace:   spec.Namespace,
+			Labels:      spec.Labels,
+			Annotations: spec.Annotations,
 		},
 		Spec: corev1.PodSpec{
 			Containers: []corev1.Container{
@@ -129,33 +157,34 @@ func (pm *PodManager) CreatePod(ctx context.Context, spec PodSpec) (*corev1.Pod,
 	}
 
 	// Set resource limits if specified
-	if spec.CPURequest != "" {
-		pod.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU] =
-			resource.MustParse(spec.CPURequest)
+	resources := &pod.Spec.Containers[0].Resources
+	if err := setQuantity(resources.Requests, corev1.ResourceCPU, spec.CPURequest); err != nil {
+		return nil, err
 	}
-	if spec.CPULimit != "" {
-		pod.Spec.Containers[0].Resources.Limits[corev1.ResourceCPU] =
-			resource.MustParse(spec.CPULimit)
+	if err := setQuantity(resources.Limits, corev1.ResourceCPU, spec.CPULimit); err != nil {
+		return nil, err
 	}
-	if spec.MemoryRequest != "" {
-		pod.Spec.Containers[0].Resources.Requests[corev1.ResourceMemory] =
-			resource.MustParse(spec.MemoryRequest)
+	if err := setQuantity(resources.Requests, corev1.ResourceMemory, spec.MemoryRequest); err != nil {
+		return nil, err
 	}
-	if spec.MemoryLimit != "" {
-		pod.Spec.Containers[0].Resources.Limits[corev1.ResourceMemory] =
-			resource.MustParse(spec.MemoryLimit)
+	if err := setQuantity(resources.Limits, corev1.ResourceMemory, spec.MemoryLimit); err != nil {
+		return nil, err
 	}
 
-	// Use kom to create the pod
-	err := kom.DefaultCluster().
-		Resource(pod).
-		Namespace(pm.namespace).
-		Create(pod).Error
+	return pod, nil
+}
+
+// setQuantity parses value and stores it in the resource list if it is not empty
+func setQuantity(list corev1.ResourceList, name corev1.ResourceName, value string) error {
+	if value == "" {
+		return nil
+	}
+	quantity, err := resource.ParseQuantity(value)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create pod: %w", err)
+		return fmt.Errorf("invalid %s quantity %q: %w", name, value, err)
 	}
-
-	return pod, nil
+	list[name] = quantity
+	return nil
 }
 
 // DeletePod deletes a Pod by name
@@ -299,6 +328,42 @@ func (pm *PodManager) WaitForPodReady(ctx context.Context, name string, timeout
 	}
 }
 
+// WaitForPodDeleted waits until a Pod no longer exists so a new one can take its name
+func (pm *PodManager) WaitForPodDeleted(ctx context.Context, name string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timeout waiting for pod to be deleted")
+		case <-ticker.C:
+			if _, err := pm.GetPod(ctx, name); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// ExecInPod runs a command in the Claw container of a Pod and returns its output
+func (pm *PodManager) ExecInPod(ctx context.Context, name string, command string, args ...string) ([]byte, error) {
+	var output []byte
+	err := kom.DefaultCluster().
+		Resource(&corev1.Pod{}).
+		Namespace(pm.namespace).
+		Name(name).
+		Ctl().Pod().ContainerName("claw").
+		Command(command, args...).
+		Execute(&output).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to exec in pod: %w", err)
+	}
+	return output, nil
+}
+
 // ListPodsByInstance lists all Pods for a given instance ID
 func (pm *PodManager) ListPodsByInstance(ctx context.Context, instanceID string) ([]corev1.Pod, error) {
 	var pods []corev1.Pod
@@ -328,8 +393,8 @@ func (pm *PodManager) GetPodLogs(ctx context.Context, name string, tailLines int
 		Namespace(pm.namespace).
 		Name(name).
 		Ctl().Pod().GetLogs(&logs, &corev1.PodLogOptions{
-			TailLines: &tailLines,
-		}).Error
+		TailLines: &tailLines,
+	}).Error
 	if err != nil {
 		return "", fmt.Errorf("failed to get pod logs: %w", err)
 	}
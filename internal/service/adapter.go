@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/weibh/openClusterClaw/internal/adapter"
+)
+
+var (
+	ErrAdapterNotFound = errors.New("adapter not found")
+)
+
+// AdapterService exposes the registered Claw adapters to API clients
+type AdapterService interface {
+	ListAdapters(ctx context.Context) ([]*AdapterInfo, error)
+	GetAdapter(ctx context.Context, adapterType string) (*AdapterInfo, error)
+	GetAdapterSchema(ctx context.Context, adapterType string) (*adapter.JSONSchema, error)
+}
+
+// AdapterInfo describes a registered Claw adapter
+type AdapterInfo struct {
+	Type              string                `json:"type"`
+	Image             string                `json:"image"`
+	SupportedVersions []string              `json:"supported_versions"`
+	DefaultVersion    string                `json:"default_version"`
+	DefaultConfig     adapter.UnifiedConfig `json:"default_config"`
+	Reload            adapter.ReloadPolicy  `json:"reload"`
+}
+
+// adapterService implements AdapterService
+type adapterService struct {
+	factory *adapter.Factory
+}
+
+// NewAdapterService creates a new adapter service
+func NewAdapterService(factory *adapter.Factory) AdapterService {
+	return &adapterService{
+		factory: factory,
+	}
+}
+
+func (s *adapterService) ListAdapters(ctx context.Context) ([]*AdapterInfo, error) {
+	types := s.factory.GetSupportedTypes()
+	infos := make([]*AdapterInfo, 0, len(types))
+	for _, t := range types {
+		info, err := s.GetAdapter(ctx, string(t))
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+func (s *adapterService) GetAdapter(ctx context.Context, adapterType string) (*AdapterInfo, error) {
+	adp, err := s.factory.CreateByString(adapterType)
+	if err != nil {
+		return nil, ErrAdapterNotFound
+	}
+
+	versions := adp.GetSupportedVersions()
+	info := &AdapterInfo{
+		Type:              adapterType,
+		SupportedVersions: versions,
+		DefaultConfig:     adp.GetDefaultConfig(),
+		Reload:            adp.GetReloadPolicy(),
+	}
+	if len(versions) > 0 {
+		info.DefaultVersion = versions[0]
+	}
+	info.Image = adp.GetImage(info.DefaultVersion)
+
+	return info, nil
+}
+
+func (s *adapterService) GetAdapterSchema(ctx context.Context, adapterType string) (*adapter.JSONSchema, error) {
+	adp, err := s.factory.CreateByString(adapterType)
+	if err != nil {
+		return nil, ErrAdapterNotFound
+	}
+	return adp.GetConfigSchema(), nil
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/weibh/openClusterClaw/internal/model"
@@ -13,22 +14,48 @@ import (
 
 // AuthService handles authentication business logic
 type AuthService struct {
-	userRepo   *repository.UserRepository
-	jwtService *jwt.JWTService
+	userRepo        *repository.UserRepository
+	tenantRepo      repository.TenantRepository
+	roleBindingRepo repository.RoleBindingRepository
+	sessionRepo     repository.SessionRepository
+	jwtService      *jwt.JWTService
+	policy          PasswordPolicy
 }
 
 // NewAuthService creates a new auth service
-func NewAuthService(userRepo *repository.UserRepository, jwtService *jwt.JWTService) *AuthService {
+func NewAuthService(userRepo *repository.UserRepository, tenantRepo repository.TenantRepository, roleBindingRepo repository.RoleBindingRepository, sessionRepo repository.SessionRepository, jwtService *jwt.JWTService, policy PasswordPolicy) *AuthService {
 	return &AuthService{
-		userRepo:   userRepo,
-		jwtService: jwtService,
+		userRepo:        userRepo,
+		tenantRepo:      tenantRepo,
+		roleBindingRepo: roleBindingRepo,
+		sessionRepo:     sessionRepo,
+		jwtService:      jwtService,
+		policy:          policy,
 	}
 }
 
+// checkTenant rejects users whose tenant is suspended, archived or cannot be loaded.
+// Admins are not bound to a tenant and can always sign in.
+func (s *AuthService) checkTenant(ctx context.Context, user *model.User) error {
+	if user.Role == model.RoleAdmin || user.TenantID == "" {
+		return nil
+	}
+	tenant, err := s.tenantRepo.GetByID(ctx, user.TenantID)
+	if err != nil {
+		return ErrTenantNotFound
+	}
+	if !tenant.IsActive() {
+		return ErrTenantInactive
+	}
+	return nil
+}
+
 // LoginRequest represents a login request
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	// Client is recorded on the session the login starts
+	Client ClientInfo `json:"-"`
 }
 
 // LoginResponse represents a login response
@@ -57,40 +84,54 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*LoginRespo
 		return nil, fmt.Errorf("invalid username or password")
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwtService.GenerateAccessToken(user)
+	if err := s.checkTenant(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(ctx, user, req.Client)
+}
+
+// issueTokens generates the access token and the current refresh token of a session
+func (s *AuthService) issueTokens(user *model.User, session *model.Session) (*LoginResponse, error) {
+	accessToken, err := s.jwtService.GenerateAccessToken(user, session.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
-
-	refreshToken, err := s.jwtService.GenerateRefreshToken(user)
+	refreshToken, err := s.jwtService.GenerateRefreshToken(user, session.ID, session.RefreshJTI)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
 	}
 
+	response := user.ToResponse()
 	return &LoginResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
-		ExpiresIn:   s.jwtService.GetAccessExpiration(),
-		User:        &model.UserResponse{
-			ID:        user.ID,
-			Username:  user.Username,
-			TenantID:  user.TenantID,
-			Role:      user.Role,
-			IsActive:  user.IsActive,
-			CreatedAt: user.CreatedAt,
-		},
+		ExpiresIn:    s.jwtService.GetAccessExpiration(),
+		User:         &response,
 	}, nil
 }
 
-// RefreshToken generates new access token using refresh token
-func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*LoginResponse, error) {
+// RefreshToken rotates the refresh token of a session and returns new tokens. Presenting a
+// refresh token that was already rotated revokes the session, since either the client or an
+// attacker holds a stolen copy.
+func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string, client ClientInfo) (*LoginResponse, error) {
 	// Validate refresh token
-	claims, err := s.jwtService.ValidateToken(refreshToken)
+	claims, err := s.jwtService.ValidateRefreshToken(refreshToken)
 	if err != nil {
 		return nil, fmt.Errorf("invalid refresh token: %w", err)
 	}
 
+	session, err := s.sessionRepo.GetByID(ctx, claims.SessionID)
+	if err != nil || session.UserID != claims.UserID {
+		return nil, ErrSessionNotFound
+	}
+	if !session.IsActive() {
+		return nil, ErrSessionRevoked
+	}
+	if session.RefreshJTI != claims.ID {
+		return nil, s.revokeReused(ctx, session.ID)
+	}
+
 	// Get user from database
 	user, err := s.userRepo.GetByID(ctx, claims.UserID)
 	if err != nil {
@@ -102,30 +143,30 @@ func (s *AuthService) RefreshToken(ctx context.Context, refreshToken string) (*L
 		return nil, fmt.Errorf("user account is disabled")
 	}
 
-	// Generate new tokens
-	newAccessToken, err := s.jwtService.GenerateAccessToken(user)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	if err := s.checkTenant(ctx, user); err != nil {
+		return nil, err
 	}
 
-	newRefreshToken, err := s.jwtService.GenerateRefreshToken(user)
+	now := time.Now()
+	session.RefreshJTI = uuid.New().String()
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(s.sessionLifetime())
+	if client.UserAgent != "" {
+		session.UserAgent = client.UserAgent
+	}
+	if client.IP != "" {
+		session.ClientIP = client.IP
+	}
+	rotated, err := s.sessionRepo.Rotate(ctx, session, claims.ID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, err
+	}
+	if !rotated {
+		// A concurrent refresh with the same token won the race
+		return nil, s.revokeReused(ctx, session.ID)
 	}
 
-	return &LoginResponse{
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
-		ExpiresIn:   s.jwtService.GetAccessExpiration(),
-		User: &model.UserResponse{
-			ID:        user.ID,
-			Username:  user.Username,
-			TenantID:  user.TenantID,
-			Role:      user.Role,
-			IsActive:  user.IsActive,
-			CreatedAt: user.CreatedAt,
-		},
-	}, nil
+	return s.issueTokens(user, session)
 }
 
 // HashPassword hashes a password using bcrypt
@@ -145,13 +186,25 @@ func GenerateUserID() string {
 // CreateUserRequest represents a create user request
 type CreateUserRequest struct {
 	Username     string            `json:"username" binding:"required"`
-	Password     string            `json:"password" binding:"required,min=6"`
+	Password     string            `json:"password" binding:"required"`
 	TenantID     string            `json:"tenant_id"`
 	Role         model.UserRole    `json:"role"`
+	// MustChangePassword makes the user change the password at their first login
+	MustChangePassword bool `json:"must_change_password"`
 }
 
 // CreateUser creates a new user (admin only)
 func (s *AuthService) CreateUser(ctx context.Context, req *CreateUserRequest) (*model.UserResponse, error) {
+	if req.Role != "" && req.Role != model.RoleAdmin && req.Role != model.RoleUser {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidUserRole, req.Role)
+	}
+	if _, err := s.userRepo.GetByUsername(ctx, req.Username); err == nil {
+		return nil, ErrUsernameExists
+	}
+	if err := s.policy.Validate(req.Password); err != nil {
+		return nil, err
+	}
+
 	// Hash password
 	passwordHash, err := HashPassword(req.Password)
 	if err != nil {
@@ -166,7 +219,10 @@ func (s *AuthService) CreateUser(ctx context.Context, req *CreateUserRequest) (*
 		TenantID:     req.TenantID,
 		Role:         req.Role,
 		IsActive:     true,
+		MustChangePassword: req.MustChangePassword,
 	}
+	now := time.Now()
+	user.PasswordChangedAt = &now
 
 	if req.Role == "" {
 		user.Role = model.RoleUser
@@ -183,6 +239,7 @@ func (s *AuthService) CreateUser(ctx context.Context, req *CreateUserRequest) (*
 		TenantID:  user.TenantID,
 		Role:      user.Role,
 		IsActive:  user.IsActive,
+		MustChangePassword: user.MustChangePassword,
 		CreatedAt: user.CreatedAt,
 	}, nil
 }
@@ -199,6 +256,7 @@ func (s *AuthService) GetUserByID(ctx context.Context, userID string) (*model.Us
 		TenantID:  user.TenantID,
 		Role:      user.Role,
 		IsActive:  user.IsActive,
+		MustChangePassword: user.MustChangePassword,
 		CreatedAt: user.CreatedAt,
 	}, nil
 }
@@ -2,9 +2,12 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/weibh/openClusterClaw/internal/model"
@@ -14,6 +17,7 @@ import (
 var (
 	ErrConfigTemplateNotFound = errors.New("config template not found")
 	ErrDuplicateTemplateName   = errors.New("config template name already exists")
+	ErrTemplateRevisionNotFound = errors.New("config template revision not found")
 )
 
 // ConfigTemplateService defines the business logic for config template management
@@ -24,6 +28,9 @@ type ConfigTemplateService interface {
 	ListTemplates(ctx context.Context, adapterType string, page, pageSize int) ([]*model.ConfigTemplate, int, error)
 	UpdateTemplate(ctx context.Context, id string, req *UpdateTemplateRequest) (*model.ConfigTemplate, error)
 	DeleteTemplate(ctx context.Context, id string) error
+	ListRevisions(ctx context.Context, id string, page, pageSize int) ([]*TemplateRevisionInfo, int, error)
+	GetRevision(ctx context.Context, id string, revision int) (*model.ConfigTemplateRevision, error)
+	DiffRevisions(ctx context.Context, id string, from, to int) (*TemplateRevisionDiff, error)
 }
 
 // CreateTemplateRequest represents the request to create a config template
@@ -33,6 +40,14 @@ type CreateTemplateRequest struct {
 	Variables   []TemplateVariable     `json:"variables"`
 	AdapterType string                 `json:"adapter_type" binding:"required"`
 	Version     string                 `json:"version"`
+	// Kind is "template" (default) or "fragment"; it cannot be changed later
+	Kind string `json:"kind"`
+	// ParentID names the template to inherit variables from
+	ParentID string `json:"parent_id"`
+	// Fragments are merged in order after the parent
+	Fragments []string `json:"fragments"`
+	Changelog string   `json:"changelog"`
+	Author    string   `json:"-"`
 }
 
 // UpdateTemplateRequest represents the request to update a config template
@@ -41,27 +56,52 @@ type UpdateTemplateRequest struct {
 	Description *string             `json:"description"`
 	Variables   *[]TemplateVariable `json:"variables"`
 	Version     *string             `json:"version"`
+	// ParentID replaces the parent template; an empty string removes it
+	ParentID  *string   `json:"parent_id"`
+	Fragments *[]string `json:"fragments"`
+	Changelog string    `json:"changelog"`
+	Author    string    `json:"-"`
+}
+
+// TemplateRevisionInfo is a template revision with the number of instances rendered from it
+type TemplateRevisionInfo struct {
+	*model.ConfigTemplateRevision
+	InstanceCount int `json:"instance_count"`
+}
+
+// TemplateRevisionDiff lists the changes between two revisions of a template
+type TemplateRevisionDiff struct {
+	TemplateID string         `json:"template_id"`
+	From       int            `json:"from"`
+	To         int            `json:"to"`
+	FromHash   string         `json:"from_hash"`
+	ToHash     string         `json:"to_hash"`
+	Changes    []ConfigChange `json:"changes"`
 }
 
 // TemplateVariable represents a variable in a config template
 type TemplateVariable struct {
-	Name         string      `json:"name" binding:"required"`
-	Type         string      `json:"type" binding:"required"`
-	Default      interface{} `json:"default"`
-	Required     bool        `json:"required"`
-	Description  string      `json:"description"`
-	Secret       bool        `json:"secret"`
+	Name         string      `json:"name" yaml:"name" binding:"required"`
+	Type         string      `json:"type" yaml:"type" binding:"required"`
+	Default      interface{} `json:"default" yaml:"default,omitempty"`
+	Required     bool        `json:"required" yaml:"required,omitempty"`
+	Description  string      `json:"description" yaml:"description,omitempty"`
+	Secret       bool        `json:"secret" yaml:"secret,omitempty"`
 }
 
 // configTemplateService implements ConfigTemplateService
 type configTemplateService struct {
 	templateRepo repository.ConfigTemplateRepository
+	revisionRepo repository.ConfigTemplateRevisionRepository
+	instanceRepo repository.InstanceRepository
 }
 
 // NewConfigTemplateService creates a new config template service
-func NewConfigTemplateService(repo repository.ConfigTemplateRepository) ConfigTemplateService {
+func NewConfigTemplateService(repo repository.ConfigTemplateRepository, revisionRepo repository.ConfigTemplateRevisionRepository, instanceRepo repository.InstanceRepository) ConfigTemplateService {
 	return &configTemplateService{
 		templateRepo: repo,
+		revisionRepo: revisionRepo,
+		instanceRepo: instanceRepo,
 	}
 }
 
@@ -75,12 +115,28 @@ func (s *configTemplateService) CreateTemplate(ctx context.Context, req *CreateT
 		}
 	}
 
+	if err := validateTemplateVariables(req.AdapterType, req.Variables); err != nil {
+		return nil, err
+	}
+
 	// Marshal variables to JSON
 	variablesJSON, err := json.Marshal(req.Variables)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal variables: %w", err)
 	}
 
+	kind := req.Kind
+	if kind == "" {
+		kind = TemplateKindTemplate
+	}
+	if kind != TemplateKindTemplate && kind != TemplateKindFragment {
+		return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidTemplateReference, kind)
+	}
+	fragmentsJSON, err := encodeFragments(req.Fragments)
+	if err != nil {
+		return nil, err
+	}
+
 	template := &model.ConfigTemplate{
 		ID:          uuid.New().String(),
 		Name:        req.Name,
@@ -88,16 +144,34 @@ func (s *configTemplateService) CreateTemplate(ctx context.Context, req *CreateT
 		Variables:   variablesJSON,
 		AdapterType: req.AdapterType,
 		Version:     req.Version,
+		Kind:        kind,
+		ParentID:    req.ParentID,
+		Fragments:   fragmentsJSON,
 	}
 
 	if template.Version == "" {
 		template.Version = "1.0.0"
 	}
 
+	if err := newTemplateResolver(s.templateRepo, template).compose(ctx, template); err != nil {
+		return nil, err
+	}
+
+	template.Revision = 1
+	template.ContentHash = templateContentHash(template)
+
 	if err := s.templateRepo.Create(ctx, template); err != nil {
 		return nil, fmt.Errorf("failed to create config template: %w", err)
 	}
 
+	changelog := req.Changelog
+	if changelog == "" {
+		changelog = "Initial revision"
+	}
+	if err := s.revisionRepo.Create(ctx, newTemplateRevision(template, req.Author, changelog)); err != nil {
+		return nil, err
+	}
+
 	return template, nil
 }
 
@@ -150,6 +224,7 @@ func (s *configTemplateService) UpdateTemplate(ctx context.Context, id string, r
 	if err != nil {
 		return nil, ErrConfigTemplateNotFound
 	}
+	previous := *template
 
 	// Check for name conflict if name is being changed
 	if req.Name != nil && *req.Name != template.Name {
@@ -168,6 +243,9 @@ func (s *configTemplateService) UpdateTemplate(ctx context.Context, id string, r
 	}
 
 	if req.Variables != nil {
+		if err := validateTemplateVariables(template.AdapterType, *req.Variables); err != nil {
+			return nil, err
+		}
 		variablesJSON, err := json.Marshal(*req.Variables)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal variables: %w", err)
@@ -179,21 +257,316 @@ func (s *configTemplateService) UpdateTemplate(ctx context.Context, id string, r
 		template.Version = *req.Version
 	}
 
-	if err := s.templateRepo.Update(ctx, template); err != nil {
-		return nil, fmt.Errorf("failed to update config template: %w", err)
+	if req.ParentID != nil {
+		template.ParentID = *req.ParentID
+	}
+	if req.Fragments != nil {
+		fragmentsJSON, err := encodeFragments(*req.Fragments)
+		if err != nil {
+			return nil, err
+		}
+		template.Fragments = fragmentsJSON
+	}
+
+	if err := newTemplateResolver(s.templateRepo, template).compose(ctx, template); err != nil {
+		return nil, err
+	}
+
+	// Unchanged content does not produce a new revision
+	hash := templateContentHash(template)
+	if hash == template.ContentHash && template.Revision > 0 {
+		return template, nil
+	}
+
+	// Every template built on this one is checked before anything is saved
+	dependents, err := s.recomposeDependents(ctx, template)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.saveRevision(ctx, &previous, template, req.Author, req.Changelog); err != nil {
+		return nil, err
+	}
+	for _, change := range dependents {
+		changelog := fmt.Sprintf("Inherited change from %s revision %d", template.Name, template.Revision)
+		if err := s.saveRevision(ctx, change.previous, change.next, req.Author, changelog); err != nil {
+			return nil, err
+		}
 	}
 
 	return template, nil
 }
 
+// saveRevision stores next as the revision following previous
+func (s *configTemplateService) saveRevision(ctx context.Context, previous, next *model.ConfigTemplate, author, changelog string) error {
+	if err := s.ensureBaselineRevision(ctx, previous); err != nil {
+		return err
+	}
+	next.Revision = previous.Revision + 1
+	next.ContentHash = templateContentHash(next)
+
+	if err := s.revisionRepo.Create(ctx, newTemplateRevision(next, author, changelog)); err != nil {
+		return err
+	}
+	if err := s.templateRepo.Update(ctx, next); err != nil {
+		return fmt.Errorf("failed to update config template: %w", err)
+	}
+	return nil
+}
+
 func (s *configTemplateService) DeleteTemplate(ctx context.Context, id string) error {
 	if _, err := s.templateRepo.GetByID(ctx, id); err != nil {
 		return ErrConfigTemplateNotFound
 	}
 
+	dependents, err := s.templateRepo.ListDependents(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(dependents) > 0 {
+		names := make([]string, len(dependents))
+		for i, d := range dependents {
+			names[i] = d.Name
+		}
+		return fmt.Errorf("%w: %s", ErrTemplateInUse, strings.Join(names, ", "))
+	}
+
 	if err := s.templateRepo.Delete(ctx, id); err != nil {
 		return fmt.Errorf("failed to delete config template: %w", err)
 	}
+	if err := s.revisionRepo.DeleteByTemplate(ctx, id); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *configTemplateService) ListRevisions(ctx context.Context, id string, page, pageSize int) ([]*TemplateRevisionInfo, int, error) {
+	template, err := s.templateRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, 0, ErrConfigTemplateNotFound
+	}
+	if err := s.ensureBaselineRevision(ctx, template); err != nil {
+		return nil, 0, err
+	}
+
+	revisions, total, err := s.revisionRepo.ListByTemplate(ctx, id, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	counts, err := s.instanceRepo.CountByTemplateRevision(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	infos := make([]*TemplateRevisionInfo, len(revisions))
+	for i, rev := range revisions {
+		count := counts[rev.Revision]
+		// Instances created before revisions existed are counted against the baseline
+		if rev.Revision == 1 {
+			count += counts[0]
+		}
+		infos[i] = &TemplateRevisionInfo{ConfigTemplateRevision: rev, InstanceCount: count}
+	}
+	return infos, total, nil
+}
+
+func (s *configTemplateService) GetRevision(ctx context.Context, id string, revision int) (*model.ConfigTemplateRevision, error) {
+	template, err := s.templateRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrConfigTemplateNotFound
+	}
+	if err := s.ensureBaselineRevision(ctx, template); err != nil {
+		return nil, err
+	}
+
+	rev, err := s.revisionRepo.Get(ctx, id, revision)
+	if err != nil {
+		return nil, ErrTemplateRevisionNotFound
+	}
+	return rev, nil
+}
+
+func (s *configTemplateService) DiffRevisions(ctx context.Context, id string, from, to int) (*TemplateRevisionDiff, error) {
+	fromRev, err := s.GetRevision(ctx, id, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := s.GetRevision(ctx, id, to)
+	if err != nil {
+		return nil, err
+	}
 
+	fromContent, err := revisionContent(fromRev)
+	if err != nil {
+		return nil, err
+	}
+	toContent, err := revisionContent(toRev)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := diffValues(fromContent, toContent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TemplateRevisionDiff{
+		TemplateID: id,
+		From:       from,
+		To:         to,
+		FromHash:   fromRev.ContentHash,
+		ToHash:     toRev.ContentHash,
+		Changes:    changes,
+	}, nil
+}
+
+// ensureBaselineRevision records revision 1 for templates created before revisions were tracked
+func (s *configTemplateService) ensureBaselineRevision(ctx context.Context, template *model.ConfigTemplate) error {
+	if template.Revision > 0 {
+		return nil
+	}
+
+	baseline := *template
+	baseline.Revision = 1
+	baseline.ContentHash = templateContentHash(&baseline)
+	if err := s.revisionRepo.Create(ctx, newTemplateRevision(&baseline, "", "Baseline of existing template")); err != nil {
+		return err
+	}
+	if err := s.templateRepo.Update(ctx, &baseline); err != nil {
+		return fmt.Errorf("failed to update config template: %w", err)
+	}
+	*template = baseline
 	return nil
+}
+
+// templateContent is the part of a template covered by its content hash
+type templateContent struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	AdapterType string             `json:"adapter_type"`
+	Version     string             `json:"version"`
+	Variables   []TemplateVariable `json:"variables"`
+	// Composition is omitted for plain templates so their hashes are unchanged
+	Kind      string             `json:"kind,omitempty"`
+	ParentID  string             `json:"parent_id,omitempty"`
+	Fragments []string           `json:"fragments,omitempty"`
+	Resolved  []TemplateVariable `json:"resolved_variables,omitempty"`
+}
+
+// contentOf decodes the hashed content of a template
+func contentOf(template *model.ConfigTemplate) (*templateContent, error) {
+	content := &templateContent{
+		Name:        template.Name,
+		Description: template.Description,
+		AdapterType: template.AdapterType,
+		Version:     template.Version,
+		Variables:   []TemplateVariable{},
+		ParentID:    template.ParentID,
+	}
+	if len(template.Variables) > 0 {
+		if err := json.Unmarshal(template.Variables, &content.Variables); err != nil {
+			return nil, fmt.Errorf("failed to parse template variables: %w", err)
+		}
+		if content.Variables == nil {
+			content.Variables = []TemplateVariable{}
+		}
+	}
+	if templateKind(template) != TemplateKindTemplate {
+		content.Kind = template.Kind
+	}
+
+	var err error
+	if content.Fragments, err = templateFragments(template.Fragments); err != nil {
+		return nil, err
+	}
+	if content.Resolved, err = decodeTemplateVariables(template.ResolvedVariables); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// revisionTemplate returns the template as it was at a revision
+func revisionTemplate(rev *model.ConfigTemplateRevision) *model.ConfigTemplate {
+	return &model.ConfigTemplate{
+		ID:                rev.TemplateID,
+		Name:              rev.Name,
+		Description:       rev.Description,
+		Variables:         rev.Variables,
+		AdapterType:       rev.AdapterType,
+		Version:           rev.Version,
+		Kind:              rev.Kind,
+		ParentID:          rev.ParentID,
+		Fragments:         rev.Fragments,
+		ResolvedVariables: rev.ResolvedVariables,
+		Revision:          rev.Revision,
+		ContentHash:       rev.ContentHash,
+	}
+}
+
+// revisionContent returns the content of a revision with variables keyed by name so diffs are stable
+func revisionContent(rev *model.ConfigTemplateRevision) (map[string]interface{}, error) {
+	content, err := contentOf(revisionTemplate(rev))
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{
+		"name":         content.Name,
+		"description":  content.Description,
+		"adapter_type": content.AdapterType,
+		"version":      content.Version,
+		"variables":    variablesByName(content.Variables),
+	}
+	if content.ParentID != "" {
+		result["parent_id"] = content.ParentID
+	}
+	if len(content.Fragments) > 0 {
+		result["fragments"] = content.Fragments
+	}
+	if len(content.Resolved) > 0 {
+		result["resolved_variables"] = variablesByName(content.Resolved)
+	}
+	return result, nil
+}
+
+// variablesByName keys variables by name
+func variablesByName(list []TemplateVariable) map[string]TemplateVariable {
+	variables := make(map[string]TemplateVariable, len(list))
+	for _, v := range list {
+		variables[v.Name] = v
+	}
+	return variables
+}
+
+// templateContentHash returns the SHA-256 of the template content in canonical JSON form
+func templateContentHash(template *model.ConfigTemplate) string {
+	h := sha256.New()
+	content, err := contentOf(template)
+	if err != nil {
+		// Unparseable variables are hashed as stored
+		h.Write(template.Variables)
+		content = &templateContent{Name: template.Name, Description: template.Description, AdapterType: template.AdapterType, Version: template.Version}
+	}
+	canonical, _ := json.Marshal(content)
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newTemplateRevision snapshots the current state of a template
+func newTemplateRevision(template *model.ConfigTemplate, author, changelog string) *model.ConfigTemplateRevision {
+	return &model.ConfigTemplateRevision{
+		TemplateID:  template.ID,
+		Revision:    template.Revision,
+		ContentHash: template.ContentHash,
+		Name:        template.Name,
+		Description: template.Description,
+		Variables:   template.Variables,
+		AdapterType: template.AdapterType,
+		Version:     template.Version,
+		Kind:        templateKind(template),
+		ParentID:    template.ParentID,
+		Fragments:   template.Fragments,
+		Author:      author,
+		Changelog:   changelog,
+		ResolvedVariables: template.ResolvedVariables,
+	}
 }
\ No newline at end of file
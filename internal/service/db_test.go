@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/weibh/openClusterClaw/internal/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens an in-memory database with the tables the services use
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// Every connection to :memory: is a separate database
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(
+		&model.Tenant{},
+		&model.Project{},
+		&model.ClawInstance{},
+		&model.User{},
+		&model.PasswordHistory{},
+		&model.Session{},
+		&model.Role{},
+		&model.RoleBinding{},
+	); err != nil {
+		t.Fatalf("migrate database: %v", err)
+	}
+	return db
+}
+
+// mustCreate inserts fixture rows
+func mustCreate(t *testing.T, db *gorm.DB, records ...any) {
+	t.Helper()
+	for _, record := range records {
+		if err := db.WithContext(context.Background()).Create(record).Error; err != nil {
+			t.Fatalf("create %T: %v", record, err)
+		}
+	}
+}
@@ -0,0 +1,540 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
+)
+
+var (
+	ErrHasDependencies       = errors.New("has dependent resources")
+	ErrDeletionNotFound      = errors.New("deletion not found")
+	ErrDeletionNotRestorable = errors.New("deletion can no longer be restored")
+	ErrDeletionUnsafe        = errors.New("deletion would lock out administrators")
+)
+
+// DependencyRef names a record owned by a tenant or project
+type DependencyRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// DependencyReport lists the records a tenant or project owns
+type DependencyReport struct {
+	Instances    []DependencyRef `json:"instances"`
+	Projects     []DependencyRef `json:"projects"`
+	Users        []DependencyRef `json:"users"`
+	Variables    []DependencyRef `json:"variables"`
+	RoleBindings []DependencyRef `json:"role_bindings"`
+	// Templates are shared by all tenants and never deleted; they are listed because the
+	// instances use them
+	Templates []DependencyRef `json:"templates"`
+}
+
+// Empty reports whether nothing would be deleted along with the tenant or project
+func (r *DependencyReport) Empty() bool {
+	return len(r.Instances) == 0 && len(r.Projects) == 0 && len(r.Users) == 0 &&
+		len(r.Variables) == 0 && len(r.RoleBindings) == 0
+}
+
+func (r *DependencyReport) summary() string {
+	var parts []string
+	for _, count := range []struct {
+		n    int
+		kind string
+	}{
+		{len(r.Instances), "instances"},
+		{len(r.Projects), "projects"},
+		{len(r.Users), "users"},
+		{len(r.Variables), "variables"},
+		{len(r.RoleBindings), "role bindings"},
+	} {
+		if count.n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", count.n, count.kind))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DependencyError is returned when a tenant or project still owns records and is not deleted
+// with cascade
+type DependencyError struct {
+	Kind   model.DeletionKind
+	ID     string
+	Report *DependencyReport
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("%s %s has %s; delete them first or delete with cascade", e.Kind, e.ID, e.Report.summary())
+}
+
+func (e *DependencyError) Unwrap() error {
+	return ErrHasDependencies
+}
+
+// DeletionService deletes tenants and projects together with everything they own. A cascading
+// deletion runs in the background: it tears down the runtime resources of the instances and
+// hides all records, which stay restorable for the grace period before they are purged.
+type DeletionService interface {
+	TenantDependencies(ctx context.Context, tenantID string) (*DependencyReport, error)
+	ProjectDependencies(ctx context.Context, projectID string) (*DependencyReport, error)
+	DeleteTenant(ctx context.Context, tenantID, requestedBy string) (*DeletionInfo, error)
+	DeleteProject(ctx context.Context, projectID, requestedBy string) (*DeletionInfo, error)
+	GetDeletion(ctx context.Context, id string) (*DeletionInfo, error)
+	ListDeletions(ctx context.Context, tenantID string, page, pageSize int) ([]*DeletionInfo, int, error)
+	RestoreDeletion(ctx context.Context, id string) (*DeletionInfo, error)
+	// ResumeDeletions restarts the deletions left unfinished by a previous process
+	ResumeDeletions(ctx context.Context) error
+	// PurgeExpired removes the records of deletions whose grace period is over
+	PurgeExpired(ctx context.Context) error
+}
+
+// DeletionInfo is the API view of a cascading deletion
+type DeletionInfo struct {
+	*model.DeletionOperation
+	Report *DependencyReport `json:"report,omitempty"`
+}
+
+// deletionService implements DeletionService
+type deletionService struct {
+	repo         repository.DeletionRepository
+	tenantRepo   repository.TenantRepository
+	projectRepo  repository.ProjectRepository
+	instanceRepo repository.InstanceRepository
+	userRepo     *repository.UserRepository
+	variableRepo repository.SharedVariableRepository
+	bindingRepo  repository.RoleBindingRepository
+	templateRepo repository.ConfigTemplateRepository
+	instances    InstanceService
+	gracePeriod  time.Duration
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// NewDeletionService creates a new cascading deletion service. Deleted records stay
+// restorable for gracePeriod.
+func NewDeletionService(repo repository.DeletionRepository, tenantRepo repository.TenantRepository, projectRepo repository.ProjectRepository, instanceRepo repository.InstanceRepository, userRepo *repository.UserRepository, variableRepo repository.SharedVariableRepository, bindingRepo repository.RoleBindingRepository, templateRepo repository.ConfigTemplateRepository, instances InstanceService, gracePeriod time.Duration) DeletionService {
+	return &deletionService{
+		repo:         repo,
+		tenantRepo:   tenantRepo,
+		projectRepo:  projectRepo,
+		instanceRepo: instanceRepo,
+		userRepo:     userRepo,
+		variableRepo: variableRepo,
+		bindingRepo:  bindingRepo,
+		templateRepo: templateRepo,
+		instances:    instances,
+		gracePeriod:  gracePeriod,
+		running:      make(map[string]bool),
+	}
+}
+
+func (s *deletionService) TenantDependencies(ctx context.Context, tenantID string) (*DependencyReport, error) {
+	report := &DependencyReport{}
+
+	instances, err := s.instanceRepo.ListByFilter(ctx, repository.InstanceFilter{TenantID: tenantID})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.addInstances(ctx, report, instances); err != nil {
+		return nil, err
+	}
+
+	projects, err := s.projectRepo.ListByTenant(ctx, tenantID, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, project := range projects {
+		report.Projects = append(report.Projects, DependencyRef{ID: project.ID, Name: project.Name})
+		if err := s.addVariables(ctx, report, model.VariableScopeProject, project.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	users, _, err := s.userRepo.List(ctx, tenantID, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		report.Users = append(report.Users, DependencyRef{ID: user.ID, Name: user.Username})
+	}
+
+	if err := s.addVariables(ctx, report, model.VariableScopeTenant, tenantID); err != nil {
+		return nil, err
+	}
+	bindings, err := s.bindingRepo.List(ctx, repository.RoleBindingFilter{TenantID: tenantID})
+	if err != nil {
+		return nil, err
+	}
+	addBindings(report, bindings)
+	return report, nil
+}
+
+func (s *deletionService) ProjectDependencies(ctx context.Context, projectID string) (*DependencyReport, error) {
+	report := &DependencyReport{}
+
+	instances, err := s.instanceRepo.ListByFilter(ctx, repository.InstanceFilter{ProjectID: projectID})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.addInstances(ctx, report, instances); err != nil {
+		return nil, err
+	}
+
+	if err := s.addVariables(ctx, report, model.VariableScopeProject, projectID); err != nil {
+		return nil, err
+	}
+	bindings, err := s.bindingRepo.List(ctx, repository.RoleBindingFilter{Scope: model.BindingScopeProject, ScopeID: projectID})
+	if err != nil {
+		return nil, err
+	}
+	addBindings(report, bindings)
+	return report, nil
+}
+
+// addInstances adds instances, their variables and the templates they use to a report
+func (s *deletionService) addInstances(ctx context.Context, report *DependencyReport, instances []*model.ClawInstance) error {
+	templates := make(map[string]bool)
+	for _, instance := range instances {
+		report.Instances = append(report.Instances, DependencyRef{ID: instance.ID, Name: instance.Name})
+		if err := s.addVariables(ctx, report, model.VariableScopeInstance, instance.ID); err != nil {
+			return err
+		}
+		if instance.TemplateID == "" || templates[instance.TemplateID] {
+			continue
+		}
+		templates[instance.TemplateID] = true
+		if template, err := s.templateRepo.GetByID(ctx, instance.TemplateID); err == nil {
+			report.Templates = append(report.Templates, DependencyRef{ID: template.ID, Name: template.Name})
+		}
+	}
+	return nil
+}
+
+func (s *deletionService) addVariables(ctx context.Context, report *DependencyReport, scope model.VariableScope, scopeID string) error {
+	variables, err := s.variableRepo.List(ctx, scope, scopeID)
+	if err != nil {
+		return err
+	}
+	for _, variable := range variables {
+		report.Variables = append(report.Variables, DependencyRef{ID: variable.ID, Name: variable.Name})
+	}
+	return nil
+}
+
+func addBindings(report *DependencyReport, bindings []*model.RoleBinding) {
+	for _, b := range bindings {
+		report.RoleBindings = append(report.RoleBindings, DependencyRef{ID: b.ID, Name: b.Role})
+	}
+}
+
+func (s *deletionService) DeleteTenant(ctx context.Context, tenantID, requestedBy string) (*DeletionInfo, error) {
+	tenant, err := s.tenantRepo.GetByID(ctx, tenantID)
+	if err != nil {
+		return nil, ErrTenantNotFound
+	}
+
+	// Deleting the tenant of an admin would delete the admin as well
+	users, _, err := s.userRepo.List(ctx, tenant.ID, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if user.Role == model.RoleAdmin {
+			return nil, fmt.Errorf("%w: admin %s belongs to tenant %s", ErrDeletionUnsafe, user.Username, tenant.Name)
+		}
+	}
+
+	return s.schedule(ctx, &model.DeletionOperation{
+		Kind:        model.DeletionKindTenant,
+		TargetID:    tenant.ID,
+		TargetName:  tenant.Name,
+		TenantID:    tenant.ID,
+		RequestedBy: requestedBy,
+	})
+}
+
+func (s *deletionService) DeleteProject(ctx context.Context, projectID, requestedBy string) (*DeletionInfo, error) {
+	project, err := s.projectRepo.GetByID(ctx, projectID)
+	if err != nil {
+		return nil, ErrProjectNotFound
+	}
+	return s.schedule(ctx, &model.DeletionOperation{
+		Kind:        model.DeletionKindProject,
+		TargetID:    project.ID,
+		TargetName:  project.Name,
+		TenantID:    project.TenantID,
+		RequestedBy: requestedBy,
+	})
+}
+
+// schedule records a deletion and starts it in the background. The target is hidden at once,
+// so nothing new can be created in it while the deletion runs.
+func (s *deletionService) schedule(ctx context.Context, op *model.DeletionOperation) (*DeletionInfo, error) {
+	op.Status = model.DeletionPending
+	if err := s.repo.Create(ctx, op); err != nil {
+		return nil, err
+	}
+	if err := s.repo.SoftDelete(ctx, targetRecords(op)); err != nil {
+		s.finish(ctx, op, model.DeletionFailed, err.Error())
+		return nil, err
+	}
+
+	s.start(op)
+	return s.toInfo(op)
+}
+
+func (s *deletionService) GetDeletion(ctx context.Context, id string) (*DeletionInfo, error) {
+	op, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrDeletionNotFound
+	}
+	return s.toInfo(op)
+}
+
+func (s *deletionService) ListDeletions(ctx context.Context, tenantID string, page, pageSize int) ([]*DeletionInfo, int, error) {
+	offset := (page - 1) * pageSize
+	ops, total, err := s.repo.List(ctx, tenantID, pageSize, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	infos := make([]*DeletionInfo, 0, len(ops))
+	for _, op := range ops {
+		info, err := s.toInfo(op)
+		if err != nil {
+			return nil, 0, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, total, nil
+}
+
+// RestoreDeletion brings back everything a soft-deleted or failed deletion hid. Restored
+// instances are stopped; starting them recreates their runtime resources.
+func (s *deletionService) RestoreDeletion(ctx context.Context, id string) (*DeletionInfo, error) {
+	op, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrDeletionNotFound
+	}
+	if op.Status != model.DeletionSoftDeleted && op.Status != model.DeletionFailed {
+		return nil, fmt.Errorf("%w: deletion is %s", ErrDeletionNotRestorable, op.Status)
+	}
+	if op.Kind == model.DeletionKindProject {
+		if _, err := s.tenantRepo.GetByID(ctx, op.TenantID); err != nil {
+			return nil, fmt.Errorf("%w: tenant %s is deleted", ErrDeletionNotRestorable, op.TenantID)
+		}
+	}
+
+	records, err := s.records(op)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Restore(ctx, records); err != nil {
+		return nil, err
+	}
+	op.PurgeAfter = nil
+	s.finish(ctx, op, model.DeletionRestored, "restored")
+	return s.toInfo(op)
+}
+
+func (s *deletionService) ResumeDeletions(ctx context.Context) error {
+	ops, err := s.repo.ListByStatus(ctx, model.DeletionPending, model.DeletionRunning)
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		log.Printf("Resuming deletion %s of %s %s", op.ID, op.Kind, op.TargetName)
+		s.start(op)
+	}
+	return nil
+}
+
+func (s *deletionService) PurgeExpired(ctx context.Context) error {
+	ops, err := s.repo.ListByStatus(ctx, model.DeletionSoftDeleted)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, op := range ops {
+		if op.PurgeAfter != nil && now.Before(*op.PurgeAfter) {
+			continue
+		}
+		if err := s.purge(ctx, op); err != nil {
+			log.Printf("Warning: failed to purge deletion %s: %v", op.ID, err)
+		}
+	}
+	return nil
+}
+
+// start runs a deletion in the background unless it already runs in this process
+func (s *deletionService) start(op *model.DeletionOperation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[op.ID] {
+		return
+	}
+	s.running[op.ID] = true
+
+	// The runner owns its copy; the caller may still read the original
+	owned := *op
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, owned.ID)
+			s.mu.Unlock()
+		}()
+		s.run(context.Background(), &owned)
+	}()
+}
+
+// run tears down the instances of a deletion and hides every record it covers
+func (s *deletionService) run(ctx context.Context, op *model.DeletionOperation) {
+	op.Status = model.DeletionRunning
+	s.save(ctx, op)
+
+	var report *DependencyReport
+	var err error
+	if op.Kind == model.DeletionKindTenant {
+		report, err = s.TenantDependencies(ctx, op.TargetID)
+	} else {
+		report, err = s.ProjectDependencies(ctx, op.TargetID)
+	}
+	if err != nil {
+		s.finish(ctx, op, model.DeletionFailed, fmt.Sprintf("failed to list dependencies: %v", err))
+		return
+	}
+	if op.Report, err = json.Marshal(report); err != nil {
+		s.finish(ctx, op, model.DeletionFailed, err.Error())
+		return
+	}
+	s.save(ctx, op)
+
+	for _, instance := range report.Instances {
+		if err := s.instances.TeardownInstance(ctx, instance.ID); err != nil && !errors.Is(err, ErrInstanceNotFound) {
+			s.finish(ctx, op, model.DeletionFailed, fmt.Sprintf("failed to tear down instance %s: %v", instance.Name, err))
+			return
+		}
+	}
+
+	records, err := s.records(op)
+	if err != nil {
+		s.finish(ctx, op, model.DeletionFailed, err.Error())
+		return
+	}
+	if err := s.repo.SoftDelete(ctx, records); err != nil {
+		s.finish(ctx, op, model.DeletionFailed, err.Error())
+		return
+	}
+
+	purgeAfter := time.Now().Add(s.gracePeriod)
+	op.Status = model.DeletionSoftDeleted
+	op.PurgeAfter = &purgeAfter
+	op.Message = fmt.Sprintf("deleted %s", report.summary())
+	s.save(ctx, op)
+
+	if s.gracePeriod <= 0 {
+		if err := s.purge(ctx, op); err != nil {
+			log.Printf("Warning: failed to purge deletion %s: %v", op.ID, err)
+		}
+	}
+}
+
+// purge removes the records of a soft-deleted deletion for good
+func (s *deletionService) purge(ctx context.Context, op *model.DeletionOperation) error {
+	records, err := s.records(op)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Purge(ctx, records); err != nil {
+		return err
+	}
+	s.finish(ctx, op, model.DeletionPurged, op.Message)
+	return nil
+}
+
+// records returns the IDs of the target and the records in the report of a deletion
+func (s *deletionService) records(op *model.DeletionOperation) (*repository.DeletionRecords, error) {
+	records := targetRecords(op)
+	if len(op.Report) == 0 {
+		return records, nil
+	}
+	var report DependencyReport
+	if err := json.Unmarshal(op.Report, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse deletion report: %w", err)
+	}
+	records.Instances = refIDs(report.Instances)
+	records.Projects = append(records.Projects, refIDs(report.Projects)...)
+	records.Users = refIDs(report.Users)
+	records.Variables = refIDs(report.Variables)
+	records.RoleBindings = refIDs(report.RoleBindings)
+	return records, nil
+}
+
+func targetRecords(op *model.DeletionOperation) *repository.DeletionRecords {
+	if op.Kind == model.DeletionKindTenant {
+		return &repository.DeletionRecords{Tenants: []string{op.TargetID}}
+	}
+	return &repository.DeletionRecords{Projects: []string{op.TargetID}}
+}
+
+func refIDs(refs []DependencyRef) []string {
+	ids := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		ids = append(ids, ref.ID)
+	}
+	return ids
+}
+
+// finish records the final status of a deletion
+func (s *deletionService) finish(ctx context.Context, op *model.DeletionOperation, status model.DeletionStatus, message string) {
+	now := time.Now()
+	op.Status = status
+	op.Message = message
+	op.FinishedAt = &now
+	s.save(ctx, op)
+}
+
+func (s *deletionService) save(ctx context.Context, op *model.DeletionOperation) {
+	if err := s.repo.Update(ctx, op); err != nil {
+		log.Printf("Warning: failed to save deletion %s: %v", op.ID, err)
+	}
+}
+
+func (s *deletionService) toInfo(op *model.DeletionOperation) (*DeletionInfo, error) {
+	info := &DeletionInfo{DeletionOperation: op}
+	if len(op.Report) > 0 {
+		info.Report = &DependencyReport{}
+		if err := json.Unmarshal(op.Report, info.Report); err != nil {
+			return nil, fmt.Errorf("failed to parse deletion report: %w", err)
+		}
+	}
+	return info, nil
+}
+
+// RunPurges purges expired deletions every interval until ctx is done
+func RunPurges(ctx context.Context, deletions DeletionService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := deletions.PurgeExpired(ctx); err != nil {
+			log.Printf("Warning: deletion purge failed: %v", err)
+		}
+	}
+}
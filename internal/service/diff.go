@@ -0,0 +1,116 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Change types reported in a ConfigChange
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// ConfigChange describes a difference at a single path between two documents
+type ConfigChange struct {
+	Path string      `json:"path"`
+	Type string      `json:"type"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// diffValues compares two values by their JSON form.
+// Objects and arrays are compared member by member; a member present on one side only
+// is reported once as added or removed with its whole value.
+func diffValues(from, to interface{}) ([]ConfigChange, error) {
+	fromDoc, err := toJSONValue(from)
+	if err != nil {
+		return nil, err
+	}
+	toDoc, err := toJSONValue(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []ConfigChange{}
+	diffNode(&changes, "", fromDoc, toDoc)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// toJSONValue round-trips v through JSON so values of any type compare uniformly
+func toJSONValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode for diff: %w", err)
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode for diff: %w", err)
+	}
+	return decoded, nil
+}
+
+func diffNode(changes *[]ConfigChange, path string, from, to interface{}) {
+	// Absent and null are treated the same
+	switch {
+	case from == nil && to == nil:
+		return
+	case from == nil:
+		*changes = append(*changes, ConfigChange{Path: path, Type: ChangeAdded, New: to})
+		return
+	case to == nil:
+		*changes = append(*changes, ConfigChange{Path: path, Type: ChangeRemoved, Old: from})
+		return
+	}
+
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		for key, fromChild := range fromMap {
+			diffNode(changes, childPath(path, key), fromChild, toMap[key])
+		}
+		for key, toChild := range toMap {
+			if _, ok := fromMap[key]; !ok {
+				diffNode(changes, childPath(path, key), nil, toChild)
+			}
+		}
+		return
+	}
+
+	fromList, fromIsList := from.([]interface{})
+	toList, toIsList := to.([]interface{})
+	if fromIsList && toIsList {
+		for i := 0; i < len(fromList) || i < len(toList); i++ {
+			var fromChild, toChild interface{}
+			if i < len(fromList) {
+				fromChild = fromList[i]
+			}
+			if i < len(toList) {
+				toChild = toList[i]
+			}
+			diffNode(changes, path+"["+strconv.Itoa(i)+"]", fromChild, toChild)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, ConfigChange{Path: path, Type: ChangeModified, Old: from, New: to})
+	}
+}
+
+// childPath appends key to path, bracketing keys that contain path separators
+func childPath(path, key string) string {
+	if strings.ContainsAny(key, ".[]") {
+		return path + "[" + strconv.Quote(key) + "]"
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
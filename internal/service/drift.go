@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
+	"github.com/weibh/openClusterClaw/internal/runtime/k8s"
+)
+
+// Drift issue types
+const (
+	// DriftMissing means the live object does not exist
+	DriftMissing = "missing"
+	// DriftModified means the live ConfigMap was written by the control plane and changed afterwards
+	DriftModified = "modified"
+	// DriftStale means the live ConfigMap holds a config the control plane did not last apply
+	DriftStale = "stale"
+	// DriftEnvOutdated means the pod was created with env vars that differ from the current revision
+	DriftEnvOutdated = "env_outdated"
+)
+
+// DriftIssue describes one difference between the desired and live state of an instance
+type DriftIssue struct {
+	Resource string `json:"resource"`
+	Type     string `json:"type"`
+	Message  string `json:"message"`
+	// Keys lists the differing ConfigMap keys; values are never reported
+	Keys []string `json:"keys,omitempty"`
+}
+
+// ConfigDrift is the result of comparing the applied config of an instance with the cluster
+type ConfigDrift struct {
+	InstanceID     string `json:"instance_id"`
+	ConfigRevision int    `json:"config_revision"`
+	// Checked is false when the cluster could not be inspected
+	Checked     bool         `json:"checked"`
+	Drifted     bool         `json:"drifted"`
+	DesiredHash string       `json:"desired_hash,omitempty"`
+	LiveHash    string       `json:"live_hash,omitempty"`
+	PodRevision int          `json:"pod_revision,omitempty"`
+	Issues      []DriftIssue `json:"issues,omitempty"`
+	Corrected   bool         `json:"corrected"`
+	Message     string       `json:"message,omitempty"`
+	CheckedAt   time.Time    `json:"checked_at"`
+}
+
+// CheckConfigDrift compares the current config revision of an instance with its live ConfigMap and pod.
+// With correct set, a drifted ConfigMap is rewritten from the revision; pods are never restarted.
+func (s *instanceService) CheckConfigDrift(ctx context.Context, id string, correct bool) (*ConfigDrift, error) {
+	instance, err := s.instanceRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrInstanceNotFound
+	}
+	return s.checkDrift(ctx, instance, correct)
+}
+
+// ListConfigDrift checks every instance matching the tenant and project filters
+func (s *instanceService) ListConfigDrift(ctx context.Context, tenantID, projectID string, correct bool) ([]*ConfigDrift, error) {
+	if tenantID != "" && !repository.InTenantScope(ctx, tenantID) {
+		return nil, ErrTenantAccessDenied
+	}
+	if projectID != "" && !repository.InProjectScope(ctx, projectID) {
+		return nil, ErrProjectAccessDenied
+	}
+	instances, err := s.instanceRepo.ListByFilter(ctx, repository.InstanceFilter{TenantID: tenantID, ProjectID: projectID})
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]*ConfigDrift, 0, len(instances))
+	for _, instance := range instances {
+		report, err := s.checkDrift(ctx, instance, correct)
+		if err != nil {
+			// One broken instance must not hide the state of the others
+			report = &ConfigDrift{
+				InstanceID:     instance.ID,
+				ConfigRevision: instance.ConfigRevision,
+				Message:        err.Error(),
+				CheckedAt:      time.Now(),
+			}
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func (s *instanceService) checkDrift(ctx context.Context, instance *model.ClawInstance, correct bool) (*ConfigDrift, error) {
+	report := &ConfigDrift{
+		InstanceID:     instance.ID,
+		ConfigRevision: instance.ConfigRevision,
+		CheckedAt:      time.Now(),
+	}
+	if s.configMapManager == nil {
+		report.Message = "Kubernetes is not configured"
+		return report, nil
+	}
+
+	applied, err := s.currentConfig(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	if applied == nil {
+		applied = &appliedConfig{}
+	}
+
+	desired, err := desiredConfigMap(instance, applied.files)
+	if err != nil {
+		return nil, err
+	}
+	desiredData, err := desired.Build()
+	if err != nil {
+		return nil, err
+	}
+	report.DesiredHash = desired.Annotations[k8s.AnnotationConfigHash]
+	report.Checked = true
+
+	configMapDrifted := false
+	live, err := s.configMapManager.GetConfigMap(ctx, k8s.GenerateConfigMapName(instance.ID))
+	if err != nil {
+		configMapDrifted = true
+		report.Issues = append(report.Issues, DriftIssue{Resource: "configmap", Type: DriftMissing, Message: "ConfigMap not found"})
+	} else {
+		report.LiveHash = k8s.HashData(live.Data)
+		if report.LiveHash != report.DesiredHash {
+			configMapDrifted = true
+			issue := DriftIssue{Resource: "configmap", Keys: diffKeys(desiredData, live.Data)}
+			if live.Annotations[k8s.AnnotationConfigHash] == report.DesiredHash {
+				issue.Type = DriftModified
+				issue.Message = "ConfigMap was changed outside the control plane"
+			} else {
+				issue.Type = DriftStale
+				issue.Message = fmt.Sprintf("ConfigMap holds config revision %s, expected %d", live.Annotations[k8s.AnnotationConfigRevision], instance.ConfigRevision)
+			}
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	// Mounted files follow ConfigMap updates, env vars only change when the pod is recreated
+	if s.podManager != nil && instance.Status == model.StatusRunning {
+		pod, err := s.podManager.GetPod(ctx, k8s.GeneratePodName(instance.ID))
+		if err != nil {
+			report.Issues = append(report.Issues, DriftIssue{Resource: "pod", Type: DriftMissing, Message: "pod not found for running instance"})
+		} else if envHash, ok := pod.Annotations[k8s.AnnotationEnvHash]; ok {
+			// Pods created before generations were recorded carry no annotations and are not compared
+			report.PodRevision, _ = strconv.Atoi(pod.Annotations[k8s.AnnotationConfigRevision])
+			if envHash != k8s.HashData(applied.env) {
+				report.Issues = append(report.Issues, DriftIssue{
+					Resource: "pod",
+					Type:     DriftEnvOutdated,
+					Message:  fmt.Sprintf("pod was created from config revision %d; restart the instance to apply env var changes", report.PodRevision),
+				})
+			}
+		}
+	}
+
+	report.Drifted = len(report.Issues) > 0
+	if correct && configMapDrifted {
+		if _, err := s.putConfigMap(ctx, instance, applied.files); err != nil {
+			report.Message = fmt.Sprintf("failed to correct ConfigMap: %v", err)
+		} else {
+			report.Corrected = true
+		}
+	}
+	return report, nil
+}
+
+// diffKeys returns the keys whose values differ between two data maps, sorted
+func diffKeys(desired, live map[string]string) []string {
+	var keys []string
+	for key, value := range desired {
+		if liveValue, ok := live[key]; !ok || liveValue != value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range live {
+		if _, ok := desired[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RunDriftChecks checks all instances for config drift every interval until ctx is done,
+// rewriting drifted ConfigMaps when autoCorrect is set
+func RunDriftChecks(ctx context.Context, instances InstanceService, interval time.Duration, autoCorrect bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reports, err := instances.ListConfigDrift(ctx, "", "", autoCorrect)
+		if err != nil {
+			log.Printf("Warning: config drift check failed: %v", err)
+			continue
+		}
+		for _, report := range reports {
+			if !report.Drifted {
+				continue
+			}
+			for _, issue := range report.Issues {
+				log.Printf("Config drift on instance %s: %s %s: %s", report.InstanceID, issue.Resource, issue.Type, issue.Message)
+			}
+			if report.Corrected {
+				log.Printf("Config drift on instance %s corrected from revision %d", report.InstanceID, report.ConfigRevision)
+			}
+		}
+	}
+}
@@ -2,24 +2,35 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/weibh/openClusterClaw/internal/adapter"
 	"github.com/weibh/openClusterClaw/internal/domain"
 	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/pkg/secretbox"
 	"github.com/weibh/openClusterClaw/internal/repository"
 	"github.com/weibh/openClusterClaw/internal/runtime/k8s"
+	corev1 "k8s.io/api/core/v1"
 )
 
 var (
-	ErrInstanceNotFound = errors.New("instance not found")
-	ErrInvalidStatus    = errors.New("invalid status transition")
+	ErrInstanceNotFound        = errors.New("instance not found")
+	ErrInvalidStatus           = errors.New("invalid status transition")
+	ErrTemplateAdapterMismatch = errors.New("config template does not match adapter type")
+	ErrTenantAccessDenied      = errors.New("access to another tenant is denied")
+	ErrProjectAccessDenied     = errors.New("access to a project you are not a member of is denied")
 )
 
+// previewInstanceID is the placeholder instance ID used when rendering configs without an instance
+const previewInstanceID = "preview"
+
 // InstanceService defines the business logic for instance management
 type InstanceService interface {
 	CreateInstance(ctx context.Context, req *CreateInstanceRequest) (*domain.ClawInstance, error)
@@ -30,7 +41,20 @@ type InstanceService interface {
 	StopInstance(ctx context.Context, id string) error
 	RestartInstance(ctx context.Context, id string) error
 	DeleteInstance(ctx context.Context, id string) error
+	// TeardownInstance removes the runtime resources of an instance but keeps its record
+	TeardownInstance(ctx context.Context, id string) error
 	GetInstanceLogs(ctx context.Context, id string, tailLines int64) (string, error)
+	RenderConfig(ctx context.Context, req *RenderConfigRequest) (*RenderConfigResult, error)
+	ListConfigRevisions(ctx context.Context, id string, page, pageSize int) ([]*InstanceConfigRevisionInfo, int, error)
+	GetConfigRevision(ctx context.Context, id string, revision int) (*InstanceConfigRevisionInfo, error)
+	DiffConfigRevisions(ctx context.Context, id string, from, to int) (*InstanceConfigDiff, error)
+	RollbackConfig(ctx context.Context, id string, req *ConfigRollbackRequest) (*domain.ClawInstance, error)
+	PatchConfig(ctx context.Context, id string, req *PatchConfigRequest) (*domain.ClawInstance, error)
+	GetInstanceHealth(ctx context.Context, id string) (*InstanceHealth, error)
+	CheckConfigDrift(ctx context.Context, id string, correct bool) (*ConfigDrift, error)
+	ListConfigDrift(ctx context.Context, tenantID, projectID string, correct bool) ([]*ConfigDrift, error)
+	VariableUsers(ctx context.Context, scope model.VariableScope, scopeID, name string) ([]string, error)
+	RerenderVariableUsers(ctx context.Context, scope model.VariableScope, scopeID, name, author string) ([]*VariableRerender, error)
 }
 
 // CreateInstanceRequest represents the request to create an instance
@@ -43,6 +67,8 @@ type CreateInstanceRequest struct {
 	Config      *domain.InstanceConfig  `json:"config"`
 	Resources   *domain.ResourceSpec    `json:"resources"`
 	Storage     *domain.StorageSpec     `json:"storage"`
+	Labels      map[string]string       `json:"labels"`
+	Author      string                  `json:"-"`
 }
 
 // UpdateInstanceRequest represents the request to update an instance
@@ -50,25 +76,91 @@ type UpdateInstanceRequest struct {
 	Name      *string                 `json:"name"`
 	Config    *domain.InstanceConfig   `json:"config"`
 	Resources *domain.ResourceSpec     `json:"resources"`
+	// Labels replaces the instance labels when not nil
+	Labels    map[string]string        `json:"labels"`
+	// Changelog describes the config change recorded in the new revision
+	Changelog string                   `json:"changelog"`
+	Author    string                   `json:"-"`
+}
+
+// RenderConfigRequest represents a dry-run request to render an instance configuration
+type RenderConfigRequest struct {
+	AdapterType      string               `json:"adapter_type" binding:"required"`
+	Version          string               `json:"version"`
+	TemplateName     string               `json:"template_name"`
+	TemplateRevision int                  `json:"template_revision"`
+	Overrides        map[string]string    `json:"overrides"`
+	Resources        *domain.ResourceSpec `json:"resources"`
+	// TenantID and ProjectID select the shared variables visible to the preview
+	TenantID  string `json:"tenant_id"`
+	ProjectID string `json:"project_id"`
+}
+
+// RenderConfigResult represents the outcome of a config dry-run
+type RenderConfigResult struct {
+	AdapterType   string                `json:"adapter_type"`
+	Version       string                `json:"version"`
+	// TargetConfig is the content of the primary file, kept for clients that expect a single config
+	TargetConfig  string                `json:"target_config"`
+	Files         []adapter.ConfigFile  `json:"files"`
+	EnvVars       map[string]string     `json:"env_vars,omitempty"`
+	VolumeMounts  []adapter.VolumeMount `json:"volume_mounts,omitempty"`
+	UnifiedConfig adapter.UnifiedConfig `json:"unified_config"`
+	Valid         bool                  `json:"valid"`
+	Errors        []adapter.FieldError  `json:"errors,omitempty"`
+	Pod           *corev1.Pod           `json:"pod,omitempty"`
+}
+
+// InstanceHealth reports whether an instance is serving
+type InstanceHealth struct {
+	InstanceID   string                `json:"instance_id"`
+	Status       domain.InstanceStatus `json:"status"`
+	Healthy      bool                  `json:"healthy"`
+	Ready        bool                  `json:"ready"`
+	RestartCount int32                 `json:"restart_count"`
+	Message      string                `json:"message,omitempty"`
 }
 
 // instanceService implements InstanceService
 type instanceService struct {
-	instanceRepo     repository.InstanceRepository
-	podManager       *k8s.PodManager
-	configMapManager *k8s.ConfigMapManager
+	instanceRepo       repository.InstanceRepository
+	tenantRepo         repository.TenantRepository
+	projectRepo        repository.ProjectRepository
+	templateRepo       repository.ConfigTemplateRepository
+	revisionRepo       repository.ConfigTemplateRevisionRepository
+	configRevisionRepo repository.InstanceConfigRevisionRepository
+	variableRepo       repository.SharedVariableRepository
+	secrets            *secretbox.Box
+	podManager         *k8s.PodManager
+	configMapManager   *k8s.ConfigMapManager
+	// quotaMu serializes quota admission so concurrent requests cannot both fit into the same headroom
+	quotaMu sync.Mutex
 }
 
 // NewInstanceService creates a new instance service
-func NewInstanceService(repo repository.InstanceRepository, podManager *k8s.PodManager, configMapManager *k8s.ConfigMapManager) InstanceService {
+func NewInstanceService(repo repository.InstanceRepository, tenantRepo repository.TenantRepository, projectRepo repository.ProjectRepository, templateRepo repository.ConfigTemplateRepository, revisionRepo repository.ConfigTemplateRevisionRepository, configRevisionRepo repository.InstanceConfigRevisionRepository, variableRepo repository.SharedVariableRepository, secrets *secretbox.Box, podManager *k8s.PodManager, configMapManager *k8s.ConfigMapManager) InstanceService {
 	return &instanceService{
-		instanceRepo:     repo,
-		podManager:       podManager,
-		configMapManager: configMapManager,
+		instanceRepo:       repo,
+		tenantRepo:         tenantRepo,
+		projectRepo:        projectRepo,
+		templateRepo:       templateRepo,
+		revisionRepo:       revisionRepo,
+		configRevisionRepo: configRevisionRepo,
+		variableRepo:       variableRepo,
+		secrets:            secrets,
+		podManager:         podManager,
+		configMapManager:   configMapManager,
 	}
 }
 
 func (s *instanceService) CreateInstance(ctx context.Context, req *CreateInstanceRequest) (*domain.ClawInstance, error) {
+	if !repository.InTenantScope(ctx, req.TenantID) {
+		return nil, ErrTenantAccessDenied
+	}
+	if !repository.InProjectScope(ctx, req.ProjectID) {
+		return nil, ErrProjectAccessDenied
+	}
+
 	instanceID := uuid.New().String()
 
 	now := time.Now()
@@ -84,10 +176,6 @@ func (s *instanceService) CreateInstance(ctx context.Context, req *CreateInstanc
 		UpdatedAt: now,
 	}
 
-	if req.Config != nil {
-		// Marshal config to JSON (simplified)
-		instance.Config = []byte("{}")
-	}
 	if req.Resources != nil {
 		instance.CPU = req.Resources.CPU
 		instance.Memory = req.Resources.Memory
@@ -97,72 +185,58 @@ func (s *instanceService) CreateInstance(ctx context.Context, req *CreateInstanc
 		instance.DataDir = req.Storage.DataDir
 		instance.StorageSize = req.Storage.Size
 	}
-
-	if err := s.instanceRepo.Create(ctx, instance); err != nil {
-		return nil, fmt.Errorf("failed to create instance: %w", err)
+	if err := s.applyProjectDefaults(ctx, instance); err != nil {
+		return nil, err
+	}
+	if err := setLabels(instance, req.Labels); err != nil {
+		return nil, err
 	}
 
-	// Create ConfigMap for the instance configuration
-	var configMapName string
-	if s.configMapManager != nil {
-		configMapName = k8s.GenerateConfigMapName(instance.ID)
-		labels := map[string]string{
-			"app":        "claw",
-			"instanceId": instance.ID,
-			"tenantId":   instance.TenantID,
-			"projectId":  instance.ProjectID,
-		}
-
-		configData := k8s.ConfigMapData{
-			Environment: map[string]string{
-				"CLAW_INSTANCE_ID":   instance.ID,
-				"CLAW_INSTANCE_TYPE": instance.Type,
-				"CLAW_VERSION":       instance.Version,
-			},
-		}
+	// Resolve the template and render the config before anything is persisted
+	config := req.Config
+	if config == nil {
+		config = &domain.InstanceConfig{}
+	}
+	rendered, err := s.generateInstanceConfig(ctx, instance, config)
+	if err != nil {
+		return nil, err
+	}
+	applied := appliedFromRendered(rendered, config)
+	revision, err := s.newConfigRevision(instance, applied, req.Author, "Initial config", 0)
+	if err != nil {
+		return nil, err
+	}
 
-		// Generate config content using adapter
-		if req.Config != nil {
-			configYAML, err := s.generateInstanceConfig(instance.Type, req.Config)
-			if err != nil {
-				log.Printf("Warning: Failed to generate config: %v", err)
-			} else if configYAML != "" {
-				configData.ConfigYAML = configYAML
-			}
+	demand, err := instanceDemand(instance)
+	if err != nil {
+		return nil, err
+	}
+	s.quotaMu.Lock()
+	err = s.admit(ctx, instance.TenantID, instance.ProjectID, "", demand)
+	if err == nil {
+		if err = s.instanceRepo.Create(ctx, instance); err != nil {
+			err = fmt.Errorf("failed to create instance: %w", err)
 		}
-
-		if _, err := s.configMapManager.CreateOrUpdateConfigMap(ctx, configMapName, labels, configData); err != nil {
-			log.Printf("Warning: Failed to create ConfigMap: %v", err)
-			configMapName = "" // Don't mount if creation failed
+	}
+	s.quotaMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.configRevisionRepo.Create(ctx, revision); err != nil {
+		// Without its first revision the instance has no current config, so it must not stay
+		if delErr := s.instanceRepo.Delete(ctx, instance.ID); delErr != nil {
+			log.Printf("Warning: Failed to remove instance %s after its config revision could not be saved: %v", instance.ID, delErr)
 		}
+		return nil, err
 	}
 
+	// Create ConfigMap for the instance configuration
+	configMapName := s.writeConfigMap(ctx, instance, applied.files)
+
 	// Create K8S Pod for the instance
 	if s.podManager != nil {
 		podName := k8s.GeneratePodName(instance.ID)
-		labels := map[string]string{
-			"app":        "claw",
-			"instanceId": instance.ID,
-			"tenantId":   instance.TenantID,
-			"projectId":  instance.ProjectID,
-			"type":       instance.Type,
-		}
-
-		// Get image based on instance type and version
-		image := s.getImageForInstance(instance.Type, instance.Version)
-
-		spec := k8s.PodSpec{
-			Name:            podName,
-			Namespace:       s.podManager.GetNamespace(),
-			Labels:          labels,
-			Image:           image,
-			CPURequest:      instance.CPU,
-			CPULimit:        instance.CPU,
-			MemoryRequest:   instance.Memory,
-			MemoryLimit:     instance.Memory,
-			ConfigMapName:   configMapName,
-			ConfigMountPath: "/etc/claw/config",
-		}
+		spec := s.buildPodSpec(instance, configMapName, applied.env)
 
 		if _, err := s.podManager.CreatePod(ctx, spec); err != nil {
 			// Update instance status to failed
@@ -177,6 +251,76 @@ func (s *instanceService) CreateInstance(ctx context.Context, req *CreateInstanc
 	return s.modelToDomain(instance), nil
 }
 
+// applyProjectDefaults fills in the resources an instance was created without from its project
+func (s *instanceService) applyProjectDefaults(ctx context.Context, instance *model.ClawInstance) error {
+	project, err := s.projectRepo.GetByID(ctx, instance.ProjectID)
+	if err != nil {
+		return ErrProjectNotFound
+	}
+	if instance.CPU == "" {
+		instance.CPU = project.DefaultCPU
+	}
+	if instance.Memory == "" {
+		instance.Memory = project.DefaultMemory
+	}
+	if instance.StorageSize == "" {
+		instance.StorageSize = project.DefaultStorage
+	}
+	return nil
+}
+
+// buildPodSpec builds the Pod specification for an instance
+func (s *instanceService) buildPodSpec(instance *model.ClawInstance, configMapName string, envVars map[string]string) k8s.PodSpec {
+	return k8s.PodSpec{
+		Name:      k8s.GeneratePodName(instance.ID),
+		Namespace: s.namespace(),
+		Labels: map[string]string{
+			"app":        "claw",
+			"instanceId": instance.ID,
+			"tenantId":   instance.TenantID,
+			"projectId":  instance.ProjectID,
+			"type":       instance.Type,
+		},
+		// Record the config generation so drift checks can tell which env the pod runs with
+		Annotations: map[string]string{
+			k8s.AnnotationConfigRevision: strconv.Itoa(instance.ConfigRevision),
+			k8s.AnnotationEnvHash:        k8s.HashData(envVars),
+		},
+		// Get image based on instance type and version
+		Image:           s.getImageForInstance(instance.Type, instance.Version),
+		CPURequest:      instance.CPU,
+		CPULimit:        instance.CPU,
+		MemoryRequest:   instance.Memory,
+		MemoryLimit:     instance.Memory,
+		Env:             envVars,
+		ConfigMapName:   configMapName,
+		ConfigMountPath: adapter.DefaultConfigMountPath,
+	}
+}
+
+// namespace returns the Kubernetes namespace instances run in
+func (s *instanceService) namespace() string {
+	if s.podManager != nil {
+		return s.podManager.GetNamespace()
+	}
+	return "default"
+}
+
+// runtimeInfo builds the runtime information passed to adapters for an instance
+func (s *instanceService) runtimeInfo(instance *model.ClawInstance) adapter.RuntimeInfo {
+	return adapter.RuntimeInfo{
+		InstanceID:      instance.ID,
+		InstanceName:    instance.Name,
+		TenantID:        instance.TenantID,
+		ProjectID:       instance.ProjectID,
+		Version:         instance.Version,
+		Namespace:       s.namespace(),
+		ConfigMountPath: adapter.DefaultConfigMountPath,
+		ConfigDir:       instance.ConfigDir,
+		DataDir:         instance.DataDir,
+	}
+}
+
 // getImageForInstance returns the appropriate Docker image for an instance type
 func (s *instanceService) getImageForInstance(instanceType, version string) string {
 	// Try to get image from adapter
@@ -202,17 +346,18 @@ func (s *instanceService) getImageForInstance(instanceType, version string) stri
 	return fmt.Sprintf("%s:latest", baseImage)
 }
 
-// syncPodStatus monitors pod status and updates instance status accordingly
+// syncPodStatus monitors pod status and updates instance status accordingly. Instances stopped
+// while their pod was starting, e.g. by a tenant suspension, stay stopped.
 func (s *instanceService) syncPodStatus(ctx context.Context, instanceID, podName string) {
 	// Wait for pod to be ready
 	timeout := 5 * time.Minute
 	if err := s.podManager.WaitForPodReady(ctx, podName, timeout); err != nil {
-		_ = s.instanceRepo.UpdateStatus(ctx, instanceID, model.StatusFailed)
+		_, _ = s.instanceRepo.UpdateStatusFrom(ctx, instanceID, model.StatusCreating, model.StatusFailed)
 		return
 	}
 
 	// Update instance status to running
-	_ = s.instanceRepo.UpdateStatus(ctx, instanceID, model.StatusRunning)
+	_, _ = s.instanceRepo.UpdateStatusFrom(ctx, instanceID, model.StatusCreating, model.StatusRunning)
 }
 
 func (s *instanceService) GetInstance(ctx context.Context, id string) (*domain.ClawInstance, error) {
@@ -223,7 +368,47 @@ func (s *instanceService) GetInstance(ctx context.Context, id string) (*domain.C
 	return s.modelToDomain(instance), nil
 }
 
+// GetInstanceHealth checks the instance status and, for running instances, the readiness of its pod
+func (s *instanceService) GetInstanceHealth(ctx context.Context, id string) (*InstanceHealth, error) {
+	instance, err := s.instanceRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrInstanceNotFound
+	}
+
+	health := &InstanceHealth{InstanceID: instance.ID, Status: domain.InstanceStatus(instance.Status)}
+	switch instance.Status {
+	case model.StatusRunning:
+		if s.podManager == nil {
+			health.Healthy = true
+			health.Ready = true
+			return health, nil
+		}
+		status, err := s.podManager.GetPodStatus(ctx, k8s.GeneratePodName(instance.ID))
+		if err != nil {
+			health.Message = fmt.Sprintf("failed to get pod status: %v", err)
+			return health, nil
+		}
+		health.Ready = status.Ready
+		health.RestartCount = status.RestartCount
+		health.Healthy = status.Ready
+		if !status.Ready {
+			health.Message = fmt.Sprintf("pod is %s and not ready", status.Phase)
+		}
+	case model.StatusFailed:
+		health.Message = "instance failed"
+	default:
+		health.Message = fmt.Sprintf("instance is %s", instance.Status)
+	}
+	return health, nil
+}
+
 func (s *instanceService) ListInstances(ctx context.Context, tenantID, projectID string, page, pageSize int) ([]*domain.ClawInstance, int, error) {
+	if tenantID != "" && !repository.InTenantScope(ctx, tenantID) {
+		return nil, 0, ErrTenantAccessDenied
+	}
+	if projectID != "" && !repository.InProjectScope(ctx, projectID) {
+		return nil, 0, ErrProjectAccessDenied
+	}
 	offset := (page - 1) * pageSize
 	instances, err := s.instanceRepo.List(ctx, tenantID, projectID, pageSize, offset)
 	if err != nil {
@@ -249,41 +434,77 @@ func (s *instanceService) UpdateInstance(ctx context.Context, id string, req *Up
 	if req.Name != nil {
 		instance.Name = *req.Name
 	}
-	if req.Config != nil {
-		instance.Config = []byte("{}")
-
-		// Update ConfigMap if config changed
-		if s.configMapManager != nil {
-			configMapName := k8s.GenerateConfigMapName(instance.ID)
-			labels := map[string]string{
-				"app":        "claw",
-				"instanceId": instance.ID,
-				"tenantId":   instance.TenantID,
-				"projectId":  instance.ProjectID,
-			}
+	var requested *resourceAmounts
+	if req.Resources != nil {
+		previous, err := instanceDemand(instance)
+		if err != nil {
+			// Stored quantities that no longer parse are being replaced, so charge them nothing
+			previous = &resourceAmounts{}
+		}
+		instance.CPU = req.Resources.CPU
+		instance.Memory = req.Resources.Memory
+		next, err := instanceDemand(instance)
+		if err != nil {
+			return nil, err
+		}
 
-			configData := k8s.ConfigMapData{
-				Environment: map[string]string{
-					"CLAW_INSTANCE_ID":   instance.ID,
-					"CLAW_INSTANCE_TYPE": instance.Type,
-					"CLAW_VERSION":       instance.Version,
-				},
-				// TODO: Use adapter to generate proper config format
-				ConfigYAML: "# Updated config\n",
-			}
+		// Only the dimensions that grow are admitted
+		grown := &resourceAmounts{}
+		if next.cpu.Cmp(previous.cpu) > 0 {
+			grown.cpu = next.cpu
+		}
+		if next.memory.Cmp(previous.memory) > 0 {
+			grown.memory = next.memory
+		}
+		if !grown.cpu.IsZero() || !grown.memory.IsZero() {
+			requested = grown
+		}
+	}
+	if req.Labels != nil {
+		if err := setLabels(instance, req.Labels); err != nil {
+			return nil, err
+		}
+	}
 
-			if _, err := s.configMapManager.CreateOrUpdateConfigMap(ctx, configMapName, labels, configData); err != nil {
-				log.Printf("Warning: Failed to update ConfigMap: %v", err)
+	// Render a config change before taking the quota lock, so a slow render holds up no one
+	var rendered *renderedConfig
+	if req.Config != nil {
+		if rendered, err = s.generateInstanceConfig(ctx, instance, req.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	// A grown instance is admitted and saved under the quota lock, which is released before the
+	// config is applied
+	if requested != nil {
+		s.quotaMu.Lock()
+		err = s.admit(ctx, instance.TenantID, instance.ProjectID, instance.ID, requested)
+		if err == nil {
+			if err = s.instanceRepo.Update(ctx, instance); err != nil {
+				err = fmt.Errorf("failed to update instance: %w", err)
 			}
 		}
+		s.quotaMu.Unlock()
+		if err != nil {
+			return nil, err
+		}
 	}
-	if req.Resources != nil {
-		instance.CPU = req.Resources.CPU
-		instance.Memory = req.Resources.Memory
+
+	// A config change is recorded as a new revision, which also persists the other fields
+	if rendered != nil {
+		plan, err := s.applyConfig(ctx, instance, appliedFromRendered(rendered, req.Config), req.Author, req.Changelog, 0)
+		if err != nil {
+			return nil, err
+		}
+		result := s.modelToDomain(instance)
+		result.ConfigApply = plan
+		return result, nil
 	}
 
-	if err := s.instanceRepo.Update(ctx, instance); err != nil {
-		return nil, fmt.Errorf("failed to update instance: %w", err)
+	if requested == nil {
+		if err := s.instanceRepo.Update(ctx, instance); err != nil {
+			return nil, fmt.Errorf("failed to update instance: %w", err)
+		}
 	}
 
 	return s.modelToDomain(instance), nil
@@ -299,60 +520,26 @@ func (s *instanceService) StartInstance(ctx context.Context, id string) error {
 		return ErrInvalidStatus
 	}
 
-	if err := s.instanceRepo.UpdateStatus(ctx, id, model.StatusCreating); err != nil {
+	// A stopped instance gives up its CPU and memory, so starting it must fit into the quota again
+	if err := s.reserveCompute(ctx, instance); err != nil {
 		return err
 	}
 
-	// Ensure ConfigMap exists
-	var configMapName string
-	if s.configMapManager != nil {
-		configMapName = k8s.GenerateConfigMapName(instance.ID)
-		labels := map[string]string{
-			"app":        "claw",
-			"instanceId": instance.ID,
-			"tenantId":   instance.TenantID,
-			"projectId":  instance.ProjectID,
-		}
-
-		configData := k8s.ConfigMapData{
-			Environment: map[string]string{
-				"CLAW_INSTANCE_ID":   instance.ID,
-				"CLAW_INSTANCE_TYPE": instance.Type,
-				"CLAW_VERSION":       instance.Version,
-			},
-		}
-
-		if _, err := s.configMapManager.CreateOrUpdateConfigMap(ctx, configMapName, labels, configData); err != nil {
-			log.Printf("Warning: Failed to create ConfigMap: %v", err)
-			configMapName = ""
-		}
+	// Ensure the ConfigMap holds the current config revision
+	applied, err := s.currentConfig(ctx, instance)
+	if err != nil {
+		_ = s.instanceRepo.UpdateStatus(ctx, id, model.StatusFailed)
+		return err
 	}
+	if applied == nil {
+		applied = &appliedConfig{}
+	}
+	configMapName := s.writeConfigMap(ctx, instance, applied.files)
 
 	// Create K8S Pod for the instance
 	if s.podManager != nil {
 		podName := k8s.GeneratePodName(instance.ID)
-		labels := map[string]string{
-			"app":        "claw",
-			"instanceId": instance.ID,
-			"tenantId":   instance.TenantID,
-			"projectId":  instance.ProjectID,
-			"type":       instance.Type,
-		}
-
-		image := s.getImageForInstance(instance.Type, instance.Version)
-
-		spec := k8s.PodSpec{
-			Name:            podName,
-			Namespace:       s.podManager.GetNamespace(),
-			Labels:          labels,
-			Image:           image,
-			CPURequest:      instance.CPU,
-			CPULimit:        instance.CPU,
-			MemoryRequest:   instance.Memory,
-			MemoryLimit:     instance.Memory,
-			ConfigMapName:   configMapName,
-			ConfigMountPath: "/etc/claw/config",
-		}
+		spec := s.buildPodSpec(instance, configMapName, applied.env)
 
 		if _, err := s.podManager.CreatePod(ctx, spec); err != nil {
 			_ = s.instanceRepo.UpdateStatus(ctx, id, model.StatusFailed)
@@ -366,20 +553,41 @@ func (s *instanceService) StartInstance(ctx context.Context, id string) error {
 	return nil
 }
 
+// reserveCompute admits the CPU and memory of an instance that does not hold them yet and
+// marks it Creating, which charges them to its tenant
+func (s *instanceService) reserveCompute(ctx context.Context, instance *model.ClawInstance) error {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+
+	if !holdsCompute(instance.Status) {
+		starting := *instance
+		starting.Status = model.StatusCreating
+		demand, err := instanceDemand(&starting)
+		if err != nil {
+			return err
+		}
+		requested := &resourceAmounts{cpu: demand.cpu, memory: demand.memory}
+		if err := s.admit(ctx, instance.TenantID, instance.ProjectID, instance.ID, requested); err != nil {
+			return err
+		}
+	}
+	return s.instanceRepo.UpdateStatus(ctx, instance.ID, model.StatusCreating)
+}
+
 func (s *instanceService) StopInstance(ctx context.Context, id string) error {
 	instance, err := s.instanceRepo.GetByID(ctx, id)
 	if err != nil {
 		return ErrInstanceNotFound
 	}
 
-	if instance.Status != model.StatusRunning {
+	if instance.Status != model.StatusRunning && instance.Status != model.StatusCreating {
 		return ErrInvalidStatus
 	}
 
-	// Delete K8S Pod
+	// Delete K8S Pod; a creating instance may not have one yet
 	if s.podManager != nil {
 		podName := k8s.GeneratePodName(instance.ID)
-		if err := s.podManager.DeletePod(ctx, podName); err != nil {
+		if err := s.podManager.DeletePod(ctx, podName); err != nil && instance.Status == model.StatusRunning {
 			return fmt.Errorf("failed to delete K8S pod: %w", err)
 		}
 	}
@@ -425,11 +633,43 @@ func (s *instanceService) DeleteInstance(ctx context.Context, id string) error {
 	if err := s.instanceRepo.Delete(ctx, id); err != nil {
 		return fmt.Errorf("failed to delete instance: %w", err)
 	}
+	if err := s.configRevisionRepo.DeleteByInstance(ctx, id); err != nil {
+		log.Printf("Warning: Failed to delete config revisions of instance %s: %v", id, err)
+	}
 
 	return nil
 }
 
+// TeardownInstance deletes the Pod and ConfigMap of an instance whatever its status and marks
+// it stopped. Starting it again recreates both from the applied config revision.
+func (s *instanceService) TeardownInstance(ctx context.Context, id string) error {
+	instance, err := s.instanceRepo.GetByID(ctx, id)
+	if err != nil {
+		return ErrInstanceNotFound
+	}
+
+	if s.podManager != nil {
+		podName := k8s.GeneratePodName(instance.ID)
+		if err := s.podManager.DeletePod(ctx, podName); err != nil && instance.Status == model.StatusRunning {
+			return fmt.Errorf("failed to delete K8S pod: %w", err)
+		}
+	}
+	if s.configMapManager != nil {
+		// Ignore error if configmap doesn't exist
+		_ = s.configMapManager.DeleteConfigMap(ctx, k8s.GenerateConfigMapName(instance.ID))
+	}
+
+	return s.instanceRepo.UpdateStatus(ctx, id, model.StatusStopped)
+}
+
 func (s *instanceService) modelToDomain(m *model.ClawInstance) *domain.ClawInstance {
+	config := &domain.InstanceConfig{}
+	if record, err := s.openInstanceConfig(m.Config); err != nil {
+		log.Printf("Warning: %v (instance %s)", err, m.ID)
+	} else {
+		config = record.toDomain(configSchema(m.Type))
+	}
+
 	return &domain.ClawInstance{
 		ID:        m.ID,
 		Name:      m.Name,
@@ -438,7 +678,9 @@ func (s *instanceService) modelToDomain(m *model.ClawInstance) *domain.ClawInsta
 		Type:      m.Type,
 		Version:   m.Version,
 		Status:    domain.InstanceStatus(m.Status),
-		Config:    &domain.InstanceConfig{},
+		Config:    config,
+		Labels:    decodeLabels(m.Labels),
+		ConfigRevision: m.ConfigRevision,
 		Resources: &domain.ResourceSpec{
 			CPU:    m.CPU,
 			Memory: m.Memory,
@@ -453,62 +695,217 @@ func (s *instanceService) modelToDomain(m *model.ClawInstance) *domain.ClawInsta
 	}
 }
 
-// generateInstanceConfig generates configuration for an instance using the appropriate adapter
-func (s *instanceService) generateInstanceConfig(instanceType string, config *domain.InstanceConfig) (string, error) {
+// setLabels stores labels on the instance model
+func setLabels(instance *model.ClawInstance, labels map[string]string) error {
+	if len(labels) == 0 {
+		instance.Labels = nil
+		return nil
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return fmt.Errorf("failed to marshal labels: %w", err)
+	}
+	instance.Labels = data
+	return nil
+}
+
+// decodeLabels restores the labels of an instance model; invalid data yields no labels
+func decodeLabels(data []byte) map[string]string {
+	if len(data) == 0 {
+		return nil
+	}
+	var labels map[string]string
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil
+	}
+	return labels
+}
+
+// renderedConfig holds the output of rendering an instance configuration through its adapter
+type renderedConfig struct {
+	unified adapter.UnifiedConfig
+	files   []adapter.ConfigFile
+	env     map[string]string
+	mounts  []adapter.VolumeMount
+	errors  []adapter.FieldError
+	binding *templateBinding
+}
+
+// record returns the resolved configuration to persist on the instance
+func (r *renderedConfig) record(config *domain.InstanceConfig) *instanceConfigRecord {
+	record := &instanceConfigRecord{
+		Overrides:   config.Overrides,
+		Values:      r.binding.values,
+		SecretPaths: r.binding.secrets,
+		Variables:   r.binding.variableNames(),
+		Resolved:    r.unified,
+	}
+	if r.binding.template != nil {
+		record.TemplateID = r.binding.template.ID
+		record.TemplateName = r.binding.template.Name
+		record.TemplateRevision = r.binding.revision
+		record.TemplateVersion = r.binding.template.Version
+	}
+	return record
+}
+
+// target returns the content of the primary (first) rendered file
+func (r *renderedConfig) target() string {
+	if len(r.files) == 0 {
+		return ""
+	}
+	return r.files[0].Content
+}
+
+// renderInstanceConfig resolves the template, overrides and shared variables into a unified config
+// and renders it with the adapter. Validation failures are reported in the result rather than as an error.
+func (s *instanceService) renderInstanceConfig(ctx context.Context, instance *model.ClawInstance, config *domain.InstanceConfig, vars variableSet) (*renderedConfig, error) {
+	instanceType := instance.Type
+
 	// Get the adapter for this instance type
 	adp, err := adapter.CreateByString(instanceType)
 	if err != nil {
-		// If adapter not found, return empty config
-		log.Printf("Warning: No adapter found for type %s, using empty config", instanceType)
-		return "", nil
+		return nil, ErrAdapterNotFound
 	}
 
-	// Build unified config from request
-	unifiedConfig := adp.GetDefaultConfig()
+	// Resolve the template variables and request overrides into config paths
+	var templateName string
+	var templateRevision int
+	var overrides map[string]string
 	if config != nil {
-		// Apply overrides from config
-		for key, value := range config.Overrides {
-			// Simple key-value mapping, can be enhanced for nested paths
-			switch key {
-			case "model.name":
-				unifiedConfig.Model.Name = value
-			case "model.api_key":
-				unifiedConfig.Model.APIKey = value
-			case "model.base_url":
-				unifiedConfig.Model.BaseURL = value
-			case "memory.limit":
-				// Parse string to int
-				var limit int
-				fmt.Sscanf(value, "%d", &limit)
-				unifiedConfig.Memory.Limit = limit
-			case "memory.storage_type":
-				unifiedConfig.Memory.StorageType = value
-			case "logging.level":
-				unifiedConfig.Logging.Level = value
-			}
+		templateName = config.TemplateName
+		templateRevision = config.TemplateRevision
+		overrides = config.Overrides
+	}
+	binding, err := s.bindTemplate(ctx, templateName, templateRevision, instanceType, overrides, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &renderedConfig{binding: binding}
+	result.errors = append(result.errors, binding.errors...)
+	unifiedConfig, err := adapter.ResolveConfig(adp.GetDefaultConfig(), binding.values)
+	if err != nil {
+		var overrideErrs adapter.ValidationErrors
+		if !errors.As(err, &overrideErrs) {
+			return nil, fmt.Errorf("failed to apply overrides: %w", err)
 		}
+		result.errors = append(result.errors, overrideErrs...)
 	}
 
-	// Parse and validate config
-	if err := adp.ParseConfig(unifiedConfig); err != nil {
-		return "", fmt.Errorf("failed to parse config: %w", err)
+	// The adapter validates the resolved config and renders it
+	artifacts, err := adp.Render(ctx, unifiedConfig, s.runtimeInfo(instance))
+	if err != nil {
+		var validationErrs adapter.ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			return nil, fmt.Errorf("failed to render config: %w", err)
+		}
+		result.errors = append(result.errors, validationErrs...)
+		result.unified = unifiedConfig
+		return result, nil
 	}
 
-	if err := adp.Validate(); err != nil {
-		return "", fmt.Errorf("config validation failed: %w", err)
+	seen := make(map[string]bool, len(artifacts.Files))
+	for _, f := range artifacts.Files {
+		if seen[f.Name] {
+			return nil, fmt.Errorf("adapter %s rendered duplicate file %q", instanceType, f.Name)
+		}
+		seen[f.Name] = true
 	}
 
-	// Generate the config
-	configStr, err := adp.GenerateConfig()
+	result.unified = artifacts.Config
+	result.files = artifacts.Files
+	result.env = artifacts.EnvVars
+	result.mounts = artifacts.VolumeMounts
+
+	return result, nil
+}
+
+// generateInstanceConfig generates configuration for an instance using the appropriate adapter.
+// It returns nil without error when the instance type has no adapter.
+func (s *instanceService) generateInstanceConfig(ctx context.Context, instance *model.ClawInstance, config *domain.InstanceConfig) (*renderedConfig, error) {
+	vars, err := s.loadVariables(ctx, instance, false)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate config: %w", err)
+		return nil, err
+	}
+	rendered, err := s.renderInstanceConfig(ctx, instance, config, vars)
+	if err != nil {
+		if errors.Is(err, ErrAdapterNotFound) {
+			// If adapter not found, return empty config
+			log.Printf("Warning: No adapter found for type %s, using empty config", instance.Type)
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if len(rendered.errors) > 0 {
+		return nil, fmt.Errorf("config validation failed: %w", adapter.ValidationErrors(rendered.errors))
+	}
+
+	return rendered, nil
+}
+
+// RenderConfig renders an instance configuration without creating any resources
+func (s *instanceService) RenderConfig(ctx context.Context, req *RenderConfigRequest) (*RenderConfigResult, error) {
+	// The preview resolves the variables of the tenant and project, so both must be in scope
+	if req.TenantID != "" && !repository.InTenantScope(ctx, req.TenantID) {
+		return nil, ErrTenantAccessDenied
+	}
+	if req.ProjectID != "" && !repository.InProjectScope(ctx, req.ProjectID) {
+		return nil, ErrProjectAccessDenied
+	}
+
+	instance := &model.ClawInstance{
+		ID:        previewInstanceID,
+		Name:      previewInstanceID,
+		TenantID:  req.TenantID,
+		ProjectID: req.ProjectID,
+		Type:      req.AdapterType,
+		Version:   req.Version,
+	}
+	if req.Resources != nil {
+		instance.CPU = req.Resources.CPU
+		instance.Memory = req.Resources.Memory
 	}
 
-	return configStr, nil
+	// Secret variables are masked so a preview never exposes them
+	vars, err := s.loadVariables(ctx, instance, true)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := s.renderInstanceConfig(ctx, instance, &domain.InstanceConfig{
+		TemplateName:     req.TemplateName,
+		TemplateRevision: req.TemplateRevision,
+		Overrides:        req.Overrides,
+	}, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := k8s.BuildPod(s.buildPodSpec(instance, k8s.GenerateConfigMapName(instance.ID), rendered.env))
+	if err != nil {
+		rendered.errors = append(rendered.errors, adapter.FieldError{Field: "resources", Message: err.Error()})
+	}
+
+	return &RenderConfigResult{
+		AdapterType:   req.AdapterType,
+		Version:       req.Version,
+		TargetConfig:  rendered.target(),
+		Files:         rendered.files,
+		EnvVars:       rendered.env,
+		VolumeMounts:  rendered.mounts,
+		UnifiedConfig: rendered.unified,
+		Valid:         len(rendered.errors) == 0,
+		Errors:        rendered.errors,
+		Pod:           pod,
+	}, nil
 }
 
 // GetInstanceLogs retrieves logs for an instance
 func (s *instanceService) GetInstanceLogs(ctx context.Context, id string, tailLines int64) (string, error) {
+	if _, err := s.instanceRepo.GetByID(ctx, id); err != nil {
+		return "", ErrInstanceNotFound
+	}
 	if s.podManager == nil {
 		return "", fmt.Errorf("K8S integration not enabled")
 	}
@@ -0,0 +1,617 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weibh/openClusterClaw/internal/adapter"
+	"github.com/weibh/openClusterClaw/internal/domain"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/runtime/k8s"
+)
+
+// ErrConfigRevisionNotFound is returned when an instance config revision does not exist
+var ErrConfigRevisionNotFound = errors.New("instance config revision not found")
+
+// InstanceConfigRevisionInfo is the API view of an applied instance config; secrets are redacted
+type InstanceConfigRevisionInfo struct {
+	InstanceID     string                 `json:"instance_id"`
+	Revision       int                    `json:"revision"`
+	Current        bool                   `json:"current"`
+	ContentHash    string                 `json:"content_hash"`
+	Author         string                 `json:"author"`
+	Changelog      string                 `json:"changelog"`
+	RolledBackFrom int                    `json:"rolled_back_from,omitempty"`
+	Config         *domain.InstanceConfig `json:"config"`
+	Files          []adapter.ConfigFile   `json:"files,omitempty"`
+	EnvVars        map[string]string      `json:"env_vars,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+}
+
+// InstanceConfigDiff lists the changes between two config revisions of an instance
+type InstanceConfigDiff struct {
+	InstanceID string         `json:"instance_id"`
+	From       int            `json:"from"`
+	To         int            `json:"to"`
+	FromHash   string         `json:"from_hash"`
+	ToHash     string         `json:"to_hash"`
+	Changes    []ConfigChange `json:"changes"`
+}
+
+// ConfigRollbackRequest represents the request to re-apply a previous instance config revision
+type ConfigRollbackRequest struct {
+	Revision  int    `json:"revision" binding:"required"`
+	Changelog string `json:"changelog"`
+	Author    string `json:"-"`
+}
+
+// ConfigPatch is a partial instance config merged into the current one
+type ConfigPatch struct {
+	// TemplateName switches the template when set, otherwise the current one is kept
+	TemplateName string `json:"template_name"`
+	// TemplateRevision pins a template revision; 0 keeps the current revision, or uses the
+	// latest revision when TemplateName switches the template
+	TemplateRevision int `json:"template_revision"`
+	// Overrides are merged into the current overrides
+	Overrides map[string]string `json:"overrides"`
+}
+
+// PatchConfigRequest represents the request to apply a ConfigPatch as a new config revision
+type PatchConfigRequest struct {
+	ConfigPatch
+	Changelog string `json:"changelog"`
+	Author    string `json:"-"`
+}
+
+// appliedConfig is the decoded content of an instance config revision
+type appliedConfig struct {
+	record *instanceConfigRecord
+	files  []adapter.ConfigFile
+	env    map[string]string
+}
+
+// newConfigRevision records config as the next revision of the instance and updates the instance fields.
+// The caller persists both the instance and the returned revision.
+func (s *instanceService) newConfigRevision(instance *model.ClawInstance, config *appliedConfig, author, changelog string, rolledBackFrom int) (*model.InstanceConfigRevision, error) {
+	recordJSON, err := json.Marshal(config.record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal instance config: %w", err)
+	}
+	filesJSON, err := json.Marshal(config.files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config files: %w", err)
+	}
+	envJSON, err := json.Marshal(config.env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal env vars: %w", err)
+	}
+
+	// The hash identifies the content, so it is taken before secrets are sealed with a random nonce
+	h := sha256.New()
+	h.Write(recordJSON)
+	h.Write(filesJSON)
+	h.Write(envJSON)
+
+	// Output rendered from secret values holds them in plain text, so it is sealed as a whole
+	if len(config.record.SecretPaths) > 0 {
+		if recordJSON, err = s.sealRecord(config.record); err != nil {
+			return nil, err
+		}
+		if filesJSON, err = s.sealJSON(config.files); err != nil {
+			return nil, err
+		}
+		if envJSON, err = s.sealJSON(config.env); err != nil {
+			return nil, err
+		}
+	}
+
+	instance.Config = recordJSON
+	instance.TemplateID = config.record.TemplateID
+	instance.TemplateRevision = config.record.TemplateRevision
+	instance.ConfigRevision++
+
+	return &model.InstanceConfigRevision{
+		InstanceID:     instance.ID,
+		Revision:       instance.ConfigRevision,
+		ContentHash:    hex.EncodeToString(h.Sum(nil)),
+		Config:         recordJSON,
+		Files:          filesJSON,
+		EnvVars:        envJSON,
+		Author:         author,
+		Changelog:      changelog,
+		RolledBackFrom: rolledBackFrom,
+	}, nil
+}
+
+// decodeConfigRevision restores the content of a stored revision, opening sealed secrets
+func (s *instanceService) decodeConfigRevision(rev *model.InstanceConfigRevision) (*appliedConfig, error) {
+	record, err := s.openInstanceConfig(rev.Config)
+	if err != nil {
+		return nil, err
+	}
+	config := &appliedConfig{record: record}
+	if len(rev.Files) > 0 {
+		if err := s.openJSON(rev.Files, &config.files); err != nil {
+			return nil, fmt.Errorf("failed to parse config files: %w", err)
+		}
+	}
+	if len(rev.EnvVars) > 0 {
+		if err := s.openJSON(rev.EnvVars, &config.env); err != nil {
+			return nil, fmt.Errorf("failed to parse env vars: %w", err)
+		}
+	}
+	return config, nil
+}
+
+// sealedRecord is the part of an instance config record that is stored encrypted
+type sealedRecord struct {
+	Values   map[string]string     `json:"values,omitempty"`
+	Resolved adapter.UnifiedConfig `json:"resolved"`
+}
+
+// sealRecord returns the stored form of a record with secret paths: the values of those paths
+// and the resolved config, which contains them, are moved into Sealed. Template, overrides and
+// variable names stay readable; overrides hold ${var.NAME} references, not the secret values.
+func (s *instanceService) sealRecord(record *instanceConfigRecord) ([]byte, error) {
+	stored := *record
+	stored.Values = make(map[string]string, len(record.Values))
+	stored.Resolved = adapter.UnifiedConfig{}
+	secret := sealedRecord{Values: make(map[string]string, len(record.SecretPaths)), Resolved: record.Resolved}
+	for path, value := range record.Values {
+		if containsString(record.SecretPaths, path) {
+			secret.Values[path] = value
+		} else {
+			stored.Values[path] = value
+		}
+	}
+
+	secretJSON, err := json.Marshal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal instance config secrets: %w", err)
+	}
+	if stored.Sealed, err = s.seal(string(secretJSON)); err != nil {
+		return nil, err
+	}
+	return json.Marshal(&stored)
+}
+
+// openInstanceConfig decodes a stored record and restores its sealed secret values and resolved config
+func (s *instanceService) openInstanceConfig(data []byte) (*instanceConfigRecord, error) {
+	record, err := decodeInstanceConfig(data)
+	if err != nil || record.Sealed == "" {
+		return record, err
+	}
+
+	secretJSON, err := s.open(record.Sealed)
+	if err != nil {
+		return nil, err
+	}
+	var secret sealedRecord
+	if err := json.Unmarshal([]byte(secretJSON), &secret); err != nil {
+		return nil, fmt.Errorf("failed to parse instance config secrets: %w", err)
+	}
+	if record.Values == nil && len(secret.Values) > 0 {
+		record.Values = make(map[string]string, len(secret.Values))
+	}
+	for path, value := range secret.Values {
+		record.Values[path] = value
+	}
+	record.Resolved = secret.Resolved
+	record.Sealed = ""
+	return record, nil
+}
+
+// sealJSON encodes v as JSON and stores the encrypted result as a JSON string
+func (s *instanceService) sealJSON(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.seal(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(sealed)
+}
+
+// openJSON decodes data into v; a JSON string is a value sealed by sealJSON and opened first
+func (s *instanceService) openJSON(data []byte, v interface{}) error {
+	if len(data) > 0 && data[0] == '"' {
+		var sealed string
+		if err := json.Unmarshal(data, &sealed); err != nil {
+			return err
+		}
+		opened, err := s.open(sealed)
+		if err != nil {
+			return err
+		}
+		data = []byte(opened)
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *instanceService) seal(plaintext string) (string, error) {
+	if s.secrets == nil {
+		return "", fmt.Errorf("configs with secret values require an encryption key")
+	}
+	return s.secrets.Seal(plaintext)
+}
+
+func (s *instanceService) open(sealed string) (string, error) {
+	if s.secrets == nil {
+		return "", fmt.Errorf("cannot decrypt instance config: no encryption key configured")
+	}
+	opened, err := s.secrets.Open(sealed)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt instance config: %w", err)
+	}
+	return opened, nil
+}
+
+// appliedFromRendered converts a render result into the config applied to an instance
+func appliedFromRendered(rendered *renderedConfig, config *domain.InstanceConfig) *appliedConfig {
+	if rendered == nil {
+		// No adapter for this type, keep the request as-is
+		return &appliedConfig{record: &instanceConfigRecord{TemplateName: config.TemplateName, Overrides: config.Overrides}}
+	}
+	return &appliedConfig{record: rendered.record(config), files: rendered.files, env: rendered.env}
+}
+
+// currentConfig returns the config currently applied to an instance, or nil if none was recorded
+func (s *instanceService) currentConfig(ctx context.Context, instance *model.ClawInstance) (*appliedConfig, error) {
+	if instance.ConfigRevision == 0 {
+		return nil, nil
+	}
+	rev, err := s.configRevisionRepo.Get(ctx, instance.ID, instance.ConfigRevision)
+	if err != nil {
+		return nil, ErrConfigRevisionNotFound
+	}
+	return s.decodeConfigRevision(rev)
+}
+
+// writeConfigMap stores the config files of an instance in its ConfigMap.
+// It returns the ConfigMap name, or "" when there is no ConfigMap to mount.
+func (s *instanceService) writeConfigMap(ctx context.Context, instance *model.ClawInstance, files []adapter.ConfigFile) string {
+	if s.configMapManager == nil {
+		return ""
+	}
+	configMapName, err := s.putConfigMap(ctx, instance, files)
+	if err != nil {
+		log.Printf("Warning: Failed to write ConfigMap: %v", err)
+		return "" // Don't mount if the write failed
+	}
+	return configMapName
+}
+
+// putConfigMap writes the desired ConfigMap of an instance, annotated with its config revision and data hash
+func (s *instanceService) putConfigMap(ctx context.Context, instance *model.ClawInstance, files []adapter.ConfigFile) (string, error) {
+	configData, err := desiredConfigMap(instance, files)
+	if err != nil {
+		return "", err
+	}
+
+	configMapName := k8s.GenerateConfigMapName(instance.ID)
+	labels := map[string]string{
+		"app":        "claw",
+		"instanceId": instance.ID,
+		"tenantId":   instance.TenantID,
+		"projectId":  instance.ProjectID,
+	}
+	if _, err := s.configMapManager.CreateOrUpdateConfigMap(ctx, configMapName, labels, configData); err != nil {
+		return "", err
+	}
+	return configMapName, nil
+}
+
+// desiredConfigMap returns the ConfigMap content the control plane expects for an instance
+func desiredConfigMap(instance *model.ClawInstance, files []adapter.ConfigFile) (k8s.ConfigMapData, error) {
+	configData := k8s.ConfigMapData{
+		Environment: map[string]string{
+			"CLAW_INSTANCE_ID":   instance.ID,
+			"CLAW_INSTANCE_TYPE": instance.Type,
+			"CLAW_VERSION":       instance.Version,
+		},
+	}
+	if len(files) > 0 {
+		configData.Files = make(map[string]string, len(files))
+		for _, f := range files {
+			configData.Files[f.Name] = f.Content
+		}
+	}
+
+	data, err := configData.Build()
+	if err != nil {
+		return configData, err
+	}
+	configData.Annotations = map[string]string{
+		k8s.AnnotationConfigRevision: strconv.Itoa(instance.ConfigRevision),
+		k8s.AnnotationConfigHash:     k8s.HashData(data),
+	}
+	return configData, nil
+}
+
+// applyConfig records config as a new revision of an existing instance and writes it to the ConfigMap.
+// A running instance is reloaded in place when its adapter allows it and restarted otherwise;
+// the returned plan says which, the delivery itself continues in the background.
+func (s *instanceService) applyConfig(ctx context.Context, instance *model.ClawInstance, config *appliedConfig, author, changelog string, rolledBackFrom int) (*domain.ConfigApply, error) {
+	previous, err := s.currentConfig(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.planConfigApply(ctx, instance, previous, config)
+	if err != nil {
+		return nil, err
+	}
+
+	rev, err := s.newConfigRevision(instance, config, author, changelog, rolledBackFrom)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.configRevisionRepo.Create(ctx, rev); err != nil {
+		return nil, err
+	}
+	if err := s.instanceRepo.Update(ctx, instance); err != nil {
+		return nil, fmt.Errorf("failed to update instance: %w", err)
+	}
+
+	configMapName := s.writeConfigMap(ctx, instance, config.files)
+	go s.deliverConfig(context.Background(), instance.ID, instance.Type, instance.ConfigRevision, plan, config, configMapName)
+	return plan, nil
+}
+
+func (s *instanceService) ListConfigRevisions(ctx context.Context, id string, page, pageSize int) ([]*InstanceConfigRevisionInfo, int, error) {
+	instance, err := s.instanceRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, 0, ErrInstanceNotFound
+	}
+
+	revisions, total, err := s.configRevisionRepo.ListByInstance(ctx, id, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	infos := make([]*InstanceConfigRevisionInfo, 0, len(revisions))
+	for _, rev := range revisions {
+		info, err := s.revisionInfo(instance, rev)
+		if err != nil {
+			return nil, 0, err
+		}
+		// File contents are only returned for a single revision
+		info.Files = nil
+		info.EnvVars = nil
+		infos = append(infos, info)
+	}
+	return infos, total, nil
+}
+
+func (s *instanceService) GetConfigRevision(ctx context.Context, id string, revision int) (*InstanceConfigRevisionInfo, error) {
+	instance, err := s.instanceRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrInstanceNotFound
+	}
+
+	rev, err := s.configRevisionRepo.Get(ctx, id, revision)
+	if err != nil {
+		return nil, ErrConfigRevisionNotFound
+	}
+	return s.revisionInfo(instance, rev)
+}
+
+func (s *instanceService) DiffConfigRevisions(ctx context.Context, id string, from, to int) (*InstanceConfigDiff, error) {
+	fromInfo, err := s.GetConfigRevision(ctx, id, from)
+	if err != nil {
+		return nil, err
+	}
+	toInfo, err := s.GetConfigRevision(ctx, id, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := diffValues(revisionDiffContent(fromInfo), revisionDiffContent(toInfo))
+	if err != nil {
+		return nil, err
+	}
+
+	return &InstanceConfigDiff{
+		InstanceID: id,
+		From:       from,
+		To:         to,
+		FromHash:   fromInfo.ContentHash,
+		ToHash:     toInfo.ContentHash,
+		Changes:    changes,
+	}, nil
+}
+
+func (s *instanceService) RollbackConfig(ctx context.Context, id string, req *ConfigRollbackRequest) (*domain.ClawInstance, error) {
+	instance, err := s.instanceRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrInstanceNotFound
+	}
+
+	rev, err := s.configRevisionRepo.Get(ctx, id, req.Revision)
+	if err != nil {
+		return nil, ErrConfigRevisionNotFound
+	}
+	config, err := s.decodeConfigRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	// The stored output is re-applied as-is so template changes since then do not leak in
+	changelog := req.Changelog
+	if changelog == "" {
+		changelog = fmt.Sprintf("Rollback to revision %d", req.Revision)
+	}
+	plan, err := s.applyConfig(ctx, instance, config, req.Author, changelog, req.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.modelToDomain(instance)
+	result.ConfigApply = plan
+	return result, nil
+}
+
+// PatchConfig re-renders the instance config with the patch merged into the current one.
+// Unlike UpdateInstance it keeps existing overrides, including secrets that the API only returns redacted.
+func (s *instanceService) PatchConfig(ctx context.Context, id string, req *PatchConfigRequest) (*domain.ClawInstance, error) {
+	instance, err := s.instanceRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrInstanceNotFound
+	}
+	record, err := decodeInstanceConfig(instance.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &domain.InstanceConfig{
+		TemplateName:     record.TemplateName,
+		TemplateRevision: record.TemplateRevision,
+		Overrides:        make(map[string]string, len(record.Overrides)+len(req.Overrides)),
+	}
+	// The pinned template revision is kept unless the patch names a revision or switches to
+	// another template, which starts from that template's latest revision
+	if req.TemplateName != "" && req.TemplateName != record.TemplateName {
+		config.TemplateName = req.TemplateName
+		config.TemplateRevision = 0
+	}
+	if req.TemplateRevision != 0 {
+		config.TemplateRevision = req.TemplateRevision
+	}
+	for path, value := range record.Overrides {
+		config.Overrides[path] = value
+	}
+	for path, value := range req.Overrides {
+		config.Overrides[path] = value
+	}
+
+	rendered, err := s.generateInstanceConfig(ctx, instance, config)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.applyConfig(ctx, instance, appliedFromRendered(rendered, config), req.Author, req.Changelog, 0)
+	if err != nil {
+		return nil, err
+	}
+	result := s.modelToDomain(instance)
+	result.ConfigApply = plan
+	return result, nil
+}
+
+// revisionInfo builds the redacted API view of a revision
+func (s *instanceService) revisionInfo(instance *model.ClawInstance, rev *model.InstanceConfigRevision) (*InstanceConfigRevisionInfo, error) {
+	config, err := s.decodeConfigRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := configSchema(instance.Type)
+	secrets := config.record.secretValues(schema)
+
+	files := make([]adapter.ConfigFile, len(config.files))
+	for i, f := range config.files {
+		f.Content = redactSecrets(f.Content, secrets)
+		files[i] = f
+	}
+	var env map[string]string
+	if config.env != nil {
+		env = make(map[string]string, len(config.env))
+		for key, value := range config.env {
+			env[key] = redactSecrets(value, secrets)
+		}
+	}
+
+	return &InstanceConfigRevisionInfo{
+		InstanceID:     rev.InstanceID,
+		Revision:       rev.Revision,
+		Current:        rev.Revision == instance.ConfigRevision,
+		ContentHash:    rev.ContentHash,
+		Author:         rev.Author,
+		Changelog:      rev.Changelog,
+		RolledBackFrom: rev.RolledBackFrom,
+		Config:         config.record.toDomain(schema),
+		Files:          files,
+		EnvVars:        env,
+		CreatedAt:      rev.CreatedAt,
+	}, nil
+}
+
+// revisionDiffContent is the part of a revision compared by DiffConfigRevisions
+func revisionDiffContent(info *InstanceConfigRevisionInfo) map[string]interface{} {
+	files := make(map[string]string, len(info.Files))
+	for _, f := range info.Files {
+		files[f.Name] = f.Content
+	}
+	return map[string]interface{}{
+		"template": map[string]interface{}{
+			"name":     info.Config.TemplateName,
+			"revision": info.Config.TemplateRevision,
+		},
+		"values": info.Config.Values,
+		"files":  files,
+		"env":    info.EnvVars,
+	}
+}
+
+// configSchema returns the config schema of an adapter type, or nil if the type has no adapter
+func configSchema(adapterType string) *adapter.JSONSchema {
+	adp, err := adapter.CreateByString(adapterType)
+	if err != nil {
+		return nil
+	}
+	return adp.GetConfigSchema()
+}
+
+// redactSecrets replaces every occurrence of the secret values in s
+func redactSecrets(s string, secrets []string) string {
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redactedValue)
+	}
+	return s
+}
+
+// secretValues returns the plain-text secrets held in the record, longest first so
+// overlapping values are redacted completely
+func (r *instanceConfigRecord) secretValues(schema *adapter.JSONSchema) []string {
+	var secrets []string
+	for _, path := range r.SecretPaths {
+		if value := r.Values[path]; value != "" {
+			secrets = append(secrets, value)
+		}
+	}
+	if schema != nil {
+		if doc, err := toJSONValue(r.Resolved); err == nil {
+			collectWriteOnly(schema, doc, &secrets)
+		}
+	}
+
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return secrets
+}
+
+// collectWriteOnly appends the string values of write-only schema properties found in v
+func collectWriteOnly(schema *adapter.JSONSchema, v interface{}, out *[]string) {
+	if schema == nil {
+		return
+	}
+	if schema.WriteOnly {
+		if s, ok := v.(string); ok && s != "" {
+			*out = append(*out, s)
+		}
+		return
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		for key, child := range m {
+			collectWriteOnly(schema.Properties[key], child, out)
+		}
+	}
+}
@@ -0,0 +1,1366 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/weibh/openClusterClaw/internal/domain"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestAPIVersion identifies the manifest format
+const ManifestAPIVersion = "openclusterclaw.io/v1"
+
+// Manifest kinds
+const (
+	ManifestKindTenant         = "Tenant"
+	ManifestKindProject        = "Project"
+	ManifestKindConfigTemplate = "ConfigTemplate"
+	ManifestKindInstance       = "Instance"
+)
+
+// Manifest apply actions
+const (
+	ManifestActionCreate    = "create"
+	ManifestActionUpdate    = "update"
+	ManifestActionDelete    = "delete"
+	ManifestActionUnchanged = "unchanged"
+)
+
+// manifestChangelog is recorded on the revisions created by an apply
+const manifestChangelog = "Applied from manifest"
+
+// ErrInvalidManifest is returned when manifests cannot be parsed or are inconsistent
+var ErrInvalidManifest = errors.New("invalid manifest")
+
+// ManifestService exports resources to declarative YAML manifests and applies them back
+type ManifestService interface {
+	Export(ctx context.Context, filter *ManifestFilter) ([]byte, error)
+	Apply(ctx context.Context, data []byte, opts *ManifestApplyOptions) (*ManifestApplyResult, error)
+}
+
+// ManifestFilter selects the resources to export
+type ManifestFilter struct {
+	// Kinds limits the export to the given kinds; empty exports all of them
+	Kinds []string
+	// Tenant limits the export to one tenant, its projects and instances
+	Tenant string
+}
+
+// ManifestApplyOptions controls how manifests are applied
+type ManifestApplyOptions struct {
+	// DryRun plans the changes without making them
+	DryRun bool
+	// Prune deletes resources of the kinds present in the manifests that the manifests do not define
+	Prune bool
+	// Tenant restricts the manifests and pruning to one tenant; templates are never pruned in a tenant scope
+	Tenant string
+	Author string
+}
+
+// ManifestApplyResult lists the changes planned or made by an apply
+type ManifestApplyResult struct {
+	DryRun  bool              `json:"dry_run"`
+	Changes []*ManifestChange `json:"changes"`
+	// Failed counts the changes with an error; nothing is applied when any change fails to plan
+	Failed int `json:"failed"`
+}
+
+// ManifestChange is the outcome for a single resource
+type ManifestChange struct {
+	Kind    string         `json:"kind"`
+	Name    string         `json:"name"`
+	Tenant  string         `json:"tenant,omitempty"`
+	Project string         `json:"project,omitempty"`
+	Action  string         `json:"action"`
+	Diff    []ConfigChange `json:"diff,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// Manifest is the declarative definition of one resource
+type Manifest struct {
+	APIVersion string           `yaml:"apiVersion" json:"apiVersion"`
+	Kind       string           `yaml:"kind" json:"kind"`
+	Metadata   ManifestMetadata `yaml:"metadata" json:"metadata"`
+	Spec       interface{}      `yaml:"spec,omitempty" json:"spec,omitempty"`
+}
+
+// ManifestMetadata identifies a resource by name; projects and instances are scoped by tenant and project names
+type ManifestMetadata struct {
+	Name    string            `yaml:"name" json:"name"`
+	Tenant  string            `yaml:"tenant,omitempty" json:"tenant,omitempty"`
+	Project string            `yaml:"project,omitempty" json:"project,omitempty"`
+	Labels  map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// TenantManifestSpec is the spec of a Tenant manifest; unset limits keep their current or default values
+type TenantManifestSpec struct {
+	MaxInstances int    `yaml:"max_instances,omitempty" json:"max_instances,omitempty"`
+	MaxCPU       string `yaml:"max_cpu,omitempty" json:"max_cpu,omitempty"`
+	MaxMemory    string `yaml:"max_memory,omitempty" json:"max_memory,omitempty"`
+	MaxStorage   string `yaml:"max_storage,omitempty" json:"max_storage,omitempty"`
+}
+
+// ProjectManifestSpec is the spec of a Project manifest; unset limits and defaults keep their current values
+type ProjectManifestSpec struct {
+	MaxInstances   int    `yaml:"max_instances,omitempty" json:"max_instances,omitempty"`
+	MaxCPU         string `yaml:"max_cpu,omitempty" json:"max_cpu,omitempty"`
+	MaxMemory      string `yaml:"max_memory,omitempty" json:"max_memory,omitempty"`
+	MaxStorage     string `yaml:"max_storage,omitempty" json:"max_storage,omitempty"`
+	DefaultCPU     string `yaml:"default_cpu,omitempty" json:"default_cpu,omitempty"`
+	DefaultMemory  string `yaml:"default_memory,omitempty" json:"default_memory,omitempty"`
+	DefaultStorage string `yaml:"default_storage,omitempty" json:"default_storage,omitempty"`
+}
+
+// TemplateManifestSpec is the spec of a ConfigTemplate manifest; parent and fragments are template names
+type TemplateManifestSpec struct {
+	AdapterType string             `yaml:"adapter_type" json:"adapter_type"`
+	Description string             `yaml:"description,omitempty" json:"description,omitempty"`
+	Version     string             `yaml:"version,omitempty" json:"version,omitempty"`
+	Fragment    bool               `yaml:"fragment,omitempty" json:"fragment,omitempty"`
+	Parent      string             `yaml:"parent,omitempty" json:"parent,omitempty"`
+	Fragments   []string           `yaml:"fragments,omitempty" json:"fragments,omitempty"`
+	Variables   []TemplateVariable `yaml:"variables,omitempty" json:"variables,omitempty"`
+}
+
+// InstanceManifestSpec is the spec of an Instance manifest
+type InstanceManifestSpec struct {
+	Type      string                  `yaml:"type" json:"type"`
+	Version   string                  `yaml:"version" json:"version"`
+	Config    *InstanceManifestConfig `yaml:"config,omitempty" json:"config,omitempty"`
+	Resources *domain.ResourceSpec    `yaml:"resources,omitempty" json:"resources,omitempty"`
+	Storage   *domain.StorageSpec     `yaml:"storage,omitempty" json:"storage,omitempty"`
+}
+
+// InstanceManifestConfig selects the template of an instance.
+// A template revision of 0 keeps the current revision and uses the latest one when the config changes.
+// Secret overrides are exported as "******", which keeps the current value when applied.
+type InstanceManifestConfig struct {
+	Template         string            `yaml:"template,omitempty" json:"template,omitempty"`
+	TemplateRevision int               `yaml:"template_revision,omitempty" json:"template_revision,omitempty"`
+	Overrides        map[string]string `yaml:"overrides,omitempty" json:"overrides,omitempty"`
+}
+
+// manifestService implements ManifestService
+type manifestService struct {
+	tenantRepo   repository.TenantRepository
+	projectRepo  repository.ProjectRepository
+	templateRepo repository.ConfigTemplateRepository
+	instanceRepo repository.InstanceRepository
+	tenants      TenantService
+	projects     ProjectService
+	templates    ConfigTemplateService
+	instances    InstanceService
+}
+
+// NewManifestService creates a new manifest service.
+// Resources are read from the repositories and changed through their services so the usual validation applies.
+func NewManifestService(tenantRepo repository.TenantRepository, projectRepo repository.ProjectRepository, templateRepo repository.ConfigTemplateRepository, instanceRepo repository.InstanceRepository, tenants TenantService, projects ProjectService, templates ConfigTemplateService, instances InstanceService) ManifestService {
+	return &manifestService{
+		tenantRepo:   tenantRepo,
+		projectRepo:  projectRepo,
+		templateRepo: templateRepo,
+		instanceRepo: instanceRepo,
+		tenants:      tenants,
+		projects:     projects,
+		templates:    templates,
+		instances:    instances,
+	}
+}
+
+// manifestKinds lists the kinds in the order they are created
+var manifestKinds = []string{ManifestKindTenant, ManifestKindProject, ManifestKindConfigTemplate, ManifestKindInstance}
+
+// manifestState indexes the stored resources by their manifest identity
+type manifestState struct {
+	tenants       map[string]*model.Tenant
+	tenantNames   map[string]string
+	projects      map[string]*model.Project
+	projectNames  map[string]string
+	templates     map[string]*model.ConfigTemplate
+	templateNames map[string]string
+	instances     map[string]*model.ClawInstance
+	// duplicates holds identities shared by several stored resources, which cannot be managed by manifest
+	duplicates map[string]bool
+}
+
+func projectKey(tenant, name string) string {
+	return tenant + "/" + name
+}
+
+func instanceKey(tenant, project, name string) string {
+	return tenant + "/" + project + "/" + name
+}
+
+// loadState reads every tenant, project, template and instance
+func (s *manifestService) loadState(ctx context.Context) (*manifestState, error) {
+	state := &manifestState{
+		tenants:       make(map[string]*model.Tenant),
+		tenantNames:   make(map[string]string),
+		projects:      make(map[string]*model.Project),
+		projectNames:  make(map[string]string),
+		templates:     make(map[string]*model.ConfigTemplate),
+		templateNames: make(map[string]string),
+		instances:     make(map[string]*model.ClawInstance),
+		duplicates:    make(map[string]bool),
+	}
+
+	tenants, err := s.tenantRepo.List(ctx, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tenants {
+		state.tenants[t.Name] = t
+		state.tenantNames[t.ID] = t.Name
+	}
+
+	projects, _, err := s.projectRepo.List(ctx, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		key := projectKey(state.tenantNames[p.TenantID], p.Name)
+		if _, ok := state.projects[key]; ok {
+			state.duplicates[ManifestKindProject+":"+key] = true
+		}
+		state.projects[key] = p
+		state.projectNames[p.ID] = p.Name
+	}
+
+	templates, err := s.templateRepo.List(ctx, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range templates {
+		state.templates[t.Name] = t
+		state.templateNames[t.ID] = t.Name
+	}
+
+	instances, err := s.instanceRepo.List(ctx, "", "", -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range instances {
+		key := instanceKey(state.tenantNames[i.TenantID], state.projectNames[i.ProjectID], i.Name)
+		if _, ok := state.instances[key]; ok {
+			state.duplicates[ManifestKindInstance+":"+key] = true
+		}
+		state.instances[key] = i
+	}
+	return state, nil
+}
+
+// Export renders the selected resources as a multi-document YAML stream
+func (s *manifestService) Export(ctx context.Context, filter *ManifestFilter) ([]byte, error) {
+	for _, kind := range filter.Kinds {
+		if !containsString(manifestKinds, kind) {
+			return nil, fmt.Errorf("%w: unknown kind %q", ErrInvalidManifest, kind)
+		}
+	}
+	want := func(kind string) bool {
+		return len(filter.Kinds) == 0 || containsString(filter.Kinds, kind)
+	}
+
+	state, err := s.loadState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Tenant != "" && state.tenants[filter.Tenant] == nil {
+		return nil, ErrTenantNotFound
+	}
+	inScope := func(tenant string) bool {
+		return filter.Tenant == "" || tenant == filter.Tenant
+	}
+
+	var manifests []*Manifest
+	if want(ManifestKindTenant) {
+		for _, name := range sortedKeys(state.tenants) {
+			if inScope(name) {
+				manifests = append(manifests, tenantManifest(state.tenants[name]))
+			}
+		}
+	}
+	if want(ManifestKindProject) {
+		for _, key := range sortedKeys(state.projects) {
+			p := state.projects[key]
+			if tenant := state.tenantNames[p.TenantID]; inScope(tenant) {
+				manifests = append(manifests, projectManifest(p, tenant))
+			}
+		}
+	}
+	// Templates are shared by all tenants and only exported unscoped
+	if want(ManifestKindConfigTemplate) && filter.Tenant == "" {
+		for _, name := range sortedKeys(state.templates) {
+			m, err := templateManifest(state, state.templates[name])
+			if err != nil {
+				return nil, err
+			}
+			manifests = append(manifests, m)
+		}
+	}
+	if want(ManifestKindInstance) {
+		for _, key := range sortedKeys(state.instances) {
+			i := state.instances[key]
+			if inScope(state.tenantNames[i.TenantID]) {
+				m, err := instanceManifest(state, i)
+				if err != nil {
+					return nil, err
+				}
+				manifests = append(manifests, m)
+			}
+		}
+	}
+
+	return encodeManifests(manifests)
+}
+
+func newManifest(kind string, metadata ManifestMetadata, spec interface{}) *Manifest {
+	return &Manifest{APIVersion: ManifestAPIVersion, Kind: kind, Metadata: metadata, Spec: spec}
+}
+
+func tenantManifest(t *model.Tenant) *Manifest {
+	return newManifest(ManifestKindTenant, ManifestMetadata{Name: t.Name}, &TenantManifestSpec{
+		MaxInstances: t.MaxInstances,
+		MaxCPU:       t.MaxCPU,
+		MaxMemory:    t.MaxMemory,
+		MaxStorage:   t.MaxStorage,
+	})
+}
+
+// projectManifest omits the spec of projects without quota or defaults
+func projectManifest(p *model.Project, tenant string) *Manifest {
+	spec := &ProjectManifestSpec{
+		MaxInstances:   p.MaxInstances,
+		MaxCPU:         p.MaxCPU,
+		MaxMemory:      p.MaxMemory,
+		MaxStorage:     p.MaxStorage,
+		DefaultCPU:     p.DefaultCPU,
+		DefaultMemory:  p.DefaultMemory,
+		DefaultStorage: p.DefaultStorage,
+	}
+	if *spec == (ProjectManifestSpec{}) {
+		return newManifest(ManifestKindProject, ManifestMetadata{Name: p.Name, Tenant: tenant}, nil)
+	}
+	return newManifest(ManifestKindProject, ManifestMetadata{Name: p.Name, Tenant: tenant}, spec)
+}
+
+func templateManifest(state *manifestState, t *model.ConfigTemplate) (*Manifest, error) {
+	variables, err := decodeTemplateVariables(t.Variables)
+	if err != nil {
+		return nil, err
+	}
+	fragmentIDs, err := templateFragments(t.Fragments)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &TemplateManifestSpec{
+		AdapterType: t.AdapterType,
+		Description: t.Description,
+		Version:     t.Version,
+		Fragment:    templateKind(t) == TemplateKindFragment,
+		Parent:      state.templateNames[t.ParentID],
+	}
+	if len(variables) > 0 {
+		spec.Variables = variables
+	}
+	for _, id := range fragmentIDs {
+		spec.Fragments = append(spec.Fragments, state.templateNames[id])
+	}
+	return newManifest(ManifestKindConfigTemplate, ManifestMetadata{Name: t.Name}, spec), nil
+}
+
+func instanceManifest(state *manifestState, i *model.ClawInstance) (*Manifest, error) {
+	record, err := decodeInstanceConfig(i.Config)
+	if err != nil {
+		return nil, err
+	}
+	// The API view redacts secret overrides
+	config := record.toDomain(configSchema(i.Type))
+
+	spec := &InstanceManifestSpec{Type: i.Type, Version: i.Version}
+	if config.TemplateName != "" || len(config.Overrides) > 0 {
+		spec.Config = &InstanceManifestConfig{
+			Template:         config.TemplateName,
+			TemplateRevision: config.TemplateRevision,
+			Overrides:        config.Overrides,
+		}
+	}
+	if i.CPU != "" || i.Memory != "" {
+		spec.Resources = &domain.ResourceSpec{CPU: i.CPU, Memory: i.Memory}
+	}
+	if i.ConfigDir != "" || i.DataDir != "" || i.StorageSize != "" {
+		spec.Storage = &domain.StorageSpec{ConfigDir: i.ConfigDir, DataDir: i.DataDir, Size: i.StorageSize}
+	}
+
+	return newManifest(ManifestKindInstance, ManifestMetadata{
+		Name:    i.Name,
+		Tenant:  state.tenantNames[i.TenantID],
+		Project: state.projectNames[i.ProjectID],
+		Labels:  decodeLabels(i.Labels),
+	}, spec), nil
+}
+
+// encodeManifests writes manifests as YAML documents separated by ---
+func encodeManifests(manifests []*Manifest) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, m := range manifests {
+		if err := encoder.Encode(m); err != nil {
+			return nil, fmt.Errorf("failed to encode manifest: %w", err)
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// rawManifest is a manifest whose spec has not been decoded yet
+type rawManifest struct {
+	APIVersion string           `yaml:"apiVersion"`
+	Kind       string           `yaml:"kind"`
+	Metadata   ManifestMetadata `yaml:"metadata"`
+	Spec       yaml.Node        `yaml:"spec"`
+}
+
+// parseManifests decodes a YAML stream and checks each manifest on its own
+func parseManifests(data []byte) ([]*Manifest, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var manifests []*Manifest
+	for n := 1; ; n++ {
+		var raw rawManifest
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: document %d: %v", ErrInvalidManifest, n, err)
+		}
+		if raw.Kind == "" && raw.Metadata.Name == "" {
+			// Empty documents, e.g. a trailing ---
+			continue
+		}
+
+		m, err := decodeManifest(&raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: document %d: %v", ErrInvalidManifest, n, err)
+		}
+		manifests = append(manifests, m)
+	}
+	return manifests, nil
+}
+
+func decodeManifest(raw *rawManifest) (*Manifest, error) {
+	if raw.APIVersion != ManifestAPIVersion {
+		return nil, fmt.Errorf("apiVersion must be %s", ManifestAPIVersion)
+	}
+	if strings.TrimSpace(raw.Metadata.Name) == "" {
+		return nil, fmt.Errorf("metadata.name is required")
+	}
+
+	meta := raw.Metadata
+	m := &Manifest{APIVersion: raw.APIVersion, Kind: raw.Kind, Metadata: meta}
+	switch raw.Kind {
+	case ManifestKindTenant:
+		if meta.Tenant != "" || meta.Project != "" || meta.Labels != nil {
+			return nil, fmt.Errorf("tenant %s: only metadata.name is allowed", meta.Name)
+		}
+		spec := &TenantManifestSpec{}
+		m.Spec = spec
+		return m, decodeSpec(&raw.Spec, spec)
+	case ManifestKindProject:
+		if meta.Tenant == "" {
+			return nil, fmt.Errorf("project %s: metadata.tenant is required", meta.Name)
+		}
+		if meta.Project != "" || meta.Labels != nil {
+			return nil, fmt.Errorf("project %s: only metadata.name and metadata.tenant are allowed", meta.Name)
+		}
+		spec := &ProjectManifestSpec{}
+		m.Spec = spec
+		return m, decodeSpec(&raw.Spec, spec)
+	case ManifestKindConfigTemplate:
+		if meta.Tenant != "" || meta.Project != "" || meta.Labels != nil {
+			return nil, fmt.Errorf("template %s: only metadata.name is allowed", meta.Name)
+		}
+		spec := &TemplateManifestSpec{}
+		m.Spec = spec
+		if err := decodeSpec(&raw.Spec, spec); err != nil {
+			return nil, err
+		}
+		if spec.AdapterType == "" {
+			return nil, fmt.Errorf("template %s: spec.adapter_type is required", meta.Name)
+		}
+		if spec.Fragment && spec.Parent != "" {
+			return nil, fmt.Errorf("template %s: fragments cannot have a parent", meta.Name)
+		}
+		return m, nil
+	case ManifestKindInstance:
+		if meta.Tenant == "" {
+			return nil, fmt.Errorf("instance %s: metadata.tenant is required", meta.Name)
+		}
+		spec := &InstanceManifestSpec{}
+		m.Spec = spec
+		if err := decodeSpec(&raw.Spec, spec); err != nil {
+			return nil, err
+		}
+		if spec.Type == "" || spec.Version == "" {
+			return nil, fmt.Errorf("instance %s: spec.type and spec.version are required", meta.Name)
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("unknown kind %q", raw.Kind)
+	}
+}
+
+// decodeSpec decodes a spec node rejecting unknown fields, which yaml.Node.Decode does not do
+func decodeSpec(node *yaml.Node, out interface{}) error {
+	if node.Kind == 0 {
+		return nil
+	}
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("spec: %v", err)
+	}
+	return nil
+}
+
+// manifestIdentity returns the key of the resource a manifest defines
+func manifestIdentity(m *Manifest) string {
+	switch m.Kind {
+	case ManifestKindProject:
+		return projectKey(m.Metadata.Tenant, m.Metadata.Name)
+	case ManifestKindInstance:
+		return instanceKey(m.Metadata.Tenant, m.Metadata.Project, m.Metadata.Name)
+	default:
+		return m.Metadata.Name
+	}
+}
+
+// manifestStep is a planned change and the function that makes it
+type manifestStep struct {
+	change *ManifestChange
+	run    func(ctx context.Context) error
+}
+
+// manifestPlan collects the steps of an apply
+type manifestPlan struct {
+	state  *manifestState
+	author string
+	// desired holds the identities defined by the manifests, keyed by kind
+	desired map[string]map[string]*Manifest
+	steps   []*manifestStep
+}
+
+func (p *manifestPlan) add(m *Manifest, action string) *manifestStep {
+	step := &manifestStep{change: &ManifestChange{
+		Kind:    m.Kind,
+		Name:    m.Metadata.Name,
+		Tenant:  m.Metadata.Tenant,
+		Project: m.Metadata.Project,
+		Action:  action,
+	}}
+	p.steps = append(p.steps, step)
+	return step
+}
+
+func (step *manifestStep) fail(format string, args ...interface{}) {
+	step.change.Error = fmt.Sprintf(format, args...)
+	step.run = nil
+}
+
+// defines reports whether the manifests or the stored state define a resource
+func (p *manifestPlan) defines(kind, key string) bool {
+	if p.desired[kind][key] != nil {
+		return true
+	}
+	switch kind {
+	case ManifestKindTenant:
+		return p.state.tenants[key] != nil
+	case ManifestKindProject:
+		return p.state.projects[key] != nil
+	case ManifestKindConfigTemplate:
+		return p.state.templates[key] != nil
+	}
+	return false
+}
+
+// Apply creates, updates and optionally prunes resources so they match the manifests.
+// The whole set is planned first; if any change cannot be planned nothing is applied.
+// Changes are made in dependency order: tenants, projects, templates, instances, then deletions in reverse.
+func (s *manifestService) Apply(ctx context.Context, data []byte, opts *ManifestApplyOptions) (*ManifestApplyResult, error) {
+	manifests, err := parseManifests(data)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := make(map[string]map[string]*Manifest, len(manifestKinds))
+	for _, kind := range manifestKinds {
+		desired[kind] = make(map[string]*Manifest)
+	}
+	for _, m := range manifests {
+		key := manifestIdentity(m)
+		if desired[m.Kind][key] != nil {
+			return nil, fmt.Errorf("%w: %s %s is defined twice", ErrInvalidManifest, m.Kind, key)
+		}
+		if opts.Tenant != "" && m.Kind != ManifestKindConfigTemplate && manifestTenant(m) != opts.Tenant {
+			return nil, fmt.Errorf("%w: %s %s is outside tenant %s", ErrInvalidManifest, m.Kind, key, opts.Tenant)
+		}
+		desired[m.Kind][key] = m
+	}
+
+	state, err := s.loadState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan := &manifestPlan{state: state, author: opts.Author, desired: desired}
+
+	for _, key := range sortedKeys(desired[ManifestKindTenant]) {
+		s.planTenant(plan, desired[ManifestKindTenant][key])
+	}
+	for _, key := range sortedKeys(desired[ManifestKindProject]) {
+		s.planProject(plan, desired[ManifestKindProject][key])
+	}
+	for _, m := range s.orderTemplates(plan) {
+		s.planTemplate(plan, m)
+	}
+	for _, key := range sortedKeys(desired[ManifestKindInstance]) {
+		s.planInstance(plan, desired[ManifestKindInstance][key])
+	}
+	if opts.Prune {
+		s.planPrune(plan, opts.Tenant)
+	}
+
+	result := &ManifestApplyResult{DryRun: opts.DryRun, Changes: make([]*ManifestChange, len(plan.steps))}
+	for i, step := range plan.steps {
+		result.Changes[i] = step.change
+		if step.change.Error != "" {
+			result.Failed++
+		}
+	}
+	if opts.DryRun || result.Failed > 0 {
+		return result, nil
+	}
+
+	for _, step := range plan.steps {
+		if step.run == nil {
+			continue
+		}
+		if err := step.run(ctx); err != nil {
+			step.change.Error = err.Error()
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// manifestTenant returns the tenant a manifest belongs to
+func manifestTenant(m *Manifest) string {
+	if m.Kind == ManifestKindTenant {
+		return m.Metadata.Name
+	}
+	return m.Metadata.Tenant
+}
+
+func (s *manifestService) planTenant(plan *manifestPlan, m *Manifest) {
+	spec := m.Spec.(*TenantManifestSpec)
+	current := plan.state.tenants[m.Metadata.Name]
+	if current == nil {
+		step := plan.add(m, ManifestActionCreate)
+		step.run = func(ctx context.Context) error {
+			tenant, err := s.tenants.CreateTenant(ctx, &CreateTenantRequest{
+				Name:         m.Metadata.Name,
+				MaxInstances: spec.MaxInstances,
+				MaxCPU:       spec.MaxCPU,
+				MaxMemory:    spec.MaxMemory,
+				MaxStorage:   spec.MaxStorage,
+			})
+			if err != nil {
+				return err
+			}
+			plan.state.tenants[tenant.Name] = tenant
+			return nil
+		}
+		return
+	}
+
+	// Unset limits keep their current values
+	existing := tenantManifest(current)
+	next := *existing.Spec.(*TenantManifestSpec)
+	if spec.MaxInstances != 0 {
+		next.MaxInstances = spec.MaxInstances
+	}
+	if spec.MaxCPU != "" {
+		next.MaxCPU = spec.MaxCPU
+	}
+	if spec.MaxMemory != "" {
+		next.MaxMemory = spec.MaxMemory
+	}
+	if spec.MaxStorage != "" {
+		next.MaxStorage = spec.MaxStorage
+	}
+
+	diff, _ := diffValues(existing.Spec, &next)
+	if len(diff) == 0 {
+		plan.add(m, ManifestActionUnchanged)
+		return
+	}
+	step := plan.add(m, ManifestActionUpdate)
+	step.change.Diff = prefixChanges("spec", diff)
+	step.run = func(ctx context.Context) error {
+		_, err := s.tenants.UpdateTenant(ctx, current.ID, &UpdateTenantRequest{
+			MaxInstances: &next.MaxInstances,
+			MaxCPU:       &next.MaxCPU,
+			MaxMemory:    &next.MaxMemory,
+			MaxStorage:   &next.MaxStorage,
+		})
+		return err
+	}
+}
+
+func (s *manifestService) planProject(plan *manifestPlan, m *Manifest) {
+	key := manifestIdentity(m)
+	if plan.state.duplicates[ManifestKindProject+":"+key] {
+		plan.add(m, ManifestActionUpdate).fail("more than one project is named %s in tenant %s", m.Metadata.Name, m.Metadata.Tenant)
+		return
+	}
+	spec := m.Spec.(*ProjectManifestSpec)
+	if current := plan.state.projects[key]; current != nil {
+		s.planProjectUpdate(plan, m, current, spec)
+		return
+	}
+
+	step := plan.add(m, ManifestActionCreate)
+	if !plan.defines(ManifestKindTenant, m.Metadata.Tenant) {
+		step.fail("tenant %s does not exist", m.Metadata.Tenant)
+		return
+	}
+	step.run = func(ctx context.Context) error {
+		tenant := plan.state.tenants[m.Metadata.Tenant]
+		if tenant == nil {
+			return fmt.Errorf("tenant %s was not created", m.Metadata.Tenant)
+		}
+		project, err := s.projects.CreateProject(ctx, &CreateProjectRequest{
+			TenantID:       tenant.ID,
+			Name:           m.Metadata.Name,
+			MaxInstances:   spec.MaxInstances,
+			MaxCPU:         spec.MaxCPU,
+			MaxMemory:      spec.MaxMemory,
+			MaxStorage:     spec.MaxStorage,
+			DefaultCPU:     spec.DefaultCPU,
+			DefaultMemory:  spec.DefaultMemory,
+			DefaultStorage: spec.DefaultStorage,
+		})
+		if err != nil {
+			return err
+		}
+		plan.state.projects[key] = project
+		return nil
+	}
+}
+
+func (s *manifestService) planProjectUpdate(plan *manifestPlan, m *Manifest, current *model.Project, spec *ProjectManifestSpec) {
+	// Unset limits and defaults keep their current values
+	existing := projectManifest(current, m.Metadata.Tenant)
+	var previous ProjectManifestSpec
+	if existing.Spec != nil {
+		previous = *existing.Spec.(*ProjectManifestSpec)
+	}
+	next := previous
+	if spec.MaxInstances != 0 {
+		next.MaxInstances = spec.MaxInstances
+	}
+	for _, f := range []struct{ value, next *string }{
+		{&spec.MaxCPU, &next.MaxCPU},
+		{&spec.MaxMemory, &next.MaxMemory},
+		{&spec.MaxStorage, &next.MaxStorage},
+		{&spec.DefaultCPU, &next.DefaultCPU},
+		{&spec.DefaultMemory, &next.DefaultMemory},
+		{&spec.DefaultStorage, &next.DefaultStorage},
+	} {
+		if *f.value != "" {
+			*f.next = *f.value
+		}
+	}
+
+	diff, _ := diffValues(&previous, &next)
+	if len(diff) == 0 {
+		plan.add(m, ManifestActionUnchanged)
+		return
+	}
+	step := plan.add(m, ManifestActionUpdate)
+	step.change.Diff = prefixChanges("spec", diff)
+	step.run = func(ctx context.Context) error {
+		_, err := s.projects.UpdateProject(ctx, current.ID, &UpdateProjectRequest{
+			MaxInstances:   &next.MaxInstances,
+			MaxCPU:         &next.MaxCPU,
+			MaxMemory:      &next.MaxMemory,
+			MaxStorage:     &next.MaxStorage,
+			DefaultCPU:     &next.DefaultCPU,
+			DefaultMemory:  &next.DefaultMemory,
+			DefaultStorage: &next.DefaultStorage,
+		})
+		return err
+	}
+}
+
+// orderTemplates sorts template manifests so parents and fragments come before the templates using them
+func (s *manifestService) orderTemplates(plan *manifestPlan) []*Manifest {
+	templates := plan.desired[ManifestKindConfigTemplate]
+	var ordered []*Manifest
+	placed := make(map[string]bool, len(templates))
+	visiting := make(map[string]bool)
+
+	var visit func(name string)
+	visit = func(name string) {
+		m := templates[name]
+		if m == nil || placed[name] || visiting[name] {
+			// References to stored templates need no ordering; cycles are reported when planned
+			return
+		}
+		visiting[name] = true
+		spec := m.Spec.(*TemplateManifestSpec)
+		if spec.Parent != "" {
+			visit(spec.Parent)
+		}
+		for _, f := range spec.Fragments {
+			visit(f)
+		}
+		delete(visiting, name)
+		placed[name] = true
+		ordered = append(ordered, m)
+	}
+	for _, name := range sortedKeys(templates) {
+		visit(name)
+	}
+	return ordered
+}
+
+func (s *manifestService) planTemplate(plan *manifestPlan, m *Manifest) {
+	spec := m.Spec.(*TemplateManifestSpec)
+	current := plan.state.templates[m.Metadata.Name]
+
+	action := ManifestActionCreate
+	if current != nil {
+		action = ManifestActionUpdate
+	}
+	check := func() string {
+		refs := append([]string{}, spec.Fragments...)
+		if spec.Parent != "" {
+			refs = append(refs, spec.Parent)
+		}
+		for _, ref := range refs {
+			if ref == m.Metadata.Name {
+				return fmt.Sprintf("template %s refers to itself", ref)
+			}
+			if !plan.defines(ManifestKindConfigTemplate, ref) {
+				return fmt.Sprintf("template %s does not exist", ref)
+			}
+		}
+		if current != nil {
+			if current.AdapterType != spec.AdapterType {
+				return "adapter_type cannot be changed"
+			}
+			if (templateKind(current) == TemplateKindFragment) != spec.Fragment {
+				return "fragment cannot be changed"
+			}
+		}
+		return ""
+	}
+
+	var diff []ConfigChange
+	if current != nil {
+		existing, err := templateManifest(plan.state, current)
+		if err != nil {
+			plan.add(m, action).fail("%v", err)
+			return
+		}
+		next := *spec
+		if next.Version == "" {
+			next.Version = current.Version
+		}
+		diff, _ = diffValues(existing.Spec, &next)
+		if len(diff) == 0 {
+			plan.add(m, ManifestActionUnchanged)
+			return
+		}
+		diff = prefixChanges("spec", diff)
+	}
+
+	step := plan.add(m, action)
+	step.change.Diff = diff
+	if msg := check(); msg != "" {
+		step.fail("%s", msg)
+		return
+	}
+
+	step.run = func(ctx context.Context) error {
+		parentID, fragmentIDs, err := resolveTemplateNames(plan.state, spec)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			kind := TemplateKindTemplate
+			if spec.Fragment {
+				kind = TemplateKindFragment
+			}
+			template, err := s.templates.CreateTemplate(ctx, &CreateTemplateRequest{
+				Name:        m.Metadata.Name,
+				Description: spec.Description,
+				Variables:   spec.Variables,
+				AdapterType: spec.AdapterType,
+				Version:     spec.Version,
+				Kind:        kind,
+				ParentID:    parentID,
+				Fragments:   fragmentIDs,
+				Changelog:   manifestChangelog,
+				Author:      plan.author,
+			})
+			if err != nil {
+				return err
+			}
+			plan.state.templates[template.Name] = template
+			plan.state.templateNames[template.ID] = template.Name
+			return nil
+		}
+
+		variables := spec.Variables
+		if variables == nil {
+			variables = []TemplateVariable{}
+		}
+		req := &UpdateTemplateRequest{
+			Description: &spec.Description,
+			Variables:   &variables,
+			ParentID:    &parentID,
+			Fragments:   &fragmentIDs,
+			Changelog:   manifestChangelog,
+			Author:      plan.author,
+		}
+		if spec.Version != "" {
+			req.Version = &spec.Version
+		}
+		_, err = s.templates.UpdateTemplate(ctx, current.ID, req)
+		return err
+	}
+}
+
+// resolveTemplateNames maps the parent and fragment names of a template manifest to IDs
+func resolveTemplateNames(state *manifestState, spec *TemplateManifestSpec) (string, []string, error) {
+	lookup := func(name string) (string, error) {
+		t := state.templates[name]
+		if t == nil {
+			return "", fmt.Errorf("template %s was not created", name)
+		}
+		return t.ID, nil
+	}
+
+	var parentID string
+	if spec.Parent != "" {
+		id, err := lookup(spec.Parent)
+		if err != nil {
+			return "", nil, err
+		}
+		parentID = id
+	}
+	fragmentIDs := []string{}
+	for _, name := range spec.Fragments {
+		id, err := lookup(name)
+		if err != nil {
+			return "", nil, err
+		}
+		fragmentIDs = append(fragmentIDs, id)
+	}
+	return parentID, fragmentIDs, nil
+}
+
+func (s *manifestService) planInstance(plan *manifestPlan, m *Manifest) {
+	spec := m.Spec.(*InstanceManifestSpec)
+	key := manifestIdentity(m)
+	current := plan.state.instances[key]
+
+	action := ManifestActionCreate
+	if current != nil {
+		action = ManifestActionUpdate
+	}
+	if plan.state.duplicates[ManifestKindInstance+":"+key] {
+		plan.add(m, action).fail("more than one instance is named %s in tenant %s project %s", m.Metadata.Name, m.Metadata.Tenant, m.Metadata.Project)
+		return
+	}
+
+	if current == nil {
+		step := plan.add(m, action)
+		if msg := checkInstanceReferences(plan, m); msg != "" {
+			step.fail("%s", msg)
+			return
+		}
+		step.run = func(ctx context.Context) error {
+			tenantID, projectID, err := resolveInstanceScope(plan.state, m)
+			if err != nil {
+				return err
+			}
+			req := &CreateInstanceRequest{
+				Name:      m.Metadata.Name,
+				TenantID:  tenantID,
+				ProjectID: projectID,
+				Type:      spec.Type,
+				Version:   spec.Version,
+				Resources: spec.Resources,
+				Storage:   spec.Storage,
+				Labels:    m.Metadata.Labels,
+				Author:    plan.author,
+			}
+			if spec.Config != nil {
+				req.Config = &domain.InstanceConfig{
+					TemplateName:     spec.Config.Template,
+					TemplateRevision: spec.Config.TemplateRevision,
+					Overrides:        spec.Config.Overrides,
+				}
+			}
+			_, err = s.instances.CreateInstance(ctx, req)
+			return err
+		}
+		return
+	}
+
+	record, err := decodeInstanceConfig(current.Config)
+	if err != nil {
+		plan.add(m, action).fail("%v", err)
+		return
+	}
+	existing, err := instanceManifest(plan.state, current)
+	if err != nil {
+		plan.add(m, action).fail("%v", err)
+		return
+	}
+	existingSpec := existing.Spec.(*InstanceManifestSpec)
+	// Compare against the stored overrides, not the redacted ones, so secret changes are detected
+	if existingSpec.Config != nil {
+		existingSpec.Config.Overrides = record.Overrides
+	}
+	next := desiredInstance(m, record)
+
+	diff, _ := diffValues(existing, next)
+	if len(diff) == 0 {
+		plan.add(m, ManifestActionUnchanged)
+		return
+	}
+	step := plan.add(m, action)
+	step.change.Diff = redactOverrideChanges(diff, record, current.Type, next.Spec.(*InstanceManifestSpec).Config)
+
+	nextSpec := next.Spec.(*InstanceManifestSpec)
+	switch {
+	case nextSpec.Type != current.Type:
+		step.fail("type cannot be changed")
+		return
+	case nextSpec.Version != current.Version:
+		step.fail("version cannot be changed")
+		return
+	case !sameStorage(existingSpec.Storage, nextSpec.Storage):
+		step.fail("storage cannot be changed")
+		return
+	}
+	if msg := checkInstanceReferences(plan, m); msg != "" {
+		step.fail("%s", msg)
+		return
+	}
+
+	req := &UpdateInstanceRequest{Changelog: manifestChangelog, Author: plan.author}
+	if configChanged(existingSpec.Config, nextSpec.Config) {
+		req.Config = &domain.InstanceConfig{}
+		if nextSpec.Config != nil {
+			req.Config.TemplateName = nextSpec.Config.Template
+			req.Config.TemplateRevision = nextSpec.Config.TemplateRevision
+			req.Config.Overrides = nextSpec.Config.Overrides
+		}
+	}
+	if !sameResources(existingSpec.Resources, nextSpec.Resources) {
+		req.Resources = &domain.ResourceSpec{}
+		if nextSpec.Resources != nil {
+			req.Resources = nextSpec.Resources
+		}
+	}
+	if changed, _ := diffValues(existing.Metadata.Labels, next.Metadata.Labels); len(changed) > 0 {
+		req.Labels = next.Metadata.Labels
+		if req.Labels == nil {
+			req.Labels = map[string]string{}
+		}
+	}
+	step.run = func(ctx context.Context) error {
+		_, err := s.instances.UpdateInstance(ctx, current.ID, req)
+		return err
+	}
+}
+
+// desiredInstance returns the manifest with unset values filled in from the stored instance:
+// a zero template revision keeps the current one and redacted overrides keep their stored values
+func desiredInstance(m *Manifest, record *instanceConfigRecord) *Manifest {
+	next := *m
+	spec := *m.Spec.(*InstanceManifestSpec)
+	next.Spec = &spec
+	if len(next.Metadata.Labels) == 0 {
+		next.Metadata.Labels = nil
+	}
+
+	if spec.Config != nil {
+		config := *spec.Config
+		spec.Config = &config
+		if config.TemplateRevision == 0 && config.Template == record.TemplateName {
+			config.TemplateRevision = record.TemplateRevision
+		}
+		if len(config.Overrides) == 0 {
+			config.Overrides = nil
+		} else {
+			overrides := make(map[string]string, len(config.Overrides))
+			for path, value := range config.Overrides {
+				if current, ok := record.Overrides[path]; ok && value == redactedValue {
+					value = current
+				}
+				overrides[path] = value
+			}
+			config.Overrides = overrides
+		}
+		if config.Template == "" && config.Overrides == nil {
+			spec.Config = nil
+		}
+	}
+	return &next
+}
+
+// checkInstanceReferences verifies that the tenant, project and template of an instance manifest exist
+func checkInstanceReferences(plan *manifestPlan, m *Manifest) string {
+	spec := m.Spec.(*InstanceManifestSpec)
+	if !plan.defines(ManifestKindTenant, m.Metadata.Tenant) {
+		return fmt.Sprintf("tenant %s does not exist", m.Metadata.Tenant)
+	}
+	if m.Metadata.Project != "" && !plan.defines(ManifestKindProject, projectKey(m.Metadata.Tenant, m.Metadata.Project)) {
+		return fmt.Sprintf("project %s does not exist in tenant %s", m.Metadata.Project, m.Metadata.Tenant)
+	}
+	if spec.Config != nil && spec.Config.Template != "" {
+		if !plan.defines(ManifestKindConfigTemplate, spec.Config.Template) {
+			return fmt.Sprintf("template %s does not exist", spec.Config.Template)
+		}
+		if t := plan.desired[ManifestKindConfigTemplate][spec.Config.Template]; t != nil && t.Spec.(*TemplateManifestSpec).Fragment {
+			return fmt.Sprintf("template %s is a fragment", spec.Config.Template)
+		}
+	}
+	return ""
+}
+
+// resolveInstanceScope maps the tenant and project names of an instance manifest to IDs
+func resolveInstanceScope(state *manifestState, m *Manifest) (string, string, error) {
+	tenant := state.tenants[m.Metadata.Tenant]
+	if tenant == nil {
+		return "", "", fmt.Errorf("tenant %s was not created", m.Metadata.Tenant)
+	}
+	if m.Metadata.Project == "" {
+		return tenant.ID, "", nil
+	}
+	project := state.projects[projectKey(m.Metadata.Tenant, m.Metadata.Project)]
+	if project == nil {
+		return "", "", fmt.Errorf("project %s was not created", m.Metadata.Project)
+	}
+	return tenant.ID, project.ID, nil
+}
+
+func configChanged(a, b *InstanceManifestConfig) bool {
+	changes, _ := diffValues(a, b)
+	return len(changes) > 0
+}
+
+func sameResources(a, b *domain.ResourceSpec) bool {
+	changes, _ := diffValues(a, b)
+	return len(changes) == 0
+}
+
+func sameStorage(a, b *domain.StorageSpec) bool {
+	changes, _ := diffValues(a, b)
+	return len(changes) == 0
+}
+
+// prefixChanges nests change paths under prefix
+func prefixChanges(prefix string, changes []ConfigChange) []ConfigChange {
+	for i := range changes {
+		if changes[i].Path == "" {
+			changes[i].Path = prefix
+		} else if strings.HasPrefix(changes[i].Path, "[") {
+			changes[i].Path = prefix + changes[i].Path
+		} else {
+			changes[i].Path = prefix + "." + changes[i].Path
+		}
+	}
+	return changes
+}
+
+// redactOverrideChanges hides the values of secret overrides in a diff
+func redactOverrideChanges(changes []ConfigChange, record *instanceConfigRecord, instanceType string, desired *InstanceManifestConfig) []ConfigChange {
+	paths := make(map[string]string, len(record.Overrides))
+	for path := range record.Overrides {
+		paths[childPath("spec.config.overrides", path)] = path
+	}
+	if desired != nil {
+		for path := range desired.Overrides {
+			paths[childPath("spec.config.overrides", path)] = path
+		}
+	}
+	schema := configSchema(instanceType)
+
+	for i := range changes {
+		path, ok := paths[changes[i].Path]
+		if !ok {
+			continue
+		}
+		secret := containsString(record.SecretPaths, path)
+		if schema != nil {
+			if prop := schema.Property(path); prop != nil && prop.WriteOnly {
+				secret = true
+			}
+		}
+		if !secret {
+			continue
+		}
+		if changes[i].Old != nil {
+			changes[i].Old = redactedValue
+		}
+		if changes[i].New != nil {
+			changes[i].New = redactedValue
+		}
+	}
+	return changes
+}
+
+// planPrune deletes the stored resources of the kinds present in the manifests that the manifests do not define
+func (s *manifestService) planPrune(plan *manifestPlan, tenantScope string) {
+	inScope := func(tenant string) bool {
+		return tenantScope == "" || tenant == tenantScope
+	}
+	pruned := func(kind, key string) bool {
+		return len(plan.desired[kind]) > 0 && plan.desired[kind][key] == nil
+	}
+	deletion := func(kind string, meta ManifestMetadata) *manifestStep {
+		return plan.add(&Manifest{Kind: kind, Metadata: meta}, ManifestActionDelete)
+	}
+
+	for _, key := range sortedKeys(plan.state.instances) {
+		instance := plan.state.instances[key]
+		tenant := plan.state.tenantNames[instance.TenantID]
+		if !pruned(ManifestKindInstance, key) || !inScope(tenant) {
+			continue
+		}
+		step := deletion(ManifestKindInstance, ManifestMetadata{Name: instance.Name, Tenant: tenant, Project: plan.state.projectNames[instance.ProjectID]})
+		step.run = func(ctx context.Context) error {
+			// Pruning is explicit, so running instances are stopped first
+			if instance.Status == model.StatusRunning {
+				if err := s.instances.StopInstance(ctx, instance.ID); err != nil {
+					return err
+				}
+			}
+			return s.instances.DeleteInstance(ctx, instance.ID)
+		}
+	}
+
+	if tenantScope == "" {
+		// Dependent templates are deleted before the templates they use
+		var names []string
+		for _, name := range sortedKeys(plan.state.templates) {
+			if pruned(ManifestKindConfigTemplate, name) {
+				names = append(names, name)
+			}
+		}
+		for _, name := range orderByDependents(plan.state, names) {
+			template := plan.state.templates[name]
+			step := deletion(ManifestKindConfigTemplate, ManifestMetadata{Name: name})
+			step.run = func(ctx context.Context) error {
+				return s.templates.DeleteTemplate(ctx, template.ID)
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(plan.state.projects) {
+		project := plan.state.projects[key]
+		tenant := plan.state.tenantNames[project.TenantID]
+		if !pruned(ManifestKindProject, key) || !inScope(tenant) {
+			continue
+		}
+		step := deletion(ManifestKindProject, ManifestMetadata{Name: project.Name, Tenant: tenant})
+		step.run = func(ctx context.Context) error {
+			return s.projects.DeleteProject(ctx, project.ID)
+		}
+	}
+
+	for _, name := range sortedKeys(plan.state.tenants) {
+		if !pruned(ManifestKindTenant, name) || !inScope(name) {
+			continue
+		}
+		tenant := plan.state.tenants[name]
+		step := deletion(ManifestKindTenant, ManifestMetadata{Name: name})
+		step.run = func(ctx context.Context) error {
+			return s.tenants.DeleteTenant(ctx, tenant.ID)
+		}
+	}
+}
+
+// orderByDependents orders template names so that a template comes before its parent and fragments
+func orderByDependents(state *manifestState, names []string) []string {
+	uses := make(map[string][]string, len(names))
+	for _, name := range names {
+		t := state.templates[name]
+		if t.ParentID != "" {
+			uses[name] = append(uses[name], state.templateNames[t.ParentID])
+		}
+		ids, _ := templateFragments(t.Fragments)
+		for _, id := range ids {
+			uses[name] = append(uses[name], state.templateNames[id])
+		}
+	}
+
+	// A reverse topological order of the "uses" graph puts users first
+	var ordered []string
+	placed := make(map[string]bool, len(names))
+	var visit func(name string)
+	visit = func(name string) {
+		if placed[name] {
+			return
+		}
+		placed[name] = true
+		for _, used := range uses[name] {
+			visit(used)
+		}
+		ordered = append(ordered, name)
+	}
+	for _, name := range names {
+		visit(name)
+	}
+
+	result := make([]string, 0, len(names))
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if containsString(names, ordered[i]) {
+			result = append(result, ordered[i])
+		}
+	}
+	return result
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -8,7 +8,6 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/weibh/openClusterClaw/internal/model"
-	"github.com/weibh/openClusterClaw/internal/pkg/jwt"
 	"github.com/weibh/openClusterClaw/internal/pkg/otp"
 	"github.com/weibh/openClusterClaw/internal/repository"
 	"golang.org/x/crypto/bcrypt"
@@ -18,15 +17,16 @@ import (
 type OTPService struct {
 	userRepo   *repository.UserRepository
 	otpService *otp.Service
-	jwtService *jwt.JWTService
+	// authService starts the login session once the OTP code is verified
+	authService *AuthService
 }
 
 // NewOTPService creates a new OTP service
-func NewOTPService(userRepo *repository.UserRepository, otpService *otp.Service, jwtService *jwt.JWTService) *OTPService {
+func NewOTPService(userRepo *repository.UserRepository, otpService *otp.Service, authService *AuthService) *OTPService {
 	return &OTPService{
-		userRepo:   userRepo,
-		otpService: otpService,
-		jwtService: jwtService,
+		userRepo:    userRepo,
+		otpService:  otpService,
+		authService: authService,
 	}
 }
 
@@ -55,6 +55,8 @@ type DisableOTPRequest struct {
 type VerifyOTPRequest struct {
 	TempToken string `json:"temp_token" binding:"required"`
 	Code      string `json:"code" binding:"required,len=6"`
+	// Client is recorded on the session the login starts
+	Client ClientInfo `json:"-"`
 }
 
 // GenerateSecret generates a new OTP secret for the user
@@ -215,30 +217,13 @@ func (s *OTPService) VerifyOTP(ctx context.Context, req *VerifyOTPRequest) (*Log
 		return nil, fmt.Errorf("invalid verification code")
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwtService.GenerateAccessToken(user)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token: %w", err)
-	}
-
-	refreshToken, err := s.jwtService.GenerateRefreshToken(user)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
-	}
-
 	// Clear temp token
 	if err := s.userRepo.ClearTempOTPToken(ctx, user.ID); err != nil {
 		// Log error but don't fail the request
 		fmt.Printf("failed to clear temp token: %v\n", err)
 	}
 
-	userResponse := user.ToResponse()
-	return &LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:   s.jwtService.GetAccessExpiration(),
-		User:        &userResponse,
-	}, nil
+	return s.authService.startSession(ctx, user, req.Client)
 }
 
 // validateBackupCode checks if the code matches any backup code and removes it if used
@@ -312,24 +297,21 @@ func (s *AuthService) LoginWithOTP(ctx context.Context, req *LoginRequest) (*Log
 		return nil, fmt.Errorf("invalid username or password")
 	}
 
+	if err := s.checkTenant(ctx, user); err != nil {
+		return nil, err
+	}
+
 	// If OTP is not enabled, proceed with normal login
 	if !user.OTPEnabled {
-		accessToken, err := s.jwtService.GenerateAccessToken(user)
-		if err != nil {
-			return nil, fmt.Errorf("failed to generate access token: %w", err)
-		}
-
-		refreshToken, err := s.jwtService.GenerateRefreshToken(user)
+		resp, err := s.startSession(ctx, user, req.Client)
 		if err != nil {
-			return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+			return nil, err
 		}
-
-		userResponse := user.ToResponse()
 		return &LoginOTPResponse{
-			AccessToken:  &accessToken,
-			RefreshToken: &refreshToken,
-			ExpiresIn:    s.jwtService.GetAccessExpiration(),
-			User:         &userResponse,
+			AccessToken:  &resp.AccessToken,
+			RefreshToken: &resp.RefreshToken,
+			ExpiresIn:    resp.ExpiresIn,
+			User:         resp.User,
 			RequiresOTP:   false,
 		}, nil
 	}
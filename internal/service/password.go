@@ -0,0 +1,101 @@
+package service
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrWeakPassword   = errors.New("password does not meet the password policy")
+	ErrPasswordReused = errors.New("password was used recently")
+)
+
+// PasswordPolicy is the set of rules that new and changed passwords must satisfy
+type PasswordPolicy struct {
+	MinLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	// History is how many recent passwords, the current one included, cannot be reused
+	History int
+}
+
+// generatedPasswordLength is the shortest password Generate returns
+const generatedPasswordLength = 16
+
+const passwordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789!@#%^&*-_=+"
+
+// Generate returns a random password that satisfies the policy
+func (p PasswordPolicy) Generate() (string, error) {
+	length := max(p.MinLength, generatedPasswordLength)
+	password := make([]byte, length)
+	for {
+		for i := range password {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(passwordAlphabet))))
+			if err != nil {
+				return "", fmt.Errorf("failed to generate password: %w", err)
+			}
+			password[i] = passwordAlphabet[n.Int64()]
+		}
+		// A draw can miss a required character class; draw again until it passes
+		if p.Validate(string(password)) == nil {
+			return string(password), nil
+		}
+	}
+}
+
+// PasswordPolicyError lists the rules a rejected password breaks
+type PasswordPolicyError struct {
+	Violations []string `json:"violations"`
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return fmt.Sprintf("password must %s", strings.Join(e.Violations, ", "))
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// Validate checks a password against the length and character class rules
+func (p PasswordPolicy) Validate(password string) error {
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	var violations []string
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("be at least %d characters long", p.MinLength))
+	}
+	if p.RequireUppercase && !upper {
+		violations = append(violations, "contain an uppercase letter")
+	}
+	if p.RequireLowercase && !lower {
+		violations = append(violations, "contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "contain a symbol")
+	}
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
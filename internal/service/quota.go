@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var (
+	ErrQuotaExceeded   = errors.New("quota exceeded")
+	ErrInvalidQuantity = errors.New("invalid resource quantity")
+	ErrQuotaAllocation = errors.New("project quotas exceed the tenant quota")
+)
+
+// Quota scopes
+const (
+	QuotaScopeTenant  = "tenant"
+	QuotaScopeProject = "project"
+)
+
+// Quota dimensions
+const (
+	QuotaInstances = "instances"
+	QuotaCPU       = "cpu"
+	QuotaMemory    = "memory"
+	QuotaStorage   = "storage"
+)
+
+// QuotaExceededError reports which quota dimension a request would exceed
+type QuotaExceededError struct {
+	Scope     string `json:"scope"`
+	TenantID  string `json:"tenant_id"`
+	ProjectID string `json:"project_id,omitempty"`
+	Resource  string `json:"resource"`
+	Requested string `json:"requested"`
+	Used      string `json:"used"`
+	Limit     string `json:"limit"`
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s quota exceeded: %s requested %s with %s of %s in use", e.Scope, e.Resource, e.Requested, e.Used, e.Limit)
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaUsage compares the usage of one resource with its limit; an empty limit is unlimited
+type QuotaUsage struct {
+	Used  string `json:"used"`
+	Limit string `json:"limit,omitempty"`
+}
+
+// ResourceUsage compares usage with the limits of a tenant or project
+type ResourceUsage struct {
+	Instances QuotaUsage `json:"instances"`
+	CPU       QuotaUsage `json:"cpu"`
+	Memory    QuotaUsage `json:"memory"`
+	Storage   QuotaUsage `json:"storage"`
+}
+
+// TenantUsage reports the resources a tenant's instances use against its quota
+type TenantUsage struct {
+	TenantID string `json:"tenant_id"`
+	ResourceUsage
+	Projects []*ProjectUsage `json:"projects,omitempty"`
+}
+
+// ProjectUsage reports the resources a project's instances use against its allocation
+type ProjectUsage struct {
+	ProjectID string `json:"project_id"`
+	Name      string `json:"name"`
+	ResourceUsage
+}
+
+// quotaLimits are the limits of a tenant or project; zero or empty limits are unlimited
+type quotaLimits struct {
+	instances int
+	cpu       string
+	memory    string
+	storage   string
+}
+
+func tenantLimits(tenant *model.Tenant) quotaLimits {
+	return quotaLimits{instances: tenant.MaxInstances, cpu: tenant.MaxCPU, memory: tenant.MaxMemory, storage: tenant.MaxStorage}
+}
+
+func projectLimits(project *model.Project) quotaLimits {
+	return quotaLimits{instances: project.MaxInstances, cpu: project.MaxCPU, memory: project.MaxMemory, storage: project.MaxStorage}
+}
+
+// resourceAmounts is a number of instances and the quantities they request
+type resourceAmounts struct {
+	instances int
+	cpu       resource.Quantity
+	memory    resource.Quantity
+	storage   resource.Quantity
+}
+
+// holdsCompute reports whether an instance in the given status counts against the CPU and
+// memory quota. Stopped instances have no pod, so only their storage and the instance count
+// are charged.
+func holdsCompute(status model.InstanceStatus) bool {
+	return status != model.StatusStopped && status != model.StatusDestroyed
+}
+
+// parseQuantity parses a Kubernetes quantity; an empty value is zero
+func parseQuantity(field, value string) (resource.Quantity, error) {
+	if value == "" {
+		return resource.Quantity{}, nil
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil || q.Sign() < 0 {
+		return resource.Quantity{}, fmt.Errorf("%w: %s %q", ErrInvalidQuantity, field, value)
+	}
+	return q, nil
+}
+
+// instanceDemand returns the resources an instance is charged for in its current status
+func instanceDemand(instance *model.ClawInstance) (*resourceAmounts, error) {
+	demand := &resourceAmounts{instances: 1}
+	var err error
+	if holdsCompute(instance.Status) {
+		if demand.cpu, err = parseQuantity("cpu", instance.CPU); err != nil {
+			return nil, err
+		}
+		if demand.memory, err = parseQuantity("memory", instance.Memory); err != nil {
+			return nil, err
+		}
+	}
+	if demand.storage, err = parseQuantity("storage", instance.StorageSize); err != nil {
+		return nil, err
+	}
+	return demand, nil
+}
+
+// validateLimits checks that quota limits are valid quantities
+func validateLimits(limits quotaLimits) error {
+	if limits.instances < 0 {
+		return fmt.Errorf("%w: max_instances %d", ErrInvalidQuantity, limits.instances)
+	}
+	for _, f := range []struct{ field, value string }{
+		{"max_cpu", limits.cpu}, {"max_memory", limits.memory}, {"max_storage", limits.storage},
+	} {
+		if _, err := parseQuantity(f.field, f.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateProjectDefaults checks that the default instance resources of a project are valid quantities
+func validateProjectDefaults(project *model.Project) error {
+	for _, f := range []struct{ field, value string }{
+		{"default_cpu", project.DefaultCPU}, {"default_memory", project.DefaultMemory}, {"default_storage", project.DefaultStorage},
+	} {
+		if _, err := parseQuantity(f.field, f.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateAllocation checks that the limits set on a tenant's projects add up to no more than
+// the tenant's own limits. Projects without a limit in a dimension are not part of the sum.
+func validateAllocation(tenant *model.Tenant, projects []*model.Project) error {
+	if tenant.MaxInstances > 0 {
+		allocated := 0
+		for _, p := range projects {
+			allocated += p.MaxInstances
+		}
+		if allocated > tenant.MaxInstances {
+			return fmt.Errorf("%w: %d instances allocated to projects, tenant allows %d", ErrQuotaAllocation, allocated, tenant.MaxInstances)
+		}
+	}
+
+	dimensions := []struct {
+		name    string
+		limit   string
+		project func(*model.Project) string
+	}{
+		{QuotaCPU, tenant.MaxCPU, func(p *model.Project) string { return p.MaxCPU }},
+		{QuotaMemory, tenant.MaxMemory, func(p *model.Project) string { return p.MaxMemory }},
+		{QuotaStorage, tenant.MaxStorage, func(p *model.Project) string { return p.MaxStorage }},
+	}
+	for _, d := range dimensions {
+		limit, err := parseQuantity("max_"+d.name, d.limit)
+		if err != nil {
+			return err
+		}
+		if limit.IsZero() {
+			continue
+		}
+		var allocated resource.Quantity
+		for _, p := range projects {
+			q, err := parseQuantity("max_"+d.name, d.project(p))
+			if err != nil {
+				return err
+			}
+			allocated.Add(q)
+		}
+		if allocated.Cmp(limit) > 0 {
+			return fmt.Errorf("%w: %s %s allocated to projects, tenant allows %s", ErrQuotaAllocation, d.name, allocated.String(), d.limit)
+		}
+	}
+	return nil
+}
+
+// sumUsage sums the resources charged to instances, leaving out the instance with ID exclude.
+// Instances whose stored quantities no longer parse are charged nothing.
+func sumUsage(instances []*model.ClawInstance, exclude string) *resourceAmounts {
+	used := &resourceAmounts{}
+	for _, instance := range instances {
+		if instance.ID == exclude || instance.Status == model.StatusDestroyed {
+			continue
+		}
+		demand, err := instanceDemand(instance)
+		if err != nil {
+			log.Printf("Warning: ignoring resources of instance %s in quota usage: %v", instance.ID, err)
+			demand = &resourceAmounts{instances: 1}
+		}
+		used.add(demand)
+	}
+	return used
+}
+
+// listUsage sums the resources charged to the instances matching filter
+func listUsage(ctx context.Context, instanceRepo repository.InstanceRepository, filter repository.InstanceFilter, exclude string) (*resourceAmounts, error) {
+	instances, err := instanceRepo.ListByFilter(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list instances: %w", err)
+	}
+	return sumUsage(instances, exclude), nil
+}
+
+func (a *resourceAmounts) add(other *resourceAmounts) {
+	a.instances += other.instances
+	a.cpu.Add(other.cpu)
+	a.memory.Add(other.memory)
+	a.storage.Add(other.storage)
+}
+
+// checkQuota returns a QuotaExceededError, filled in from scope, for the first dimension in which
+// used plus requested exceeds limits. Dimensions the request does not add to are not checked,
+// so a tenant or project whose limit was lowered below its usage can still shrink.
+func checkQuota(scope QuotaExceededError, limits quotaLimits, used, requested *resourceAmounts) error {
+	if limits.instances > 0 && requested.instances > 0 && used.instances+requested.instances > limits.instances {
+		exceeded := scope
+		exceeded.Resource = QuotaInstances
+		exceeded.Requested = strconv.Itoa(requested.instances)
+		exceeded.Used = strconv.Itoa(used.instances)
+		exceeded.Limit = strconv.Itoa(limits.instances)
+		return &exceeded
+	}
+
+	dimensions := []struct {
+		name      string
+		limit     string
+		used      resource.Quantity
+		requested resource.Quantity
+	}{
+		{QuotaCPU, limits.cpu, used.cpu, requested.cpu},
+		{QuotaMemory, limits.memory, used.memory, requested.memory},
+		{QuotaStorage, limits.storage, used.storage, requested.storage},
+	}
+	for _, d := range dimensions {
+		if d.limit == "" || d.requested.Sign() <= 0 {
+			continue
+		}
+		limit, err := resource.ParseQuantity(d.limit)
+		if err != nil {
+			return fmt.Errorf("%s has an invalid %s limit %q: %w", scope.Scope, d.name, d.limit, err)
+		}
+		if limit.IsZero() {
+			continue
+		}
+		total := d.used.DeepCopy()
+		total.Add(d.requested)
+		if total.Cmp(limit) > 0 {
+			exceeded := scope
+			exceeded.Resource = d.name
+			exceeded.Requested = d.requested.String()
+			exceeded.Used = d.used.String()
+			exceeded.Limit = d.limit
+			return &exceeded
+		}
+	}
+	return nil
+}
+
+// usageReport compares summed usage with limits
+func usageReport(limits quotaLimits, used *resourceAmounts) ResourceUsage {
+	usage := ResourceUsage{
+		Instances: QuotaUsage{Used: strconv.Itoa(used.instances)},
+		CPU:       QuotaUsage{Used: used.cpu.String(), Limit: limits.cpu},
+		Memory:    QuotaUsage{Used: used.memory.String(), Limit: limits.memory},
+		Storage:   QuotaUsage{Used: used.storage.String(), Limit: limits.storage},
+	}
+	if limits.instances > 0 {
+		usage.Instances.Limit = strconv.Itoa(limits.instances)
+	}
+	return usage
+}
+
+// admit checks that the tenant and project of an instance can take on requested more
+// resources, leaving out the instance with ID exclude. Callers hold quotaMu until the
+// change is persisted.
+func (s *instanceService) admit(ctx context.Context, tenantID, projectID, exclude string, requested *resourceAmounts) error {
+	tenant, err := s.tenantRepo.GetByID(ctx, tenantID)
+	if err != nil {
+		return ErrTenantNotFound
+	}
+	// Nothing is created, started or scaled up while the tenant is suspended or archived
+	if !tenant.IsActive() {
+		return ErrTenantInactive
+	}
+	project, err := s.projectRepo.GetByID(ctx, projectID)
+	if err != nil || project.TenantID != tenant.ID {
+		return ErrProjectNotFound
+	}
+
+	// Quotas count every instance of the tenant, including projects the caller cannot see
+	instances, err := s.instanceRepo.ListByFilter(repository.WithoutProjectScope(ctx), repository.InstanceFilter{TenantID: tenantID})
+	if err != nil {
+		return fmt.Errorf("failed to list tenant instances: %w", err)
+	}
+	scope := QuotaExceededError{Scope: QuotaScopeTenant, TenantID: tenant.ID}
+	if err := checkQuota(scope, tenantLimits(tenant), sumUsage(instances, exclude), requested); err != nil {
+		return err
+	}
+
+	var inProject []*model.ClawInstance
+	for _, instance := range instances {
+		if instance.ProjectID == project.ID {
+			inProject = append(inProject, instance)
+		}
+	}
+	scope = QuotaExceededError{Scope: QuotaScopeProject, TenantID: tenant.ID, ProjectID: project.ID}
+	return checkQuota(scope, projectLimits(project), sumUsage(inProject, exclude), requested)
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func amounts(instances int, cpu, memory, storage string) *resourceAmounts {
+	a := &resourceAmounts{instances: instances}
+	if cpu != "" {
+		a.cpu = resource.MustParse(cpu)
+	}
+	if memory != "" {
+		a.memory = resource.MustParse(memory)
+	}
+	if storage != "" {
+		a.storage = resource.MustParse(storage)
+	}
+	return a
+}
+
+func TestCheckQuota(t *testing.T) {
+	limits := quotaLimits{instances: 3, cpu: "4", memory: "8Gi", storage: "100Gi"}
+
+	tests := []struct {
+		name      string
+		limits    quotaLimits
+		used      *resourceAmounts
+		requested *resourceAmounts
+		exceeded  string
+	}{
+		{"fits", limits, amounts(1, "1", "2Gi", "10Gi"), amounts(1, "1", "2Gi", "10Gi"), ""},
+		{"fills every limit exactly", limits, amounts(2, "3", "6Gi", "90Gi"), amounts(1, "1", "2Gi", "10Gi"), ""},
+		{"instance count", limits, amounts(3, "1", "1Gi", "1Gi"), amounts(1, "", "", ""), QuotaInstances},
+		{"cpu", limits, amounts(1, "3500m", "1Gi", "1Gi"), amounts(0, "600m", "", ""), QuotaCPU},
+		{"memory", limits, amounts(1, "1", "7Gi", "1Gi"), amounts(0, "", "2Gi", ""), QuotaMemory},
+		{"storage", limits, amounts(1, "1", "1Gi", "95Gi"), amounts(1, "", "", "10Gi"), QuotaStorage},
+		{"unlimited", quotaLimits{}, amounts(100, "100", "100Gi", "1Ti"), amounts(1, "8", "16Gi", "1Ti"), ""},
+		{"zero limit is unlimited", quotaLimits{cpu: "0"}, amounts(0, "100", "", ""), amounts(0, "1", "", ""), ""},
+		// A lowered limit must not block a request that does not add to the exceeded dimension
+		{"over limit but not growing", limits, amounts(5, "10", "1Gi", "1Gi"), amounts(0, "", "1Gi", ""), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := QuotaExceededError{Scope: QuotaScopeTenant, TenantID: "t1"}
+			err := checkQuota(scope, tt.limits, tt.used, tt.requested)
+			if tt.exceeded == "" {
+				if err != nil {
+					t.Fatalf("checkQuota = %v, want nil", err)
+				}
+				return
+			}
+			var exceeded *QuotaExceededError
+			if !errors.As(err, &exceeded) {
+				t.Fatalf("checkQuota = %v, want a QuotaExceededError", err)
+			}
+			if exceeded.Resource != tt.exceeded || exceeded.TenantID != "t1" {
+				t.Errorf("exceeded %s in tenant %s, want %s in t1", exceeded.Resource, exceeded.TenantID, tt.exceeded)
+			}
+			if !errors.Is(err, ErrQuotaExceeded) {
+				t.Errorf("error does not wrap ErrQuotaExceeded")
+			}
+		})
+	}
+}
+
+func TestCheckQuotaRejectsInvalidLimit(t *testing.T) {
+	err := checkQuota(QuotaExceededError{Scope: QuotaScopeTenant}, quotaLimits{cpu: "lots"}, amounts(0, "", "", ""), amounts(0, "1", "", ""))
+	if err == nil || errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("checkQuota = %v, want an invalid limit error", err)
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	db := newTestDB(t)
+	mustCreate(t, db,
+		&model.Tenant{ID: "t1", Name: "t1", MaxInstances: 4, MaxCPU: "4", MaxMemory: "8Gi", MaxStorage: "100Gi"},
+		&model.Tenant{ID: "t2", Name: "t2", MaxInstances: 4, MaxCPU: "4", MaxMemory: "8Gi", MaxStorage: "100Gi", Status: model.TenantSuspended},
+		&model.Project{ID: "p1", TenantID: "t1", Name: "p1", MaxCPU: "2"},
+		&model.Project{ID: "p2", TenantID: "t1", Name: "p2"},
+		&model.Project{ID: "p3", TenantID: "t2", Name: "p3"},
+		&model.ClawInstance{ID: "i1", TenantID: "t1", ProjectID: "p1", Name: "i1", Status: model.StatusRunning, CPU: "1", Memory: "2Gi"},
+		&model.ClawInstance{ID: "i2", TenantID: "t1", ProjectID: "p2", Name: "i2", Status: model.StatusRunning, CPU: "1", Memory: "2Gi"},
+		// Stopped instances are charged for their storage and count, not their CPU and memory
+		&model.ClawInstance{ID: "i3", TenantID: "t1", ProjectID: "p2", Name: "i3", Status: model.StatusStopped, CPU: "2", Memory: "4Gi", StorageSize: "50Gi"},
+	)
+	s := &instanceService{
+		tenantRepo:   repository.NewTenantRepository(db),
+		projectRepo:  repository.NewProjectRepository(db),
+		instanceRepo: repository.NewInstanceRepository(db),
+	}
+
+	tests := []struct {
+		name      string
+		tenantID  string
+		projectID string
+		exclude   string
+		requested *resourceAmounts
+		want      error
+		exceeded  string
+		scope     string
+	}{
+		{name: "fits", tenantID: "t1", projectID: "p2", requested: amounts(1, "2", "4Gi", "10Gi")},
+		{name: "tenant cpu", tenantID: "t1", projectID: "p2", requested: amounts(1, "3", "", ""), exceeded: QuotaCPU, scope: QuotaScopeTenant},
+		{name: "tenant storage", tenantID: "t1", projectID: "p2", requested: amounts(1, "", "", "60Gi"), exceeded: QuotaStorage, scope: QuotaScopeTenant},
+		{name: "tenant instances", tenantID: "t1", projectID: "p2", requested: amounts(2, "", "", ""), exceeded: QuotaInstances, scope: QuotaScopeTenant},
+		{name: "project cpu", tenantID: "t1", projectID: "p1", requested: amounts(1, "1500m", "", ""), exceeded: QuotaCPU, scope: QuotaScopeProject},
+		{name: "resize leaves out the old size", tenantID: "t1", projectID: "p1", exclude: "i1", requested: amounts(0, "2", "", "")},
+		{name: "suspended tenant", tenantID: "t2", projectID: "p3", requested: amounts(1, "", "", ""), want: ErrTenantInactive},
+		{name: "unknown tenant", tenantID: "t9", projectID: "p1", requested: amounts(1, "", "", ""), want: ErrTenantNotFound},
+		{name: "project of another tenant", tenantID: "t1", projectID: "p3", requested: amounts(1, "", "", ""), want: ErrProjectNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.admit(context.Background(), tt.tenantID, tt.projectID, tt.exclude, tt.requested)
+			switch {
+			case tt.want != nil:
+				if !errors.Is(err, tt.want) {
+					t.Fatalf("admit = %v, want %v", err, tt.want)
+				}
+			case tt.exceeded != "":
+				var exceeded *QuotaExceededError
+				if !errors.As(err, &exceeded) {
+					t.Fatalf("admit = %v, want a QuotaExceededError", err)
+				}
+				if exceeded.Resource != tt.exceeded || exceeded.Scope != tt.scope {
+					t.Errorf("exceeded %s %s, want %s %s", exceeded.Scope, exceeded.Resource, tt.scope, tt.exceeded)
+				}
+			case err != nil:
+				t.Fatalf("admit = %v, want nil", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
+)
+
+// newTestRBAC returns an RBAC service over tenants t1 and t2 with projects p1 and p2 in t1,
+// and a user in t1 for every name in users
+func newTestRBAC(t *testing.T, users ...string) (RBACService, repository.RoleBindingRepository) {
+	t.Helper()
+	db := newTestDB(t)
+	mustCreate(t, db,
+		&model.Tenant{ID: "t1", Name: "t1"},
+		&model.Tenant{ID: "t2", Name: "t2"},
+		&model.Project{ID: "p1", TenantID: "t1", Name: "p1"},
+		&model.Project{ID: "p2", TenantID: "t1", Name: "p2"},
+	)
+	for _, name := range users {
+		mustCreate(t, db, &model.User{ID: name, Username: name, PasswordHash: "-", TenantID: "t1", Role: model.RoleUser, IsActive: true})
+	}
+	bindings := repository.NewRoleBindingRepository(db)
+	return NewRBACService(repository.NewRoleRepository(db), bindings, repository.NewTenantRepository(db), repository.NewProjectRepository(db), repository.NewUserRepository(db)), bindings
+}
+
+// bind grants a role to a user without the checks of CreateBinding
+func bind(t *testing.T, bindings repository.RoleBindingRepository, userID, role string, scope model.BindingScope, scopeID, tenantID string) {
+	t.Helper()
+	if err := bindings.Create(context.Background(), &model.RoleBinding{UserID: userID, Role: role, Scope: scope, ScopeID: scopeID, TenantID: tenantID}); err != nil {
+		t.Fatalf("bind %s: %v", role, err)
+	}
+}
+
+func TestAuthorizeBindingScopes(t *testing.T) {
+	rbac, bindings := newTestRBAC(t, "tenant-admin", "maintainer", "member")
+	bind(t, bindings, "tenant-admin", RoleTenantAdmin, model.BindingScopeTenant, "t1", "t1")
+	bind(t, bindings, "maintainer", RoleProjectMaintainer, model.BindingScopeProject, "p1", "t1")
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		user     string
+		resource string
+		verb     string
+		scope    AccessScope
+		allowed  bool
+	}{
+		{"tenant binding covers the tenant", "tenant-admin", ResourceUsers, VerbCreate, AccessScope{TenantID: "t1"}, true},
+		{"tenant binding covers its projects", "tenant-admin", ResourceInstances, VerbDelete, AccessScope{TenantID: "t1", ProjectID: "p2"}, true},
+		{"tenant binding stops at the tenant", "tenant-admin", ResourceInstances, VerbGet, AccessScope{TenantID: "t2"}, false},
+		{"tenant binding is not global", "tenant-admin", ResourceTenants, VerbList, AccessScope{}, false},
+		{"role limits verbs", "tenant-admin", ResourceTenants, VerbUpdate, AccessScope{TenantID: "t1"}, false},
+		{"project binding covers the project", "maintainer", ResourceInstances, VerbUpdate, AccessScope{TenantID: "t1", ProjectID: "p1"}, true},
+		{"project binding stops at the project", "maintainer", ResourceInstances, VerbGet, AccessScope{TenantID: "t1", ProjectID: "p2"}, false},
+		{"project binding does not cover the tenant", "maintainer", ResourceInstances, VerbGet, AccessScope{TenantID: "t1"}, false},
+		{"project binding covers narrowed listings", "maintainer", ResourceInstances, VerbList, AccessScope{TenantID: "t1", AnyProject: true}, true},
+		{"implicit member covers projects without members", "member", ResourceInstances, VerbCreate, AccessScope{TenantID: "t1", ProjectID: "p2"}, true},
+		{"implicit member does not cover projects with members", "member", ResourceInstances, VerbGet, AccessScope{TenantID: "t1", ProjectID: "p1"}, false},
+		{"implicit member only covers instances", "member", ResourceProjects, VerbGet, AccessScope{TenantID: "t1"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subject := Subject{UserID: tt.user, TenantID: "t1"}
+			err := rbac.Authorize(ctx, subject, tt.resource, tt.verb, tt.scope)
+			if tt.allowed && err != nil {
+				t.Fatalf("Authorize = %v, want allowed", err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrPermissionDenied) {
+				t.Fatalf("Authorize = %v, want ErrPermissionDenied", err)
+			}
+		})
+	}
+}
+
+// A role can only be granted by someone who holds every permission in it at the binding's scope
+func TestCreateBindingRequiresGranterToHoldRole(t *testing.T) {
+	rbac, bindings := newTestRBAC(t, "tenant-admin", "maintainer", "target")
+	bind(t, bindings, "tenant-admin", RoleTenantAdmin, model.BindingScopeTenant, "t1", "t1")
+	bind(t, bindings, "maintainer", RoleProjectMaintainer, model.BindingScopeProject, "p1", "t1")
+	ctx := context.Background()
+	if _, err := rbac.CreateRole(ctx, &CreateRoleRequest{Name: "template-editor", Permissions: []string{"templates:*"}}); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+	if _, err := rbac.CreateRole(ctx, &CreateRoleRequest{Name: "instance-editor", Permissions: []string{"instances:get", "instances:update"}}); err != nil {
+		t.Fatalf("CreateRole: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		granter Subject
+		role    string
+		scope   model.BindingScope
+		scopeID string
+		allowed bool
+	}{
+		{"tenant admin grants a subset at the tenant", Subject{UserID: "tenant-admin", TenantID: "t1"}, RoleOperator, model.BindingScopeTenant, "t1", true},
+		{"tenant admin grants a subset at a project", Subject{UserID: "tenant-admin", TenantID: "t1"}, RoleProjectMaintainer, model.BindingScopeProject, "p1", true},
+		{"tenant admin grants a custom subset", Subject{UserID: "tenant-admin", TenantID: "t1"}, "instance-editor", model.BindingScopeTenant, "t1", true},
+		{"tenant admin cannot grant its own role globally", Subject{UserID: "tenant-admin", TenantID: "t1"}, RoleTenantAdmin, model.BindingScopeGlobal, "", false},
+		{"tenant admin cannot grant template permissions", Subject{UserID: "tenant-admin", TenantID: "t1"}, RoleViewer, model.BindingScopeTenant, "t1", false},
+		{"tenant admin cannot grant a custom role it does not hold", Subject{UserID: "tenant-admin", TenantID: "t1"}, "template-editor", model.BindingScopeTenant, "t1", false},
+		{"maintainer cannot grant without users:update", Subject{UserID: "maintainer", TenantID: "t1"}, RoleOperator, model.BindingScopeProject, "p1", false},
+		{"admin grants anything", Subject{UserID: "root", Admin: true}, RoleTenantAdmin, model.BindingScopeGlobal, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			binding, err := rbac.CreateBinding(ctx, tt.granter, &CreateRoleBindingRequest{UserID: "target", Role: tt.role, Scope: tt.scope, ScopeID: tt.scopeID})
+			if !tt.allowed {
+				if !errors.Is(err, ErrPermissionDenied) {
+					t.Fatalf("CreateBinding = %v, want ErrPermissionDenied", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateBinding = %v, want allowed", err)
+			}
+			if err := bindings.Delete(ctx, binding.ID); err != nil {
+				t.Fatalf("delete binding: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/weibh/openClusterClaw/internal/adapter"
+	"github.com/weibh/openClusterClaw/internal/domain"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/runtime/k8s"
+)
+
+// Config apply actions reported in domain.ConfigApply
+const (
+	ApplyNone    = "none"
+	ApplyReload  = "reload"
+	ApplyRestart = "restart"
+)
+
+const (
+	// configSyncTimeout bounds the wait for the kubelet to project an updated ConfigMap into the pod
+	configSyncTimeout = 2 * time.Minute
+	// podDeleteTimeout bounds the wait for the old pod to terminate before it is recreated
+	podDeleteTimeout = time.Minute
+)
+
+// reloadPolicy returns the reload policy of an adapter type; types without an adapter are always restarted
+func reloadPolicy(adapterType string) adapter.ReloadPolicy {
+	adp, err := adapter.CreateByString(adapterType)
+	if err != nil {
+		return adapter.ReloadPolicy{Method: adapter.ReloadNone}
+	}
+	return adp.GetReloadPolicy()
+}
+
+// planConfigApply decides whether a running instance can reload next in place or has to be restarted
+func (s *instanceService) planConfigApply(ctx context.Context, instance *model.ClawInstance, previous, next *appliedConfig) (*domain.ConfigApply, error) {
+	if s.podManager == nil {
+		return &domain.ConfigApply{Action: ApplyNone, Reason: "Kubernetes is not configured"}, nil
+	}
+	if instance.Status != model.StatusRunning {
+		return &domain.ConfigApply{Action: ApplyNone, Reason: "instance is not running; the config is used on the next start"}, nil
+	}
+
+	policy := reloadPolicy(instance.Type)
+	plan := &domain.ConfigApply{Action: ApplyReload, Method: string(policy.Method)}
+	if previous == nil {
+		plan.Action, plan.Reason = ApplyRestart, "no previous config revision to compare with"
+		return plan, nil
+	}
+
+	// Compare env vars with what the pod was created with, which may predate previous
+	envHash := k8s.HashData(previous.env)
+	if pod, err := s.podManager.GetPod(ctx, k8s.GeneratePodName(instance.ID)); err == nil {
+		if hash, ok := pod.Annotations[k8s.AnnotationEnvHash]; ok {
+			envHash = hash
+		}
+	}
+	if envHash != k8s.HashData(next.env) {
+		plan.Action, plan.Reason = ApplyRestart, "env vars changed"
+		return plan, nil
+	}
+
+	changes, err := diffValues(previous.record.Resolved, next.record.Resolved)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 && filesHash(previous.files) == filesHash(next.files) {
+		plan.Action, plan.Reason = ApplyNone, "config is unchanged"
+		return plan, nil
+	}
+	if policy.Method == adapter.ReloadNone {
+		plan.Action, plan.Reason = ApplyRestart, "adapter does not support hot reload"
+		return plan, nil
+	}
+	for _, change := range changes {
+		if policy.RequiresRestart(change.Path) {
+			plan.RestartFields = append(plan.RestartFields, change.Path)
+		}
+	}
+	if len(plan.RestartFields) > 0 {
+		plan.Action, plan.Reason = ApplyRestart, "changed fields cannot be reloaded"
+	}
+	return plan, nil
+}
+
+// filesHash hashes the content of rendered config files
+func filesHash(files []adapter.ConfigFile) string {
+	data := make(map[string]string, len(files))
+	for _, f := range files {
+		data[f.Name] = f.Content
+	}
+	return k8s.HashData(data)
+}
+
+// deliverConfig carries out a config apply plan for a running instance.
+// It runs in the background because the kubelet may take a minute to update mounted files.
+func (s *instanceService) deliverConfig(ctx context.Context, instanceID, instanceType string, revision int, plan *domain.ConfigApply, next *appliedConfig, configMapName string) {
+	if plan.Action == ApplyNone {
+		return
+	}
+
+	if plan.Action == ApplyReload {
+		err := s.reloadInPlace(ctx, instanceID, instanceType, revision, next)
+		if err == nil || errors.Is(err, errConfigSuperseded) {
+			return
+		}
+		log.Printf("Warning: Hot reload of instance %s failed, restarting: %v", instanceID, err)
+	}
+
+	if s.superseded(ctx, instanceID, revision) {
+		return
+	}
+	if err := s.recreatePod(ctx, instanceID, configMapName, next.env); err != nil {
+		log.Printf("Warning: Failed to restart instance %s for config revision %d: %v", instanceID, revision, err)
+	}
+}
+
+// errConfigSuperseded stops a delivery when a newer config revision was applied meanwhile
+var errConfigSuperseded = errors.New("config revision superseded")
+
+// superseded reports whether the instance moved on from revision, in which case the newer apply delivers it
+func (s *instanceService) superseded(ctx context.Context, instanceID string, revision int) bool {
+	instance, err := s.instanceRepo.GetByID(ctx, instanceID)
+	return err != nil || instance.ConfigRevision != revision || instance.Status != model.StatusRunning
+}
+
+// reloadInPlace waits for the new files to appear in the pod and asks the Claw to re-read them
+func (s *instanceService) reloadInPlace(ctx context.Context, instanceID, instanceType string, revision int, next *appliedConfig) error {
+	policy := reloadPolicy(instanceType)
+	podName := k8s.GeneratePodName(instanceID)
+
+	// A watching Claw picks the files up on its own
+	if policy.Method == adapter.ReloadFileWatch {
+		return nil
+	}
+	if err := s.waitForConfigSync(ctx, instanceID, revision, podName, next.files); err != nil {
+		return err
+	}
+
+	switch policy.Method {
+	case adapter.ReloadSignal:
+		if _, err := s.podManager.ExecInPod(ctx, podName, "kill", "-"+policy.Signal, "1"); err != nil {
+			return err
+		}
+	case adapter.ReloadHTTP:
+		if err := s.callReloadEndpoint(ctx, podName, next.record.Resolved.Server.Port, policy.Path); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported reload method %q", policy.Method)
+	}
+	log.Printf("Instance %s reloaded config revision %d via %s", instanceID, revision, policy.Method)
+	return nil
+}
+
+// waitForConfigSync polls the mounted config files until they match the applied revision
+func (s *instanceService) waitForConfigSync(ctx context.Context, instanceID string, revision int, podName string, files []adapter.ConfigFile) error {
+	ctx, cancel := context.WithTimeout(ctx, configSyncTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timeout waiting for config files to be updated in the pod")
+		case <-ticker.C:
+		}
+
+		if s.superseded(ctx, instanceID, revision) {
+			return errConfigSuperseded
+		}
+		synced := true
+		for _, f := range files {
+			content, err := s.podManager.ExecInPod(ctx, podName, "cat", path.Join(adapter.DefaultConfigMountPath, f.Name))
+			if err != nil || string(content) != f.Content {
+				synced = false
+				break
+			}
+		}
+		if synced {
+			return nil
+		}
+	}
+}
+
+// callReloadEndpoint POSTs to the reload endpoint of the Claw on its pod IP
+func (s *instanceService) callReloadEndpoint(ctx context.Context, podName string, port int, reloadPath string) error {
+	pod, err := s.podManager.GetPod(ctx, podName)
+	if err != nil {
+		return err
+	}
+	if pod.Status.PodIP == "" || port == 0 {
+		return fmt.Errorf("pod has no reachable address")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	url := fmt.Sprintf("http://%s:%d%s", pod.Status.PodIP, port, reloadPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("reload request failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("reload endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// recreatePod replaces the pod of an instance so it starts with the current env vars and files
+func (s *instanceService) recreatePod(ctx context.Context, instanceID, configMapName string, env map[string]string) error {
+	instance, err := s.instanceRepo.GetByID(ctx, instanceID)
+	if err != nil {
+		return err
+	}
+	podName := k8s.GeneratePodName(instanceID)
+	if err := s.podManager.DeletePod(ctx, podName); err != nil {
+		return fmt.Errorf("failed to delete K8S pod: %w", err)
+	}
+	if err := s.podManager.WaitForPodDeleted(ctx, podName, podDeleteTimeout); err != nil {
+		return err
+	}
+
+	if err := s.instanceRepo.UpdateStatus(ctx, instanceID, model.StatusCreating); err != nil {
+		return err
+	}
+	if _, err := s.podManager.CreatePod(ctx, s.buildPodSpec(instance, configMapName, env)); err != nil {
+		_ = s.instanceRepo.UpdateStatus(ctx, instanceID, model.StatusFailed)
+		return fmt.Errorf("failed to create K8S pod: %w", err)
+	}
+	s.syncPodStatus(ctx, instanceID, podName)
+	return nil
+}
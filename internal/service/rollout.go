@@ -0,0 +1,649 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/weibh/openClusterClaw/internal/domain"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
+)
+
+var (
+	ErrRolloutNotFound = errors.New("config rollout not found")
+	ErrInvalidRollout  = errors.New("invalid config rollout")
+	ErrRolloutConflict = errors.New("instance is already part of an active rollout")
+	ErrRolloutFinished = errors.New("config rollout already finished")
+)
+
+// defaultHealthCheckSeconds is the health window after each wave when the strategy sets none
+const defaultHealthCheckSeconds = 30
+
+// maxHealthPollInterval bounds how often instance health is polled during a health window
+const maxHealthPollInterval = 5 * time.Second
+
+// RolloutService defines the business logic for staged config rollouts
+type RolloutService interface {
+	CreateRollout(ctx context.Context, req *CreateRolloutRequest) (*RolloutInfo, error)
+	GetRollout(ctx context.Context, id string) (*RolloutInfo, error)
+	ListRollouts(ctx context.Context, page, pageSize int) ([]*RolloutInfo, int, error)
+	AbortRollout(ctx context.Context, id string) (*RolloutInfo, error)
+	// ResumeRollouts restarts the rollouts left unfinished by a previous process
+	ResumeRollouts(ctx context.Context) error
+}
+
+// RolloutSelector selects the instances of a rollout; all set fields must match
+type RolloutSelector struct {
+	TemplateName string            `json:"template_name,omitempty"`
+	TenantID     string            `json:"tenant_id,omitempty"`
+	ProjectID    string            `json:"project_id,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	InstanceIDs  []string          `json:"instance_ids,omitempty"`
+}
+
+// RolloutStrategy controls how a rollout is split into waves and when it aborts
+type RolloutStrategy struct {
+	// CanaryPercent of the targets form the first wave; 0 disables the canary wave
+	CanaryPercent int `json:"canary_percent"`
+	// BatchSize is the size of each following wave; 0 applies the rest in one wave
+	BatchSize    int `json:"batch_size"`
+	PauseSeconds int `json:"pause_seconds"`
+	// HealthCheckSeconds is how long instances are observed after each wave
+	HealthCheckSeconds int `json:"health_check_seconds"`
+	// MaxFailures is the number of failed instances tolerated before the rollout aborts and rolls back
+	MaxFailures int `json:"max_failures"`
+}
+
+// CreateRolloutRequest represents the request to start a config rollout
+type CreateRolloutRequest struct {
+	Name     string          `json:"name" binding:"required"`
+	Selector RolloutSelector `json:"selector"`
+	Patch    ConfigPatch     `json:"patch"`
+	Strategy RolloutStrategy `json:"strategy"`
+	Author   string          `json:"-"`
+}
+
+// RolloutInfo is the API view of a rollout
+type RolloutInfo struct {
+	*model.ConfigRollout
+	Selector RolloutSelector              `json:"selector"`
+	Patch    ConfigPatch                  `json:"patch"`
+	Targets  []*model.ConfigRolloutTarget `json:"targets,omitempty"`
+}
+
+// rolloutRun tracks a rollout executing in this process
+type rolloutRun struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// rolloutService implements RolloutService
+type rolloutService struct {
+	repo         repository.ConfigRolloutRepository
+	instanceRepo repository.InstanceRepository
+	templateRepo repository.ConfigTemplateRepository
+	instances    InstanceService
+
+	mu      sync.Mutex
+	running map[string]*rolloutRun
+}
+
+// NewRolloutService creates a new rollout service
+func NewRolloutService(repo repository.ConfigRolloutRepository, instanceRepo repository.InstanceRepository, templateRepo repository.ConfigTemplateRepository, instances InstanceService) RolloutService {
+	return &rolloutService{
+		repo:         repo,
+		instanceRepo: instanceRepo,
+		templateRepo: templateRepo,
+		instances:    instances,
+		running:      make(map[string]*rolloutRun),
+	}
+}
+
+func (s *rolloutService) CreateRollout(ctx context.Context, req *CreateRolloutRequest) (*RolloutInfo, error) {
+	if err := validateRollout(req); err != nil {
+		return nil, err
+	}
+
+	instances, err := s.selectInstances(ctx, &req.Selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("%w: selector matches no instances", ErrInvalidRollout)
+	}
+
+	selectorJSON, err := json.Marshal(req.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal selector: %w", err)
+	}
+	patchJSON, err := json.Marshal(req.Patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal patch: %w", err)
+	}
+
+	strategy := req.Strategy
+	if strategy.HealthCheckSeconds == 0 {
+		strategy.HealthCheckSeconds = defaultHealthCheckSeconds
+	}
+
+	waves := planWaves(len(instances), strategy.CanaryPercent, strategy.BatchSize)
+	targets := make([]*model.ConfigRolloutTarget, 0, len(instances))
+	next := 0
+	for i, size := range waves {
+		for _, inst := range instances[next : next+size] {
+			targets = append(targets, &model.ConfigRolloutTarget{
+				InstanceID: inst.ID,
+				Wave:       i + 1,
+				Status:     model.TargetPending,
+			})
+		}
+		next += size
+	}
+
+	rollout := &model.ConfigRollout{
+		Name:               req.Name,
+		Selector:           selectorJSON,
+		Patch:              patchJSON,
+		CanaryPercent:      strategy.CanaryPercent,
+		BatchSize:          strategy.BatchSize,
+		PauseSeconds:       strategy.PauseSeconds,
+		HealthCheckSeconds: strategy.HealthCheckSeconds,
+		MaxFailures:        strategy.MaxFailures,
+		Status:             model.RolloutPending,
+		TotalWaves:         len(waves),
+		Author:             req.Author,
+	}
+
+	// Hold the lock across the conflict check and insert so two rollouts cannot claim the same instance
+	s.mu.Lock()
+	if err := s.checkConflicts(ctx, targets); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, rollout, targets); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.startLocked(rollout)
+	s.mu.Unlock()
+
+	return s.toInfo(rollout, targets)
+}
+
+func (s *rolloutService) GetRollout(ctx context.Context, id string) (*RolloutInfo, error) {
+	rollout, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrRolloutNotFound
+	}
+	targets, err := s.repo.ListTargets(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.toInfo(rollout, targets)
+}
+
+func (s *rolloutService) ListRollouts(ctx context.Context, page, pageSize int) ([]*RolloutInfo, int, error) {
+	rollouts, total, err := s.repo.List(ctx, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	infos := make([]*RolloutInfo, 0, len(rollouts))
+	for _, rollout := range rollouts {
+		info, err := s.toInfo(rollout, nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, total, nil
+}
+
+// AbortRollout stops a rollout and rolls back the instances it changed
+func (s *rolloutService) AbortRollout(ctx context.Context, id string) (*RolloutInfo, error) {
+	rollout, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrRolloutNotFound
+	}
+	if isRolloutFinished(rollout.Status) {
+		return nil, ErrRolloutFinished
+	}
+
+	s.mu.Lock()
+	run, ok := s.running[id]
+	s.mu.Unlock()
+
+	if ok {
+		// The runner rolls back and records the abort itself
+		run.cancel()
+		select {
+		case <-run.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	} else {
+		targets, err := s.repo.ListTargets(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		s.rollBack(ctx, rollout, targets)
+		s.finish(ctx, rollout, model.RolloutAborted, "aborted")
+	}
+
+	return s.GetRollout(ctx, id)
+}
+
+func (s *rolloutService) ResumeRollouts(ctx context.Context) error {
+	rollouts, err := s.repo.ListByStatus(ctx, model.RolloutPending, model.RolloutRunning)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rollout := range rollouts {
+		log.Printf("Resuming config rollout %s (%s) at wave %d", rollout.ID, rollout.Name, rollout.CurrentWave)
+		s.startLocked(rollout)
+	}
+	return nil
+}
+
+// startLocked runs the rollout in the background; s.mu must be held
+func (s *rolloutService) startLocked(rollout *model.ConfigRollout) {
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &rolloutRun{cancel: cancel, done: make(chan struct{})}
+	s.running[rollout.ID] = run
+
+	// The runner owns its copy; the caller may still read the original
+	owned := *rollout
+	rollout = &owned
+
+	go func() {
+		defer close(run.done)
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, rollout.ID)
+			s.mu.Unlock()
+			cancel()
+		}()
+		s.run(ctx, rollout)
+	}()
+}
+
+// run applies the rollout wave by wave. Cancelling ctx aborts the rollout.
+func (s *rolloutService) run(ctx context.Context, rollout *model.ConfigRollout) {
+	// Bookkeeping must survive cancellation, so it uses its own context
+	bg := context.Background()
+
+	targets, err := s.repo.ListTargets(bg, rollout.ID)
+	if err != nil {
+		s.finish(bg, rollout, model.RolloutFailed, err.Error())
+		return
+	}
+	var patch ConfigPatch
+	if err := json.Unmarshal(rollout.Patch, &patch); err != nil {
+		s.finish(bg, rollout, model.RolloutFailed, fmt.Sprintf("failed to parse patch: %v", err))
+		return
+	}
+
+	if rollout.Status == model.RolloutPending {
+		now := time.Now()
+		rollout.Status = model.RolloutRunning
+		rollout.StartedAt = &now
+	}
+
+	for wave := max(rollout.CurrentWave, 1); wave <= rollout.TotalWaves; wave++ {
+		rollout.CurrentWave = wave
+		rollout.Message = fmt.Sprintf("applying wave %d of %d", wave, rollout.TotalWaves)
+		s.save(bg, rollout)
+
+		var waveTargets []*model.ConfigRolloutTarget
+		for _, t := range targets {
+			if t.Wave == wave {
+				waveTargets = append(waveTargets, t)
+			}
+		}
+
+		for _, t := range waveTargets {
+			if ctx.Err() != nil {
+				break
+			}
+			if t.Status == model.TargetPending {
+				s.applyTarget(ctx, rollout, &patch, t)
+			}
+		}
+		if ctx.Err() == nil {
+			s.checkHealth(ctx, rollout, waveTargets)
+		}
+		if ctx.Err() != nil {
+			s.rollBack(bg, rollout, targets)
+			s.finish(bg, rollout, model.RolloutAborted, fmt.Sprintf("aborted during wave %d", wave))
+			return
+		}
+
+		rollout.Failures = countFailed(targets)
+		if rollout.Failures > rollout.MaxFailures {
+			s.rollBack(bg, rollout, targets)
+			s.finish(bg, rollout, model.RolloutFailed, fmt.Sprintf("%d instance(s) failed in wave %d, exceeding the limit of %d", rollout.Failures, wave, rollout.MaxFailures))
+			return
+		}
+
+		if wave < rollout.TotalWaves && rollout.PauseSeconds > 0 {
+			rollout.Message = fmt.Sprintf("wave %d complete, pausing", wave)
+			s.save(bg, rollout)
+			select {
+			case <-time.After(time.Duration(rollout.PauseSeconds) * time.Second):
+			case <-ctx.Done():
+				s.rollBack(bg, rollout, targets)
+				s.finish(bg, rollout, model.RolloutAborted, fmt.Sprintf("aborted after wave %d", wave))
+				return
+			}
+		}
+	}
+
+	s.finish(bg, rollout, model.RolloutSucceeded, fmt.Sprintf("%d wave(s) applied", rollout.TotalWaves))
+}
+
+// applyTarget applies the patch to one instance, recording its state beforehand for health checks and rollback
+func (s *rolloutService) applyTarget(ctx context.Context, rollout *model.ConfigRollout, patch *ConfigPatch, t *model.ConfigRolloutTarget) {
+	defer s.saveTarget(t)
+
+	instance, err := s.instances.GetInstance(ctx, t.InstanceID)
+	if err != nil {
+		t.Status = model.TargetFailed
+		t.Message = err.Error()
+		return
+	}
+	health, err := s.instances.GetInstanceHealth(ctx, t.InstanceID)
+	if err != nil {
+		t.Status = model.TargetFailed
+		t.Message = err.Error()
+		return
+	}
+	t.PreviousRevision = instance.ConfigRevision
+	t.WasRunning = health.Status == domain.StatusRunning
+	t.RestartsBefore = health.RestartCount
+
+	applied, err := s.instances.PatchConfig(ctx, t.InstanceID, &PatchConfigRequest{
+		ConfigPatch: *patch,
+		Changelog:   fmt.Sprintf("Rollout %s, wave %d", rollout.Name, t.Wave),
+		Author:      rollout.Author,
+	})
+	if err != nil {
+		t.Status = model.TargetFailed
+		t.Message = err.Error()
+		return
+	}
+	t.AppliedRevision = applied.ConfigRevision
+	t.Status = model.TargetApplied
+	t.Message = ""
+}
+
+// checkHealth observes the applied targets of a wave for the health window.
+// A target fails when its instance fails or its pod restarts, or when a previously running
+// instance is not healthy at the end of the window.
+func (s *rolloutService) checkHealth(ctx context.Context, rollout *model.ConfigRollout, targets []*model.ConfigRolloutTarget) {
+	window := time.Duration(rollout.HealthCheckSeconds) * time.Second
+	interval := min(window, maxHealthPollInterval)
+	deadline := time.Now().Add(window)
+
+	for {
+		final := !time.Now().Before(deadline)
+		pending := 0
+		for _, t := range targets {
+			if t.Status != model.TargetApplied {
+				continue
+			}
+			health, err := s.instances.GetInstanceHealth(ctx, t.InstanceID)
+			switch {
+			case err != nil:
+				t.Status, t.Message = model.TargetFailed, err.Error()
+			case health.Status == domain.StatusFailed:
+				t.Status, t.Message = model.TargetFailed, health.Message
+			case t.WasRunning && health.RestartCount > t.RestartsBefore:
+				t.Status, t.Message = model.TargetFailed, fmt.Sprintf("pod restarted %d time(s) after the change", health.RestartCount-t.RestartsBefore)
+			case final && t.WasRunning && !health.Healthy:
+				t.Status, t.Message = model.TargetFailed, health.Message
+			case final:
+				t.Status = model.TargetHealthy
+			default:
+				pending++
+				continue
+			}
+			s.saveTarget(t)
+		}
+		if final || pending == 0 {
+			return
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// rollBack restores the previous config revision of every target the rollout changed.
+// Instances whose config changed again since are left alone.
+func (s *rolloutService) rollBack(ctx context.Context, rollout *model.ConfigRollout, targets []*model.ConfigRolloutTarget) {
+	for _, t := range targets {
+		if t.AppliedRevision == 0 || t.Status == model.TargetRolledBack {
+			continue
+		}
+		if t.PreviousRevision == 0 {
+			t.Message = "no previous config revision to restore"
+			s.saveTarget(t)
+			continue
+		}
+
+		instance, err := s.instances.GetInstance(ctx, t.InstanceID)
+		if err != nil {
+			t.Message = fmt.Sprintf("rollback failed: %v", err)
+			s.saveTarget(t)
+			continue
+		}
+		if instance.ConfigRevision != t.AppliedRevision {
+			t.Message = fmt.Sprintf("config changed to revision %d since the rollout, not rolled back", instance.ConfigRevision)
+			s.saveTarget(t)
+			continue
+		}
+
+		if _, err := s.instances.RollbackConfig(ctx, t.InstanceID, &ConfigRollbackRequest{
+			Revision:  t.PreviousRevision,
+			Changelog: fmt.Sprintf("Rollout %s rolled back", rollout.Name),
+			Author:    rollout.Author,
+		}); err != nil {
+			t.Message = fmt.Sprintf("rollback failed: %v", err)
+		} else {
+			t.Status = model.TargetRolledBack
+		}
+		s.saveTarget(t)
+	}
+}
+
+// finish records the final status of a rollout
+func (s *rolloutService) finish(ctx context.Context, rollout *model.ConfigRollout, status model.RolloutStatus, message string) {
+	now := time.Now()
+	rollout.Status = status
+	rollout.Message = message
+	rollout.FinishedAt = &now
+	s.save(ctx, rollout)
+}
+
+func (s *rolloutService) save(ctx context.Context, rollout *model.ConfigRollout) {
+	if err := s.repo.Update(ctx, rollout); err != nil {
+		log.Printf("Warning: failed to save config rollout %s: %v", rollout.ID, err)
+	}
+}
+
+func (s *rolloutService) saveTarget(t *model.ConfigRolloutTarget) {
+	if err := s.repo.UpdateTarget(context.Background(), t); err != nil {
+		log.Printf("Warning: failed to save config rollout target %s: %v", t.ID, err)
+	}
+}
+
+// selectInstances resolves the selector to instances, oldest first
+func (s *rolloutService) selectInstances(ctx context.Context, selector *RolloutSelector) ([]*model.ClawInstance, error) {
+	filter := repository.InstanceFilter{
+		TenantID:  selector.TenantID,
+		ProjectID: selector.ProjectID,
+		IDs:       selector.InstanceIDs,
+	}
+	if selector.TemplateName != "" {
+		template, err := s.templateRepo.GetByName(ctx, selector.TemplateName)
+		if err != nil {
+			return nil, ErrConfigTemplateNotFound
+		}
+		filter.TemplateID = template.ID
+	}
+
+	instances, err := s.instanceRepo.ListByFilter(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(selector.Labels) == 0 {
+		return instances, nil
+	}
+
+	matched := instances[:0]
+	for _, inst := range instances {
+		if matchLabels(decodeLabels(inst.Labels), selector.Labels) {
+			matched = append(matched, inst)
+		}
+	}
+	return matched, nil
+}
+
+// checkConflicts rejects targets that belong to another unfinished rollout
+func (s *rolloutService) checkConflicts(ctx context.Context, targets []*model.ConfigRolloutTarget) error {
+	active, err := s.repo.ListByStatus(ctx, model.RolloutPending, model.RolloutRunning)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		wanted[t.InstanceID] = true
+	}
+	for _, rollout := range active {
+		activeTargets, err := s.repo.ListTargets(ctx, rollout.ID)
+		if err != nil {
+			return err
+		}
+		for _, t := range activeTargets {
+			if wanted[t.InstanceID] {
+				return fmt.Errorf("%w: instance %s is in rollout %s", ErrRolloutConflict, t.InstanceID, rollout.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// toInfo builds the API view of a rollout, redacting patch values of secret template variables
+func (s *rolloutService) toInfo(rollout *model.ConfigRollout, targets []*model.ConfigRolloutTarget) (*RolloutInfo, error) {
+	info := &RolloutInfo{ConfigRollout: rollout, Targets: targets}
+	if len(rollout.Selector) > 0 {
+		if err := json.Unmarshal(rollout.Selector, &info.Selector); err != nil {
+			return nil, fmt.Errorf("failed to parse selector: %w", err)
+		}
+	}
+	if len(rollout.Patch) > 0 {
+		if err := json.Unmarshal(rollout.Patch, &info.Patch); err != nil {
+			return nil, fmt.Errorf("failed to parse patch: %w", err)
+		}
+	}
+
+	templateName := info.Patch.TemplateName
+	if templateName == "" {
+		templateName = info.Selector.TemplateName
+	}
+	if templateName != "" && len(info.Patch.Overrides) > 0 {
+		if template, err := s.templateRepo.GetByName(context.Background(), templateName); err == nil {
+			var variables []TemplateVariable
+			if err := json.Unmarshal(template.Variables, &variables); err == nil {
+				for _, v := range variables {
+					if _, ok := info.Patch.Overrides[v.Name]; ok && v.Secret {
+						info.Patch.Overrides[v.Name] = redactedValue
+					}
+				}
+			}
+		}
+	}
+	return info, nil
+}
+
+// validateRollout checks the request before any instance is selected
+func validateRollout(req *CreateRolloutRequest) error {
+	sel := req.Selector
+	if sel.TemplateName == "" && sel.TenantID == "" && sel.ProjectID == "" && len(sel.Labels) == 0 && len(sel.InstanceIDs) == 0 {
+		return fmt.Errorf("%w: selector must not be empty", ErrInvalidRollout)
+	}
+	if req.Patch.TemplateName == "" && req.Patch.TemplateRevision == 0 && len(req.Patch.Overrides) == 0 {
+		return fmt.Errorf("%w: patch must not be empty", ErrInvalidRollout)
+	}
+
+	st := req.Strategy
+	switch {
+	case st.CanaryPercent < 0 || st.CanaryPercent > 100:
+		return fmt.Errorf("%w: canary_percent must be between 0 and 100", ErrInvalidRollout)
+	case st.BatchSize < 0:
+		return fmt.Errorf("%w: batch_size must not be negative", ErrInvalidRollout)
+	case st.PauseSeconds < 0:
+		return fmt.Errorf("%w: pause_seconds must not be negative", ErrInvalidRollout)
+	case st.HealthCheckSeconds < 0:
+		return fmt.Errorf("%w: health_check_seconds must not be negative", ErrInvalidRollout)
+	case st.MaxFailures < 0:
+		return fmt.Errorf("%w: max_failures must not be negative", ErrInvalidRollout)
+	}
+	return nil
+}
+
+// planWaves splits n targets into wave sizes: an optional canary wave, then batches
+func planWaves(n, canaryPercent, batchSize int) []int {
+	var waves []int
+	remaining := n
+	if canaryPercent > 0 && remaining > 0 {
+		canary := min((n*canaryPercent+99)/100, remaining)
+		waves = append(waves, canary)
+		remaining -= canary
+	}
+	for remaining > 0 {
+		size := remaining
+		if batchSize > 0 && batchSize < remaining {
+			size = batchSize
+		}
+		waves = append(waves, size)
+		remaining -= size
+	}
+	return waves
+}
+
+// matchLabels reports whether labels contain every key/value pair of selector
+func matchLabels(labels, selector map[string]string) bool {
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func countFailed(targets []*model.ConfigRolloutTarget) int {
+	n := 0
+	for _, t := range targets {
+		if t.Status == model.TargetFailed {
+			n++
+		}
+	}
+	return n
+}
+
+func isRolloutFinished(status model.RolloutStatus) bool {
+	return status == model.RolloutSucceeded || status == model.RolloutFailed || status == model.RolloutAborted
+}
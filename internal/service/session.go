@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
+)
+
+var (
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrSessionRevoked     = errors.New("session has been revoked or has expired")
+	ErrRefreshTokenReused = errors.New("refresh token was already used; the session has been revoked")
+)
+
+// Reasons recorded on revoked sessions
+const (
+	RevokedLogout          = "logout"
+	RevokedByUser          = "revoked"
+	RevokedTokenReuse      = "refresh_token_reuse"
+	RevokedPasswordChanged = "password_changed"
+	RevokedUserDeactivated = "user_deactivated"
+	RevokedUserChanged     = "user_changed"
+	RevokedUserDeleted     = repository.SessionRevokedUserDeleted
+)
+
+// ClientInfo describes the client a session is used from
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// SessionResponse is an active session of a user. Current marks the session of the caller.
+type SessionResponse struct {
+	*model.Session
+	Current bool `json:"current"`
+}
+
+// startSession records a new login session for a user and issues its first tokens
+func (s *AuthService) startSession(ctx context.Context, user *model.User, client ClientInfo) (*LoginResponse, error) {
+	now := time.Now()
+	// Expired sessions cannot be used any more; clearing them here keeps the table small
+	if err := s.sessionRepo.DeleteExpired(ctx, now); err != nil {
+		log.Printf("Warning: failed to delete expired sessions: %v", err)
+	}
+
+	session := &model.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		RefreshJTI: uuid.New().String(),
+		UserAgent:  client.UserAgent,
+		ClientIP:   client.IP,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.sessionLifetime()),
+	}
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return s.issueTokens(user, session)
+}
+
+// sessionLifetime is how long a session lasts without a refresh
+func (s *AuthService) sessionLifetime() time.Duration {
+	return time.Duration(s.jwtService.GetRefreshExpiration()) * time.Second
+}
+
+// revokeReused revokes a session whose rotated refresh token was presented again
+func (s *AuthService) revokeReused(ctx context.Context, sessionID string) error {
+	if err := s.sessionRepo.Revoke(ctx, sessionID, RevokedTokenReuse); err != nil {
+		return err
+	}
+	log.Printf("Warning: refresh token reuse detected, revoked session %s", sessionID)
+	return ErrRefreshTokenReused
+}
+
+// ValidateSession checks that the session of an access token still belongs to the user and
+// is neither revoked nor expired
+func (s *AuthService) ValidateSession(ctx context.Context, userID, sessionID string) error {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	if !session.IsActive() {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// Logout revokes the session of the caller, invalidating its access and refresh tokens
+func (s *AuthService) Logout(ctx context.Context, sessionID string) error {
+	return s.sessionRepo.Revoke(ctx, sessionID, RevokedLogout)
+}
+
+// ListSessions returns the active sessions of a user; currentID marks the caller's session
+func (s *AuthService) ListSessions(ctx context.Context, userID, currentID string) ([]*SessionResponse, error) {
+	sessions, err := s.sessionRepo.ListActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]*SessionResponse, len(sessions))
+	for i, session := range sessions {
+		responses[i] = &SessionResponse{Session: session, Current: session.ID == currentID}
+	}
+	return responses, nil
+}
+
+// RevokeSession revokes one session of a user
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	return s.sessionRepo.Revoke(ctx, session.ID, RevokedByUser)
+}
+
+// ListUserSessions returns the active sessions of a user in the caller's tenant scope
+func (s *AuthService) ListUserSessions(ctx context.Context, id string) ([]*SessionResponse, error) {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.ListSessions(ctx, user.ID, "")
+}
+
+// RevokeUserSessions revokes all sessions of a user in the caller's tenant scope, signing the
+// user out everywhere
+func (s *AuthService) RevokeUserSessions(ctx context.Context, id string) error {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeByUser(ctx, user.ID, RevokedByUser)
+}
+
+// RevokeUserSession revokes one session of a user in the caller's tenant scope
+func (s *AuthService) RevokeUserSession(ctx context.Context, id, sessionID string) error {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.RevokeSession(ctx, user.ID, sessionID)
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/weibh/openClusterClaw/config"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/pkg/jwt"
+	"github.com/weibh/openClusterClaw/internal/repository"
+)
+
+const testPassword = "Secret123x"
+
+// newTestAuth returns an auth service with one active user, "alice", in tenant t1
+func newTestAuth(t *testing.T, policy PasswordPolicy) (*AuthService, *model.UserResponse) {
+	t.Helper()
+	db := newTestDB(t)
+	mustCreate(t, db, &model.Tenant{ID: "t1", Name: "t1"}, &model.Tenant{ID: "t2", Name: "t2"})
+	jwtService := jwt.NewJWTService(&config.Config{JWT: config.JWTConfig{Secret: "test-secret", ExpireTime: 900}})
+	s := NewAuthService(repository.NewUserRepository(db), repository.NewTenantRepository(db), repository.NewRoleBindingRepository(db), repository.NewSessionRepository(db), jwtService, policy)
+
+	user, err := s.CreateUser(context.Background(), &CreateUserRequest{Username: "alice", Password: testPassword, TenantID: "t1"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return s, user
+}
+
+func login(t *testing.T, s *AuthService) *LoginResponse {
+	t.Helper()
+	resp, err := s.Login(context.Background(), &LoginRequest{Username: "alice", Password: testPassword})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	return resp
+}
+
+// sessionOf returns the session ID an access token belongs to
+func sessionOf(t *testing.T, s *AuthService, accessToken string) string {
+	t.Helper()
+	claims, err := s.jwtService.ValidateAccessToken(accessToken)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken: %v", err)
+	}
+	return claims.SessionID
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	s, user := newTestAuth(t, PasswordPolicy{})
+	ctx := context.Background()
+	first := login(t, s)
+	sessionID := sessionOf(t, s, first.AccessToken)
+
+	second, err := s.RefreshToken(ctx, first.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if got := sessionOf(t, s, second.AccessToken); got != sessionID {
+		t.Errorf("refresh moved to session %s, want %s", got, sessionID)
+	}
+	third, err := s.RefreshToken(ctx, second.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshToken with the rotated token: %v", err)
+	}
+
+	// Access tokens cannot be used to refresh
+	if _, err := s.RefreshToken(ctx, third.AccessToken, ClientInfo{}); err == nil {
+		t.Error("RefreshToken accepted an access token")
+	}
+	if err := s.ValidateSession(ctx, user.ID, sessionID); err != nil {
+		t.Errorf("ValidateSession = %v, want the session to stay active", err)
+	}
+}
+
+// Presenting a rotated refresh token again revokes the whole session
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	s, user := newTestAuth(t, PasswordPolicy{})
+	ctx := context.Background()
+	first := login(t, s)
+	other := login(t, s)
+	sessionID := sessionOf(t, s, first.AccessToken)
+
+	second, err := s.RefreshToken(ctx, first.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if _, err := s.RefreshToken(ctx, first.RefreshToken, ClientInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reusing a rotated token = %v, want ErrRefreshTokenReused", err)
+	}
+
+	if err := s.ValidateSession(ctx, user.ID, sessionID); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("ValidateSession = %v, want ErrSessionRevoked", err)
+	}
+	if _, err := s.RefreshToken(ctx, second.RefreshToken, ClientInfo{}); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("refreshing the latest token = %v, want ErrSessionRevoked", err)
+	}
+	// Other sessions of the user are not affected
+	if err := s.ValidateSession(ctx, user.ID, sessionOf(t, s, other.AccessToken)); err != nil {
+		t.Errorf("ValidateSession of another session = %v, want active", err)
+	}
+}
+
+// Tokens carry the role and tenant, so changing either signs the user out
+func TestUpdateUserRevokesSessions(t *testing.T) {
+	admin := model.RoleAdmin
+	tenant := "t2"
+	sameTenant := "t1"
+
+	tests := []struct {
+		name    string
+		req     *UpdateUserRequest
+		revoked bool
+	}{
+		{"role", &UpdateUserRequest{Role: &admin}, true},
+		{"tenant", &UpdateUserRequest{TenantID: &tenant}, true},
+		{"unchanged tenant", &UpdateUserRequest{TenantID: &sameTenant}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, user := newTestAuth(t, PasswordPolicy{})
+			ctx := context.Background()
+			sessionID := sessionOf(t, s, login(t, s).AccessToken)
+
+			if _, err := s.UpdateUser(ctx, user.ID, tt.req); err != nil {
+				t.Fatalf("UpdateUser: %v", err)
+			}
+			err := s.ValidateSession(ctx, user.ID, sessionID)
+			if tt.revoked && !errors.Is(err, ErrSessionRevoked) {
+				t.Errorf("ValidateSession = %v, want ErrSessionRevoked", err)
+			}
+			if !tt.revoked && err != nil {
+				t.Errorf("ValidateSession = %v, want active", err)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := PasswordPolicy{MinLength: 10, RequireUppercase: true, RequireLowercase: true, RequireDigit: true, RequireSymbol: true}
+
+	tests := []struct {
+		password   string
+		violations int
+	}{
+		{"Abcdefgh1!", 0},
+		{"Abcdef1!", 1},
+		{"abcdefgh1!", 1},
+		{"ABCDEFGH1!", 1},
+		{"Abcdefghi!", 1},
+		{"Abcdefghi1", 1},
+		{"abc", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			err := policy.Validate(tt.password)
+			if tt.violations == 0 {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			var policyErr *PasswordPolicyError
+			if !errors.As(err, &policyErr) || !errors.Is(err, ErrWeakPassword) {
+				t.Fatalf("Validate = %v, want a PasswordPolicyError", err)
+			}
+			if len(policyErr.Violations) != tt.violations {
+				t.Errorf("violations = %v, want %d", policyErr.Violations, tt.violations)
+			}
+		})
+	}
+
+	generated, err := policy.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if err := policy.Validate(generated); err != nil {
+		t.Errorf("generated password %q fails the policy: %v", generated, err)
+	}
+}
+
+func TestChangePasswordHistory(t *testing.T) {
+	s, user := newTestAuth(t, PasswordPolicy{MinLength: 8, History: 3})
+	ctx := context.Background()
+	old := login(t, s)
+
+	change := func(current, next string) error {
+		_, err := s.ChangePassword(ctx, user.ID, current, next, ClientInfo{})
+		return err
+	}
+	if err := change(testPassword, testPassword); !errors.Is(err, ErrPasswordReused) {
+		t.Fatalf("reusing the current password = %v, want ErrPasswordReused", err)
+	}
+	if err := change("wrong-password", "Another123x"); !errors.Is(err, ErrInvalidCurrentPassword) {
+		t.Fatalf("wrong current password = %v, want ErrInvalidCurrentPassword", err)
+	}
+	if err := change(testPassword, "Second123x"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if err := s.ValidateSession(ctx, user.ID, sessionOf(t, s, old.AccessToken)); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("session before the change = %v, want ErrSessionRevoked", err)
+	}
+	if err := change("Second123x", "Third1234x"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	// The last three passwords, the current one included, cannot be reused
+	if err := change("Third1234x", testPassword); !errors.Is(err, ErrPasswordReused) {
+		t.Fatalf("reusing a recent password = %v, want ErrPasswordReused", err)
+	}
+	if err := change("Third1234x", "Fourth123x"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if err := change("Fourth123x", testPassword); err != nil {
+		t.Errorf("a password older than the history = %v, want accepted", err)
+	}
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/weibh/openClusterClaw/internal/adapter"
+	"github.com/weibh/openClusterClaw/internal/domain"
+	"github.com/weibh/openClusterClaw/internal/model"
+)
+
+// ErrInvalidTemplateVariable is returned when a template declares an unusable variable
+var ErrInvalidTemplateVariable = errors.New("invalid template variable")
+
+// Template variable types
+const (
+	VariableTypeString  = "string"
+	VariableTypeNumber  = "number"
+	VariableTypeInteger = "integer"
+	VariableTypeBoolean = "boolean"
+	VariableTypeArray   = "array"
+	VariableTypeObject  = "object"
+)
+
+// redactedValue replaces secret values in API responses
+const redactedValue = "******"
+
+// instanceConfigRecord is the resolved configuration persisted in ClawInstance.Config
+type instanceConfigRecord struct {
+	TemplateID      string `json:"template_id,omitempty"`
+	TemplateName    string `json:"template_name,omitempty"`
+	TemplateVersion string `json:"template_version,omitempty"`
+	// TemplateRevision pins the template revision the config is rendered from
+	TemplateRevision int `json:"template_revision,omitempty"`
+	// Overrides are the values supplied by the user
+	Overrides map[string]string `json:"overrides,omitempty"`
+	// Values are the template variables and overrides after defaults were applied, keyed by config path
+	Values map[string]string `json:"values,omitempty"`
+	// SecretPaths lists the value paths that must not be returned by the API
+	SecretPaths []string `json:"secret_paths,omitempty"`
+	// Variables lists the shared variables referenced by the template and overrides
+	Variables []string              `json:"variables,omitempty"`
+	Resolved  adapter.UnifiedConfig `json:"resolved"`
+	// Sealed holds the values of SecretPaths and the resolved config encrypted when the record
+	// has secret paths; at rest they are left out of Values and Resolved. See sealRecord.
+	Sealed string `json:"sealed,omitempty"`
+}
+
+// templateBinding is the outcome of resolving a template against supplied values
+type templateBinding struct {
+	template *model.ConfigTemplate
+	revision int
+	values   map[string]string
+	secrets  []string
+	errors   []adapter.FieldError
+	// variables records the shared variables referenced while binding
+	variables map[string]bool
+}
+
+// validateTemplateVariables checks variable definitions before a template is saved
+func validateTemplateVariables(adapterType string, variables []TemplateVariable) error {
+	adp, _ := adapter.CreateByString(adapterType)
+
+	seen := make(map[string]bool, len(variables))
+	for _, v := range variables {
+		if strings.TrimSpace(v.Name) == "" {
+			return fmt.Errorf("%w: name is required", ErrInvalidTemplateVariable)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("%w: duplicate variable %s", ErrInvalidTemplateVariable, v.Name)
+		}
+		seen[v.Name] = true
+
+		if !isKnownVariableType(v.Type) {
+			return fmt.Errorf("%w: %s has unknown type %q", ErrInvalidTemplateVariable, v.Name, v.Type)
+		}
+		if v.hasDefault() {
+			value, err := formatVariableValue(v.Default)
+			if err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrInvalidTemplateVariable, v.Name, err)
+			}
+			if err := checkVariableType(v.Type, value); err != nil {
+				return fmt.Errorf("%w: default of %s: %v", ErrInvalidTemplateVariable, v.Name, err)
+			}
+		}
+
+		// Variable names are config paths; probe the adapter defaults so unknown paths fail early
+		if adp != nil {
+			probe := adp.GetDefaultConfig()
+			if err := adapter.ApplyOverride(&probe, v.Name, zeroVariableValue(v.Type)); errors.Is(err, adapter.ErrUnknownConfigPath) {
+				return fmt.Errorf("%w: %v", ErrInvalidTemplateVariable, err)
+			}
+		}
+	}
+	return nil
+}
+
+// bindTemplate resolves the named template and validates overrides against its variables.
+// Composed templates are bound with the variables merged from their parent and fragments.
+// A non-zero revision pins that template revision, otherwise the latest one is used.
+// Overrides for paths the template does not declare are passed through unchanged.
+// ${var.NAME} references in overrides and defaults are resolved from vars before values are type checked.
+func (s *instanceService) bindTemplate(ctx context.Context, templateName string, revision int, adapterType string, overrides map[string]string, vars variableSet) (*templateBinding, error) {
+	binding := &templateBinding{values: make(map[string]string)}
+
+	var variables []TemplateVariable
+	if templateName != "" {
+		template, err := s.templateRepo.GetByName(ctx, templateName)
+		if err != nil {
+			return nil, ErrConfigTemplateNotFound
+		}
+
+		if templateKind(template) == TemplateKindFragment {
+			return nil, ErrTemplateIsFragment
+		}
+
+		variablesJSON := effectiveVariables(template.Variables, template.ResolvedVariables)
+		version := template.Version
+		if revision > 0 && revision != template.Revision {
+			rev, err := s.revisionRepo.Get(ctx, template.ID, revision)
+			if err != nil {
+				return nil, ErrTemplateRevisionNotFound
+			}
+			variablesJSON = effectiveVariables(rev.Variables, rev.ResolvedVariables)
+			version = rev.Version
+			adapterType = rev.AdapterType
+		}
+		if revision == 0 {
+			revision = template.Revision
+		}
+
+		if template.AdapterType != adapterType {
+			return nil, ErrTemplateAdapterMismatch
+		}
+		if len(variablesJSON) > 0 {
+			if err := json.Unmarshal(variablesJSON, &variables); err != nil {
+				return nil, fmt.Errorf("failed to parse template variables: %w", err)
+			}
+		}
+
+		pinned := *template
+		pinned.Version = version
+		binding.template = &pinned
+		binding.revision = revision
+	}
+
+	declared := make(map[string]bool, len(variables))
+	for _, v := range variables {
+		declared[v.Name] = true
+		if v.Secret {
+			binding.secrets = append(binding.secrets, v.Name)
+		}
+
+		value, supplied := overrides[v.Name]
+		if !supplied && v.hasDefault() {
+			formatted, err := formatVariableValue(v.Default)
+			if err != nil {
+				binding.errors = append(binding.errors, adapter.FieldError{Field: v.Name, Message: err.Error()})
+				continue
+			}
+			value, supplied = formatted, true
+		}
+		if supplied {
+			expanded, ok := binding.expandVariables(v.Name, value, vars)
+			if !ok {
+				continue
+			}
+			value = expanded
+		}
+		if !supplied || (v.Required && value == "") {
+			if v.Required {
+				binding.errors = append(binding.errors, adapter.FieldError{Field: v.Name, Message: "value is required"})
+			}
+			continue
+		}
+		if err := checkVariableType(v.Type, value); err != nil {
+			binding.errors = append(binding.errors, adapter.FieldError{Field: v.Name, Message: err.Error()})
+			continue
+		}
+		binding.values[v.Name] = value
+	}
+
+	for path, value := range overrides {
+		if !declared[path] {
+			if expanded, ok := binding.expandVariables(path, value, vars); ok {
+				binding.values[path] = expanded
+			}
+		}
+	}
+
+	return binding, nil
+}
+
+// hasDefault reports whether the variable declares a default; an empty string counts as none
+func (v TemplateVariable) hasDefault() bool {
+	if s, ok := v.Default.(string); ok {
+		return s != ""
+	}
+	return v.Default != nil
+}
+
+// isKnownVariableType reports whether t is a supported variable type
+func isKnownVariableType(t string) bool {
+	switch t {
+	case VariableTypeString, VariableTypeNumber, VariableTypeInteger, VariableTypeBoolean, VariableTypeArray, VariableTypeObject:
+		return true
+	}
+	return false
+}
+
+// checkVariableType verifies that the string form of a value matches the declared type
+func checkVariableType(t, value string) error {
+	trimmed := strings.TrimSpace(value)
+	switch t {
+	case VariableTypeString:
+		return nil
+	case VariableTypeNumber:
+		if _, err := strconv.ParseFloat(trimmed, 64); err != nil {
+			return fmt.Errorf("expected number, got %q", value)
+		}
+	case VariableTypeInteger:
+		if _, err := strconv.ParseInt(trimmed, 10, 64); err != nil {
+			return fmt.Errorf("expected integer, got %q", value)
+		}
+	case VariableTypeBoolean:
+		if _, err := strconv.ParseBool(trimmed); err != nil {
+			return fmt.Errorf("expected boolean, got %q", value)
+		}
+	case VariableTypeArray:
+		// Comma-separated lists are accepted for string arrays
+		if strings.HasPrefix(trimmed, "[") {
+			var decoded []interface{}
+			if err := json.Unmarshal([]byte(trimmed), &decoded); err != nil {
+				return fmt.Errorf("expected JSON array, got %q", value)
+			}
+		}
+	case VariableTypeObject:
+		var decoded map[string]interface{}
+		if err := json.Unmarshal([]byte(trimmed), &decoded); err != nil {
+			return fmt.Errorf("expected JSON object, got %q", value)
+		}
+	default:
+		return fmt.Errorf("unknown variable type %q", t)
+	}
+	return nil
+}
+
+// formatVariableValue converts a JSON-decoded default into the string form used by overrides
+func formatVariableValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case []interface{}, map[string]interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode default: %w", err)
+		}
+		return string(encoded), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// zeroVariableValue returns a value of the given type used to probe config paths
+func zeroVariableValue(t string) string {
+	switch t {
+	case VariableTypeNumber, VariableTypeInteger:
+		return "0"
+	case VariableTypeBoolean:
+		return "false"
+	case VariableTypeArray:
+		return "[]"
+	case VariableTypeObject:
+		return "{}"
+	default:
+		return ""
+	}
+}
+
+// decodeInstanceConfig restores the persisted configuration of an instance without its sealed
+// secrets, which is enough to read the template, overrides and referenced variables.
+// Use instanceService.openInstanceConfig for the secret values and the resolved config.
+func decodeInstanceConfig(data []byte) (*instanceConfigRecord, error) {
+	record := &instanceConfigRecord{}
+	if len(data) == 0 {
+		return record, nil
+	}
+	if err := json.Unmarshal(data, record); err != nil {
+		return nil, fmt.Errorf("failed to parse instance config: %w", err)
+	}
+	return record, nil
+}
+
+// toDomain returns the API view of the record with secret values redacted
+func (r *instanceConfigRecord) toDomain(schema *adapter.JSONSchema) *domain.InstanceConfig {
+	secret := make(map[string]bool, len(r.SecretPaths))
+	for _, p := range r.SecretPaths {
+		secret[p] = true
+	}
+	isSecret := func(path string) bool {
+		if secret[path] {
+			return true
+		}
+		if schema != nil {
+			if prop := schema.Property(path); prop != nil && prop.WriteOnly {
+				return true
+			}
+		}
+		return false
+	}
+	redact := func(values map[string]string) map[string]string {
+		if values == nil {
+			return nil
+		}
+		out := make(map[string]string, len(values))
+		for path, value := range values {
+			if isSecret(path) && value != "" {
+				value = redactedValue
+			}
+			out[path] = value
+		}
+		return out
+	}
+
+	return &domain.InstanceConfig{
+		TemplateName:     r.TemplateName,
+		TemplateRevision: r.TemplateRevision,
+		TemplateVersion:  r.TemplateVersion,
+		Overrides:        redact(r.Overrides),
+		Values:           redact(r.Values),
+	}
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
+)
+
+// Config template kinds
+const (
+	TemplateKindTemplate = "template"
+	TemplateKindFragment = "fragment"
+)
+
+var (
+	ErrInvalidTemplateReference = errors.New("invalid template reference")
+	ErrTemplateCycle            = errors.New("template inheritance cycle")
+	ErrTemplateConflict         = errors.New("template variable conflict")
+	ErrTemplateInUse            = errors.New("config template is used by other templates")
+	ErrTemplateIsFragment       = errors.New("config template is a fragment")
+)
+
+// templateKind returns the kind of a template; templates created before kinds existed are full templates
+func templateKind(template *model.ConfigTemplate) string {
+	if template.Kind == "" {
+		return TemplateKindTemplate
+	}
+	return template.Kind
+}
+
+// isComposed reports whether a template inherits from a parent or includes fragments
+func isComposed(template *model.ConfigTemplate) bool {
+	return template.ParentID != "" || len(template.Fragments) > 0
+}
+
+// templateFragments decodes the fragment IDs of a template
+func templateFragments(data []byte) ([]string, error) {
+	var ids []string
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &ids); err != nil {
+			return nil, fmt.Errorf("failed to parse template fragments: %w", err)
+		}
+	}
+	return ids, nil
+}
+
+// encodeFragments stores fragment IDs, dropping the column when there are none
+func encodeFragments(ids []string) ([]byte, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return nil, fmt.Errorf("%w: fragment %s is listed twice", ErrInvalidTemplateReference, id)
+		}
+		seen[id] = true
+	}
+	return json.Marshal(ids)
+}
+
+// decodeTemplateVariables parses stored template variables
+func decodeTemplateVariables(data []byte) ([]TemplateVariable, error) {
+	var variables []TemplateVariable
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &variables); err != nil {
+			return nil, fmt.Errorf("failed to parse template variables: %w", err)
+		}
+	}
+	return variables, nil
+}
+
+// effectiveVariables returns the variables instances are rendered from
+func effectiveVariables(variables, resolved []byte) []byte {
+	if len(resolved) > 0 {
+		return resolved
+	}
+	return variables
+}
+
+// templateResolver merges templates with their parents and fragments.
+// Templates in pending are being saved and shadow the stored versions.
+type templateResolver struct {
+	repo     repository.ConfigTemplateRepository
+	pending  map[string]*model.ConfigTemplate
+	visiting map[string]bool
+}
+
+func newTemplateResolver(repo repository.ConfigTemplateRepository, pending ...*model.ConfigTemplate) *templateResolver {
+	r := &templateResolver{
+		repo:     repo,
+		pending:  make(map[string]*model.ConfigTemplate, len(pending)),
+		visiting: make(map[string]bool),
+	}
+	for _, t := range pending {
+		r.pending[t.ID] = t
+	}
+	return r
+}
+
+// compose resolves a template and stores the merged variables on it
+func (r *templateResolver) compose(ctx context.Context, template *model.ConfigTemplate) error {
+	variables, err := r.resolve(ctx, template)
+	if err != nil {
+		return err
+	}
+	if err := validateTemplateVariables(template.AdapterType, variables); err != nil {
+		return err
+	}
+
+	template.ResolvedVariables = nil
+	if isComposed(template) {
+		resolved, err := json.Marshal(variables)
+		if err != nil {
+			return fmt.Errorf("failed to marshal resolved variables: %w", err)
+		}
+		template.ResolvedVariables = resolved
+	}
+	return nil
+}
+
+// resolve merges a template in a fixed order: the parent's resolved variables first,
+// then each fragment in the order listed, then the template's own variables.
+// Later layers may override a variable of the same type. Two fragments defining
+// the same or overlapping paths differently is a conflict.
+func (r *templateResolver) resolve(ctx context.Context, template *model.ConfigTemplate) ([]TemplateVariable, error) {
+	if r.visiting[template.ID] {
+		return nil, fmt.Errorf("%w: %s refers back to itself", ErrTemplateCycle, template.Name)
+	}
+	r.visiting[template.ID] = true
+	defer delete(r.visiting, template.ID)
+
+	merge := newVariableMerge()
+
+	if template.ParentID != "" {
+		if templateKind(template) == TemplateKindFragment {
+			return nil, fmt.Errorf("%w: fragment %s cannot have a parent", ErrInvalidTemplateReference, template.Name)
+		}
+		parent, err := r.reference(ctx, template, template.ParentID, TemplateKindTemplate)
+		if err != nil {
+			return nil, err
+		}
+		variables, err := r.resolve(ctx, parent)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range variables {
+			if err := merge.override(v, "parent "+parent.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	fragmentIDs, err := templateFragments(template.Fragments)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range fragmentIDs {
+		fragment, err := r.reference(ctx, template, id, TemplateKindFragment)
+		if err != nil {
+			return nil, err
+		}
+		variables, err := r.resolve(ctx, fragment)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range variables {
+			if err := merge.include(v, "fragment "+fragment.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	own, err := decodeTemplateVariables(template.Variables)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range own {
+		if err := merge.override(v, template.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return merge.variables, nil
+}
+
+// reference loads a template referenced by another and checks that it can be composed
+func (r *templateResolver) reference(ctx context.Context, from *model.ConfigTemplate, id, kind string) (*model.ConfigTemplate, error) {
+	target, ok := r.pending[id]
+	if !ok {
+		var err error
+		target, err = r.repo.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s refers to unknown template %s", ErrInvalidTemplateReference, from.Name, id)
+		}
+	}
+
+	if r.visiting[target.ID] {
+		return nil, fmt.Errorf("%w: %s and %s refer to each other", ErrTemplateCycle, from.Name, target.Name)
+	}
+	if templateKind(target) != kind {
+		return nil, fmt.Errorf("%w: %s is a %s and cannot be used as a %s", ErrInvalidTemplateReference, target.Name, templateKind(target), kind)
+	}
+	if target.AdapterType != from.AdapterType {
+		return nil, fmt.Errorf("%w: %s is for adapter %s, not %s", ErrInvalidTemplateReference, target.Name, target.AdapterType, from.AdapterType)
+	}
+	return target, nil
+}
+
+// variableMerge accumulates variables in merge order
+type variableMerge struct {
+	variables []TemplateVariable
+	index     map[string]int
+	source    map[string]string
+	// fragments records which fragment defined a path within the current template
+	fragments map[string]string
+}
+
+func newVariableMerge() *variableMerge {
+	return &variableMerge{
+		index:     make(map[string]int),
+		source:    make(map[string]string),
+		fragments: make(map[string]string),
+	}
+}
+
+// override sets a variable, replacing an earlier definition of the same type
+func (m *variableMerge) override(v TemplateVariable, source string) error {
+	if i, ok := m.index[v.Name]; ok {
+		if m.variables[i].Type != v.Type {
+			return fmt.Errorf("%w: %s is %s in %s but %s in %s", ErrTemplateConflict, v.Name, m.variables[i].Type, m.source[v.Name], v.Type, source)
+		}
+		m.variables[i] = v
+		m.source[v.Name] = source
+		return nil
+	}
+	m.index[v.Name] = len(m.variables)
+	m.variables = append(m.variables, v)
+	m.source[v.Name] = source
+	return nil
+}
+
+// include merges a fragment variable. Fragments may override the parent but not each other,
+// unless both define the variable identically, as happens when they share a fragment.
+func (m *variableMerge) include(v TemplateVariable, source string) error {
+	for path, other := range m.fragments {
+		if other == source || !pathsOverlap(path, v.Name) {
+			continue
+		}
+		if path != v.Name {
+			return fmt.Errorf("%w: %s in %s overlaps %s in %s", ErrTemplateConflict, v.Name, source, path, other)
+		}
+		if !reflect.DeepEqual(m.variables[m.index[path]], v) {
+			return fmt.Errorf("%w: %s is defined differently by %s and %s", ErrTemplateConflict, v.Name, other, source)
+		}
+	}
+	if err := m.override(v, source); err != nil {
+		return err
+	}
+	m.fragments[v.Name] = source
+	return nil
+}
+
+// pathsOverlap reports whether two config paths are equal or one contains the other
+func pathsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// templateChange is a template whose content changed because of a template it is composed from
+type templateChange struct {
+	previous *model.ConfigTemplate
+	next     *model.ConfigTemplate
+}
+
+// recomposeDependents re-resolves every template that directly or transitively inherits from
+// or includes changed, without saving anything. It fails if the change breaks any of them.
+func (s *configTemplateService) recomposeDependents(ctx context.Context, changed *model.ConfigTemplate) ([]templateChange, error) {
+	resolver := newTemplateResolver(s.templateRepo, changed)
+	seen := map[string]bool{changed.ID: true}
+	queue := []string{changed.ID}
+
+	var changes []templateChange
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		dependents, err := s.templateRepo.ListDependents(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, dependent := range dependents {
+			if seen[dependent.ID] {
+				continue
+			}
+			seen[dependent.ID] = true
+			queue = append(queue, dependent.ID)
+
+			next := *dependent
+			if err := resolver.compose(ctx, &next); err != nil {
+				return nil, fmt.Errorf("dependent template %s: %w", dependent.Name, err)
+			}
+			if templateContentHash(&next) != dependent.ContentHash {
+				changes = append(changes, templateChange{previous: dependent, next: &next})
+			}
+		}
+	}
+	return changes, nil
+}
@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/weibh/openClusterClaw/internal/model"
 	"github.com/weibh/openClusterClaw/internal/repository"
@@ -14,7 +16,9 @@ var (
 	ErrTenantNameExists   = errors.New("tenant name already exists")
 	ErrProjectNotFound    = errors.New("project not found")
 	ErrProjectNameExists  = errors.New("project name already exists")
-	ErrTenantHasInstances = errors.New("tenant has instances and cannot be deleted")
+	// ErrTenantInactive is returned when a suspended or archived tenant tries to use resources
+	ErrTenantInactive          = errors.New("tenant is suspended or archived")
+	ErrInvalidTenantTransition = errors.New("tenant cannot change to the requested status")
 )
 
 // TenantService defines the business logic for tenant management
@@ -24,6 +28,13 @@ type TenantService interface {
 	ListTenants(ctx context.Context, page, pageSize int) ([]*model.Tenant, int, error)
 	UpdateTenant(ctx context.Context, id string, req *UpdateTenantRequest) (*model.Tenant, error)
 	DeleteTenant(ctx context.Context, id string) error
+	GetTenantUsage(ctx context.Context, id string) (*TenantUsage, error)
+	// SuspendTenant stops the tenant's running instances and locks its users out
+	SuspendTenant(ctx context.Context, id, reason string) (*model.Tenant, error)
+	// ArchiveTenant is SuspendTenant without bringing any instances back on resume
+	ArchiveTenant(ctx context.Context, id, reason string) (*model.Tenant, error)
+	// ResumeTenant reactivates the tenant and starts the instances stopped by its suspension
+	ResumeTenant(ctx context.Context, id string) (*model.Tenant, error)
 }
 
 // CreateTenantRequest represents the request to create a tenant
@@ -46,15 +57,21 @@ type UpdateTenantRequest struct {
 
 // tenantService implements TenantService
 type tenantService struct {
-	tenantRepo  repository.TenantRepository
+	tenantRepo   repository.TenantRepository
+	projectRepo  repository.ProjectRepository
 	instanceRepo repository.InstanceRepository
+	instances    InstanceService
+	deletions    DeletionService
 }
 
 // NewTenantService creates a new tenant service
-func NewTenantService(tenantRepo repository.TenantRepository, instanceRepo repository.InstanceRepository) TenantService {
+func NewTenantService(tenantRepo repository.TenantRepository, projectRepo repository.ProjectRepository, instanceRepo repository.InstanceRepository, instances InstanceService, deletions DeletionService) TenantService {
 	return &tenantService{
-		tenantRepo:  tenantRepo,
+		tenantRepo:   tenantRepo,
+		projectRepo:  projectRepo,
 		instanceRepo: instanceRepo,
+		instances:    instances,
+		deletions:    deletions,
 	}
 }
 
@@ -71,6 +88,9 @@ func (s *tenantService) CreateTenant(ctx context.Context, req *CreateTenantReque
 		MaxMemory:   req.MaxMemory,
 		MaxStorage:  req.MaxStorage,
 	}
+	if err := validateLimits(tenantLimits(tenant)); err != nil {
+		return nil, err
+	}
 
 	if err := s.tenantRepo.Create(ctx, tenant); err != nil {
 		return nil, fmt.Errorf("failed to create tenant: %w", err)
@@ -132,6 +152,17 @@ func (s *tenantService) UpdateTenant(ctx context.Context, id string, req *Update
 	if req.MaxStorage != nil {
 		tenant.MaxStorage = *req.MaxStorage
 	}
+	if err := validateLimits(tenantLimits(tenant)); err != nil {
+		return nil, err
+	}
+	// Lowering a limit below what is allocated to projects would let them exceed the tenant
+	projects, err := s.projectRepo.ListByTenant(ctx, tenant.ID, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateAllocation(tenant, projects); err != nil {
+		return nil, err
+	}
 
 	if err := s.tenantRepo.Update(ctx, tenant); err != nil {
 		return nil, fmt.Errorf("failed to update tenant: %w", err)
@@ -146,10 +177,13 @@ func (s *tenantService) DeleteTenant(ctx context.Context, id string) error {
 		return ErrTenantNotFound
 	}
 
-	// Check if tenant has instances
-	instances, err := s.instanceRepo.List(ctx, tenant.ID, "", 1, 0)
-	if err == nil && len(instances) > 0 {
-		return ErrTenantHasInstances
+	// Refuse while the tenant owns anything; cascading deletion goes through DeletionService
+	report, err := s.deletions.TenantDependencies(ctx, tenant.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check tenant dependencies: %w", err)
+	}
+	if !report.Empty() {
+		return &DependencyError{Kind: model.DeletionKindTenant, ID: tenant.ID, Report: report}
 	}
 
 	if err := s.tenantRepo.Delete(ctx, id); err != nil {
@@ -159,6 +193,173 @@ func (s *tenantService) DeleteTenant(ctx context.Context, id string) error {
 	return nil
 }
 
+// GetTenantUsage reports the resources used by a tenant's instances against its quota
+func (s *tenantService) GetTenantUsage(ctx context.Context, id string) (*TenantUsage, error) {
+	tenant, err := s.tenantRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrTenantNotFound
+	}
+
+	instances, err := s.instanceRepo.ListByFilter(ctx, repository.InstanceFilter{TenantID: tenant.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenant instances: %w", err)
+	}
+	projects, err := s.projectRepo.ListByTenant(ctx, tenant.ID, -1, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &TenantUsage{
+		TenantID:      tenant.ID,
+		ResourceUsage: usageReport(tenantLimits(tenant), sumUsage(instances, "")),
+	}
+	byProject := make(map[string][]*model.ClawInstance)
+	for _, instance := range instances {
+		byProject[instance.ProjectID] = append(byProject[instance.ProjectID], instance)
+	}
+	for _, project := range projects {
+		usage.Projects = append(usage.Projects, &ProjectUsage{
+			ProjectID:     project.ID,
+			Name:          project.Name,
+			ResourceUsage: usageReport(projectLimits(project), sumUsage(byProject[project.ID], "")),
+		})
+	}
+	return usage, nil
+}
+
+func (s *tenantService) SuspendTenant(ctx context.Context, id, reason string) (*model.Tenant, error) {
+	return s.deactivate(ctx, id, model.TenantSuspended, reason)
+}
+
+func (s *tenantService) ArchiveTenant(ctx context.Context, id, reason string) (*model.Tenant, error) {
+	return s.deactivate(ctx, id, model.TenantArchived, reason)
+}
+
+// deactivate moves a tenant to a suspended or archived status and stops its running and
+// creating instances.
+// The status changes first so that quota admission rejects new instances and starts meanwhile.
+// Suspending a suspended tenant again retries instances that failed to stop.
+func (s *tenantService) deactivate(ctx context.Context, id string, status model.TenantStatus, reason string) (*model.Tenant, error) {
+	tenant, err := s.tenantRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrTenantNotFound
+	}
+	if tenant.Status == model.TenantArchived && status == model.TenantSuspended {
+		return nil, ErrInvalidTenantTransition
+	}
+
+	// Instances stopped by an earlier suspension are still owed a restart
+	restore, err := suspendedInstances(tenant)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tenant.Status = status
+	tenant.StatusReason = reason
+	tenant.StatusChangedAt = &now
+	if err := s.tenantRepo.UpdateStatus(ctx, tenant); err != nil {
+		return nil, err
+	}
+
+	instances, err := s.instanceRepo.ListByFilter(ctx, repository.InstanceFilter{TenantID: tenant.ID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tenant instances: %w", err)
+	}
+	var errs []error
+	for _, instance := range instances {
+		// Creating instances would otherwise come up running once their pod is ready
+		if instance.Status != model.StatusRunning && instance.Status != model.StatusCreating {
+			continue
+		}
+		if err := s.instances.StopInstance(ctx, instance.ID); err != nil {
+			errs = append(errs, fmt.Errorf("instance %s: %w", instance.ID, err))
+			continue
+		}
+		restore = append(restore, instance.ID)
+	}
+
+	// An archived tenant comes back with all of its instances stopped
+	if status == model.TenantArchived {
+		restore = nil
+	}
+	if err := setSuspendedInstances(tenant, restore); err != nil {
+		return nil, err
+	}
+	if err := s.tenantRepo.UpdateStatus(ctx, tenant); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to stop tenant instances: %w", errors.Join(errs...))
+	}
+	return tenant, nil
+}
+
+func (s *tenantService) ResumeTenant(ctx context.Context, id string) (*model.Tenant, error) {
+	tenant, err := s.tenantRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrTenantNotFound
+	}
+	if tenant.IsActive() {
+		return nil, ErrInvalidTenantTransition
+	}
+
+	restore, err := suspendedInstances(tenant)
+	if err != nil {
+		return nil, err
+	}
+	// The tenant must be active again before its instances pass quota admission
+	now := time.Now()
+	tenant.Status = model.TenantActive
+	tenant.StatusReason = ""
+	tenant.StatusChangedAt = &now
+	tenant.SuspendedInstances = nil
+	if err := s.tenantRepo.UpdateStatus(ctx, tenant); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, instanceID := range restore {
+		// Skip instances deleted or started by hand while the tenant was suspended
+		instance, err := s.instanceRepo.GetByID(ctx, instanceID)
+		if err != nil || instance.Status != model.StatusStopped {
+			continue
+		}
+		if err := s.instances.StartInstance(ctx, instanceID); err != nil {
+			errs = append(errs, fmt.Errorf("instance %s: %w", instanceID, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("tenant resumed but instances failed to start: %w", errors.Join(errs...))
+	}
+	return tenant, nil
+}
+
+// suspendedInstances decodes the IDs of the instances a tenant's suspension stopped
+func suspendedInstances(tenant *model.Tenant) ([]string, error) {
+	if len(tenant.SuspendedInstances) == 0 {
+		return nil, nil
+	}
+	var ids []string
+	if err := json.Unmarshal(tenant.SuspendedInstances, &ids); err != nil {
+		return nil, fmt.Errorf("failed to decode suspended instances: %w", err)
+	}
+	return ids, nil
+}
+
+// setSuspendedInstances stores the IDs of the instances to start when the tenant resumes
+func setSuspendedInstances(tenant *model.Tenant, ids []string) error {
+	if len(ids) == 0 {
+		tenant.SuspendedInstances = nil
+		return nil
+	}
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return fmt.Errorf("failed to encode suspended instances: %w", err)
+	}
+	tenant.SuspendedInstances = data
+	return nil
+}
+
 // ProjectService defines the business logic for project management
 type ProjectService interface {
 	CreateProject(ctx context.Context, req *CreateProjectRequest) (*model.Project, error)
@@ -166,35 +367,70 @@ type ProjectService interface {
 	ListProjects(ctx context.Context, tenantID string, page, pageSize int) ([]*model.Project, int, error)
 	UpdateProject(ctx context.Context, id string, req *UpdateProjectRequest) (*model.Project, error)
 	DeleteProject(ctx context.Context, id string) error
+	GetProjectUsage(ctx context.Context, id string) (*ProjectUsage, error)
 }
 
-// CreateProjectRequest represents the request to create a project
+// CreateProjectRequest represents the request to create a project.
+// Limits are allocated out of the tenant quota; defaults apply to instances created without resources.
 type CreateProjectRequest struct {
-	TenantID string `json:"tenant_id" binding:"required"`
-	Name     string `json:"name" binding:"required"`
+	TenantID       string `json:"tenant_id" binding:"required"`
+	Name           string `json:"name" binding:"required"`
+	MaxInstances   int    `json:"max_instances"`
+	MaxCPU         string `json:"max_cpu"`
+	MaxMemory      string `json:"max_memory"`
+	MaxStorage     string `json:"max_storage"`
+	DefaultCPU     string `json:"default_cpu"`
+	DefaultMemory  string `json:"default_memory"`
+	DefaultStorage string `json:"default_storage"`
 }
 
 // UpdateProjectRequest represents the request to update a project
 type UpdateProjectRequest struct {
-	Name *string `json:"name"`
+	Name           *string `json:"name"`
+	MaxInstances   *int    `json:"max_instances"`
+	MaxCPU         *string `json:"max_cpu"`
+	MaxMemory      *string `json:"max_memory"`
+	MaxStorage     *string `json:"max_storage"`
+	DefaultCPU     *string `json:"default_cpu"`
+	DefaultMemory  *string `json:"default_memory"`
+	DefaultStorage *string `json:"default_storage"`
 }
 
 // projectService implements ProjectService
 type projectService struct {
-	projectRepo repository.ProjectRepository
+	projectRepo  repository.ProjectRepository
+	tenantRepo   repository.TenantRepository
+	instanceRepo repository.InstanceRepository
+	deletions    DeletionService
 }
 
 // NewProjectService creates a new project service
-func NewProjectService(projectRepo repository.ProjectRepository) ProjectService {
+func NewProjectService(projectRepo repository.ProjectRepository, tenantRepo repository.TenantRepository, instanceRepo repository.InstanceRepository, deletions DeletionService) ProjectService {
 	return &projectService{
-		projectRepo: projectRepo,
+		projectRepo:  projectRepo,
+		tenantRepo:   tenantRepo,
+		instanceRepo: instanceRepo,
+		deletions:    deletions,
 	}
 }
 
 func (s *projectService) CreateProject(ctx context.Context, req *CreateProjectRequest) (*model.Project, error) {
+	if !repository.InTenantScope(ctx, req.TenantID) {
+		return nil, ErrTenantAccessDenied
+	}
 	project := &model.Project{
-		TenantID: req.TenantID,
-		Name:     req.Name,
+		TenantID:       req.TenantID,
+		Name:           req.Name,
+		MaxInstances:   req.MaxInstances,
+		MaxCPU:         req.MaxCPU,
+		MaxMemory:      req.MaxMemory,
+		MaxStorage:     req.MaxStorage,
+		DefaultCPU:     req.DefaultCPU,
+		DefaultMemory:  req.DefaultMemory,
+		DefaultStorage: req.DefaultStorage,
+	}
+	if err := s.validateQuota(ctx, project); err != nil {
+		return nil, err
 	}
 
 	if err := s.projectRepo.Create(ctx, project); err != nil {
@@ -213,6 +449,12 @@ func (s *projectService) GetProject(ctx context.Context, id string) (*model.Proj
 }
 
 func (s *projectService) ListProjects(ctx context.Context, tenantID string, page, pageSize int) ([]*model.Project, int, error) {
+	if tenantID == "" {
+		tenantID, _ = repository.TenantScope(ctx)
+	}
+	if !repository.InTenantScope(ctx, tenantID) {
+		return nil, 0, ErrTenantAccessDenied
+	}
 	offset := (page - 1) * pageSize
 
 	if tenantID != "" {
@@ -237,6 +479,30 @@ func (s *projectService) UpdateProject(ctx context.Context, id string, req *Upda
 	if req.Name != nil {
 		project.Name = *req.Name
 	}
+	if req.MaxInstances != nil {
+		project.MaxInstances = *req.MaxInstances
+	}
+	if req.MaxCPU != nil {
+		project.MaxCPU = *req.MaxCPU
+	}
+	if req.MaxMemory != nil {
+		project.MaxMemory = *req.MaxMemory
+	}
+	if req.MaxStorage != nil {
+		project.MaxStorage = *req.MaxStorage
+	}
+	if req.DefaultCPU != nil {
+		project.DefaultCPU = *req.DefaultCPU
+	}
+	if req.DefaultMemory != nil {
+		project.DefaultMemory = *req.DefaultMemory
+	}
+	if req.DefaultStorage != nil {
+		project.DefaultStorage = *req.DefaultStorage
+	}
+	if err := s.validateQuota(ctx, project); err != nil {
+		return nil, err
+	}
 
 	if err := s.projectRepo.Update(ctx, project); err != nil {
 		return nil, fmt.Errorf("failed to update project: %w", err)
@@ -246,13 +512,68 @@ func (s *projectService) UpdateProject(ctx context.Context, id string, req *Upda
 }
 
 func (s *projectService) DeleteProject(ctx context.Context, id string) error {
-	if _, err := s.projectRepo.GetByID(ctx, id); err != nil {
+	project, err := s.projectRepo.GetByID(ctx, id)
+	if err != nil {
 		return ErrProjectNotFound
 	}
 
+	// Refuse while instances, variables or members reference the project
+	report, err := s.deletions.ProjectDependencies(ctx, project.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check project dependencies: %w", err)
+	}
+	if !report.Empty() {
+		return &DependencyError{Kind: model.DeletionKindProject, ID: project.ID, Report: report}
+	}
+
 	if err := s.projectRepo.Delete(ctx, id); err != nil {
 		return fmt.Errorf("failed to delete project: %w", err)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// GetProjectUsage reports the resources used by a project's instances against its allocation
+func (s *projectService) GetProjectUsage(ctx context.Context, id string) (*ProjectUsage, error) {
+	project, err := s.projectRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrProjectNotFound
+	}
+
+	used, err := listUsage(ctx, s.instanceRepo, repository.InstanceFilter{ProjectID: project.ID}, "")
+	if err != nil {
+		return nil, err
+	}
+	return &ProjectUsage{
+		ProjectID:     project.ID,
+		Name:          project.Name,
+		ResourceUsage: usageReport(projectLimits(project), used),
+	}, nil
+}
+
+// validateQuota checks the limits and defaults of a project and that, together with the
+// tenant's other projects, its limits fit into the tenant quota
+func (s *projectService) validateQuota(ctx context.Context, project *model.Project) error {
+	if err := validateLimits(projectLimits(project)); err != nil {
+		return err
+	}
+	if err := validateProjectDefaults(project); err != nil {
+		return err
+	}
+
+	tenant, err := s.tenantRepo.GetByID(ctx, project.TenantID)
+	if err != nil {
+		return ErrTenantNotFound
+	}
+	siblings, err := s.projectRepo.ListByTenant(ctx, tenant.ID, -1, -1)
+	if err != nil {
+		return err
+	}
+	projects := []*model.Project{project}
+	for _, p := range siblings {
+		if p.ID != project.ID {
+			projects = append(projects, p)
+		}
+	}
+	return validateAllocation(tenant, projects)
+}
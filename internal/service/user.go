@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/repository"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUserNotFound           = errors.New("user not found")
+	ErrUsernameExists         = errors.New("username already exists")
+	ErrInvalidUserRole        = errors.New("invalid user role")
+	ErrInvalidCurrentPassword = errors.New("current password is incorrect")
+)
+
+// UpdateUserRequest changes the role or tenant of a user; nil fields are left unchanged
+type UpdateUserRequest struct {
+	Role     *model.UserRole `json:"role"`
+	TenantID *string         `json:"tenant_id"`
+}
+
+// ListUsers returns a page of the users matching filter. Non-admin callers only see their
+// own tenant.
+func (s *AuthService) ListUsers(ctx context.Context, filter repository.UserFilter, page, pageSize int) ([]*model.UserResponse, int, error) {
+	if filter.TenantID == "" {
+		filter.TenantID, _ = repository.TenantScope(ctx)
+	}
+	if !repository.InTenantScope(ctx, filter.TenantID) {
+		return nil, 0, ErrTenantAccessDenied
+	}
+
+	users, total, err := s.userRepo.Search(ctx, filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	responses := make([]*model.UserResponse, len(users))
+	for i, user := range users {
+		response := user.ToResponse()
+		responses[i] = &response
+	}
+	return responses, total, nil
+}
+
+// GetUser retrieves a user; users outside the caller's tenant scope are not found
+func (s *AuthService) GetUser(ctx context.Context, id string) (*model.User, error) {
+	user, err := s.userRepo.GetByID(ctx, id)
+	if err != nil || !repository.InTenantScope(ctx, user.TenantID) {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// UpdateUser changes the role or tenant of a user. A user can only be moved to a tenant
+// within the caller's scope. Tokens carry the role and tenant, so changing either revokes the
+// user's sessions.
+func (s *AuthService) UpdateUser(ctx context.Context, id string, req *UpdateUserRequest) (*model.UserResponse, error) {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	role, tenantID := user.Role, user.TenantID
+
+	if req.Role != nil {
+		if *req.Role != model.RoleAdmin && *req.Role != model.RoleUser {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidUserRole, *req.Role)
+		}
+		user.Role = *req.Role
+	}
+	if req.TenantID != nil && *req.TenantID != user.TenantID {
+		if !repository.InTenantScope(ctx, *req.TenantID) {
+			return nil, ErrTenantAccessDenied
+		}
+		if _, err := s.tenantRepo.GetByID(ctx, *req.TenantID); err != nil {
+			return nil, ErrTenantNotFound
+		}
+		user.TenantID = *req.TenantID
+	}
+
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	if user.Role != role || user.TenantID != tenantID {
+		if err := s.sessionRepo.RevokeByUser(ctx, user.ID, RevokedUserChanged); err != nil {
+			return nil, err
+		}
+	}
+	response := user.ToResponse()
+	return &response, nil
+}
+
+// SetUserActive deactivates or reactivates a user. Deactivating a user revokes their sessions,
+// so they are signed out right away and cannot log in again.
+func (s *AuthService) SetUserActive(ctx context.Context, id string, active bool) (*model.UserResponse, error) {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	user.IsActive = active
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+	if !active {
+		if err := s.sessionRepo.RevokeByUser(ctx, user.ID, RevokedUserDeactivated); err != nil {
+			return nil, err
+		}
+	}
+	response := user.ToResponse()
+	return &response, nil
+}
+
+// DeleteUser deletes a user together with its role bindings, password history and sessions
+func (s *AuthService) DeleteUser(ctx context.Context, id string) error {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	bindings, err := s.roleBindingRepo.List(ctx, repository.RoleBindingFilter{UserID: user.ID})
+	if err != nil {
+		return err
+	}
+	for _, binding := range bindings {
+		if err := s.roleBindingRepo.Delete(ctx, binding.ID); err != nil {
+			return err
+		}
+	}
+	if err := s.userRepo.DeletePasswordHistory(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.DeleteByUser(ctx, user.ID); err != nil {
+		return err
+	}
+	return s.userRepo.Delete(ctx, user.ID)
+}
+
+// ResetPassword sets a new password for a user without knowing the current one. The user's
+// sessions are revoked and they have to change the password at their next login.
+func (s *AuthService) ResetPassword(ctx context.Context, id, newPassword string) error {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.setPassword(ctx, user, newPassword, true); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeByUser(ctx, user.ID, RevokedPasswordChanged)
+}
+
+// ChangePassword lets a user replace their own password after verifying the current one.
+// All sessions of the user are revoked and the caller gets the tokens of a new session, which
+// also lifts the restriction of tokens issued while a password change was required.
+func (s *AuthService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string, client ClientInfo) (*LoginResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)); err != nil {
+		return nil, ErrInvalidCurrentPassword
+	}
+	if err := s.setPassword(ctx, user, newPassword, false); err != nil {
+		return nil, err
+	}
+	if err := s.sessionRepo.RevokeByUser(ctx, user.ID, RevokedPasswordChanged); err != nil {
+		return nil, err
+	}
+	return s.startSession(ctx, user, client)
+}
+
+// setPassword applies the password policy, refuses recently used passwords and keeps the
+// replaced hash in the user's password history. mustChange requires the user to pick another
+// password at their next login.
+func (s *AuthService) setPassword(ctx context.Context, user *model.User, password string, mustChange bool) error {
+	if err := s.policy.Validate(password); err != nil {
+		return err
+	}
+
+	if s.policy.History > 0 {
+		recent := []string{user.PasswordHash}
+		previous, err := s.userRepo.ListPasswordHistory(ctx, user.ID, s.policy.History-1)
+		if err != nil {
+			return err
+		}
+		for _, hash := range append(recent, previous...) {
+			if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+				return ErrPasswordReused
+			}
+		}
+	}
+
+	passwordHash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if s.policy.History > 1 {
+		if err := s.userRepo.AddPasswordHistory(ctx, user.ID, user.PasswordHash, s.policy.History-1); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	user.PasswordHash = passwordHash
+	user.PasswordChangedAt = &now
+	user.MustChangePassword = mustChange
+	return s.userRepo.Update(ctx, user)
+}
@@ -0,0 +1,512 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weibh/openClusterClaw/internal/adapter"
+	"github.com/weibh/openClusterClaw/internal/domain"
+	"github.com/weibh/openClusterClaw/internal/model"
+	"github.com/weibh/openClusterClaw/internal/pkg/secretbox"
+	"github.com/weibh/openClusterClaw/internal/repository"
+)
+
+var (
+	ErrVariableNotFound = errors.New("variable not found")
+	ErrVariableExists   = errors.New("variable already exists at this scope")
+	ErrInvalidVariable  = errors.New("invalid variable")
+	ErrVariableInUse    = errors.New("variable is referenced by instances")
+)
+
+var (
+	// variableReference matches ${var.NAME} in template defaults and overrides
+	variableReference = regexp.MustCompile(`\$\{var\.([A-Za-z_][A-Za-z0-9_]*)\}`)
+	variableName      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// variableScopeRank orders scopes from the broadest to the most specific
+var variableScopeRank = map[model.VariableScope]int{
+	model.VariableScopeGlobal:   0,
+	model.VariableScopeTenant:   1,
+	model.VariableScopeProject:  2,
+	model.VariableScopeInstance: 3,
+}
+
+// VariableService manages the shared variables referenced from templates and overrides
+type VariableService interface {
+	CreateVariable(ctx context.Context, req *CreateVariableRequest) (*VariableInfo, error)
+	GetVariable(ctx context.Context, id string) (*VariableInfo, error)
+	ListVariables(ctx context.Context, scope model.VariableScope, scopeID string) ([]*VariableInfo, error)
+	UpdateVariable(ctx context.Context, id string, req *UpdateVariableRequest) (*VariableInfo, error)
+	DeleteVariable(ctx context.Context, id string) error
+}
+
+// CreateVariableRequest represents the request to define a shared variable
+type CreateVariableRequest struct {
+	Scope       model.VariableScope `json:"scope" binding:"required"`
+	ScopeID     string              `json:"scope_id"`
+	Name        string              `json:"name" binding:"required"`
+	Value       string              `json:"value"`
+	Secret      bool                `json:"secret"`
+	Description string              `json:"description"`
+	Author      string              `json:"-"`
+}
+
+// UpdateVariableRequest represents the request to change a shared variable; nil fields are kept
+type UpdateVariableRequest struct {
+	Value       *string `json:"value"`
+	Secret      *bool   `json:"secret"`
+	Description *string `json:"description"`
+	Author      string  `json:"-"`
+}
+
+// VariableInfo is the API view of a shared variable; secret values are redacted
+type VariableInfo struct {
+	ID          string              `json:"id"`
+	Scope       model.VariableScope `json:"scope"`
+	ScopeID     string              `json:"scope_id,omitempty"`
+	Name        string              `json:"name"`
+	Value       string              `json:"value"`
+	Secret      bool                `json:"secret"`
+	Description string              `json:"description"`
+	UpdatedBy   string              `json:"updated_by"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+	// Rerendered lists the instances re-rendered because of this change
+	Rerendered []*VariableRerender `json:"rerendered,omitempty"`
+}
+
+// VariableRerender reports the re-render of one instance after a shared variable changed
+type VariableRerender struct {
+	InstanceID  string              `json:"instance_id"`
+	Revision    int                 `json:"revision,omitempty"`
+	ConfigApply *domain.ConfigApply `json:"config_apply,omitempty"`
+	Error       string              `json:"error,omitempty"`
+}
+
+// variableService implements VariableService
+type variableService struct {
+	variableRepo repository.SharedVariableRepository
+	tenantRepo   repository.TenantRepository
+	projectRepo  repository.ProjectRepository
+	instanceRepo repository.InstanceRepository
+	instances    InstanceService
+	secrets      *secretbox.Box
+}
+
+// NewVariableService creates a new shared variable service
+func NewVariableService(variableRepo repository.SharedVariableRepository, tenantRepo repository.TenantRepository, projectRepo repository.ProjectRepository, instanceRepo repository.InstanceRepository, instances InstanceService, secrets *secretbox.Box) VariableService {
+	return &variableService{
+		variableRepo: variableRepo,
+		tenantRepo:   tenantRepo,
+		projectRepo:  projectRepo,
+		instanceRepo: instanceRepo,
+		instances:    instances,
+		secrets:      secrets,
+	}
+}
+
+func (s *variableService) CreateVariable(ctx context.Context, req *CreateVariableRequest) (*VariableInfo, error) {
+	if !variableName.MatchString(req.Name) {
+		return nil, fmt.Errorf("%w: name must start with a letter or underscore and contain only letters, digits and underscores", ErrInvalidVariable)
+	}
+	if err := s.checkScope(ctx, req.Scope, req.ScopeID); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.variableRepo.List(ctx, req.Scope, req.ScopeID)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range existing {
+		if v.Name == req.Name {
+			return nil, ErrVariableExists
+		}
+	}
+
+	variable := &model.SharedVariable{
+		Scope:       req.Scope,
+		ScopeID:     req.ScopeID,
+		Name:        req.Name,
+		Secret:      req.Secret,
+		Description: req.Description,
+		UpdatedBy:   req.Author,
+	}
+	if err := s.setValue(variable, req.Value); err != nil {
+		return nil, err
+	}
+	if err := s.variableRepo.Create(ctx, variable); err != nil {
+		return nil, err
+	}
+
+	// A new definition may shadow one at a broader scope
+	return s.rerender(ctx, variable, req.Author)
+}
+
+func (s *variableService) GetVariable(ctx context.Context, id string) (*VariableInfo, error) {
+	variable, err := s.variableRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrVariableNotFound
+	}
+	return variableInfo(variable), nil
+}
+
+func (s *variableService) ListVariables(ctx context.Context, scope model.VariableScope, scopeID string) ([]*VariableInfo, error) {
+	if _, ok := variableScopeRank[scope]; scope != "" && !ok {
+		return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidVariable, scope)
+	}
+	variables, err := s.variableRepo.List(ctx, scope, scopeID)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]*VariableInfo, len(variables))
+	for i, v := range variables {
+		infos[i] = variableInfo(v)
+	}
+	return infos, nil
+}
+
+func (s *variableService) UpdateVariable(ctx context.Context, id string, req *UpdateVariableRequest) (*VariableInfo, error) {
+	variable, err := s.variableRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, ErrVariableNotFound
+	}
+
+	if req.Secret != nil && *req.Secret != variable.Secret {
+		// Clearing the flag must not reveal the stored secret
+		if variable.Secret && req.Value == nil {
+			return nil, fmt.Errorf("%w: a new value is required when clearing the secret flag", ErrInvalidVariable)
+		}
+		if req.Value == nil {
+			value := variable.Value
+			req.Value = &value
+		}
+		variable.Secret = *req.Secret
+	}
+	if req.Value != nil {
+		if err := s.setValue(variable, *req.Value); err != nil {
+			return nil, err
+		}
+	}
+	if req.Description != nil {
+		variable.Description = *req.Description
+	}
+	variable.UpdatedBy = req.Author
+
+	if err := s.variableRepo.Update(ctx, variable); err != nil {
+		return nil, err
+	}
+	if req.Value == nil {
+		return variableInfo(variable), nil
+	}
+	return s.rerender(ctx, variable, req.Author)
+}
+
+// DeleteVariable removes a variable that no instance in its scope references
+func (s *variableService) DeleteVariable(ctx context.Context, id string) error {
+	variable, err := s.variableRepo.GetByID(ctx, id)
+	if err != nil {
+		return ErrVariableNotFound
+	}
+
+	users, err := s.instances.VariableUsers(ctx, variable.Scope, variable.ScopeID, variable.Name)
+	if err != nil {
+		return err
+	}
+	if len(users) > 0 {
+		return fmt.Errorf("%w: %s is used by %d instance(s)", ErrVariableInUse, variable.Name, len(users))
+	}
+	return s.variableRepo.Delete(ctx, id)
+}
+
+// checkScope verifies that the scope exists and the scope ID refers to an existing object
+func (s *variableService) checkScope(ctx context.Context, scope model.VariableScope, scopeID string) error {
+	var err error
+	switch scope {
+	case model.VariableScopeGlobal:
+		if scopeID != "" {
+			return fmt.Errorf("%w: global variables take no scope_id", ErrInvalidVariable)
+		}
+		return nil
+	case model.VariableScopeTenant:
+		_, err = s.tenantRepo.GetByID(ctx, scopeID)
+	case model.VariableScopeProject:
+		_, err = s.projectRepo.GetByID(ctx, scopeID)
+	case model.VariableScopeInstance:
+		_, err = s.instanceRepo.GetByID(ctx, scopeID)
+	default:
+		return fmt.Errorf("%w: unknown scope %q", ErrInvalidVariable, scope)
+	}
+	if scopeID == "" || err != nil {
+		return fmt.Errorf("%w: %s %q not found", ErrInvalidVariable, scope, scopeID)
+	}
+	return nil
+}
+
+// setValue stores value on the variable, encrypting it when the variable is secret
+func (s *variableService) setValue(variable *model.SharedVariable, value string) error {
+	if !variable.Secret {
+		variable.Value = value
+		return nil
+	}
+	if s.secrets == nil {
+		return fmt.Errorf("secret variables require an encryption key")
+	}
+	sealed, err := s.secrets.Seal(value)
+	if err != nil {
+		return err
+	}
+	variable.Value = sealed
+	return nil
+}
+
+// rerender applies a changed variable to the instances that reference it
+func (s *variableService) rerender(ctx context.Context, variable *model.SharedVariable, author string) (*VariableInfo, error) {
+	info := variableInfo(variable)
+	results, err := s.instances.RerenderVariableUsers(ctx, variable.Scope, variable.ScopeID, variable.Name, author)
+	if err != nil {
+		return nil, err
+	}
+	info.Rerendered = results
+	return info, nil
+}
+
+// variableInfo builds the API view of a variable
+func variableInfo(v *model.SharedVariable) *VariableInfo {
+	value := v.Value
+	if v.Secret {
+		value = redactedValue
+	}
+	return &VariableInfo{
+		ID:          v.ID,
+		Scope:       v.Scope,
+		ScopeID:     v.ScopeID,
+		Name:        v.Name,
+		Value:       value,
+		Secret:      v.Secret,
+		Description: v.Description,
+		UpdatedBy:   v.UpdatedBy,
+		CreatedAt:   v.CreatedAt,
+		UpdatedAt:   v.UpdatedAt,
+	}
+}
+
+// resolvedVariable is the effective value of a shared variable for one instance
+type resolvedVariable struct {
+	value  string
+	secret bool
+}
+
+// variableSet maps variable names to their effective values for one instance
+type variableSet map[string]resolvedVariable
+
+// loadVariables returns the variables visible to an instance, keeping the most specific definition of each name.
+// With mask set, secret values are replaced by a placeholder instead of being decrypted.
+func (s *instanceService) loadVariables(ctx context.Context, instance *model.ClawInstance, mask bool) (variableSet, error) {
+	if s.variableRepo == nil {
+		return nil, nil
+	}
+	variables, err := s.variableRepo.ListForInstance(ctx, instance.TenantID, instance.ProjectID, instance.ID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(variables, func(i, j int) bool {
+		return variableScopeRank[variables[i].Scope] < variableScopeRank[variables[j].Scope]
+	})
+
+	vars := make(variableSet, len(variables))
+	for _, v := range variables {
+		value := v.Value
+		if v.Secret {
+			switch {
+			case mask:
+				value = redactedValue
+			case s.secrets == nil:
+				return nil, fmt.Errorf("cannot decrypt secret variable %s: no encryption key configured", v.Name)
+			default:
+				if value, err = s.secrets.Open(v.Value); err != nil {
+					return nil, fmt.Errorf("failed to decrypt variable %s: %w", v.Name, err)
+				}
+			}
+		}
+		vars[v.Name] = resolvedVariable{value: value, secret: v.Secret}
+	}
+	return vars, nil
+}
+
+// expandVariables replaces the ${var.NAME} references in the value bound to path.
+// A path that references a secret variable becomes secret itself; undefined variables are field errors.
+// Values of variables are inserted literally and not expanded again.
+func (b *templateBinding) expandVariables(path, value string, vars variableSet) (string, bool) {
+	if !strings.Contains(value, "${var.") {
+		return value, true
+	}
+
+	var undefined []string
+	secret := false
+	expanded := variableReference.ReplaceAllStringFunc(value, func(ref string) string {
+		name := variableReference.FindStringSubmatch(ref)[1]
+		if b.variables == nil {
+			b.variables = make(map[string]bool)
+		}
+		b.variables[name] = true
+
+		v, ok := vars[name]
+		if !ok {
+			undefined = append(undefined, name)
+			return ref
+		}
+		secret = secret || v.secret
+		return v.value
+	})
+
+	if len(undefined) > 0 {
+		b.errors = append(b.errors, adapter.FieldError{Field: path, Message: "undefined variable " + strings.Join(undefined, ", ")})
+		return "", false
+	}
+	if secret && !containsString(b.secrets, path) {
+		b.secrets = append(b.secrets, path)
+	}
+	return expanded, true
+}
+
+// variableNames returns the referenced variables sorted by name
+func (b *templateBinding) variableNames() []string {
+	if len(b.variables) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(b.variables))
+	for name := range b.variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// variableScopeFilter selects the instances a variable defined at scope can affect
+func variableScopeFilter(scope model.VariableScope, scopeID string) repository.InstanceFilter {
+	switch scope {
+	case model.VariableScopeTenant:
+		return repository.InstanceFilter{TenantID: scopeID}
+	case model.VariableScopeProject:
+		return repository.InstanceFilter{ProjectID: scopeID}
+	case model.VariableScopeInstance:
+		return repository.InstanceFilter{IDs: []string{scopeID}}
+	default:
+		return repository.InstanceFilter{}
+	}
+}
+
+// variableUsers returns the instances in scope whose current config references the variable
+func (s *instanceService) variableUsers(ctx context.Context, scope model.VariableScope, scopeID, name string) ([]*model.ClawInstance, error) {
+	instances, err := s.instanceRepo.ListByFilter(ctx, variableScopeFilter(scope, scopeID))
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*model.ClawInstance
+	for _, instance := range instances {
+		record, err := decodeInstanceConfig(instance.Config)
+		if err != nil {
+			return nil, err
+		}
+		if containsString(record.Variables, name) {
+			users = append(users, instance)
+		}
+	}
+	return users, nil
+}
+
+// VariableUsers returns the IDs of the instances in scope that reference the variable
+func (s *instanceService) VariableUsers(ctx context.Context, scope model.VariableScope, scopeID, name string) ([]string, error) {
+	users, err := s.variableUsers(ctx, scope, scopeID, name)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(users))
+	for i, instance := range users {
+		ids[i] = instance.ID
+	}
+	return ids, nil
+}
+
+// RerenderVariableUsers re-renders the instances in scope that reference a changed variable.
+// Each instance keeps its template revision and overrides; instances whose output is unchanged are skipped.
+// A failing instance keeps its current config and is reported with the error.
+func (s *instanceService) RerenderVariableUsers(ctx context.Context, scope model.VariableScope, scopeID, name, author string) ([]*VariableRerender, error) {
+	users, err := s.variableUsers(ctx, scope, scopeID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*VariableRerender
+	for _, instance := range users {
+		result, err := s.rerenderInstance(ctx, instance, fmt.Sprintf("Variable %s changed", name), author)
+		if err != nil {
+			log.Printf("Warning: Failed to re-render instance %s after variable %s changed: %v", instance.ID, name, err)
+			result = &VariableRerender{InstanceID: instance.ID, Error: err.Error()}
+		}
+		if result != nil {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// rerenderInstance renders the current config of an instance again and applies it if the output changed
+func (s *instanceService) rerenderInstance(ctx context.Context, instance *model.ClawInstance, changelog, author string) (*VariableRerender, error) {
+	record, err := decodeInstanceConfig(instance.Config)
+	if err != nil {
+		return nil, err
+	}
+	config := &domain.InstanceConfig{
+		TemplateName:     record.TemplateName,
+		TemplateRevision: record.TemplateRevision,
+		Overrides:        record.Overrides,
+	}
+	rendered, err := s.generateInstanceConfig(ctx, instance, config)
+	if err != nil {
+		return nil, err
+	}
+	next := appliedFromRendered(rendered, config)
+
+	current, err := s.currentConfig(ctx, instance)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && sameAppliedConfig(current, next) {
+		return nil, nil
+	}
+
+	plan, err := s.applyConfig(ctx, instance, next, author, changelog, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &VariableRerender{InstanceID: instance.ID, Revision: instance.ConfigRevision, ConfigApply: plan}, nil
+}
+
+// sameAppliedConfig reports whether two applied configs produce the same values and output
+func sameAppliedConfig(a, b *appliedConfig) bool {
+	changes, err := diffValues(
+		map[string]interface{}{"values": a.record.Values, "resolved": a.record.Resolved, "env": a.env},
+		map[string]interface{}{"values": b.record.Values, "resolved": b.record.Resolved, "env": b.env},
+	)
+	return err == nil && len(changes) == 0 && filesHash(a.files) == filesHash(b.files)
+}